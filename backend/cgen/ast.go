@@ -0,0 +1,536 @@
+package cgen
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// === [ Expressions ] =========================================================
+
+// An Expr is a C expression.
+type Expr interface {
+	// prec returns the precedence of the expression; higher values bind
+	// tighter.
+	prec() int
+	// String returns the C source code of the expression.
+	String() string
+}
+
+// Precedence levels of C expressions.
+const (
+	precComma = iota + 1
+	precAssign
+	precCond
+	precOrOr
+	precAndAnd
+	precOr
+	precXor
+	precAnd
+	precEq
+	precRel
+	precShift
+	precAdd
+	precMul
+	precUnary
+	precPostfix
+	precPrimary
+)
+
+// binaryPrec maps from binary operator to precedence.
+var binaryPrec = map[string]int{
+	"=":  precAssign,
+	"||": precOrOr,
+	"&&": precAndAnd,
+	"|":  precOr,
+	"^":  precXor,
+	"&":  precAnd,
+	"==": precEq,
+	"!=": precEq,
+	"<":  precRel,
+	"<=": precRel,
+	">":  precRel,
+	">=": precRel,
+	"<<": precShift,
+	">>": precShift,
+	"+":  precAdd,
+	"-":  precAdd,
+	"*":  precMul,
+	"/":  precMul,
+	"%":  precMul,
+}
+
+// An Ident is a C identifier or literal.
+//
+//    x
+//    42
+type Ident struct {
+	Name string
+}
+
+// A UnaryExpr is a C unary expression.
+//
+//    -x
+//    *p
+type UnaryExpr struct {
+	Op string
+	X  Expr
+}
+
+// A BinaryExpr is a C binary expression.
+//
+//    x + y
+type BinaryExpr struct {
+	X  Expr
+	Op string
+	Y  Expr
+}
+
+// A CastExpr is a C cast expression.
+//
+//    (int64_t)x
+type CastExpr struct {
+	Type string
+	X    Expr
+}
+
+// A CallExpr is a C function call expression.
+//
+//    f(x, y)
+type CallExpr struct {
+	Fun  Expr
+	Args []Expr
+}
+
+// An IndexExpr is a C array subscript expression.
+//
+//    a[i]
+type IndexExpr struct {
+	X     Expr
+	Index Expr
+}
+
+// A MemberExpr is a C struct member access expression.
+//
+//    s.field_0
+//    p->field_0
+type MemberExpr struct {
+	X     Expr
+	Name  string
+	Arrow bool
+}
+
+// A CondExpr is a C conditional expression.
+//
+//    c ? x : y
+type CondExpr struct {
+	Cond Expr
+	X    Expr
+	Y    Expr
+}
+
+// A CompositeLit is a C initializer list, or a compound literal if Type is
+// set.
+//
+//    {1, 2}
+//    (int32_t[2]){1, 2}
+type CompositeLit struct {
+	Type string
+	Elts []Expr
+}
+
+func (*Ident) prec() int        { return precPrimary }
+func (*UnaryExpr) prec() int    { return precUnary }
+func (e *BinaryExpr) prec() int { return binaryPrec[e.Op] }
+func (*CastExpr) prec() int     { return precUnary }
+func (*CallExpr) prec() int     { return precPostfix }
+func (*IndexExpr) prec() int    { return precPostfix }
+func (*MemberExpr) prec() int   { return precPostfix }
+func (*CondExpr) prec() int     { return precCond }
+func (e *CompositeLit) prec() int {
+	if len(e.Type) > 0 {
+		return precPostfix
+	}
+	return precPrimary
+}
+
+// paren returns the C source code of the given expression, parenthesized if
+// its precedence is lower than prec.
+func paren(x Expr, prec int) string {
+	if x.prec() < prec {
+		return "(" + x.String() + ")"
+	}
+	return x.String()
+}
+
+func (e *Ident) String() string {
+	return e.Name
+}
+
+func (e *UnaryExpr) String() string {
+	x := paren(e.X, precUnary)
+	// Separate repeated sign operators (e.g. "- -x").
+	if (e.Op == "-" || e.Op == "+") && strings.HasPrefix(x, e.Op) {
+		return e.Op + " " + x
+	}
+	return e.Op + x
+}
+
+func (e *BinaryExpr) String() string {
+	prec := e.prec()
+	// Binary operators are left-associative, except for assignment.
+	lprec, rprec := prec, prec+1
+	if e.Op == "=" {
+		lprec, rprec = prec+1, prec
+	}
+	return fmt.Sprintf("%s %s %s", e.operand(e.X, lprec), e.Op, e.operand(e.Y, rprec))
+}
+
+// operand returns the C source code of the given operand of the binary
+// expression, parenthesized if its precedence is lower than prec. Binary
+// operands of bitwise and shift operators are parenthesized for readability
+// (e.g. "(x + y) << 2"), unless of the same operator.
+func (e *BinaryExpr) operand(x Expr, prec int) string {
+	if b, ok := x.(*BinaryExpr); ok && isBitwise(e.Op) && (b.Op != e.Op || x == e.Y) {
+		return "(" + x.String() + ")"
+	}
+	return paren(x, prec)
+}
+
+// isBitwise reports whether the given binary operator is a bitwise or shift
+// operator.
+func isBitwise(op string) bool {
+	switch op {
+	case "&", "|", "^", "<<", ">>":
+		return true
+	}
+	return false
+}
+
+func (e *CastExpr) String() string {
+	return fmt.Sprintf("(%s)%s", e.Type, paren(e.X, precUnary))
+}
+
+func (e *CallExpr) String() string {
+	var args []string
+	for _, arg := range e.Args {
+		args = append(args, paren(arg, precAssign))
+	}
+	return fmt.Sprintf("%s(%s)", paren(e.Fun, precPostfix), strings.Join(args, ", "))
+}
+
+func (e *IndexExpr) String() string {
+	return fmt.Sprintf("%s[%s]", paren(e.X, precPostfix), e.Index)
+}
+
+func (e *MemberExpr) String() string {
+	if e.Arrow {
+		return fmt.Sprintf("%s->%s", paren(e.X, precPostfix), e.Name)
+	}
+	return fmt.Sprintf("%s.%s", paren(e.X, precPostfix), e.Name)
+}
+
+func (e *CondExpr) String() string {
+	return fmt.Sprintf("%s ? %s : %s", paren(e.Cond, precCond+1), paren(e.X, precAssign), paren(e.Y, precCond))
+}
+
+func (e *CompositeLit) String() string {
+	var elts []string
+	for _, elt := range e.Elts {
+		elts = append(elts, paren(elt, precAssign))
+	}
+	lit := "{" + strings.Join(elts, ", ") + "}"
+	if len(e.Type) > 0 {
+		return fmt.Sprintf("(%s)%s", e.Type, lit)
+	}
+	return lit
+}
+
+// === [ Statements ] ==========================================================
+
+// A Stmt is a C statement.
+type Stmt interface {
+	// print prints the statement to p.
+	print(p *printer)
+}
+
+// An ExprStmt is a C expression statement.
+//
+//    f(x);
+type ExprStmt struct {
+	X Expr
+}
+
+// A DeclStmt is a C declaration of a local variable, with an optional
+// initializer.
+//
+//    int32_t x = 0;
+type DeclStmt struct {
+	// Declaration of the variable, excluding initializer (e.g. "int32_t x").
+	Decl string
+	// Initializer; or nil.
+	Init Expr
+}
+
+// A BlockStmt is a C compound statement.
+//
+//    { ... }
+type BlockStmt struct {
+	List []Stmt
+}
+
+// An IfStmt is a C if-statement, with an optional else branch.
+//
+//    if (c) { ... } else { ... }
+type IfStmt struct {
+	Cond Expr
+	Body *BlockStmt
+	// Else branch; either *BlockStmt, *IfStmt or nil.
+	Else Stmt
+}
+
+// A WhileStmt is a C while-statement.
+//
+//    while (c) { ... }
+type WhileStmt struct {
+	Cond Expr
+	Body *BlockStmt
+}
+
+// A DoWhileStmt is a C do-while-statement.
+//
+//    do { ... } while (c);
+type DoWhileStmt struct {
+	Body *BlockStmt
+	Cond Expr
+}
+
+// A SwitchStmt is a C switch-statement.
+//
+//    switch (x) { case 1: ... default: ... }
+type SwitchStmt struct {
+	Tag   Expr
+	Cases []*CaseClause
+}
+
+// A CaseClause is a case of a C switch-statement.
+//
+//    case 1:
+//    default:
+type CaseClause struct {
+	// Case value; or nil for the default case.
+	X    Expr
+	Body []Stmt
+}
+
+// A ReturnStmt is a C return-statement.
+//
+//    return x;
+type ReturnStmt struct {
+	// Return value; or nil.
+	X Expr
+}
+
+// A GotoStmt is a C goto-statement.
+//
+//    goto block_1;
+type GotoStmt struct {
+	Label string
+}
+
+// A BreakStmt is a C break-statement.
+//
+//    break;
+type BreakStmt struct{}
+
+// A LabeledStmt is a labeled C statement.
+//
+//    block_1:
+//    x = 1;
+type LabeledStmt struct {
+	Label string
+	Stmt  Stmt
+}
+
+// A CommentStmt is a C comment on a line of its own.
+//
+//    // unreachable
+type CommentStmt struct {
+	Text string
+}
+
+// === [ Declarations ] ========================================================
+
+// A File is a C source file.
+type File struct {
+	// Included system headers (e.g. "stdio.h").
+	Includes []string
+	// Type declarations (e.g. struct definitions).
+	Types []string
+	// Global variable declarations and function prototypes.
+	Decls []string
+	// Function definitions.
+	Funcs []*FuncDecl
+}
+
+// A FuncDecl is a C function definition.
+type FuncDecl struct {
+	// Function signature (e.g. "int32_t f(int32_t x)").
+	Sig  string
+	Body *BlockStmt
+}
+
+// === [ Printer ] =============================================================
+
+// A printer prints C source code.
+type printer struct {
+	w      io.Writer
+	indent int
+	err    error
+}
+
+// printf prints the given formatted line, indented by the current indentation
+// level.
+func (p *printer) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	line := fmt.Sprintf(format, args...)
+	if len(line) > 0 {
+		line = strings.Repeat("\t", p.indent) + line
+	}
+	_, p.err = fmt.Fprintln(p.w, line)
+}
+
+// Print prints the C source code of the file to w.
+func (f *File) Print(w io.Writer) error {
+	p := &printer{w: w}
+	for _, include := range f.Includes {
+		p.printf("#include <%s>", include)
+	}
+	for _, sec := range [][]string{f.Types, f.Decls} {
+		if len(sec) == 0 {
+			continue
+		}
+		p.printf("")
+		for _, decl := range sec {
+			p.printf("%s", decl)
+		}
+	}
+	for _, fn := range f.Funcs {
+		p.printf("")
+		p.printf("%s {", fn.Sig)
+		p.indent++
+		p.stmts(fn.Body.List)
+		p.indent--
+		p.printf("}")
+	}
+	return p.err
+}
+
+// stmts prints the given statements.
+func (p *printer) stmts(stmts []Stmt) {
+	for _, stmt := range stmts {
+		stmt.print(p)
+	}
+}
+
+// block prints the statements of the given block, with an increased
+// indentation level, followed by the closing line.
+func (p *printer) block(block *BlockStmt, closing string) {
+	p.indent++
+	p.stmts(block.List)
+	p.indent--
+	p.printf("%s", closing)
+}
+
+func (s *ExprStmt) print(p *printer) {
+	p.printf("%s;", s.X)
+}
+
+func (s *DeclStmt) print(p *printer) {
+	if s.Init == nil {
+		p.printf("%s;", s.Decl)
+		return
+	}
+	p.printf("%s = %s;", s.Decl, paren(s.Init, precAssign))
+}
+
+func (s *BlockStmt) print(p *printer) {
+	p.printf("{")
+	p.block(s, "}")
+}
+
+func (s *IfStmt) print(p *printer) {
+	p.printf("if (%s) {", s.Cond)
+	s.printBody(p)
+}
+
+// printBody prints the body and else branch of the if-statement.
+func (s *IfStmt) printBody(p *printer) {
+	switch e := s.Else.(type) {
+	case nil:
+		p.block(s.Body, "}")
+	case *IfStmt:
+		p.block(s.Body, fmt.Sprintf("} else if (%s) {", e.Cond))
+		e.printBody(p)
+	case *BlockStmt:
+		p.block(s.Body, "} else {")
+		p.block(e, "}")
+	default:
+		panic(fmt.Errorf("support for else branch %T not yet implemented", e))
+	}
+}
+
+func (s *WhileStmt) print(p *printer) {
+	p.printf("while (%s) {", s.Cond)
+	p.block(s.Body, "}")
+}
+
+func (s *DoWhileStmt) print(p *printer) {
+	p.printf("do {")
+	p.block(s.Body, fmt.Sprintf("} while (%s);", s.Cond))
+}
+
+func (s *SwitchStmt) print(p *printer) {
+	p.printf("switch (%s) {", s.Tag)
+	for _, c := range s.Cases {
+		if c.X == nil {
+			p.printf("default:")
+		} else {
+			p.printf("case %s:", c.X)
+		}
+		p.indent++
+		p.stmts(c.Body)
+		p.indent--
+	}
+	p.printf("}")
+}
+
+func (s *ReturnStmt) print(p *printer) {
+	if s.X == nil {
+		p.printf("return;")
+		return
+	}
+	p.printf("return %s;", s.X)
+}
+
+func (s *GotoStmt) print(p *printer) {
+	p.printf("goto %s;", s.Label)
+}
+
+func (s *BreakStmt) print(p *printer) {
+	p.printf("break;")
+}
+
+func (s *LabeledStmt) print(p *printer) {
+	// Labels are outdented by one level.
+	p.indent--
+	p.printf("%s:", s.Label)
+	p.indent++
+	s.Stmt.print(p)
+}
+
+func (s *CommentStmt) print(p *printer) {
+	p.printf("// %s", s.Text)
+}
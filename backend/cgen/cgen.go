@@ -0,0 +1,164 @@
+// Package cgen implements a C back-end of the decompiler, which translates LLVM
+// IR modules into C99 source code.
+//
+// The control flow of each function is recovered by control flow analysis
+// (see cfa.Restructure), or from user-supplied control flow primitives, and
+// lowered to C if-statements and loops; with goto-statements as a fallback for
+// unstructured control flow. Struct types are recovered as C struct
+// definitions.
+//
+//    d := &cgen.Decompiler{}
+//    f, err := d.Decompile(module)
+//    err = f.Print(os.Stdout)
+package cgen
+
+import (
+	"log"
+
+	"github.com/decomp/decomp/cfa"
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/llir/llvm/ir"
+	"github.com/pkg/errors"
+)
+
+// A Decompiler decompiles LLVM IR modules into C source code. The zero value is
+// a decompiler which recovers control flow primitives by control flow
+// analysis.
+type Decompiler struct {
+	// Functions of the LLVM IR module to decompile; or all functions if nil.
+	Funcs []*ir.Func
+	// Prims returns the control flow primitives of the given function; or nil
+	// to recover the control flow primitives by control flow analysis (see
+	// cfa.RestructureFunc). Incomplete control flow recovery (see
+	// cfa.ErrIncomplete) is not an error, as unstructured control flow is
+	// decompiled into goto-statements.
+	Prims func(f *ir.Func) ([]*primitive.Primitive, error)
+	// Logger of debug messages; or no logging if nil.
+	Logger *log.Logger
+}
+
+// Decompile decompiles the given LLVM IR module into a C source file.
+func (dec *Decompiler) Decompile(module *ir.Module) (*File, error) {
+	funcs := dec.Funcs
+	if funcs == nil {
+		funcs = module.Funcs
+	}
+	d := newDecompiler()
+	file := &File{}
+	for _, f := range module.Funcs {
+		if _, ok := libcHeader(f); ok {
+			d.libcFuncs[f.Name()] = true
+		}
+	}
+
+	// Report types, instructions and constants without C representation as
+	// errors, before any declarations are recovered.
+	for _, t := range module.TypeDefs {
+		if err := checkType(t); err != nil {
+			return nil, errors.Wrapf(err, "unable to decompile type %q", t.Name())
+		}
+	}
+	for _, g := range module.Globals {
+		if err := checkType(g.ContentType); err != nil {
+			return nil, errors.Wrapf(err, "unable to decompile global variable %q", g.Ident())
+		}
+		if g.Init != nil {
+			if err := checkConst(g.Init); err != nil {
+				return nil, errors.Wrapf(err, "unable to decompile global variable %q", g.Ident())
+			}
+		}
+	}
+	for _, f := range funcs {
+		if _, ok := intrinsic(f.Name()); ok {
+			continue
+		}
+		if err := checkFunc(f); err != nil {
+			return nil, errors.Wrapf(err, "unable to decompile function %q", f.Ident())
+		}
+	}
+
+	// Recover function prototypes, omitting declarations of C standard library
+	// functions and lowered LLVM intrinsic functions.
+	var protos []string
+	for _, f := range funcs {
+		if header, ok := libcHeader(f); ok {
+			d.include(header)
+			continue
+		}
+		if _, ok := intrinsic(f.Name()); ok {
+			continue
+		}
+		if f.Name() == "main" {
+			continue
+		}
+		protos = append(protos, d.funcSig(f, false)+";")
+	}
+
+	// Recover function definitions.
+	for _, f := range funcs {
+		if len(f.Blocks) == 0 {
+			continue
+		}
+		prims, err := dec.prims(f)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		dec.logf("decompiling function %q.", f.Ident())
+		fn, err := d.funcDecl(f, prims)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decompile function %q", f.Ident())
+		}
+		file.Funcs = append(file.Funcs, fn)
+	}
+
+	// Recover global variables, omitting string literals inlined at their uses.
+	// Function prototypes precede global variables, as referred to by their
+	// initializers.
+	file.Decls = protos
+	globals := make([]string, len(module.Globals))
+	for i, g := range module.Globals {
+		if _, _, ok := stringGlobal(g); !ok {
+			globals[i] = d.globalDecl(g)
+		}
+	}
+	for i, g := range module.Globals {
+		if _, _, ok := stringGlobal(g); ok && d.usedGlobals[g.Name()] {
+			globals[i] = d.globalDecl(g)
+		}
+		if len(globals[i]) > 0 {
+			file.Decls = append(file.Decls, globals[i])
+		}
+	}
+
+	// Recover type definitions, including the literal struct types used by
+	// globals and functions.
+	file.Types = d.typeDefs(module.TypeDefs)
+	file.Includes = d.sortedIncludes()
+	return file, nil
+}
+
+// prims returns the control flow primitives of the given function.
+func (dec *Decompiler) prims(f *ir.Func) ([]*primitive.Primitive, error) {
+	var prims []*primitive.Primitive
+	var err error
+	if dec.Prims != nil {
+		prims, err = dec.Prims(f)
+	}
+	if prims == nil && err == nil {
+		prims, err = cfa.RestructureFunc(f)
+	}
+	if err != nil {
+		if errors.Cause(err) != cfa.ErrIncomplete {
+			return nil, errors.WithStack(err)
+		}
+		dec.logf("WARNING: incomplete control flow recovery of %q", f.Ident())
+	}
+	return prims, nil
+}
+
+// logf logs the given debug message, if logging is enabled.
+func (dec *Decompiler) logf(format string, args ...interface{}) {
+	if dec.Logger != nil {
+		dec.Logger.Printf(format, args...)
+	}
+}
@@ -0,0 +1,493 @@
+package cgen
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/pkg/errors"
+)
+
+func TestDecompile(t *testing.T) {
+	golden := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "if",
+			src: `
+define i32 @f(i32 %x) {
+entry:
+	%c = icmp slt i32 %x, 0
+	br i1 %c, label %exit, label %pos
+
+pos:
+	%y = udiv i32 %x, 2
+	br label %exit
+
+exit:
+	%z = phi i32 [ %y, %pos ], [ %x, %entry ]
+	ret i32 %z
+}
+`,
+			want: `#include <stdbool.h>
+#include <stdint.h>
+
+int32_t f(int32_t);
+
+int32_t f(int32_t x) {
+	bool c;
+	int32_t y;
+	int32_t z;
+	c = x < 0;
+	z = x;
+	if (!c) {
+		y = (uint32_t)x / (uint32_t)2;
+		z = y;
+	}
+	return z;
+}
+`,
+		},
+		{
+			name: "pre_loop",
+			src: `
+@.str = private unnamed_addr constant [4 x i8] c"%d\0A\00"
+
+declare i32 @printf(i8*, ...)
+
+define void @f(i32* %p) {
+entry:
+	br label %cond
+
+cond:
+	%x = load i32, i32* %p
+	%c = icmp ne i32 %x, 0
+	br i1 %c, label %body, label %exit
+
+body:
+	%call = call i32 (i8*, ...) @printf(i8* getelementptr ([4 x i8], [4 x i8]* @.str, i64 0, i64 0), i32 %x)
+	%q = getelementptr i32, i32* %p, i64 1
+	store i32 %x, i32* %q
+	br label %cond
+
+exit:
+	ret void
+}
+`,
+			want: `#include <stdbool.h>
+#include <stdint.h>
+#include <stdio.h>
+
+void f(int32_t *);
+
+void f(int32_t *p) {
+	int32_t x;
+	bool c;
+	int32_t *q;
+	while (true) {
+		x = *p;
+		c = x != 0;
+		if (!c) {
+			break;
+		}
+		printf("%d\n", x);
+		q = &p[1];
+		*q = x;
+	}
+}
+`,
+		},
+		{
+			name: "swap",
+			src: `
+define i32 @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%x = phi i32 [ 1, %entry ], [ %y, %body ]
+	%y = phi i32 [ 2, %entry ], [ %x, %body ]
+	%i = phi i32 [ 0, %entry ], [ %i1, %body ]
+	br label %body
+
+body:
+	%i1 = add i32 %i, 1
+	%c = icmp slt i32 %i1, %n
+	br i1 %c, label %loop, label %exit
+
+exit:
+	%r = mul i32 %x, 10
+	%s = add i32 %r, %i
+	ret i32 %s
+}
+`,
+			want: `#include <stdbool.h>
+#include <stdint.h>
+
+int32_t f(int32_t);
+
+int32_t f(int32_t n) {
+	int32_t x;
+	int32_t y;
+	int32_t i;
+	int32_t i1;
+	bool c;
+	int32_t r;
+	int32_t s;
+	int32_t x_phi;
+	int32_t i_phi;
+	x_phi = 1;
+	y = 2;
+	i_phi = 0;
+	do {
+		x = x_phi;
+		i = i_phi;
+		i1 = i + 1;
+		c = i1 < n;
+		x_phi = y;
+		y = x;
+		i_phi = i1;
+	} while (c);
+	r = x * 10;
+	s = r + i;
+	return s;
+}
+`,
+		},
+		{
+			name: "struct",
+			src: `
+%point = type { i32, { i8, double } }
+
+@origin = global %point zeroinitializer
+@name = internal constant [4 x i8] c"abc\00"
+
+define i8* @f(%point* %p) {
+	%x = getelementptr %point, %point* %p, i32 0, i32 1, i32 0
+	store i8 1, i8* %x
+	%y = getelementptr %point, %point* @origin, i32 0, i32 0
+	store i32 -2147483648, i32* %y
+	ret i8* getelementptr ([4 x i8], [4 x i8]* @name, i64 0, i64 1)
+}
+`,
+			want: `#include <stdint.h>
+
+struct point;
+struct struct_0 {
+	char field_0;
+	double field_1;
+};
+struct point {
+	int32_t field_0;
+	struct struct_0 field_1;
+};
+
+char *f(struct point *);
+struct point origin = {0};
+static const char name[4] = "abc";
+
+char *f(struct point *p) {
+	char *x;
+	int32_t *y;
+	x = &p->field_1.field_0;
+	*x = 1;
+	y = &origin.field_0;
+	*y = -2147483647 - 1;
+	return &name[1];
+}
+`,
+		},
+		// Switch terminators are decompiled into switch-statements of
+		// goto-statements.
+		{
+			name: "switch",
+			src: `
+define i32 @f(i32 %x) {
+entry:
+	switch i32 %x, label %default [
+		i32 1, label %one
+		i32 2, label %two
+	]
+
+one:
+	br label %exit
+
+two:
+	br label %exit
+
+default:
+	br label %exit
+
+exit:
+	%r = phi i32 [ 10, %one ], [ 20, %two ], [ 0, %default ]
+	ret i32 %r
+}
+`,
+			want: `#include <stdint.h>
+
+int32_t f(int32_t);
+
+int32_t f(int32_t x) {
+	int32_t r;
+	switch (x) {
+	case 1:
+		goto block_one;
+	case 2:
+		goto block_two;
+	default:
+		goto block_default;
+	}
+block_one:
+	r = 10;
+	goto block_exit;
+block_two:
+	r = 20;
+	goto block_exit;
+block_default:
+	r = 0;
+	goto block_exit;
+block_exit:
+	return r;
+}
+`,
+		},
+		{
+			name: "casts",
+			src: `
+define i64 @f(i32 %x, i8 %c, double %d, i8* %p) {
+	%a = sext i32 %x to i64
+	%b = zext i8 %c to i32
+	%t = trunc i32 %x to i8
+	%u = trunc i32 %x to i1
+	%f = sitofp i32 %x to double
+	%g = fptosi double %d to i32
+	%h = uitofp i8 %c to float
+	%i = fptoui double %d to i8
+	%j = fptrunc double %d to float
+	%k = fpext float %j to double
+	%l = ptrtoint i8* %p to i64
+	%m = inttoptr i64 %l to i32*
+	%n = bitcast i8* %p to i64*
+	%o = load i64, i64* %n
+	ret i64 %o
+}
+`,
+			want: `#include <stdbool.h>
+#include <stdint.h>
+
+int64_t f(int32_t, char, double, char *);
+
+int64_t f(int32_t x, char c, double d, char *p) {
+	int64_t a;
+	int32_t b;
+	char t;
+	bool u;
+	double f;
+	int32_t g;
+	float h;
+	char i;
+	float j;
+	double k;
+	int64_t l;
+	int32_t *m;
+	int64_t *n;
+	int64_t o;
+	a = (int64_t)x;
+	b = (int32_t)(uint8_t)c;
+	t = (char)x;
+	u = (bool)(x & 1);
+	f = (double)x;
+	g = (int32_t)d;
+	h = (float)(uint8_t)c;
+	i = (char)(uint8_t)d;
+	j = (float)d;
+	k = (double)j;
+	l = (int64_t)p;
+	m = (int32_t *)l;
+	n = (int64_t *)p;
+	o = *n;
+	return o;
+}
+`,
+		},
+		{
+			name: "libc_collision",
+			src: `
+@nan = global double 0.0
+
+declare double @sqrt(double)
+
+define double @round(double %x) {
+	%y = call double @sqrt(double %x)
+	store double %y, double* @nan
+	ret double %y
+}
+`,
+			want: `#include <math.h>
+
+double _round(double);
+double _nan = 0.0;
+
+double _round(double x) {
+	double y;
+	y = sqrt(x);
+	_nan = y;
+	return y;
+}
+`,
+		},
+	}
+	for _, g := range golden {
+		got, err := decompile(g.name, g.src)
+		if err != nil {
+			t.Errorf("%s: %+v", g.name, err)
+			continue
+		}
+		if got != g.want {
+			t.Errorf("%s: C source code mismatch; expected\n%s\ngot\n%s", g.name, g.want, got)
+		}
+	}
+}
+
+func TestDecompileError(t *testing.T) {
+	golden := []struct {
+		name string
+		src  string
+		// Substring of the expected error.
+		err string
+	}{
+		{
+			name: "vector_param",
+			src: `
+define i32 @f(<4 x i32> %v) {
+	%x = extractelement <4 x i32> %v, i32 0
+	ret i32 %x
+}
+`,
+			err: `unable to decompile function "@f": support for type ` + "`<4 x i32>`" + ` not yet implemented`,
+		},
+		{
+			name: "vector_inst",
+			src: `
+define i32 @f(i8* %q) {
+	%p = bitcast i8* %q to <4 x i32>*
+	%v = load <4 x i32>, <4 x i32>* %p
+	%x = extractelement <4 x i32> %v, i32 0
+	ret i32 %x
+}
+`,
+			err: "unable to decompile function \"@f\": invalid type of instruction `%p = bitcast i8* %q to <4 x i32>*`",
+		},
+		{
+			name: "vector_global",
+			src: `
+@v = global <2 x float> zeroinitializer
+`,
+			err: `unable to decompile global variable "@v"`,
+		},
+		{
+			name: "vector_type_def",
+			src: `
+%T = type { i32, <2 x float> }
+`,
+			err: `unable to decompile type "T"`,
+		},
+		{
+			name: "int256",
+			src: `
+define i256 @f(i256 %x) {
+	ret i256 %x
+}
+`,
+			err: "support for integer type with bit size 256 not yet implemented",
+		},
+		{
+			name: "cmpxchg",
+			src: `
+define i32 @f(i32* %p, i32 %old, i32 %new) {
+	%pair = cmpxchg i32* %p, i32 %old, i32 %new seq_cst seq_cst
+	%x = extractvalue { i32, i1 } %pair, 0
+	ret i32 %x
+}
+`,
+			err: "unable to decompile function \"@f\": invalid instruction `%pair = cmpxchg i32* %p, i32 %old, i32 %new seq_cst seq_cst`: support for instruction *ir.InstCmpXchg not yet implemented",
+		},
+		{
+			name: "atomicrmw",
+			src: `
+define i32 @f(i32* %p) {
+	%x = atomicrmw add i32* %p, i32 1 seq_cst
+	ret i32 %x
+}
+`,
+			err: "support for instruction *ir.InstAtomicRMW not yet implemented",
+		},
+		{
+			name: "va_arg",
+			src: `
+define i32 @f(i8* %ap) {
+	%x = va_arg i8* %ap, i32
+	ret i32 %x
+}
+`,
+			err: "support for instruction *ir.InstVAArg not yet implemented",
+		},
+		{
+			name: "invoke",
+			src: `
+declare void @g()
+
+define void @f() personality i32 (...)* @h {
+	invoke void @g()
+		to label %ok unwind label %lpad
+ok:
+	ret void
+lpad:
+	%x = landingpad { i8*, i32 } cleanup
+	resume { i8*, i32 } %x
+}
+
+declare i32 @h(...)
+`,
+			err: "support for terminator *ir.TermInvoke not yet implemented",
+		},
+		{
+			name: "vector_const_expr",
+			src: `
+@x = global i32 extractelement (<2 x i32> <i32 1, i32 2>, i32 0)
+`,
+			err: `unable to decompile global variable "@x"`,
+		},
+	}
+	for _, g := range golden {
+		_, err := decompile(g.name, g.src)
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", g.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), g.err) {
+			t.Errorf("%s: error mismatch; expected %q, got %q", g.name, g.err, err)
+		}
+	}
+}
+
+// decompile decompiles the given LLVM IR assembly into C source code.
+func decompile(name, src string) (string, error) {
+	module, err := asm.ParseString(name+".ll", src)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to parse LLVM IR")
+	}
+	d := &Decompiler{}
+	f, err := d.Decompile(module)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to decompile LLVM IR")
+	}
+	buf := &bytes.Buffer{}
+	if err := f.Print(buf); err != nil {
+		return "", errors.Wrap(err, "unable to print C source code")
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,228 @@
+package cgen
+
+import (
+	"math/big"
+
+	"github.com/decomp/decomp/internal/lower"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// checkType returns an error if the given LLVM IR type, or a type it refers to,
+// has no C representation (e.g. vector types).
+func checkType(t irtypes.Type) error {
+	return checkTypes(t, make(map[irtypes.Type]bool))
+}
+
+// checkFunc returns an error if the signature of the given LLVM IR function, or
+// one of its instructions, terminators or constant operands, has no C
+// representation (e.g. cmpxchg instructions).
+func checkFunc(f *ir.Func) error {
+	if err := checkType(f.Sig); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if v, ok := inst.(value.Value); ok {
+				if err := checkType(v.Type()); err != nil {
+					return errors.Wrapf(err, "invalid type of instruction `%v`", inst.LLString())
+				}
+			}
+			if err := checkInst(inst); err != nil {
+				return errors.Wrapf(err, "invalid instruction `%v`", inst.LLString())
+			}
+		}
+		if err := checkTerm(block.Term); err != nil {
+			return errors.Wrapf(err, "invalid terminator `%v`", block.Term.LLString())
+		}
+	}
+	return nil
+}
+
+// checkInst returns an error if the given LLVM IR instruction or one of its
+// constant operands has no C representation.
+func checkInst(inst ir.Instruction) error {
+	ops := lower.InstOperands(inst)
+	switch inst := inst.(type) {
+	case *ir.InstPhi:
+		for _, inc := range inst.Incs {
+			ops = append(ops, inc.X)
+		}
+	case *ir.InstAlloca:
+		if inst.NElems != nil {
+			if _, ok := inst.NElems.(*constant.Int); !ok {
+				return errors.New("support for alloca with non-constant number of elements not yet implemented")
+			}
+		}
+	case *ir.InstFNeg,
+		*ir.InstAdd, *ir.InstFAdd, *ir.InstSub, *ir.InstFSub, *ir.InstMul, *ir.InstFMul,
+		*ir.InstUDiv, *ir.InstSDiv, *ir.InstFDiv, *ir.InstURem, *ir.InstSRem, *ir.InstFRem,
+		*ir.InstShl, *ir.InstLShr, *ir.InstAShr, *ir.InstAnd, *ir.InstOr, *ir.InstXor,
+		*ir.InstExtractValue, *ir.InstInsertValue,
+		*ir.InstLoad, *ir.InstStore, *ir.InstFence, *ir.InstGetElementPtr,
+		*ir.InstTrunc, *ir.InstZExt, *ir.InstSExt, *ir.InstFPTrunc, *ir.InstFPExt,
+		*ir.InstFPToUI, *ir.InstFPToSI, *ir.InstUIToFP, *ir.InstSIToFP,
+		*ir.InstPtrToInt, *ir.InstIntToPtr, *ir.InstBitCast, *ir.InstAddrSpaceCast,
+		*ir.InstICmp, *ir.InstFCmp, *ir.InstSelect, *ir.InstFreeze, *ir.InstCall:
+		// Supported instructions.
+	default:
+		return errors.Errorf("support for instruction %T not yet implemented", inst)
+	}
+	for _, op := range ops {
+		if err := checkValue(op); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// checkTerm returns an error if the given LLVM IR terminator or one of its
+// constant operands has no C representation.
+func checkTerm(term ir.Terminator) error {
+	switch term := term.(type) {
+	case *ir.TermRet, *ir.TermBr, *ir.TermCondBr, *ir.TermSwitch, *ir.TermIndirectBr, *ir.TermUnreachable:
+		// Supported terminators.
+	default:
+		return errors.Errorf("support for terminator %T not yet implemented", term)
+	}
+	for _, op := range lower.TermOperands(term) {
+		if err := checkValue(op); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// checkValue returns an error if the given LLVM IR value is a constant or an
+// inline assembly without C representation.
+func checkValue(v value.Value) error {
+	switch v := v.(type) {
+	case *ir.InlineAsm:
+		return errors.Errorf("support for inline assembly %q not yet implemented", v.Asm)
+	case constant.Constant:
+		return checkConst(v)
+	}
+	return nil
+}
+
+// checkConst returns an error if the given LLVM IR constant, or a constant it
+// refers to, has no C representation.
+func checkConst(c constant.Constant) error {
+	if err := checkType(c.Type()); err != nil {
+		return errors.WithStack(err)
+	}
+	var ops []constant.Constant
+	switch c := c.(type) {
+	case *ir.Global, *ir.Func:
+		return nil
+	case *constant.Int:
+		// Integer constants are represented within the range of the signed C
+		// integer type of at most 64 bits.
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(c.Typ.BitSize))
+		x := new(big.Int).Mod(c.X, limit)
+		if x.Cmp(new(big.Int).Rsh(limit, 1)) >= 0 {
+			x.Sub(x, limit)
+		}
+		if !x.IsInt64() {
+			return errors.Errorf("support for integer constant %v of type %v not yet implemented", x, c.Typ)
+		}
+	case *constant.Float, *constant.Null, *constant.CharArray, *constant.ZeroInitializer, *constant.Undef, *constant.BlockAddress:
+		// Supported constants.
+	case *constant.Index:
+		ops = []constant.Constant{c.Constant}
+	case *constant.Array:
+		ops = c.Elems
+	case *constant.Struct:
+		ops = c.Fields
+	case *constant.ExprAdd:
+		ops = []constant.Constant{c.X, c.Y}
+	case *constant.ExprSub:
+		ops = []constant.Constant{c.X, c.Y}
+	case *constant.ExprMul:
+		ops = []constant.Constant{c.X, c.Y}
+	case *constant.ExprShl:
+		ops = []constant.Constant{c.X, c.Y}
+	case *constant.ExprAnd:
+		ops = []constant.Constant{c.X, c.Y}
+	case *constant.ExprOr:
+		ops = []constant.Constant{c.X, c.Y}
+	case *constant.ExprXor:
+		ops = []constant.Constant{c.X, c.Y}
+	case *constant.ExprGetElementPtr:
+		ops = []constant.Constant{c.Src}
+		for _, index := range c.Indices {
+			ops = append(ops, index)
+		}
+	case *constant.ExprTrunc:
+		ops = []constant.Constant{c.From}
+	case *constant.ExprZExt:
+		ops = []constant.Constant{c.From}
+	case *constant.ExprSExt:
+		ops = []constant.Constant{c.From}
+	case *constant.ExprPtrToInt:
+		ops = []constant.Constant{c.From}
+	case *constant.ExprIntToPtr:
+		ops = []constant.Constant{c.From}
+	case *constant.ExprBitCast:
+		ops = []constant.Constant{c.From}
+	case *constant.ExprAddrSpaceCast:
+		ops = []constant.Constant{c.From}
+	case *constant.ExprICmp:
+		ops = []constant.Constant{c.X, c.Y}
+	case *constant.ExprSelect:
+		ops = []constant.Constant{c.Cond, c.X, c.Y}
+	default:
+		return errors.Errorf("support for constant %T not yet implemented", c)
+	}
+	for _, op := range ops {
+		if err := checkConst(op); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// checkTypes returns an error if the given LLVM IR type, or a type it refers
+// to, has no C representation. Checked types are recorded in done, to
+// terminate on recursive struct types.
+func checkTypes(t irtypes.Type, done map[irtypes.Type]bool) error {
+	if done[t] {
+		return nil
+	}
+	done[t] = true
+	switch t := t.(type) {
+	case *irtypes.VoidType, *irtypes.FloatType:
+		return nil
+	case *irtypes.IntType:
+		if t.BitSize > 128 {
+			return errors.Errorf("support for integer type with bit size %d not yet implemented", t.BitSize)
+		}
+		return nil
+	case *irtypes.PointerType:
+		return checkTypes(t.ElemType, done)
+	case *irtypes.ArrayType:
+		return checkTypes(t.ElemType, done)
+	case *irtypes.StructType:
+		for _, field := range t.Fields {
+			if err := checkTypes(field, done); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		return nil
+	case *irtypes.FuncType:
+		if err := checkTypes(t.RetType, done); err != nil {
+			return errors.WithStack(err)
+		}
+		for _, param := range t.Params {
+			if err := checkTypes(param, done); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		return nil
+	default:
+		return errors.Errorf("support for type `%v` not yet implemented", t)
+	}
+}
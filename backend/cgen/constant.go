@@ -0,0 +1,259 @@
+package cgen
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/llir/llvm/ir/constant"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// constant converts the given LLVM IR constant to a corresponding C
+// expression.
+func (d *decompiler) constant(c constant.Constant) Expr {
+	switch c := c.(type) {
+	// Simple constants
+	case *constant.Int:
+		return d.constInt(c)
+	case *constant.Float:
+		return d.constFloat(c)
+	case *constant.Null:
+		d.include("stddef.h")
+		return &Ident{Name: "NULL"}
+	// Complex constants
+	case *constant.Array, *constant.Struct:
+		lit := d.init(c).(*CompositeLit)
+		lit.Type = d.declare(c.Type(), "")
+		return lit
+	case *constant.CharArray:
+		lit := &CompositeLit{Type: d.declare(c.Type(), "")}
+		for _, b := range c.X {
+			lit.Elts = append(lit.Elts, &Ident{Name: strconv.Itoa(int(b))})
+		}
+		return lit
+	case *constant.ZeroInitializer:
+		return d.zero(c.Type())
+	case *constant.Undef:
+		return d.zero(c.Type())
+	case *constant.BlockAddress:
+		// Addresses of basic blocks use the labels as values extension of GNU C.
+		//
+		//    &&block_1
+		d.labels[c.Block.Name()] = true
+		return &UnaryExpr{Op: "&&", X: &Ident{Name: d.label(c.Block.Name())}}
+	// Constant expressions
+	case constant.Expression:
+		return d.expr(c)
+	default:
+		panic(fmt.Errorf("support for constant value %T not yet implemented", c))
+	}
+}
+
+// constInt converts the given LLVM IR integer constant to a corresponding C
+// expression.
+//
+// Integer constants are represented in two's complement form within the range
+// of the signed C integer type; e.g. `i32 4294967295` is converted to -1. The
+// minimum value of integer types of at least 32 bits is given as an expression,
+// as the negation of an integer literal does not fit its type.
+//
+//    (-2147483647 - 1)
+func (d *decompiler) constInt(c *constant.Int) Expr {
+	bits := c.Typ.BitSize
+	if bits == 1 {
+		return d.boolLit(c.X.Sign() != 0)
+	}
+	// Wrap around values outside of the signed range [-2^(n-1), 2^(n-1)).
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	x := new(big.Int).Mod(c.X, limit)
+	max := new(big.Int).Rsh(limit, 1)
+	if x.Cmp(max) >= 0 {
+		x.Sub(x, limit)
+	}
+	if !x.IsInt64() {
+		panic(fmt.Errorf("support for integer constant %v of type %v not yet implemented", x, c.Typ))
+	}
+	if x.Sign() >= 0 {
+		return &Ident{Name: x.String()}
+	}
+	if bits >= 32 && new(big.Int).Neg(x).Cmp(max) == 0 {
+		max.Sub(max, big.NewInt(1))
+		return &BinaryExpr{
+			X:  &UnaryExpr{Op: "-", X: &Ident{Name: max.String()}},
+			Op: "-",
+			Y:  &Ident{Name: "1"},
+		}
+	}
+	return &UnaryExpr{Op: "-", X: &Ident{Name: new(big.Int).Neg(x).String()}}
+}
+
+// boolLit returns a C boolean literal of the given value.
+func (d *decompiler) boolLit(x bool) Expr {
+	d.include("stdbool.h")
+	return &Ident{Name: strconv.FormatBool(x)}
+}
+
+// constFloat converts the given LLVM IR floating-point constant to a
+// corresponding C expression.
+//
+//    1.5f
+//    -INFINITY
+func (d *decompiler) constFloat(c *constant.Float) Expr {
+	var s string
+	switch {
+	case c.NaN:
+		d.include("math.h")
+		return &Ident{Name: "NAN"}
+	case c.X.IsInf():
+		d.include("math.h")
+		s = "INFINITY"
+		if c.X.Signbit() {
+			s = "-" + s
+		}
+	default:
+		switch c.Typ.Kind {
+		case irtypes.FloatKindHalf, irtypes.FloatKindFloat:
+			f, _ := c.X.Float32()
+			s = strconv.FormatFloat(float64(f), 'g', -1, 32)
+		case irtypes.FloatKindDouble:
+			f, _ := c.X.Float64()
+			s = strconv.FormatFloat(f, 'g', -1, 64)
+		default:
+			s = c.X.Text('g', -1)
+		}
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		switch c.Typ.Kind {
+		case irtypes.FloatKindHalf, irtypes.FloatKindFloat:
+			s += "f"
+		case irtypes.FloatKindDouble:
+		default:
+			s += "L"
+		}
+	}
+	if strings.HasPrefix(s, "-") {
+		return &UnaryExpr{Op: "-", X: &Ident{Name: s[1:]}}
+	}
+	return &Ident{Name: s}
+}
+
+// zero returns a C expression of the zero value of the given LLVM IR type.
+//
+//    0
+//    NULL
+//    (struct foo){0}
+func (d *decompiler) zero(t irtypes.Type) Expr {
+	switch t := t.(type) {
+	case *irtypes.IntType:
+		if t.BitSize == 1 {
+			return d.boolLit(false)
+		}
+		return &Ident{Name: "0"}
+	case *irtypes.FloatType:
+		return d.constFloat(constant.NewFloat(t, 0))
+	case *irtypes.PointerType:
+		d.include("stddef.h")
+		return &Ident{Name: "NULL"}
+	default:
+		return &CompositeLit{Type: d.declare(t, ""), Elts: []Expr{&Ident{Name: "0"}}}
+	}
+}
+
+// init converts the given LLVM IR constant to a corresponding C initializer.
+// Aggregates are represented as initializer lists, and character arrays as
+// string literals.
+//
+//    {1, 2}
+//    "foo"
+func (d *decompiler) init(c constant.Constant) Expr {
+	switch c := c.(type) {
+	case *constant.Array:
+		lit := &CompositeLit{}
+		for _, elem := range c.Elems {
+			lit.Elts = append(lit.Elts, d.init(elem))
+		}
+		return lit
+	case *constant.CharArray:
+		// The terminating NUL character is implicit in C string literals, and
+		// omitted if the array is full.
+		s := string(c.X)
+		if strings.HasSuffix(s, "\x00") {
+			s = s[:len(s)-1]
+		}
+		return stringLit(s)
+	case *constant.Struct:
+		lit := &CompositeLit{}
+		for _, field := range c.Fields {
+			lit.Elts = append(lit.Elts, d.init(field))
+		}
+		return lit
+	case *constant.ZeroInitializer, *constant.Undef:
+		switch c.Type().(type) {
+		case *irtypes.IntType, *irtypes.FloatType, *irtypes.PointerType:
+			return d.zero(c.Type())
+		}
+		return &CompositeLit{Elts: []Expr{&Ident{Name: "0"}}}
+	default:
+		return d.value(c)
+	}
+}
+
+// expr converts the given LLVM IR constant expression to a corresponding C
+// expression.
+func (d *decompiler) expr(expr constant.Expression) Expr {
+	switch expr := expr.(type) {
+	// Binary expressions
+	case *constant.ExprAdd:
+		return d.binaryOp(expr.X, "+", expr.Y)
+	case *constant.ExprSub:
+		return d.binaryOp(expr.X, "-", expr.Y)
+	case *constant.ExprMul:
+		return d.binaryOp(expr.X, "*", expr.Y)
+	// Bitwise expressions
+	case *constant.ExprShl:
+		return d.binaryOp(expr.X, "<<", expr.Y)
+	case *constant.ExprAnd:
+		return d.binaryOp(expr.X, "&", expr.Y)
+	case *constant.ExprOr:
+		return d.binaryOp(expr.X, "|", expr.Y)
+	case *constant.ExprXor:
+		return d.binaryOp(expr.X, "^", expr.Y)
+	// Memory expressions
+	case *constant.ExprGetElementPtr:
+		var indices []value.Value
+		for _, index := range expr.Indices {
+			indices = append(indices, index)
+		}
+		return d.gep(expr.ElemType, expr.Src, indices)
+	// Conversion expressions
+	case *constant.ExprTrunc:
+		return d.trunc(expr.From, expr.To)
+	case *constant.ExprZExt:
+		return d.zext(expr.From, expr.To)
+	case *constant.ExprSExt:
+		return d.sext(expr.From, expr.To)
+	case *constant.ExprPtrToInt:
+		return d.convert(expr.From, expr.To)
+	case *constant.ExprIntToPtr:
+		return d.convert(expr.From, expr.To)
+	case *constant.ExprBitCast:
+		return d.bitcast(expr.From, expr.To)
+	case *constant.ExprAddrSpaceCast:
+		return d.convert(expr.From, expr.To)
+	// Other expressions
+	case *constant.ExprICmp:
+		return d.intCmp(expr.Pred, expr.X, expr.Y)
+	case *constant.ExprSelect:
+		return &CondExpr{
+			Cond: d.value(expr.Cond),
+			X:    d.value(expr.X),
+			Y:    d.value(expr.Y),
+		}
+	default:
+		panic(fmt.Errorf("support for constant expression %T not yet implemented", expr))
+	}
+}
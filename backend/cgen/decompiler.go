@@ -0,0 +1,605 @@
+package cgen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/decomp/decomp/internal/outssa"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// A decompiler keeps track of relevant information during the decompilation
+// process.
+type decompiler struct {
+	// Global states.
+
+	// Tracks included system headers.
+	includes map[string]bool
+	// Map from LLVM IR assembly of literal struct type to struct name.
+	structNames map[string]string
+	// Literal struct types, in order of occurrence.
+	structs []*structDef
+	// Tracks string literal globals referred to other than by their contents.
+	usedGlobals map[string]bool
+	// Tracks functions replaced by C standard library functions of the same
+	// name.
+	libcFuncs map[string]bool
+
+	// Per function states.
+
+	// Map from basic block label to conceptual basic block.
+	blocks map[string]*basicBlock
+	// Track use of basic block labels.
+	labels map[string]bool
+	// Map from local identifier of alloca instruction to C local variable
+	// name.
+	vars map[string]string
+}
+
+// newDecompiler returns a new decompiler.
+func newDecompiler() *decompiler {
+	return &decompiler{
+		includes:    make(map[string]bool),
+		structNames: make(map[string]string),
+		usedGlobals: make(map[string]bool),
+		libcFuncs:   make(map[string]bool),
+	}
+}
+
+// funcSig returns the C function signature of the given LLVM IR function;
+// including parameter names if params is set.
+//
+//    int32_t f(int32_t _0, char **_1)
+func (d *decompiler) funcSig(f *ir.Func, params bool) string {
+	var ps []string
+	for i, p := range f.Params {
+		name := ""
+		if params {
+			name = d.localIdent(p.Name())
+		}
+		ps = append(ps, d.declare(f.Sig.Params[i], name))
+	}
+	if f.Sig.Variadic {
+		ps = append(ps, "...")
+	}
+	if len(ps) == 0 {
+		ps = []string{"void"}
+	}
+	sig := d.declare(f.Sig.RetType, fmt.Sprintf("%s(%s)", d.globalIdent(f.Name()), strings.Join(ps, ", ")))
+	if isInternal(f.Linkage) {
+		sig = "static " + sig
+	}
+	return sig
+}
+
+// isInternal reports whether the given linkage is local to the module.
+func isInternal(linkage enum.Linkage) bool {
+	return linkage == enum.LinkagePrivate || linkage == enum.LinkageInternal
+}
+
+// globalDecl converts the given LLVM IR global into a corresponding C variable
+// declaration.
+//
+//    static int32_t x = 42;
+//    static const char _str[6] = "hello";
+func (d *decompiler) globalDecl(g *ir.Global) string {
+	decl := d.declare(g.ContentType, d.globalIdent(g.Name()))
+	if g.Init == nil {
+		return fmt.Sprintf("extern %s;", decl)
+	}
+	if g.Immutable {
+		decl = "const " + decl
+	}
+	if isInternal(g.Linkage) {
+		decl = "static " + decl
+	}
+	return fmt.Sprintf("%s = %s;", decl, d.init(g.Init))
+}
+
+// funcDecl converts the given LLVM IR function into a corresponding C function
+// definition.
+func (d *decompiler) funcDecl(f *ir.Func, prims []*primitive.Primitive) (*FuncDecl, error) {
+	// Force generate local IDs.
+	if err := f.AssignIDs(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Reset per function states.
+	d.labels = make(map[string]bool)
+	d.vars = make(map[string]string)
+	d.blocks = make(map[string]*basicBlock)
+	for i, block := range f.Blocks {
+		d.blocks[block.Name()] = &basicBlock{Block: block, num: i}
+	}
+
+	// Record local variables of allocas.
+	var decls []Stmt
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if inst, ok := inst.(*ir.InstAlloca); ok {
+				decls = append(decls, d.allocaDecl(inst))
+			}
+		}
+	}
+
+	// Record incoming and outgoing PHI values (see outssa.Eliminate).
+	copies := outssa.Eliminate(f)
+	for _, block := range f.Blocks {
+		b := d.blocks[block.Name()]
+		b.in = d.copyStmts(copies.In[block])
+		b.out = d.copyStmts(copies.Out[block])
+	}
+
+	// Recover control flow primitives.
+	for _, prim := range prims {
+		block, err := d.prim(prim)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		// Delete merged basic blocks.
+		for _, node := range prim.Nodes {
+			delete(d.blocks, node)
+		}
+		// Add primitive basic block.
+		d.blocks[block.Name()] = block
+	}
+
+	// A single remaining basic block indicates successful control flow recovery.
+	// If more than one basic block remains, unstructured control flow is added
+	// using goto-statements.
+	var blocks basicBlocks
+	for _, block := range d.blocks {
+		blocks = append(blocks, block)
+	}
+	sort.Sort(blocks)
+	for _, block := range blocks {
+		block.stmts = d.stmts(block)
+		block.stmts = append(block.stmts, d.term(block.Term))
+	}
+
+	// Insert labels of target branches into corresponding basic blocks.
+	for _, block := range blocks {
+		if d.labels[block.Name()] {
+			block.stmts[0] = &LabeledStmt{
+				Label: d.label(block.Name()),
+				Stmt:  block.stmts[0],
+			}
+		}
+	}
+
+	var stmts []Stmt
+	for _, block := range blocks {
+		stmts = append(stmts, block.stmts...)
+	}
+	stmts = d.pruneUnused(stmts)
+	// Omit return-statement without value at the end of the function.
+	if n := len(stmts); n > 0 {
+		if s, ok := stmts[n-1].(*ReturnStmt); ok && s.X == nil {
+			stmts = stmts[:n-1]
+		}
+	}
+	decls = append(decls, d.localDecls(f, stmts)...)
+	fn := &FuncDecl{
+		Sig:  d.funcSig(f, true),
+		Body: &BlockStmt{List: append(decls, stmts...)},
+	}
+	return fn, nil
+}
+
+// allocaDecl records the local variable of the given LLVM IR alloca
+// instruction, and returns its declaration.
+//
+//    int32_t x;
+//    char buf[32];
+func (d *decompiler) allocaDecl(inst *ir.InstAlloca) Stmt {
+	name := d.localIdent(inst.Name())
+	d.vars[inst.Name()] = name
+	t := inst.ElemType
+	if inst.NElems != nil {
+		n, ok := inst.NElems.(*constant.Int)
+		if !ok {
+			panic(fmt.Errorf("support for alloca with non-constant number of elements `%v` not yet implemented", inst.LLString()))
+		}
+		if n.X.Int64() != 1 {
+			t = irtypes.NewArray(n.X.Uint64(), t)
+		}
+	}
+	return &DeclStmt{Decl: d.declare(t, name)}
+}
+
+// localDecls returns declarations of the local variables of the given function
+// assigned by the specified statements of its body.
+//
+//    int32_t _3;
+func (d *decompiler) localDecls(f *ir.Func, stmts []Stmt) []Stmt {
+	assigned := make(map[string]bool)
+	inspectStmts(stmts, func(stmt Stmt) {
+		if x, ok := assignee(stmt); ok {
+			assigned[x] = true
+		}
+	}, nil)
+	var decls []Stmt
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			v, ok := inst.(value.Named)
+			if !ok || irtypes.Equal(v.Type(), irtypes.Void) {
+				continue
+			}
+			if _, ok := inst.(*ir.InstAlloca); ok {
+				continue
+			}
+			name := d.localIdent(v.Name())
+			if !assigned[name] {
+				continue
+			}
+			decls = append(decls, &DeclStmt{Decl: d.declare(v.Type(), name)})
+		}
+	}
+	// Declare temporary variables of PHI instructions.
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if phi, ok := inst.(*ir.InstPhi); ok && assigned[d.tempName(phi)] {
+				decls = append(decls, &DeclStmt{Decl: d.declare(phi.Type(), d.tempName(phi))})
+			}
+		}
+	}
+	return decls
+}
+
+// pruneUnused replaces assignments of function call results to local
+// variables which are never used by the function calls themselves.
+//
+//    printf("foo");
+func (d *decompiler) pruneUnused(stmts []Stmt) []Stmt {
+	used := make(map[string]bool)
+	inspectStmts(stmts, nil, func(x Expr, lhs bool) {
+		if id, ok := x.(*Ident); ok && !lhs {
+			used[id.Name] = true
+		}
+	})
+	var prune func(stmts []Stmt) []Stmt
+	prune = func(stmts []Stmt) []Stmt {
+		for i, stmt := range stmts {
+			switch s := stmt.(type) {
+			case *ExprStmt:
+				if x, ok := assignee(s); ok && !used[x] {
+					if call, ok := s.X.(*BinaryExpr).Y.(*CallExpr); ok {
+						stmts[i] = &ExprStmt{X: call}
+					}
+				}
+			case *LabeledStmt:
+				s.Stmt = prune([]Stmt{s.Stmt})[0]
+			case *BlockStmt:
+				s.List = prune(s.List)
+			case *IfStmt:
+				s.Body.List = prune(s.Body.List)
+				if s.Else != nil {
+					s.Else = prune([]Stmt{s.Else})[0]
+				}
+			case *WhileStmt:
+				s.Body.List = prune(s.Body.List)
+			case *DoWhileStmt:
+				s.Body.List = prune(s.Body.List)
+			case *SwitchStmt:
+				for _, c := range s.Cases {
+					c.Body = prune(c.Body)
+				}
+			}
+		}
+		return stmts
+	}
+	return prune(stmts)
+}
+
+// assignee returns the name of the local variable assigned by the given
+// statement, if an assignment to a local variable. The boolean return value
+// indicates success.
+func assignee(stmt Stmt) (string, bool) {
+	s, ok := stmt.(*ExprStmt)
+	if !ok {
+		return "", false
+	}
+	assign, ok := s.X.(*BinaryExpr)
+	if !ok || assign.Op != "=" {
+		return "", false
+	}
+	x, ok := assign.X.(*Ident)
+	if !ok {
+		return "", false
+	}
+	return x.Name, true
+}
+
+// inspectStmts traverses the given statements in depth-first order, invoking
+// stmtFn for each statement and exprFn for each expression, if non-nil. The lhs
+// argument of exprFn reports whether the expression is the left-hand side of
+// an assignment.
+func inspectStmts(stmts []Stmt, stmtFn func(stmt Stmt), exprFn func(x Expr, lhs bool)) {
+	var expr func(x Expr, lhs bool)
+	expr = func(x Expr, lhs bool) {
+		if x == nil || exprFn == nil {
+			return
+		}
+		exprFn(x, lhs)
+		switch x := x.(type) {
+		case *UnaryExpr:
+			expr(x.X, false)
+		case *BinaryExpr:
+			expr(x.X, x.Op == "=")
+			expr(x.Y, false)
+		case *CastExpr:
+			expr(x.X, false)
+		case *CallExpr:
+			expr(x.Fun, false)
+			for _, arg := range x.Args {
+				expr(arg, false)
+			}
+		case *IndexExpr:
+			expr(x.X, false)
+			expr(x.Index, false)
+		case *MemberExpr:
+			expr(x.X, lhs)
+		case *CondExpr:
+			expr(x.Cond, false)
+			expr(x.X, false)
+			expr(x.Y, false)
+		case *CompositeLit:
+			for _, elt := range x.Elts {
+				expr(elt, false)
+			}
+		}
+	}
+	var stmt func(s Stmt)
+	stmt = func(s Stmt) {
+		if s == nil {
+			return
+		}
+		if stmtFn != nil {
+			stmtFn(s)
+		}
+		switch s := s.(type) {
+		case *ExprStmt:
+			expr(s.X, false)
+		case *DeclStmt:
+			expr(s.Init, false)
+		case *BlockStmt:
+			for _, s := range s.List {
+				stmt(s)
+			}
+		case *IfStmt:
+			expr(s.Cond, false)
+			stmt(s.Body)
+			stmt(s.Else)
+		case *WhileStmt:
+			expr(s.Cond, false)
+			stmt(s.Body)
+		case *DoWhileStmt:
+			stmt(s.Body)
+			expr(s.Cond, false)
+		case *SwitchStmt:
+			expr(s.Tag, false)
+			for _, c := range s.Cases {
+				expr(c.X, false)
+				for _, s := range c.Body {
+					stmt(s)
+				}
+			}
+		case *ReturnStmt:
+			expr(s.X, false)
+		case *LabeledStmt:
+			stmt(s.Stmt)
+		}
+	}
+	for _, s := range stmts {
+		stmt(s)
+	}
+}
+
+// basicBlock represents a conceptual basic block, that may contain both LLVM IR
+// instructions and C statements.
+type basicBlock struct {
+	*ir.Block
+	// C statements.
+	stmts []Stmt
+	// Incoming values for PHI instructions assigned through temporary
+	// variables. In other words, a list of assignment statements to appear at
+	// the beginning of the basic block.
+	in []Stmt
+	// Outgoing values for PHI instructions. In other words, a list of assignment
+	// statements to appear at the end of the basic block.
+	out []Stmt
+	// Track basic block number in f.Blocks slice, to be used for sorting basic
+	// blocks after incomplete control flow recovery.
+	num int
+}
+
+// basicBlocks implements the sort.Sort interface to sort basic blocks according
+// to their occurrence in f.Blocks.
+type basicBlocks []*basicBlock
+
+func (bs basicBlocks) Less(i, j int) bool { return bs[i].num < bs[j].num }
+func (bs basicBlocks) Len() int           { return len(bs) }
+func (bs basicBlocks) Swap(i, j int)      { bs[i], bs[j] = bs[j], bs[i] }
+
+// stmts converts the incoming PHI values, basic block instructions, recorded
+// statements and outgoing PHI values into a corresponding list of C statements.
+func (d *decompiler) stmts(block *basicBlock) []Stmt {
+	var stmts []Stmt
+	stmts = append(stmts, block.in...)
+	for _, inst := range block.Insts {
+		stmts = append(stmts, d.inst(inst)...)
+	}
+	stmts = append(stmts, block.stmts...)
+	stmts = append(stmts, block.out...)
+	return stmts
+}
+
+// assign returns a C statement assigning x to the local variable of the given
+// LLVM IR value.
+//
+//    _3 = x;
+func (d *decompiler) assign(v value.Named, x Expr) Stmt {
+	return &ExprStmt{
+		X: &BinaryExpr{X: &Ident{Name: d.localIdent(v.Name())}, Op: "=", Y: x},
+	}
+}
+
+// copyStmts converts the given copies replacing PHI instructions into a
+// corresponding list of C statements.
+//
+//    _2_phi = _2;
+//    _2 = _3;
+//    _3 = _2_phi;
+func (d *decompiler) copyStmts(copies []*outssa.Copy) []Stmt {
+	var stmts []Stmt
+	for _, c := range copies {
+		dst := d.localIdent(c.Dst.Name())
+		if c.DstTemp {
+			dst = d.tempName(c.Dst)
+		}
+		src := d.value(c.Src)
+		if c.SrcTemp {
+			src = &Ident{Name: d.tempName(c.Src.(*ir.InstPhi))}
+		}
+		stmts = append(stmts, &ExprStmt{
+			X: &BinaryExpr{X: &Ident{Name: dst}, Op: "=", Y: src},
+		})
+	}
+	return stmts
+}
+
+// tempName returns the name of the temporary variable of the given PHI
+// instruction.
+func (d *decompiler) tempName(phi *ir.InstPhi) string {
+	return d.localIdent(phi.Name()) + "_phi"
+}
+
+// value converts the given LLVM IR value to a corresponding C expression.
+func (d *decompiler) value(v value.Value) Expr {
+	switch v := v.(type) {
+	case *ir.InstAlloca:
+		// The address of the local variable of the alloca instruction; or its
+		// first element if an array.
+		name := d.vars[v.Name()]
+		if v.NElems != nil {
+			if n, ok := v.NElems.(*constant.Int); !ok || n.X.Int64() != 1 {
+				return &Ident{Name: name}
+			}
+		}
+		return &UnaryExpr{Op: "&", X: &Ident{Name: name}}
+	case *ir.Func:
+		return &Ident{Name: d.globalIdent(v.Name())}
+	case *ir.Global:
+		if _, _, ok := stringGlobal(v); ok {
+			d.usedGlobals[v.Name()] = true
+		}
+		return &UnaryExpr{Op: "&", X: &Ident{Name: d.globalIdent(v.Name())}}
+	case value.Named:
+		return &Ident{Name: d.localIdent(v.Name())}
+	case constant.Constant:
+		return d.constant(v)
+	default:
+		panic(fmt.Errorf("support for value %T not yet implemented", v))
+	}
+}
+
+// deref returns a C expression dereferencing the given pointer.
+//
+//    *p
+func deref(p Expr) Expr {
+	if x, ok := p.(*UnaryExpr); ok && x.Op == "&" {
+		return x.X
+	}
+	return &UnaryExpr{Op: "*", X: p}
+}
+
+// addr returns a C expression of the address of the given lvalue.
+//
+//    &x
+func addr(x Expr) Expr {
+	if p, ok := x.(*UnaryExpr); ok && p.Op == "*" {
+		return p.X
+	}
+	return &UnaryExpr{Op: "&", X: x}
+}
+
+// member returns a C expression of the named member of the given struct
+// lvalue.
+//
+//    s.field_0
+//    p->field_0
+func member(x Expr, name string) Expr {
+	if p, ok := x.(*UnaryExpr); ok && p.Op == "*" {
+		return &MemberExpr{X: p.X, Name: name, Arrow: true}
+	}
+	return &MemberExpr{X: x, Name: name}
+}
+
+// typeIdent converts the given LLVM IR type identifier to a corresponding C
+// identifier.
+func (d *decompiler) typeIdent(name string) string {
+	name = strings.TrimPrefix(name, "struct.")
+	name = strings.TrimPrefix(name, "union.")
+	return ident(name)
+}
+
+// globalIdent converts the given LLVM IR global identifier to a corresponding
+// C identifier.
+func (d *decompiler) globalIdent(name string) string {
+	if d.libcFuncs[name] {
+		return name
+	}
+	return ident(name)
+}
+
+// localIdent converts the given LLVM IR local identifier to a corresponding C
+// identifier.
+func (d *decompiler) localIdent(name string) string {
+	return ident(name)
+}
+
+// label converts the given LLVM IR basic block label to a corresponding C
+// label.
+func (d *decompiler) label(name string) string {
+	return ident("block_" + name)
+}
+
+// ident returns a sanitized version of the given identifier; prefixed by an
+// underscore if an unnamed identifier, C keyword or reserved C standard library
+// name.
+func ident(s string) string {
+	f := func(r rune) rune {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			// valid rune in identifier.
+			return r
+		}
+		return '_'
+	}
+	s = strings.Map(f, s)
+	if len(s) == 0 || unicode.IsDigit(rune(s[0])) || keywords[s] || libcReserved(s) {
+		s = "_" + s
+	}
+	return s
+}
+
+// keywords is the set of C99 keywords.
+var keywords = map[string]bool{
+	"auto": true, "break": true, "case": true, "char": true, "const": true,
+	"continue": true, "default": true, "do": true, "double": true, "else": true,
+	"enum": true, "extern": true, "float": true, "for": true, "goto": true,
+	"if": true, "inline": true, "int": true, "long": true, "register": true,
+	"restrict": true, "return": true, "short": true, "signed": true,
+	"sizeof": true, "static": true, "struct": true, "switch": true,
+	"typedef": true, "union": true, "unsigned": true, "void": true,
+	"volatile": true, "while": true, "_Bool": true, "_Complex": true,
+	"_Imaginary": true, "bool": true, "true": true, "false": true,
+}
@@ -0,0 +1,41 @@
+package cgen
+
+import (
+	"github.com/decomp/decomp/internal/lower"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// gep converts the given LLVM IR getelementptr operands to a corresponding C
+// address expression. The first index selects an element of the source
+// pointer, and the remaining indices select struct fields and array elements.
+//
+//    &p[i]
+//    &p->field_1[2]
+func (d *decompiler) gep(elemType irtypes.Type, src value.Value, indices []value.Value) Expr {
+	if expr, ok := stringAddr(src, indices); ok {
+		return expr
+	}
+	x := d.value(src)
+	if len(indices) == 0 {
+		return x
+	}
+	var lv Expr
+	if lower.IsZero(indices[0]) {
+		lv = deref(x)
+	} else {
+		lv = &IndexExpr{X: x, Index: d.value(lower.Index(indices[0]))}
+	}
+	path, err := lower.Path(elemType, indices[1:])
+	if err != nil {
+		panic(err)
+	}
+	for _, step := range path {
+		if step.Struct != nil {
+			lv = member(lv, fieldName(step.Field))
+			continue
+		}
+		lv = &IndexExpr{X: lv, Index: d.value(step.Index)}
+	}
+	return addr(lv)
+}
@@ -0,0 +1,495 @@
+package cgen
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// inst converts the given LLVM IR instruction to a corresponding list of C
+// statements.
+func (d *decompiler) inst(inst ir.Instruction) []Stmt {
+	switch inst := inst.(type) {
+	case *ir.InstPhi:
+		// PHI instructions are handled during the pre-processing of basic
+		// blocks.
+		return nil
+	case *ir.InstAlloca:
+		// Local variables of alloca instructions are declared at the beginning
+		// of the function.
+		return nil
+	case *ir.InstInsertValue:
+		return d.instInsertValue(inst)
+	case *ir.InstStore:
+		return []Stmt{d.instStore(inst)}
+	case *ir.InstFence:
+		return []Stmt{&ExprStmt{X: call("__atomic_thread_fence", &Ident{Name: "__ATOMIC_SEQ_CST"})}}
+	case *ir.InstCall:
+		return d.instCall(inst)
+	}
+	v, ok := inst.(value.Named)
+	if !ok {
+		panic(fmt.Errorf("support for instruction %T not yet implemented", inst))
+	}
+	return []Stmt{d.assign(v, d.instExpr(inst))}
+}
+
+// instExpr converts the given LLVM IR instruction to a corresponding C
+// expression of its result.
+func (d *decompiler) instExpr(inst ir.Instruction) Expr {
+	switch inst := inst.(type) {
+	// Unary instructions
+	case *ir.InstFNeg:
+		return &UnaryExpr{Op: "-", X: d.value(inst.X)}
+	// Binary instructions
+	case *ir.InstAdd:
+		return d.binaryOp(inst.X, "+", inst.Y)
+	case *ir.InstFAdd:
+		return d.binaryOp(inst.X, "+", inst.Y)
+	case *ir.InstSub:
+		return d.binaryOp(inst.X, "-", inst.Y)
+	case *ir.InstFSub:
+		return d.binaryOp(inst.X, "-", inst.Y)
+	case *ir.InstMul:
+		return d.binaryOp(inst.X, "*", inst.Y)
+	case *ir.InstFMul:
+		return d.binaryOp(inst.X, "*", inst.Y)
+	case *ir.InstUDiv:
+		return d.unsignedOp(inst.X, "/", inst.Y)
+	case *ir.InstSDiv:
+		return d.signedOp(inst.X, "/", inst.Y)
+	case *ir.InstFDiv:
+		return d.binaryOp(inst.X, "/", inst.Y)
+	case *ir.InstURem:
+		return d.unsignedOp(inst.X, "%", inst.Y)
+	case *ir.InstSRem:
+		return d.signedOp(inst.X, "%", inst.Y)
+	case *ir.InstFRem:
+		return d.mathCall("fmod", inst.X.Type(), d.value(inst.X), d.value(inst.Y))
+	// Bitwise instructions
+	case *ir.InstShl:
+		return d.binaryOp(inst.X, "<<", inst.Y)
+	case *ir.InstLShr:
+		return d.unsignedOp(inst.X, ">>", inst.Y)
+	case *ir.InstAShr:
+		return d.signedOp(inst.X, ">>", inst.Y)
+	case *ir.InstAnd:
+		return d.binaryOp(inst.X, "&", inst.Y)
+	case *ir.InstOr:
+		return d.binaryOp(inst.X, "|", inst.Y)
+	case *ir.InstXor:
+		return d.binaryOp(inst.X, "^", inst.Y)
+	// Aggregate instructions
+	case *ir.InstExtractValue:
+		return d.aggregateElem(d.value(inst.X), inst.X.Type(), inst.Indices)
+	// Memory instructions
+	case *ir.InstLoad:
+		return deref(d.value(inst.Src))
+	case *ir.InstGetElementPtr:
+		return d.gep(inst.ElemType, inst.Src, inst.Indices)
+	// Conversion instructions
+	case *ir.InstTrunc:
+		return d.trunc(inst.From, inst.To)
+	case *ir.InstZExt:
+		return d.zext(inst.From, inst.To)
+	case *ir.InstSExt:
+		return d.sext(inst.From, inst.To)
+	case *ir.InstFPTrunc:
+		return d.convert(inst.From, inst.To)
+	case *ir.InstFPExt:
+		return d.convert(inst.From, inst.To)
+	case *ir.InstFPToUI:
+		return d.fptoui(inst.From, inst.To)
+	case *ir.InstFPToSI:
+		return d.convert(inst.From, inst.To)
+	case *ir.InstUIToFP:
+		return d.uitofp(inst.From, inst.To)
+	case *ir.InstSIToFP:
+		return d.convert(inst.From, inst.To)
+	case *ir.InstPtrToInt:
+		return d.convert(inst.From, inst.To)
+	case *ir.InstIntToPtr:
+		return d.convert(inst.From, inst.To)
+	case *ir.InstBitCast:
+		return d.bitcast(inst.From, inst.To)
+	case *ir.InstAddrSpaceCast:
+		return d.convert(inst.From, inst.To)
+	// Other instructions
+	case *ir.InstICmp:
+		return d.intCmp(inst.Pred, inst.X, inst.Y)
+	case *ir.InstFCmp:
+		return d.floatCmp(inst.Pred, inst.X, inst.Y)
+	case *ir.InstSelect:
+		if _, ok := inst.Cond.Type().(*irtypes.VectorType); ok {
+			panic(fmt.Errorf("support for vector select instruction `%v` not yet implemented", inst.LLString()))
+		}
+		return &CondExpr{
+			Cond: d.value(inst.Cond),
+			X:    d.value(inst.ValueTrue),
+			Y:    d.value(inst.ValueFalse),
+		}
+	case *ir.InstFreeze:
+		return d.value(inst.X)
+	default:
+		panic(fmt.Errorf("support for instruction %T not yet implemented", inst))
+	}
+}
+
+// instInsertValue converts the given LLVM IR insertvalue instruction to a
+// corresponding list of C statements.
+//
+//    _3 = _2;
+//    _3.field_1 = x;
+func (d *decompiler) instInsertValue(inst *ir.InstInsertValue) []Stmt {
+	result := &Ident{Name: d.localIdent(inst.Name())}
+	elem := d.aggregateElem(result, inst.X.Type(), inst.Indices)
+	return []Stmt{
+		d.assign(inst, d.value(inst.X)),
+		&ExprStmt{X: &BinaryExpr{X: elem, Op: "=", Y: d.value(inst.Elem)}},
+	}
+}
+
+// aggregateElem returns a C expression of the element of the given aggregate
+// value (of type t) at the specified indices.
+//
+//    x.field_1[2]
+func (d *decompiler) aggregateElem(x Expr, t irtypes.Type, indices []uint64) Expr {
+	for _, index := range indices {
+		switch tt := t.(type) {
+		case *irtypes.StructType:
+			x = member(x, fieldName(int(index)))
+			t = tt.Fields[index]
+		case *irtypes.ArrayType:
+			x = &IndexExpr{X: x, Index: &Ident{Name: fmt.Sprint(index)}}
+			t = tt.ElemType
+		default:
+			panic(fmt.Errorf("support for aggregate type %T not yet implemented", t))
+		}
+	}
+	return x
+}
+
+// instStore converts the given LLVM IR store instruction to a corresponding C
+// statement.
+//
+//    *p = x;
+func (d *decompiler) instStore(inst *ir.InstStore) Stmt {
+	return &ExprStmt{
+		X: &BinaryExpr{X: deref(d.value(inst.Dst)), Op: "=", Y: d.value(inst.Src)},
+	}
+}
+
+// instCall converts the given LLVM IR call instruction to a corresponding list
+// of C statements. Calls to LLVM intrinsic functions are lowered to C standard
+// library calls, or omitted if of no relevance to the C source code.
+func (d *decompiler) instCall(inst *ir.InstCall) []Stmt {
+	expr := d.callExpr(inst.Callee, inst.Args)
+	if expr == nil {
+		return nil
+	}
+	if irtypes.Equal(inst.Type(), irtypes.Void) {
+		return []Stmt{&ExprStmt{X: expr}}
+	}
+	return []Stmt{d.assign(inst, expr)}
+}
+
+// callExpr converts the given LLVM IR function call to a corresponding C call
+// expression; or nil if a call to an omitted LLVM intrinsic function.
+func (d *decompiler) callExpr(callee value.Value, args []value.Value) Expr {
+	if f, ok := callee.(*ir.Func); ok {
+		if b, ok := intrinsic(f.Name()); ok {
+			if b == nil {
+				return nil
+			}
+			if len(args) > b.nargs {
+				args = args[:b.nargs]
+			}
+			if len(b.header) > 0 {
+				d.include(b.header)
+			}
+			name := b.name
+			if b.header == "math.h" && isFloat(f.Sig.RetType) {
+				name += "f"
+			}
+			return call(name, d.values(args)...)
+		}
+	}
+	return &CallExpr{Fun: d.value(callee), Args: d.values(args)}
+}
+
+// values converts the given LLVM IR values to corresponding C expressions.
+func (d *decompiler) values(vs []value.Value) []Expr {
+	var exprs []Expr
+	for _, v := range vs {
+		exprs = append(exprs, d.value(v))
+	}
+	return exprs
+}
+
+// call returns a C call expression of the named function.
+//
+//    f(x, y)
+func call(name string, args ...Expr) Expr {
+	return &CallExpr{Fun: &Ident{Name: name}, Args: args}
+}
+
+// mathCall returns a call expression of the named math.h function, using the
+// float variant of the function for operands of float type.
+//
+//    fmodf(x, y)
+func (d *decompiler) mathCall(name string, t irtypes.Type, args ...Expr) Expr {
+	d.include("math.h")
+	if isFloat(t) {
+		name += "f"
+	}
+	return call(name, args...)
+}
+
+// isFloat reports whether the given LLVM IR type is of single-precision
+// floating-point type.
+func isFloat(t irtypes.Type) bool {
+	f, ok := t.(*irtypes.FloatType)
+	return ok && f.Kind == irtypes.FloatKindFloat
+}
+
+// binaryOp converts the given LLVM IR binary operation to a corresponding C
+// expression.
+//
+//    x + y
+func (d *decompiler) binaryOp(x value.Value, op string, y value.Value) Expr {
+	if _, ok := x.Type().(*irtypes.VectorType); ok {
+		panic(fmt.Errorf("support for vector operation %q not yet implemented", op))
+	}
+	return &BinaryExpr{X: d.value(x), Op: op, Y: d.value(y)}
+}
+
+// unsignedOp converts the given LLVM IR binary operation with unsigned
+// semantics (e.g. udiv, urem, lshr) to a corresponding C expression, by casting
+// its operands to unsigned integer type.
+//
+//    (uint32_t)x / (uint32_t)y
+func (d *decompiler) unsignedOp(x value.Value, op string, y value.Value) Expr {
+	if _, ok := x.Type().(*irtypes.VectorType); ok {
+		panic(fmt.Errorf("support for vector operation %q not yet implemented", op))
+	}
+	return &BinaryExpr{X: d.unsigned(x), Op: op, Y: d.unsigned(y)}
+}
+
+// signedOp converts the given LLVM IR binary operation with signed semantics
+// (e.g. sdiv, srem, ashr) to a corresponding C expression. Operands of type i8
+// are cast to int8_t, as the signedness of char is implementation-defined.
+//
+//    (int8_t)x / (int8_t)y
+func (d *decompiler) signedOp(x value.Value, op string, y value.Value) Expr {
+	if _, ok := x.Type().(*irtypes.VectorType); ok {
+		panic(fmt.Errorf("support for vector operation %q not yet implemented", op))
+	}
+	return &BinaryExpr{X: d.signed(x), Op: op, Y: d.signed(y)}
+}
+
+// unsigned converts the given LLVM IR value to a corresponding C expression of
+// unsigned integer type. Values not of integer type, or of type i1, are
+// returned unconverted.
+func (d *decompiler) unsigned(v value.Value) Expr {
+	t, ok := v.Type().(*irtypes.IntType)
+	if !ok || t.BitSize == 1 {
+		return d.value(v)
+	}
+	return &CastExpr{Type: d.unsignedType(t), X: d.value(v)}
+}
+
+// signed converts the given LLVM IR value to a corresponding C expression of
+// signed integer type. Only values of type i8 are converted.
+func (d *decompiler) signed(v value.Value) Expr {
+	t, ok := v.Type().(*irtypes.IntType)
+	if !ok || t.BitSize != 8 {
+		return d.value(v)
+	}
+	return &CastExpr{Type: d.signedType(t), X: d.value(v)}
+}
+
+// intCmp converts the given LLVM IR integer comparison to a corresponding C
+// expression. Operands of integer type are cast to unsigned integer type for
+// unsigned predicates.
+//
+//    (uint32_t)x < (uint32_t)y
+func (d *decompiler) intCmp(pred enum.IPred, x, y value.Value) Expr {
+	var op string
+	switch pred {
+	case enum.IPredEQ:
+		op = "=="
+	case enum.IPredNE:
+		op = "!="
+	case enum.IPredUGT, enum.IPredSGT:
+		op = ">"
+	case enum.IPredUGE, enum.IPredSGE:
+		op = ">="
+	case enum.IPredULT, enum.IPredSLT:
+		op = "<"
+	case enum.IPredULE, enum.IPredSLE:
+		op = "<="
+	default:
+		panic(fmt.Errorf("support for integer predicate %v not yet implemented", pred))
+	}
+	switch pred {
+	case enum.IPredUGT, enum.IPredUGE, enum.IPredULT, enum.IPredULE:
+		return d.unsignedOp(x, op, y)
+	case enum.IPredSGT, enum.IPredSGE, enum.IPredSLT, enum.IPredSLE:
+		return d.signedOp(x, op, y)
+	}
+	return d.binaryOp(x, op, y)
+}
+
+// floatCmp converts the given LLVM IR floating-point comparison to a
+// corresponding C expression. C comparisons are ordered, except for !=, thus
+// unordered predicates are represented by the negation of the inverse ordered
+// comparison.
+//
+//    !(x >= y)
+func (d *decompiler) floatCmp(pred enum.FPred, x, y value.Value) Expr {
+	switch pred {
+	case enum.FPredFalse:
+		return d.boolLit(false)
+	case enum.FPredTrue:
+		return d.boolLit(true)
+	case enum.FPredOEQ:
+		return d.binaryOp(x, "==", y)
+	case enum.FPredOGT:
+		return d.binaryOp(x, ">", y)
+	case enum.FPredOGE:
+		return d.binaryOp(x, ">=", y)
+	case enum.FPredOLT:
+		return d.binaryOp(x, "<", y)
+	case enum.FPredOLE:
+		return d.binaryOp(x, "<=", y)
+	case enum.FPredONE:
+		return &BinaryExpr{X: d.binaryOp(x, "<", y), Op: "||", Y: d.binaryOp(x, ">", y)}
+	case enum.FPredORD:
+		return not(d.isNaN(x, y))
+	case enum.FPredUEQ:
+		return not(&BinaryExpr{X: d.binaryOp(x, "<", y), Op: "||", Y: d.binaryOp(x, ">", y)})
+	case enum.FPredUGT:
+		return not(d.binaryOp(x, "<=", y))
+	case enum.FPredUGE:
+		return not(d.binaryOp(x, "<", y))
+	case enum.FPredULT:
+		return not(d.binaryOp(x, ">=", y))
+	case enum.FPredULE:
+		return not(d.binaryOp(x, ">", y))
+	case enum.FPredUNE:
+		return d.binaryOp(x, "!=", y)
+	case enum.FPredUNO:
+		return d.isNaN(x, y)
+	default:
+		panic(fmt.Errorf("support for floating-point predicate %v not yet implemented", pred))
+	}
+}
+
+// isNaN returns a C expression reporting whether either of the given LLVM IR
+// values is NaN.
+//
+//    isnan(x) || isnan(y)
+func (d *decompiler) isNaN(x, y value.Value) Expr {
+	d.include("math.h")
+	return &BinaryExpr{
+		X:  call("isnan", d.value(x)),
+		Op: "||",
+		Y:  call("isnan", d.value(y)),
+	}
+}
+
+// not returns the logical negation of the given C expression.
+//
+//    !x
+func not(x Expr) Expr {
+	if u, ok := x.(*UnaryExpr); ok && u.Op == "!" {
+		return u.X
+	}
+	return &UnaryExpr{Op: "!", X: x}
+}
+
+// convert returns a C expression for converting the given LLVM IR value into
+// the specified type.
+//
+//    (int64_t)x
+func (d *decompiler) convert(from value.Value, to irtypes.Type) Expr {
+	if _, ok := to.(*irtypes.VectorType); ok {
+		panic(fmt.Errorf("support for vector conversion to %v not yet implemented", to))
+	}
+	return &CastExpr{Type: d.declare(to, ""), X: d.value(from)}
+}
+
+// trunc returns a C expression for truncating the given LLVM IR value into the
+// specified integer type. Truncation to i1 keeps the least significant bit.
+//
+//    (int8_t)x
+//    (bool)(x & 1)
+func (d *decompiler) trunc(from value.Value, to irtypes.Type) Expr {
+	if t, ok := to.(*irtypes.IntType); ok && t.BitSize == 1 {
+		x := &BinaryExpr{X: d.value(from), Op: "&", Y: &Ident{Name: "1"}}
+		return &CastExpr{Type: d.declare(to, ""), X: x}
+	}
+	return d.convert(from, to)
+}
+
+// zext returns a C expression for zero-extending the given LLVM IR value into
+// the specified integer type.
+//
+//    (int64_t)(uint32_t)x
+func (d *decompiler) zext(from value.Value, to irtypes.Type) Expr {
+	return &CastExpr{Type: d.declare(to, ""), X: d.unsigned(from)}
+}
+
+// sext returns a C expression for sign-extending the given LLVM IR value into
+// the specified integer type. Values of type i1 are extended to 0 or -1.
+//
+//    (int64_t)x
+//    -(int32_t)c
+func (d *decompiler) sext(from value.Value, to irtypes.Type) Expr {
+	if t, ok := from.Type().(*irtypes.IntType); ok && t.BitSize == 1 {
+		return &UnaryExpr{Op: "-", X: d.convert(from, to)}
+	}
+	return &CastExpr{Type: d.declare(to, ""), X: d.signed(from)}
+}
+
+// fptoui returns a C expression for converting the given LLVM IR
+// floating-point value into the specified integer type, interpreting the result
+// as unsigned.
+//
+//    (int32_t)(uint32_t)x
+func (d *decompiler) fptoui(from value.Value, to irtypes.Type) Expr {
+	t, ok := to.(*irtypes.IntType)
+	if !ok || t.BitSize == 1 {
+		return d.convert(from, to)
+	}
+	x := &CastExpr{Type: d.unsignedType(t), X: d.value(from)}
+	return &CastExpr{Type: d.declare(to, ""), X: x}
+}
+
+// uitofp returns a C expression for converting the given LLVM IR integer value
+// into the specified floating-point type, interpreting the value as unsigned.
+//
+//    (double)(uint32_t)x
+func (d *decompiler) uitofp(from value.Value, to irtypes.Type) Expr {
+	return &CastExpr{Type: d.declare(to, ""), X: d.unsigned(from)}
+}
+
+// bitcast returns a C expression for reinterpreting the bits of the given LLVM
+// IR value as the specified type. Pointers are cast, and other values are
+// reinterpreted through a union.
+//
+//    (int32_t *)p
+//    ((union { float from; int32_t to; }){x}).to
+func (d *decompiler) bitcast(from value.Value, to irtypes.Type) Expr {
+	if _, ok := to.(*irtypes.PointerType); ok {
+		return d.convert(from, to)
+	}
+	u := fmt.Sprintf("union { %s; %s; }", d.declare(from.Type(), "from"), d.declare(to, "to"))
+	return &MemberExpr{
+		X:    &CompositeLit{Type: u, Elts: []Expr{d.value(from)}},
+		Name: "to",
+	}
+}
@@ -0,0 +1,183 @@
+package cgen
+
+import (
+	"github.com/decomp/decomp/internal/lower"
+	"github.com/llir/llvm/ir"
+)
+
+// libcHeaders maps from C standard library function name to the system header
+// declaring the function. Declarations of these functions are replaced by
+// includes of their headers.
+var libcHeaders = map[string]string{
+	// ctype.h
+	"isalnum": "ctype.h",
+	"isalpha": "ctype.h",
+	"isdigit": "ctype.h",
+	"islower": "ctype.h",
+	"isspace": "ctype.h",
+	"isupper": "ctype.h",
+	"tolower": "ctype.h",
+	"toupper": "ctype.h",
+	// math.h
+	"ceil":  "math.h",
+	"cos":   "math.h",
+	"exp":   "math.h",
+	"fabs":  "math.h",
+	"floor": "math.h",
+	"fmod":  "math.h",
+	"log":   "math.h",
+	"pow":   "math.h",
+	"sin":   "math.h",
+	"sqrt":  "math.h",
+	// stdio.h
+	"fclose":   "stdio.h",
+	"fgets":    "stdio.h",
+	"fopen":    "stdio.h",
+	"fprintf":  "stdio.h",
+	"fputs":    "stdio.h",
+	"fread":    "stdio.h",
+	"fwrite":   "stdio.h",
+	"getchar":  "stdio.h",
+	"printf":   "stdio.h",
+	"putchar":  "stdio.h",
+	"puts":     "stdio.h",
+	"scanf":    "stdio.h",
+	"snprintf": "stdio.h",
+	"sprintf":  "stdio.h",
+	// stdlib.h
+	"abort":   "stdlib.h",
+	"abs":     "stdlib.h",
+	"atoi":    "stdlib.h",
+	"atol":    "stdlib.h",
+	"calloc":  "stdlib.h",
+	"exit":    "stdlib.h",
+	"free":    "stdlib.h",
+	"getenv":  "stdlib.h",
+	"malloc":  "stdlib.h",
+	"qsort":   "stdlib.h",
+	"rand":    "stdlib.h",
+	"realloc": "stdlib.h",
+	"srand":   "stdlib.h",
+	"strtol":  "stdlib.h",
+	// string.h
+	"memcmp":  "string.h",
+	"memcpy":  "string.h",
+	"memmove": "string.h",
+	"memset":  "string.h",
+	"strcat":  "string.h",
+	"strchr":  "string.h",
+	"strcmp":  "string.h",
+	"strcpy":  "string.h",
+	"strdup":  "string.h",
+	"strlen":  "string.h",
+	"strncmp": "string.h",
+	"strncpy": "string.h",
+	"strrchr": "string.h",
+	"strstr":  "string.h",
+	// time.h
+	"time": "time.h",
+}
+
+// libcNames is the set of identifiers, other than the functions of libcHeaders
+// and intrinsics, declared by the C standard library headers included in
+// decompiled C source files.
+var libcNames = map[string]bool{
+	// ctype.h
+	"iscntrl": true, "isgraph": true, "isprint": true, "ispunct": true,
+	"isxdigit": true,
+	// math.h
+	"HUGE_VAL": true, "INFINITY": true, "NAN": true, "acos": true,
+	"asin": true, "atan": true, "atan2": true, "cbrt": true, "cosh": true,
+	"exp2": true, "fmax": true, "fmin": true, "frexp": true, "hypot": true,
+	"isfinite": true, "isinf": true, "isnan": true, "ldexp": true, "log10": true,
+	"log2": true, "modf": true, "nan": true, "nanf": true, "round": true,
+	"sinh": true, "tan": true, "tanh": true, "trunc": true,
+	// stddef.h
+	"NULL": true, "offsetof": true, "ptrdiff_t": true, "size_t": true,
+	// stdint.h
+	"int8_t": true, "int16_t": true, "int32_t": true, "int64_t": true,
+	"uint8_t": true, "uint16_t": true, "uint32_t": true, "uint64_t": true,
+	"intptr_t": true, "uintptr_t": true,
+	// stdio.h
+	"EOF": true, "FILE": true, "fflush": true, "fgetc": true, "fputc": true,
+	"fscanf": true, "getc": true, "perror": true, "putc": true, "remove": true,
+	"rename": true, "sscanf": true, "stderr": true, "stdin": true,
+	"stdout": true,
+	// stdlib.h
+	"atof": true, "bsearch": true, "div": true, "labs": true, "strtod": true,
+	"strtoul": true, "system": true,
+	// string.h
+	"memchr": true, "strcspn": true, "strerror": true, "strpbrk": true,
+	"strspn": true, "strtok": true,
+	// time.h
+	"clock": true, "clock_t": true, "difftime": true, "mktime": true,
+	"time_t": true,
+}
+
+// libcReserved reports whether the given identifier is reserved by the C
+// standard library headers included in decompiled C source files.
+func libcReserved(name string) bool {
+	if _, ok := libcHeaders[name]; ok {
+		return true
+	}
+	for _, b := range intrinsics {
+		if b != nil && b.header != "" && b.name == name {
+			return true
+		}
+	}
+	return libcNames[name]
+}
+
+// A builtin is a C function replacing calls to an LLVM intrinsic function.
+type builtin struct {
+	// Function name.
+	name string
+	// System header declaring the function; or empty if a compiler builtin.
+	header string
+	// Number of leading arguments of the intrinsic passed to the function.
+	nargs int
+}
+
+// intrinsics maps from LLVM intrinsic function name, excluding overloaded type
+// suffixes, to the C function replacing its calls; or nil if calls are omitted
+// (e.g. debug information).
+var intrinsics = map[string]*builtin{
+	"llvm.assume":         nil,
+	"llvm.dbg.declare":    nil,
+	"llvm.dbg.label":      nil,
+	"llvm.dbg.value":      nil,
+	"llvm.lifetime.end":   nil,
+	"llvm.lifetime.start": nil,
+	"llvm.fabs":           {name: "fabs", header: "math.h", nargs: 1},
+	"llvm.memcpy":         {name: "memcpy", header: "string.h", nargs: 3},
+	"llvm.memmove":        {name: "memmove", header: "string.h", nargs: 3},
+	"llvm.memset":         {name: "memset", header: "string.h", nargs: 3},
+	"llvm.sqrt":           {name: "sqrt", header: "math.h", nargs: 1},
+	"llvm.trap":           {name: "__builtin_trap"},
+}
+
+// intrinsic returns the C function replacing calls to the given LLVM intrinsic
+// function; or nil if calls are omitted. The boolean return value indicates
+// whether the function is a known intrinsic.
+//
+//    llvm.memcpy.p0i8.p0i8.i64
+func intrinsic(name string) (*builtin, bool) {
+	name, ok := lower.Intrinsic(name, func(name string) bool {
+		_, ok := intrinsics[name]
+		return ok
+	})
+	if !ok {
+		return nil, false
+	}
+	return intrinsics[name], true
+}
+
+// libcHeader returns the system header declaring the given function, if it is
+// an external C standard library function. The boolean return value indicates
+// success.
+func libcHeader(f *ir.Func) (string, bool) {
+	if !lower.Libc(f, func(name string) bool { _, ok := libcHeaders[name]; return ok }) {
+		return "", false
+	}
+	return libcHeaders[f.Name()], true
+}
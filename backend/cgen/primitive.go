@@ -0,0 +1,271 @@
+package cgen
+
+import (
+	"fmt"
+
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/decomp/decomp/internal/lower"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/pkg/errors"
+)
+
+// prim merges the basic blocks of the given primitive into a corresponding
+// conceptual basic block for the primitive.
+func (d *decompiler) prim(prim *primitive.Primitive) (*basicBlock, error) {
+	var roles []string
+	switch prim.Prim {
+	case "if", "if_return", "pre_loop":
+		roles = []string{"cond", "body", "exit"}
+	case "if_else":
+		roles = []string{"cond", "body_true", "body_false", "exit"}
+	case "post_loop":
+		roles = []string{"cond", "exit"}
+	case "seq":
+		roles = []string{"entry", "exit"}
+	default:
+		panic(fmt.Errorf("support for primitive %q not yet implemented", prim.Prim))
+	}
+	var blocks []*basicBlock
+	for _, role := range roles {
+		name := prim.Nodes[role]
+		block, ok := d.blocks[name]
+		if !ok {
+			return nil, errors.Errorf("unable to locate %s basic block %q", role, name)
+		}
+		blocks = append(blocks, block)
+	}
+	var block *basicBlock
+	var err error
+	switch prim.Prim {
+	case "if":
+		block, err = d.primIf(blocks[0], blocks[1], blocks[2])
+	case "if_else":
+		block, err = d.primIfElse(blocks[0], blocks[1], blocks[2], blocks[3])
+	case "if_return":
+		block, err = d.primIfReturn(blocks[0], blocks[1], blocks[2])
+	case "pre_loop":
+		block, err = d.primPreLoop(blocks[0], blocks[1], blocks[2])
+	case "post_loop":
+		block, err = d.primPostLoop(blocks[0], blocks[1])
+	case "seq":
+		block, err = d.primSeq(blocks[0], blocks[1])
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	block.LocalIdent = ir.NewLocalIdent(prim.Entry)
+	block.num = blocks[0].num
+	return block, nil
+}
+
+// condBr returns the C condition of the given conditional branch terminator,
+// negated if the specified target is the false branch.
+func (d *decompiler) condBr(condBlock, target *basicBlock) (Expr, error) {
+	term, ok := condBlock.Term.(*ir.TermCondBr)
+	if !ok {
+		return nil, errors.Errorf("invalid cond terminator type; expected *ir.TermCondBr, got %T", condBlock.Term)
+	}
+	cond := d.value(term.Cond)
+	if name, _ := lower.TrueTarget(term); name != target.Name() {
+		cond = not(cond)
+	}
+	return cond, nil
+}
+
+// primIf merges the basic blocks of the given if-primitive into a corresponding
+// conceptual basic block for the primitive.
+//
+//    if (cond) {
+//       body;
+//    }
+//    exit;
+func (d *decompiler) primIf(condBlock, bodyBlock, exitBlock *basicBlock) (*basicBlock, error) {
+	// Handle terminators.
+	cond, err := d.condBr(condBlock, bodyBlock)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if _, ok := bodyBlock.Term.(*ir.TermBr); !ok {
+		return nil, errors.Errorf("invalid body terminator type; expected *ir.TermBr, got %T", bodyBlock.Term)
+	}
+	block := &basicBlock{Block: &ir.Block{}}
+	block.Term = exitBlock.Term
+	// Handle instructions.
+	block.stmts = append(block.stmts, d.stmts(condBlock)...)
+	ifStmt := &IfStmt{
+		Cond: cond,
+		Body: &BlockStmt{List: d.stmts(bodyBlock)},
+	}
+	block.stmts = append(block.stmts, ifStmt)
+	block.stmts = append(block.stmts, d.stmts(exitBlock)...)
+	return block, nil
+}
+
+// primIfElse merges the basic blocks of the given if_else-primitive into a
+// corresponding conceptual basic block for the primitive.
+//
+//    if (cond) {
+//       body_true;
+//    } else {
+//       body_false;
+//    }
+//    exit;
+func (d *decompiler) primIfElse(condBlock, bodyTrueBlock, bodyFalseBlock, exitBlock *basicBlock) (*basicBlock, error) {
+	// Handle terminators.
+	var cond Expr
+	switch condTerm := condBlock.Term.(type) {
+	case *ir.TermCondBr:
+		cond = d.value(condTerm.Cond)
+	case *ir.TermSwitch:
+		cases := condTerm.Cases
+		if len(cases) != 1 {
+			return nil, errors.Errorf("invalid number of switch cases in if_else primitive; expected 1, got %d", len(cases))
+		}
+		cond = &BinaryExpr{
+			X:  d.value(condTerm.X),
+			Op: "==",
+			Y:  d.constant(cases[0].X.(constant.Constant)),
+		}
+	default:
+		return nil, errors.Errorf("invalid cond terminator type; expected *ir.TermCondBr, got %T", condBlock.Term)
+	}
+	if name, _ := lower.TrueTarget(condBlock.Term); name != bodyTrueBlock.Name() {
+		bodyTrueBlock, bodyFalseBlock = bodyFalseBlock, bodyTrueBlock
+	}
+	if _, ok := bodyTrueBlock.Term.(*ir.TermBr); !ok {
+		return nil, errors.Errorf("invalid body_true terminator type; expected *ir.TermBr, got %T", bodyTrueBlock.Term)
+	}
+	if _, ok := bodyFalseBlock.Term.(*ir.TermBr); !ok {
+		return nil, errors.Errorf("invalid body_false terminator type; expected *ir.TermBr, got %T", bodyFalseBlock.Term)
+	}
+	block := &basicBlock{Block: &ir.Block{}}
+	block.Term = exitBlock.Term
+	// Handle instructions.
+	block.stmts = append(block.stmts, d.stmts(condBlock)...)
+	ifElseStmt := &IfStmt{
+		Cond: cond,
+		Body: &BlockStmt{List: d.stmts(bodyTrueBlock)},
+		Else: &BlockStmt{List: d.stmts(bodyFalseBlock)},
+	}
+	block.stmts = append(block.stmts, ifElseStmt)
+	block.stmts = append(block.stmts, d.stmts(exitBlock)...)
+	return block, nil
+}
+
+// primIfReturn merges the basic blocks of the given if_return-primitive into a
+// corresponding conceptual basic block for the primitive.
+//
+//    if (cond) {
+//       body;
+//       return x;
+//    }
+//    exit;
+func (d *decompiler) primIfReturn(condBlock, bodyBlock, exitBlock *basicBlock) (*basicBlock, error) {
+	// Handle terminators.
+	cond, err := d.condBr(condBlock, bodyBlock)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	block := &basicBlock{Block: &ir.Block{}}
+	block.Term = exitBlock.Term
+	// Handle instructions.
+	block.stmts = append(block.stmts, d.stmts(condBlock)...)
+	body := &BlockStmt{List: d.stmts(bodyBlock)}
+	body.List = append(body.List, d.term(bodyBlock.Term))
+	ifReturnStmt := &IfStmt{
+		Cond: cond,
+		Body: body,
+	}
+	block.stmts = append(block.stmts, ifReturnStmt)
+	block.stmts = append(block.stmts, d.stmts(exitBlock)...)
+	return block, nil
+}
+
+// primPreLoop merges the basic blocks of the given pre_loop-primitive into a
+// corresponding conceptual basic block for the primitive. The statements of the
+// cond basic block are evaluated at each iteration, before the loop condition.
+//
+//    while (cond) {
+//       body;
+//    }
+//    exit;
+//
+//    while (true) {
+//       cond_stmts;
+//       if (!cond) {
+//          break;
+//       }
+//       body;
+//    }
+//    exit;
+func (d *decompiler) primPreLoop(condBlock, bodyBlock, exitBlock *basicBlock) (*basicBlock, error) {
+	// Handle terminators.
+	cond, err := d.condBr(condBlock, bodyBlock)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if _, ok := bodyBlock.Term.(*ir.TermBr); !ok {
+		return nil, errors.Errorf("invalid body terminator type; expected *ir.TermBr, got %T", bodyBlock.Term)
+	}
+	block := &basicBlock{Block: &ir.Block{}}
+	block.Term = exitBlock.Term
+	// Handle instructions.
+	condStmts := d.stmts(condBlock)
+	body := &BlockStmt{List: d.stmts(bodyBlock)}
+	whileStmt := &WhileStmt{
+		Cond: cond,
+		Body: body,
+	}
+	if len(condStmts) > 0 {
+		ifBreakStmt := &IfStmt{
+			Cond: not(cond),
+			Body: &BlockStmt{List: []Stmt{&BreakStmt{}}},
+		}
+		whileStmt.Cond = d.boolLit(true)
+		body.List = append(append(condStmts, ifBreakStmt), body.List...)
+	}
+	block.stmts = append(block.stmts, whileStmt)
+	block.stmts = append(block.stmts, d.stmts(exitBlock)...)
+	return block, nil
+}
+
+// primPostLoop merges the basic blocks of the given post_loop-primitive into a
+// corresponding conceptual basic block for the primitive.
+//
+//    do {
+//       cond_stmts;
+//    } while (cond);
+//    exit;
+func (d *decompiler) primPostLoop(condBlock, exitBlock *basicBlock) (*basicBlock, error) {
+	// Handle terminators.
+	cond, err := d.condBr(condBlock, condBlock)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	block := &basicBlock{Block: &ir.Block{}}
+	block.Term = exitBlock.Term
+	// Handle instructions.
+	doWhileStmt := &DoWhileStmt{
+		Body: &BlockStmt{List: d.stmts(condBlock)},
+		Cond: cond,
+	}
+	block.stmts = append(block.stmts, doWhileStmt)
+	block.stmts = append(block.stmts, d.stmts(exitBlock)...)
+	return block, nil
+}
+
+// primSeq merges the basic blocks of the given seq-primitive into a
+// corresponding conceptual basic block for the primitive.
+func (d *decompiler) primSeq(entryBlock, exitBlock *basicBlock) (*basicBlock, error) {
+	// Handle terminators.
+	if _, ok := entryBlock.Term.(*ir.TermBr); !ok {
+		return nil, errors.Errorf("invalid entry terminator type; expected *ir.TermBr, got %T", entryBlock.Term)
+	}
+	block := &basicBlock{Block: &ir.Block{}}
+	block.Term = exitBlock.Term
+	// Handle instructions.
+	block.stmts = append(block.stmts, d.stmts(entryBlock)...)
+	block.stmts = append(block.stmts, d.stmts(exitBlock)...)
+	return block, nil
+}
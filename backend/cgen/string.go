@@ -0,0 +1,74 @@
+package cgen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/decomp/decomp/internal/lower"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+// stringGlobal returns the contents of the given LLVM IR value, if it is a
+// global constant of character array type holding a C string (see
+// lower.StringGlobal). The boolean return value indicates success.
+//
+// Only globals local to the module are recognized, as their uses may be
+// replaced by C string literals without changing the semantics of other
+// modules.
+func stringGlobal(v value.Value) (*ir.Global, string, bool) {
+	g, s, ok := lower.StringGlobal(v)
+	if !ok || !isInternal(g.Linkage) {
+		return nil, "", false
+	}
+	return g, s, true
+}
+
+// stringAddr returns a C string literal of the contents of the string global
+// referred to by the given getelementptr operands, if the address of its first
+// character. The boolean return value indicates success.
+//
+//    getelementptr ([4 x i8], [4 x i8]* @.str, i64 0, i64 0)
+//    "foo"
+func stringAddr(src value.Value, indices []value.Value) (Expr, bool) {
+	_, s, ok := stringGlobal(src)
+	if !ok {
+		return nil, false
+	}
+	if index, ok := lower.CharIndex(indices); !ok || !lower.IsZero(index) {
+		return nil, false
+	}
+	return stringLit(s), true
+}
+
+// stringLit returns a C string literal of the given string. Non-printable
+// characters are represented by octal escape sequences, as hexadecimal escape
+// sequences are terminated only by non-hexadecimal characters.
+//
+//    "foo\n"
+func stringLit(s string) Expr {
+	buf := &strings.Builder{}
+	buf.WriteString(`"`)
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		switch b {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(b)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			if b < ' ' || b >= 0x7F {
+				fmt.Fprintf(buf, `\%03o`, b)
+				continue
+			}
+			buf.WriteByte(b)
+		}
+	}
+	buf.WriteString(`"`)
+	return &Ident{Name: buf.String()}
+}
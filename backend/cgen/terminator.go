@@ -0,0 +1,67 @@
+package cgen
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/value"
+)
+
+// term converts the given LLVM IR terminator to a corresponding C statement.
+// Branches are converted to goto-statements, as used for unstructured control
+// flow.
+func (d *decompiler) term(term ir.Terminator) Stmt {
+	switch term := term.(type) {
+	case *ir.TermRet:
+		if term.X == nil {
+			return &ReturnStmt{}
+		}
+		return &ReturnStmt{X: d.value(term.X)}
+	case *ir.TermBr:
+		return d.gotoStmt(term.Target)
+	case *ir.TermCondBr:
+		return &IfStmt{
+			Cond: d.value(term.Cond),
+			Body: &BlockStmt{List: []Stmt{d.gotoStmt(term.TargetTrue)}},
+			Else: &BlockStmt{List: []Stmt{d.gotoStmt(term.TargetFalse)}},
+		}
+	case *ir.TermSwitch:
+		switchStmt := &SwitchStmt{Tag: d.value(term.X)}
+		for _, c := range term.Cases {
+			switchStmt.Cases = append(switchStmt.Cases, &CaseClause{
+				X:    d.constant(c.X.(constant.Constant)),
+				Body: []Stmt{d.gotoStmt(c.Target)},
+			})
+		}
+		switchStmt.Cases = append(switchStmt.Cases, &CaseClause{
+			Body: []Stmt{d.gotoStmt(term.TargetDefault)},
+		})
+		return switchStmt
+	case *ir.TermIndirectBr:
+		// Indirect branches use the labels as values extension of GNU C.
+		for _, target := range term.ValidTargets {
+			d.labels[blockName(target)] = true
+		}
+		return &GotoStmt{Label: deref(d.value(term.Addr)).String()}
+	case *ir.TermUnreachable:
+		return &ExprStmt{X: call("__builtin_unreachable")}
+	default:
+		panic(fmt.Errorf("support for terminator %T not yet implemented", term))
+	}
+}
+
+// gotoStmt returns a goto-statement to the given target basic block, and
+// records the use of its label.
+//
+//    goto block_1;
+func (d *decompiler) gotoStmt(target value.Value) Stmt {
+	name := blockName(target)
+	d.labels[name] = true
+	return &GotoStmt{Label: d.label(name)}
+}
+
+// blockName returns the label of the given LLVM IR basic block.
+func blockName(block value.Value) string {
+	return block.(value.Named).Name()
+}
@@ -0,0 +1,314 @@
+package cgen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	irtypes "github.com/llir/llvm/ir/types"
+)
+
+// declare returns the C declaration of the given name with the specified LLVM
+// IR type; or the C type name if name is empty.
+//
+//    int32_t x
+//    int32_t (*f)(int32_t a, char *b)
+//    char s[6]
+func (d *decompiler) declare(t irtypes.Type, name string) string {
+	if len(t.Name()) > 0 {
+		return join(d.typeName(t), name)
+	}
+	switch t := t.(type) {
+	case *irtypes.PointerType:
+		decl := "*" + name
+		switch t.ElemType.(type) {
+		case *irtypes.ArrayType, *irtypes.FuncType:
+			if len(t.ElemType.Name()) == 0 {
+				decl = "(" + decl + ")"
+			}
+		}
+		return d.declare(t.ElemType, decl)
+	case *irtypes.ArrayType:
+		return d.declare(t.ElemType, fmt.Sprintf("%s[%d]", name, t.Len))
+	case *irtypes.FuncType:
+		var params []string
+		for _, param := range t.Params {
+			params = append(params, d.declare(param, ""))
+		}
+		if t.Variadic {
+			params = append(params, "...")
+		}
+		if len(params) == 0 {
+			params = []string{"void"}
+		}
+		return d.declare(t.RetType, fmt.Sprintf("%s(%s)", name, strings.Join(params, ", ")))
+	default:
+		return join(d.typeName(t), name)
+	}
+}
+
+// join joins the given C type name and declarator.
+func join(typeName, decl string) string {
+	switch {
+	case len(decl) == 0:
+		return typeName
+	case strings.HasPrefix(decl, "*") && strings.HasSuffix(typeName, "*"):
+		return typeName + decl
+	}
+	return typeName + " " + decl
+}
+
+// typeName returns the C type name of the given LLVM IR type, excluding
+// pointer, array and function types.
+func (d *decompiler) typeName(t irtypes.Type) string {
+	if name := t.Name(); len(name) > 0 {
+		if _, ok := t.(*irtypes.StructType); ok {
+			return "struct " + d.typeIdent(name)
+		}
+		return d.typeIdent(name)
+	}
+	switch t := t.(type) {
+	case *irtypes.VoidType:
+		return "void"
+	case *irtypes.IntType:
+		return d.intType(t.BitSize, true)
+	case *irtypes.FloatType:
+		switch t.Kind {
+		case irtypes.FloatKindHalf, irtypes.FloatKindFloat:
+			return "float"
+		case irtypes.FloatKindDouble:
+			return "double"
+		default:
+			return "long double"
+		}
+	case *irtypes.StructType:
+		// Name literal struct types, as structurally identical anonymous structs
+		// are distinct types in C.
+		return "struct " + d.structName(t)
+	case *irtypes.PointerType, *irtypes.ArrayType, *irtypes.FuncType:
+		return d.declare(t, "")
+	default:
+		panic(fmt.Errorf("support for type %T not yet implemented", t))
+	}
+}
+
+// intType returns the C integer type of the given bit size. The i1 type is
+// represented as bool, and the i8 type as char when signed.
+func (d *decompiler) intType(bitSize uint64, signed bool) string {
+	if bitSize == 1 {
+		d.include("stdbool.h")
+		return "bool"
+	}
+	if bitSize == 8 && signed {
+		return "char"
+	}
+	d.include("stdint.h")
+	prefix := "int"
+	if !signed {
+		prefix = "uint"
+	}
+	switch {
+	case bitSize <= 8:
+		return prefix + "8_t"
+	case bitSize <= 16:
+		return prefix + "16_t"
+	case bitSize <= 32:
+		return prefix + "32_t"
+	case bitSize <= 64:
+		return prefix + "64_t"
+	case bitSize <= 128:
+		if signed {
+			return "__int128"
+		}
+		return "unsigned __int128"
+	default:
+		panic(fmt.Errorf("support for integer type with bit size %d not yet implemented", bitSize))
+	}
+}
+
+// signedType returns the signed C integer type of the given LLVM IR integer
+// type, as used for signed operations.
+func (d *decompiler) signedType(t *irtypes.IntType) string {
+	if t.BitSize == 8 {
+		d.include("stdint.h")
+		return "int8_t"
+	}
+	return d.intType(t.BitSize, true)
+}
+
+// unsignedType returns the unsigned C integer type of the given LLVM IR integer
+// type, as used for unsigned operations.
+func (d *decompiler) unsignedType(t *irtypes.IntType) string {
+	return d.intType(t.BitSize, false)
+}
+
+// structName returns the name of the given literal struct type.
+func (d *decompiler) structName(t *irtypes.StructType) string {
+	key := t.LLString()
+	if name, ok := d.structNames[key]; ok {
+		return name
+	}
+	name := fmt.Sprintf("struct_%d", len(d.structNames))
+	d.structNames[key] = name
+	d.structs = append(d.structs, &structDef{name: name, typ: t})
+	return name
+}
+
+// A structDef is a C struct definition.
+type structDef struct {
+	// Struct name.
+	name string
+	// Underlying struct type.
+	typ *irtypes.StructType
+}
+
+// typeDefs returns the C type declarations of the given LLVM IR type
+// definitions and of the literal struct types used by the decompiled functions.
+// Structs are declared ahead of their definitions, which are ordered such that
+// structs are defined before being embedded by value.
+//
+//    struct foo;
+//    typedef int32_t bar;
+//    struct foo {
+//       int32_t field_0;
+//       struct foo *field_1;
+//    };
+func (d *decompiler) typeDefs(typeDefs []irtypes.Type) []string {
+	var decls []string
+	var structs []*structDef
+	for _, t := range typeDefs {
+		switch u := t.(type) {
+		case *irtypes.StructType:
+			name := d.typeIdent(t.Name())
+			decls = append(decls, fmt.Sprintf("struct %s;", name))
+			if !u.Opaque {
+				structs = append(structs, &structDef{name: name, typ: u})
+			}
+		default:
+			// Declare the underlying type of non-struct type definitions.
+			decls = append(decls, fmt.Sprintf("typedef %s;", d.declare(underlying(t), d.typeIdent(t.Name()))))
+		}
+	}
+	// Field types of struct definitions may add literal struct types.
+	var defs []string
+	done := make(map[string]bool)
+	var define func(def *structDef)
+	define = func(def *structDef) {
+		if done[def.name] {
+			return
+		}
+		done[def.name] = true
+		// Define structs embedded by value first.
+		for _, field := range def.typ.Fields {
+			for _, dep := range d.embedded(field) {
+				define(dep)
+			}
+		}
+		defs = append(defs, d.structDef(def))
+	}
+	for _, def := range structs {
+		define(def)
+	}
+	for i := 0; i < len(d.structs); i++ {
+		def := d.structs[i]
+		if !done[def.name] {
+			decls = append(decls, fmt.Sprintf("struct %s;", def.name))
+		}
+		define(def)
+	}
+	return append(decls, defs...)
+}
+
+// underlying returns the underlying type of the given named LLVM IR type.
+func underlying(t irtypes.Type) irtypes.Type {
+	switch t := t.(type) {
+	case *irtypes.IntType:
+		u := *t
+		u.TypeName = ""
+		return &u
+	case *irtypes.FloatType:
+		u := *t
+		u.TypeName = ""
+		return &u
+	case *irtypes.PointerType:
+		u := *t
+		u.TypeName = ""
+		return &u
+	case *irtypes.ArrayType:
+		u := *t
+		u.TypeName = ""
+		return &u
+	case *irtypes.FuncType:
+		u := *t
+		u.TypeName = ""
+		return &u
+	case *irtypes.VoidType:
+		u := *t
+		u.TypeName = ""
+		return &u
+	default:
+		panic(fmt.Errorf("support for type definition %T not yet implemented", t))
+	}
+}
+
+// embedded returns the struct definitions embedded by value in the given LLVM
+// IR type.
+func (d *decompiler) embedded(t irtypes.Type) []*structDef {
+	switch t := t.(type) {
+	case *irtypes.StructType:
+		if t.Opaque {
+			return nil
+		}
+		if len(t.Name()) > 0 {
+			return []*structDef{{name: d.typeIdent(t.Name()), typ: t}}
+		}
+		name := d.structName(t)
+		for _, def := range d.structs {
+			if def.name == name {
+				return []*structDef{def}
+			}
+		}
+		return nil
+	case *irtypes.ArrayType:
+		return d.embedded(t.ElemType)
+	case *irtypes.VectorType:
+		return d.embedded(t.ElemType)
+	default:
+		return nil
+	}
+}
+
+// structDef returns the C definition of the given struct.
+func (d *decompiler) structDef(def *structDef) string {
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "struct %s {\n", def.name)
+	for i, field := range def.typ.Fields {
+		fmt.Fprintf(buf, "\t%s;\n", d.declare(field, fieldName(i)))
+	}
+	buf.WriteString("}")
+	if def.typ.Packed {
+		buf.WriteString(" __attribute__((packed))")
+	}
+	buf.WriteString(";")
+	return buf.String()
+}
+
+// fieldName returns the name of the i:th field of a struct.
+func fieldName(i int) string {
+	return fmt.Sprintf("field_%d", i)
+}
+
+// include records the use of the given system header.
+func (d *decompiler) include(header string) {
+	d.includes[header] = true
+}
+
+// sortedIncludes returns the sorted list of used system headers.
+func (d *decompiler) sortedIncludes() []string {
+	var includes []string
+	for include := range d.includes {
+		includes = append(includes, include)
+	}
+	sort.Strings(includes)
+	return includes
+}
@@ -0,0 +1,404 @@
+package gogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	irtypes "github.com/llir/llvm/ir/types"
+)
+
+// Import paths of the runtime support packages for atomic operations and
+// variadic arguments.
+const (
+	atomicsPath = "github.com/decomp/decomp/rt/atomics"
+	vaPath      = "github.com/decomp/decomp/rt/va"
+)
+
+// vaArgsName is the name of the variadic parameter of Go functions decompiled
+// from variadic LLVM IR functions.
+const vaArgsName = "_va"
+
+// atomicSuffix returns the suffix of the sync/atomic and atomics functions
+// operating on values of the given LLVM IR type (e.g. "Int32" of
+// atomic.AddInt32, "Float64" of atomics.LoadFloat64). The boolean return value
+// is false if the type is not of 32 or 64 bits, in which case atomic operations
+// are performed while holding the global lock of atomics.Do. Every width is
+// thus routed through either sync/atomic or the global lock, never both.
+func atomicSuffix(t irtypes.Type) (string, bool) {
+	switch t := t.(type) {
+	case *irtypes.IntType:
+		switch t.BitSize {
+		case 32:
+			return "Int32", true
+		case 64:
+			return "Int64", true
+		}
+	case *irtypes.FloatType:
+		switch t.Kind {
+		case irtypes.FloatKindFloat:
+			return "Float32", true
+		case irtypes.FloatKindDouble:
+			return "Float64", true
+		}
+	case *irtypes.PointerType:
+		return "Pointer", true
+	}
+	return "", false
+}
+
+// atomicPkg returns the import path of the package of the atomic operations on
+// values of the given atomicSuffix; sync/atomic for integers and atomics
+// otherwise.
+func atomicPkg(suffix string) string {
+	switch suffix {
+	case "Int32", "Int64":
+		return "sync/atomic"
+	}
+	return atomicsPath
+}
+
+// unsafePointer returns a Go expression converting the given pointer to
+// unsafe.Pointer.
+func (d *decompiler) unsafePointer(p ast.Expr) ast.Expr {
+	return call(d.importSel("unsafe", "Pointer"), p)
+}
+
+// atomicLoad converts the given LLVM IR atomic load instruction to a
+// corresponding Go statement. The memory ordering is ignored, as sync/atomic
+// operations are sequentially consistent.
+//
+//    _2 = atomic.LoadInt32(_1)
+//    _2 = (*int8)(atomics.LoadPointer(unsafe.Pointer(_1)))
+//    atomics.Do(func() { _2 = *_1 })
+func (d *decompiler) atomicLoad(inst *ir.InstLoad) ast.Stmt {
+	src := d.value(inst.Src)
+	if suffix, ok := atomicSuffix(inst.ElemType); ok {
+		if suffix == "Pointer" {
+			load := call(d.importSel(atomicsPath, "LoadPointer"), d.unsafePointer(src))
+			return d.assign(inst.Name(), call(&ast.ParenExpr{X: d.goType(inst.ElemType)}, load))
+		}
+		return d.assign(inst.Name(), call(d.importSel(atomicPkg(suffix), "Load"+suffix), src))
+	}
+	// Fallback for types not supported by sync/atomic.
+	return d.atomicDo([]ast.Stmt{d.assign(inst.Name(), &ast.StarExpr{X: src})})
+}
+
+// atomicStore converts the given LLVM IR atomic store instruction to a
+// corresponding Go statement. The memory ordering is ignored, as sync/atomic
+// operations are sequentially consistent.
+//
+//    atomic.StoreInt32(_1, 42)
+//    atomics.StorePointer(unsafe.Pointer(_1), unsafe.Pointer(_2))
+//    atomics.Do(func() { *_1 = 42 })
+func (d *decompiler) atomicStore(inst *ir.InstStore) ast.Stmt {
+	dst := d.value(inst.Dst)
+	src := d.value(inst.Src)
+	if suffix, ok := atomicSuffix(inst.Src.Type()); ok {
+		if suffix == "Pointer" {
+			dst, src = d.unsafePointer(dst), d.unsafePointer(src)
+		}
+		return &ast.ExprStmt{
+			X: call(d.importSel(atomicPkg(suffix), "Store"+suffix), dst, src),
+		}
+	}
+	// Fallback for types not supported by sync/atomic.
+	return d.atomicDo([]ast.Stmt{assignExpr(&ast.StarExpr{X: dst}, src)})
+}
+
+// instCmpXchg converts the given LLVM IR cmpxchg instruction to a corresponding
+// list of Go statements. The memory ordering is ignored, as sync/atomic
+// operations are sequentially consistent.
+//
+//    var _3 struct{ field_0 int32; field_1 bool }
+//    _3.field_0, _3.field_1 = atomics.CompareAndSwapInt32(_1, _2, 42)
+//    _3.field_1 = atomics.CompareAndSwapPointer(unsafe.Pointer(_1), unsafe.Pointer(_2), unsafe.Pointer(_4), unsafe.Pointer(&_3.field_0))
+func (d *decompiler) instCmpXchg(inst *ir.InstCmpXchg) []ast.Stmt {
+	t := inst.Cmp.Type()
+	decl := d.varDecl(inst.Name(), d.flagStructType(t))
+	old := &ast.SelectorExpr{X: d.localIdent(inst.Name()), Sel: ast.NewIdent("field_0")}
+	success := &ast.SelectorExpr{X: d.localIdent(inst.Name()), Sel: ast.NewIdent("field_1")}
+	ptr := d.value(inst.Ptr)
+	if suffix, ok := atomicSuffix(t); ok && suffix == "Pointer" {
+		fn := d.importSel(atomicsPath, "CompareAndSwapPointer")
+		args := []ast.Expr{
+			d.unsafePointer(ptr),
+			d.unsafePointer(d.value(inst.Cmp)),
+			d.unsafePointer(d.value(inst.New)),
+			d.unsafePointer(&ast.UnaryExpr{Op: token.AND, X: old}),
+		}
+		return []ast.Stmt{decl, assignExpr(success, call(fn, args...))}
+	}
+	if suffix, ok := atomicSuffix(t); ok {
+		fn := d.importSel(atomicsPath, "CompareAndSwap"+suffix)
+		stmt := &ast.AssignStmt{
+			Lhs: []ast.Expr{old, success},
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{call(fn, ptr, d.value(inst.Cmp), d.value(inst.New))},
+		}
+		return []ast.Stmt{decl, stmt}
+	}
+	// Fallback for types not supported by sync/atomic.
+	//
+	//    atomics.Do(func() {
+	//       _3.field_0 = *_1
+	//       _3.field_1 = _3.field_0 == _2
+	//       if _3.field_1 {
+	//          *_1 = 42
+	//       }
+	//    })
+	var eq ast.Expr
+	if _, ok := wideInt(t); ok {
+		eq = &ast.BinaryExpr{X: method(old, "Cmp", d.value(inst.Cmp)), Op: token.EQL, Y: d.intLit(0)}
+	} else {
+		eq = &ast.BinaryExpr{X: old, Op: token.EQL, Y: d.value(inst.Cmp)}
+	}
+	body := []ast.Stmt{
+		assignExpr(old, &ast.StarExpr{X: ptr}),
+		assignExpr(success, eq),
+		&ast.IfStmt{
+			Cond: success,
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{assignExpr(&ast.StarExpr{X: ptr}, d.value(inst.New))},
+			},
+		},
+	}
+	return []ast.Stmt{decl, d.atomicDo(body)}
+}
+
+// instAtomicRMW converts the given LLVM IR atomicrmw instruction to a
+// corresponding list of Go statements. The memory ordering is ignored, as
+// sync/atomic operations are sequentially consistent.
+//
+//    _3 = atomic.SwapInt32(_1, _2)
+//    _3 = atomic.AddInt32(_1, _2) - _2
+//    _3 = atomics.UpdateInt32(_1, func(old int32) int32 { return old & _2 })
+//    _3 = atomics.UpdateFloat64(_1, func(old float64) float64 { return old + _2 })
+//    _3 = (*int8)(atomics.SwapPointer(unsafe.Pointer(_1), unsafe.Pointer(_2)))
+func (d *decompiler) instAtomicRMW(inst *ir.InstAtomicRMW) []ast.Stmt {
+	t := inst.X.Type()
+	ptr := d.value(inst.Dst)
+	x := d.value(inst.X)
+	if suffix, ok := atomicSuffix(t); ok {
+		var expr ast.Expr
+		integer := atomicPkg(suffix) == "sync/atomic"
+		switch {
+		case suffix == "Pointer":
+			// Pointers only support xchg.
+			swap := call(d.importSel(atomicsPath, "SwapPointer"), d.unsafePointer(ptr), d.unsafePointer(x))
+			expr = call(&ast.ParenExpr{X: d.goType(t)}, swap)
+		case integer && inst.Op == enum.AtomicOpXChg:
+			expr = call(d.importSel("sync/atomic", "Swap"+suffix), ptr, x)
+		case integer && inst.Op == enum.AtomicOpAdd:
+			// atomic.AddInt32 returns the new value, while atomicrmw returns the
+			// old value.
+			add := call(d.importSel("sync/atomic", "Add"+suffix), ptr, x)
+			expr = &ast.BinaryExpr{X: add, Op: token.SUB, Y: x}
+		case integer && inst.Op == enum.AtomicOpSub:
+			neg := &ast.UnaryExpr{Op: token.SUB, X: &ast.ParenExpr{X: x}}
+			add := call(d.importSel("sync/atomic", "Add"+suffix), ptr, neg)
+			expr = &ast.BinaryExpr{X: add, Op: token.ADD, Y: x}
+		default:
+			old := ast.NewIdent("old")
+			ret := func(v ast.Expr) ast.Stmt {
+				return &ast.ReturnStmt{Results: []ast.Expr{v}}
+			}
+			fn := &ast.FuncLit{
+				Type: &ast.FuncType{
+					Params: &ast.FieldList{
+						List: []*ast.Field{{Names: []*ast.Ident{old}, Type: d.goType(t)}},
+					},
+					Results: &ast.FieldList{
+						List: []*ast.Field{{Type: d.goType(t)}},
+					},
+				},
+				Body: &ast.BlockStmt{
+					List: d.rmwStmts(inst.Op, old, x, t, ret),
+				},
+			}
+			expr = call(d.importSel(atomicsPath, "Update"+suffix), ptr, fn)
+		}
+		return []ast.Stmt{d.assign(inst.Name(), expr)}
+	}
+	// Fallback for types not supported by sync/atomic.
+	//
+	//    var _3 int8
+	//    atomics.Do(func() {
+	//       _3 = *_1
+	//       *_1 = _3 & _2
+	//    })
+	decl := d.varDecl(inst.Name(), d.goType(t))
+	old := d.localIdent(inst.Name())
+	store := func(v ast.Expr) ast.Stmt {
+		return assignExpr(&ast.StarExpr{X: ptr}, v)
+	}
+	body := []ast.Stmt{d.assign(inst.Name(), &ast.StarExpr{X: ptr})}
+	body = append(body, d.rmwStmts(inst.Op, old, x, t, store)...)
+	return []ast.Stmt{decl, d.atomicDo(body)}
+}
+
+// rmwStmts returns Go statements computing the new value of the given atomicrmw
+// operation on the old value and the operand x of type t, where result converts
+// the new value to a statement (e.g. a return or store statement).
+func (d *decompiler) rmwStmts(op enum.AtomicOp, old, x ast.Expr, t irtypes.Type, result func(v ast.Expr) ast.Stmt) []ast.Stmt {
+	binary := func(op token.Token) ast.Expr {
+		if _, ok := wideInt(t); ok {
+			return method(old, wideMethod(op, false), x)
+		}
+		expr := &ast.BinaryExpr{X: old, Op: op, Y: x}
+		if t, ok := oddInt(t); ok && (op == token.ADD || op == token.SUB) {
+			return d.signExtend(expr, t)
+		}
+		return expr
+	}
+	// pick returns an if-statement selecting old if cond holds and x otherwise.
+	pick := func(cond ast.Expr) []ast.Stmt {
+		stmt := &ast.IfStmt{
+			Cond: cond,
+			Body: &ast.BlockStmt{List: []ast.Stmt{result(old)}},
+			Else: &ast.BlockStmt{List: []ast.Stmt{result(x)}},
+		}
+		return []ast.Stmt{stmt}
+	}
+	switch op {
+	case enum.AtomicOpXChg:
+		return []ast.Stmt{result(x)}
+	case enum.AtomicOpAdd, enum.AtomicOpFAdd:
+		return []ast.Stmt{result(binary(token.ADD))}
+	case enum.AtomicOpSub, enum.AtomicOpFSub:
+		return []ast.Stmt{result(binary(token.SUB))}
+	case enum.AtomicOpAnd:
+		return []ast.Stmt{result(binary(token.AND))}
+	case enum.AtomicOpOr:
+		return []ast.Stmt{result(binary(token.OR))}
+	case enum.AtomicOpXor:
+		return []ast.Stmt{result(binary(token.XOR))}
+	case enum.AtomicOpNAnd:
+		if t, ok := wideInt(t); ok {
+			ones := call(d.intnSel("FromInt64"), bitsLit(t), d.intLit(-1))
+			return []ast.Stmt{result(method(binary(token.AND), "Xor", ones))}
+		}
+		return []ast.Stmt{result(&ast.UnaryExpr{Op: token.XOR, X: &ast.ParenExpr{X: binary(token.AND)}})}
+	case enum.AtomicOpMax:
+		return pick(d.rmwCmp(old, token.GTR, x, t, false))
+	case enum.AtomicOpMin:
+		return pick(d.rmwCmp(old, token.LSS, x, t, false))
+	case enum.AtomicOpUMax:
+		return pick(d.rmwCmp(old, token.GTR, x, t, true))
+	case enum.AtomicOpUMin:
+		return pick(d.rmwCmp(old, token.LSS, x, t, true))
+	default:
+		panic(fmt.Sprintf("support for atomicrmw operation %v not yet implemented", op))
+	}
+}
+
+// rmwCmp returns a Go expression comparing x and y of integer type t, with
+// signed or unsigned semantics.
+func (d *decompiler) rmwCmp(x ast.Expr, op token.Token, y ast.Expr, t irtypes.Type, unsigned bool) ast.Expr {
+	if _, ok := wideInt(t); ok {
+		name := "Cmp"
+		if unsigned {
+			name = "UCmp"
+		}
+		return &ast.BinaryExpr{X: method(x, name, y), Op: op, Y: d.intLit(0)}
+	}
+	if unsigned {
+		it, ok := t.(*irtypes.IntType)
+		if !ok {
+			panic(fmt.Sprintf("invalid atomicrmw operand type; expected *types.IntType, got %T", t))
+		}
+		if _, ok := oddInt(it); ok {
+			x, y = d.zeroExtend(x, it), d.zeroExtend(y, it)
+		} else {
+			x, y = call(d.goUintType(it), x), call(d.goUintType(it), y)
+		}
+	}
+	return &ast.BinaryExpr{X: x, Op: op, Y: y}
+}
+
+// instFence converts the given LLVM IR fence instruction to a corresponding Go
+// statement. Memory orderings weaker than sequential consistency are
+// strengthened.
+//
+//    atomics.Fence()
+func (d *decompiler) instFence(inst *ir.InstFence) ast.Stmt {
+	return &ast.ExprStmt{
+		X: call(d.importSel(atomicsPath, "Fence")),
+	}
+}
+
+// instFreeze converts the given LLVM IR freeze instruction to a corresponding
+// Go statement. Go values are never undefined, thus freeze is a plain copy.
+func (d *decompiler) instFreeze(inst *ir.InstFreeze) ast.Stmt {
+	return d.assign(inst.Name(), d.value(inst.X))
+}
+
+// instVAArg converts the given LLVM IR va_arg instruction to a corresponding Go
+// statement. The argument is converted by va.Arg to the type of the zero value
+// given, thus the type assertion always succeeds.
+//
+//    _3 = va.Arg(_1, *new(int32)).(int32)
+func (d *decompiler) instVAArg(inst *ir.InstVAArg) ast.Stmt {
+	arg := call(d.importSel(vaPath, "Arg"), d.value(inst.ArgList), d.zeroValue(inst.ArgType))
+	expr := &ast.TypeAssertExpr{
+		X:    arg,
+		Type: d.goType(inst.ArgType),
+	}
+	return d.assign(inst.Name(), expr)
+}
+
+// atomicDo returns a Go statement executing the given statements while holding
+// the global lock of atomic operations not supported by sync/atomic.
+func (d *decompiler) atomicDo(body []ast.Stmt) ast.Stmt {
+	fn := &ast.FuncLit{
+		Type: &ast.FuncType{Params: &ast.FieldList{}},
+		Body: &ast.BlockStmt{List: body},
+	}
+	return &ast.ExprStmt{
+		X: call(d.importSel(atomicsPath, "Do"), fn),
+	}
+}
+
+// flagStructType returns the Go type of LLVM IR values of type { T, i1 } (e.g.
+// the results of cmpxchg or llvm.sadd.with.overflow), where T is the given
+// type. The flag is represented by a Go boolean, as produced by comparisons.
+//
+//    struct{ field_0 T; field_1 bool }
+func (d *decompiler) flagStructType(t irtypes.Type) ast.Expr {
+	return &ast.StructType{
+		Fields: &ast.FieldList{
+			List: []*ast.Field{
+				{Names: []*ast.Ident{ast.NewIdent("field_0")}, Type: d.goType(t)},
+				{Names: []*ast.Ident{ast.NewIdent("field_1")}, Type: ast.NewIdent("bool")},
+			},
+		},
+	}
+}
+
+// varDecl returns a Go variable declaration statement of the given local
+// variable and type.
+//
+//    var _3 T
+func (d *decompiler) varDecl(name string, typ ast.Expr) ast.Stmt {
+	spec := &ast.ValueSpec{
+		Names: []*ast.Ident{d.localIdent(name)},
+		Type:  typ,
+	}
+	return &ast.DeclStmt{
+		Decl: &ast.GenDecl{
+			Tok:   token.VAR,
+			Specs: []ast.Spec{spec},
+		},
+	}
+}
+
+// assignExpr returns an assignment statement, assigning expr to dst.
+func assignExpr(dst, expr ast.Expr) ast.Stmt {
+	return &ast.AssignStmt{
+		Lhs: []ast.Expr{dst},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{expr},
+	}
+}
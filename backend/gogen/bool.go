@@ -0,0 +1,81 @@
+package gogen
+
+import (
+	"go/ast"
+	"go/token"
+
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// isBool reports whether the given LLVM IR type is i1, or a vector of i1.
+//
+// Values of type i1 (e.g. as produced by icmp and fcmp) are represented by the
+// Go bool type.
+func isBool(t irtypes.Type) bool {
+	if vt, ok := t.(*irtypes.VectorType); ok {
+		t = vt.ElemType
+	}
+	it, ok := t.(*irtypes.IntType)
+	return ok && it.BitSize == 1
+}
+
+// boolOp returns the Go boolean operator corresponding to the given Go integer
+// operator on i1 values, as computed modulo 2. The boolean return value
+// indicates success.
+func boolOp(op token.Token) (token.Token, bool) {
+	switch op {
+	case token.AND, token.MUL:
+		return token.LAND, true
+	case token.OR:
+		return token.LOR, true
+	case token.XOR, token.ADD, token.SUB:
+		return token.NEQ, true
+	}
+	return token.ILLEGAL, false
+}
+
+// convertBool returns a Go expression for extending the given LLVM IR i1 value
+// (or vector of i1) to the specified integer or floating-point type; with
+// sign-extension (true as -1) if signed is set, and zero-extension (true as 1)
+// otherwise.
+//
+//    int32(intn.Bool(x))
+//    int32(-intn.Bool(x))
+func (d *decompiler) convertBool(from value.Value, to irtypes.Type, signed bool) ast.Expr {
+	if _, toType, ok := vectorConv(from, to); ok {
+		return d.convertVector(from, toType, func(xi ast.Expr) ast.Expr {
+			return d.extendBool(xi, toType.ElemType, signed)
+		})
+	}
+	return d.extendBool(d.value(from), to, signed)
+}
+
+// extendBool returns a Go expression for extending the given Go boolean
+// expression to the specified integer or floating-point type.
+func (d *decompiler) extendBool(b ast.Expr, to irtypes.Type, signed bool) ast.Expr {
+	x := call(d.intnSel("Bool"), b)
+	if signed {
+		x = &ast.UnaryExpr{Op: token.SUB, X: x}
+	}
+	if t, ok := wideInt(to); ok {
+		return call(d.intnSel("FromInt64"), bitsLit(t), x)
+	}
+	if t, ok := softFloat(to); ok {
+		return call(d.floatnSel("FromInt64"), d.precSel(t), x)
+	}
+	return call(d.goType(to), x)
+}
+
+// truncBool returns a Go expression for truncating the given LLVM IR integer
+// value to i1.
+//
+//    x&1 != 0
+func (d *decompiler) truncBool(from value.Value) ast.Expr {
+	x := d.value(from)
+	if _, ok := wideInt(from.Type()); ok {
+		x = method(x, "Int64")
+	}
+	and := &ast.BinaryExpr{X: x, Op: token.AND, Y: d.intLit(1)}
+	return &ast.BinaryExpr{X: and, Op: token.NEQ, Y: d.intLit(0)}
+}
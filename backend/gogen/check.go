@@ -0,0 +1,375 @@
+package gogen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxFixRounds specifies the maximum number of rounds of automatic conversion
+// insertion, each followed by type checking the updated Go source file.
+const maxFixRounds = 4
+
+// typeCheck type-checks the given decompiled Go source file using go/types.
+// Missing conversions are inserted automatically where valid in Go (e.g. of
+// mismatched integer widths), and conversions between pointer types of
+// different element types are made through unsafe.Pointer. Remaining type
+// errors are marked by comments in the Go source file.
+//
+// The positions of the given Go source file, if any, are in fset; and used to
+// retain its comments. The type-checked Go source file is returned together
+// with the file set of its positions, and the remaining type errors.
+func typeCheck(fset *token.FileSet, file *ast.File) (*ast.File, *token.FileSet, []types.Error, error) {
+	// The imported packages are shared between rounds of type checking.
+	imp := newImporter()
+	for round := 0; ; round++ {
+		buf, err := formatFile(fset, file)
+		if err != nil {
+			return nil, nil, nil, errors.WithStack(err)
+		}
+		var f *ast.File
+		fset, f, err = parse(buf)
+		if err != nil {
+			return nil, nil, nil, errors.WithStack(err)
+		}
+		pkg, info, errs := checkFile(fset, f, imp)
+		if len(errs) == 0 {
+			return f, fset, nil, nil
+		}
+		if round < maxFixRounds && insertConversions(f, pkg, info) {
+			file = f
+			continue
+		}
+		fset, f, err = parse(annotateErrors(fset, buf, f, errs))
+		if err != nil {
+			return nil, nil, nil, errors.WithStack(err)
+		}
+		return f, fset, errs, nil
+	}
+}
+
+// parse parses the given decompiled Go source code, to assign positions to the
+// nodes of the file.
+func parse(buf []byte) (*token.FileSet, *ast.File, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", buf, parser.ParseComments)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to parse decompiled Go source code")
+	}
+	return fset, f, nil
+}
+
+// formatFile returns the gofmt'd Go source code of the given file, with
+// positions in the specified file set.
+func formatFile(fset *token.FileSet, file *ast.File) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, fset, file); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// checkFile type-checks the given Go source file, and returns its package, the
+// recorded type information and type errors.
+func checkFile(fset *token.FileSet, f *ast.File, imp types.Importer) (*types.Package, *types.Info, []types.Error) {
+	var errs []types.Error
+	conf := &types.Config{
+		Importer: imp,
+		Error: func(err error) {
+			if err, ok := err.(types.Error); ok {
+				errs = append(errs, err)
+			}
+		},
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	// The main function of C returns the exit status, and is wrapped by go-post
+	// (see the cmain rule). Check the file outside of package main, so that the
+	// signature of main is not rejected.
+	pkgName := f.Name.Name
+	if pkgName == "main" {
+		f.Name.Name = "main_"
+		defer func() { f.Name.Name = pkgName }()
+	}
+	pkg, _ := conf.Check(pkgName, fset, []*ast.File{f}, info)
+	return pkg, info, errs
+}
+
+// insertConversions inserts conversions of expressions assigned, passed or
+// returned as values of a different type, and of the second operand of binary
+// operations with mismatched operand types. Conversions between pointer types
+// are made through unsafe.Pointer. The boolean return value indicates whether a
+// conversion was inserted.
+//
+//    _3 = int32(_2)
+//    _5 = (*int32)(unsafe.Pointer(_4))
+func insertConversions(f *ast.File, pkg *types.Package, info *types.Info) bool {
+	c := &converter{file: f, pkg: pkg, info: info}
+	// Enclosing function signatures, innermost last.
+	var sigs []*types.Signature
+	var stack []ast.Node
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			switch top.(type) {
+			case *ast.FuncDecl, *ast.FuncLit:
+				sigs = sigs[:len(sigs)-1]
+			}
+			return true
+		}
+		stack = append(stack, n)
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			sig, _ := info.Defs[n.Name].Type().(*types.Signature)
+			sigs = append(sigs, sig)
+		case *ast.FuncLit:
+			sig, _ := info.TypeOf(n).(*types.Signature)
+			sigs = append(sigs, sig)
+		case *ast.ValueSpec:
+			if n.Type != nil {
+				t := info.TypeOf(n.Type)
+				for i := range n.Values {
+					n.Values[i] = c.convert(n.Values[i], t)
+				}
+			}
+		case *ast.AssignStmt:
+			if n.Tok == token.ASSIGN && len(n.Lhs) == len(n.Rhs) {
+				for i := range n.Rhs {
+					n.Rhs[i] = c.convert(n.Rhs[i], info.TypeOf(n.Lhs[i]))
+				}
+			}
+		case *ast.ReturnStmt:
+			if sig := sigs[len(sigs)-1]; sig != nil && sig.Results().Len() == len(n.Results) {
+				for i := range n.Results {
+					n.Results[i] = c.convert(n.Results[i], sig.Results().At(i).Type())
+				}
+			}
+		case *ast.CallExpr:
+			if info.Types[n.Fun].IsType() && len(n.Args) == 1 {
+				// Conversion between pointer types of different element types.
+				n.Args[0] = c.unsafePointer(n.Args[0], info.TypeOf(n.Fun))
+				break
+			}
+			sig, ok := info.TypeOf(n.Fun).(*types.Signature)
+			if !ok || info.Types[n.Fun].IsType() || info.Types[n.Fun].IsBuiltin() {
+				break
+			}
+			params := sig.Params()
+			for i := range n.Args {
+				if i >= params.Len() || (sig.Variadic() && i >= params.Len()-1) {
+					break
+				}
+				n.Args[i] = c.convert(n.Args[i], params.At(i).Type())
+			}
+		case *ast.BinaryExpr:
+			switch n.Op {
+			case token.SHL, token.SHR, token.LAND, token.LOR:
+			default:
+				n.Y = c.convert(n.Y, info.TypeOf(n.X))
+			}
+		}
+		return true
+	})
+	return c.fixed
+}
+
+// A converter inserts conversions of expressions in a type-checked Go source
+// file.
+type converter struct {
+	// Go source file.
+	file *ast.File
+	// Package of the Go source file.
+	pkg *types.Package
+	// Type information of the Go source file.
+	info *types.Info
+	// Tracks whether a conversion was inserted.
+	fixed bool
+}
+
+// convert returns a conversion of expr to the given type, if the expression is
+// of a different typed type convertible to t. The expression is returned
+// unchanged otherwise, and for conversions from integer to string types, which
+// yield the UTF-8 encoding of a rune rather than a decimal string; these are
+// left to be annotated as type errors.
+func (c *converter) convert(expr ast.Expr, t types.Type) ast.Expr {
+	tv, ok := c.info.Types[expr]
+	if !ok || tv.IsType() || tv.Type == nil || t == nil {
+		return expr
+	}
+	from := tv.Type
+	if isInvalid(from) || isInvalid(t) || isUntyped(from) || isUntyped(t) || types.AssignableTo(from, t) {
+		return expr
+	}
+	switch {
+	case isInteger(from) && isString(t):
+		return expr
+	case types.ConvertibleTo(from, t):
+		c.fixed = true
+		return call(c.typeExpr(t), expr)
+	case isPointer(from) && isPointer(t):
+		return call(c.typeExpr(t), c.unsafePointer(expr, t))
+	}
+	return expr
+}
+
+// unsafePointer returns a conversion of expr to unsafe.Pointer, if the
+// expression is of a pointer type not convertible to the pointer type t. The
+// expression is returned unchanged otherwise.
+func (c *converter) unsafePointer(expr ast.Expr, t types.Type) ast.Expr {
+	from := c.info.TypeOf(expr)
+	if from == nil || t == nil || !isPointer(from) || !isPointer(t) || types.ConvertibleTo(from, t) {
+		return expr
+	}
+	c.fixed = true
+	return call(c.importSel("unsafe", "Pointer"), expr)
+}
+
+// typeExpr returns a Go type expression of the given type, for use as the
+// function of a conversion.
+func (c *converter) typeExpr(t types.Type) ast.Expr {
+	qualifier := func(pkg *types.Package) string {
+		if pkg == c.pkg {
+			return ""
+		}
+		return pkg.Name()
+	}
+	s := types.TypeString(t, qualifier)
+	expr, err := parser.ParseExpr(s)
+	if err != nil {
+		panic(fmt.Errorf("unable to parse type %q; %v", s, err))
+	}
+	switch expr.(type) {
+	case *ast.StarExpr, *ast.FuncType, *ast.ChanType:
+		return &ast.ParenExpr{X: expr}
+	}
+	return expr
+}
+
+// importSel returns a Go selector expression for the given identifier of the
+// specified package, and adds an import of the package to the Go source file
+// if not already present.
+func (c *converter) importSel(pkgPath, name string) ast.Expr {
+	addImport(c.file, pkgPath)
+	return &ast.SelectorExpr{
+		X:   ast.NewIdent(pkgPath[strings.LastIndex(pkgPath, "/")+1:]),
+		Sel: ast.NewIdent(name),
+	}
+}
+
+// addImport adds an import of the given package to the Go source file, if not
+// already present.
+func addImport(f *ast.File, pkgPath string) {
+	for _, spec := range f.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err == nil && path == pkgPath {
+			return
+		}
+	}
+	spec := &ast.ImportSpec{
+		Path: &ast.BasicLit{
+			Kind:  token.STRING,
+			Value: strconv.Quote(pkgPath),
+		},
+	}
+	f.Imports = append(f.Imports, spec)
+	if len(f.Decls) > 0 {
+		if decl, ok := f.Decls[0].(*ast.GenDecl); ok && decl.Tok == token.IMPORT {
+			decl.Specs = append(decl.Specs, spec)
+			if !decl.Lparen.IsValid() {
+				decl.Lparen = decl.Pos()
+				decl.Rparen = decl.End()
+			}
+			return
+		}
+	}
+	decl := &ast.GenDecl{
+		Tok:   token.IMPORT,
+		Specs: []ast.Spec{spec},
+	}
+	f.Decls = append([]ast.Decl{decl}, f.Decls...)
+}
+
+// annotateErrors marks the statements and declarations of the given Go source
+// file containing type errors with comments of the error messages, and returns
+// the annotated Go source code. The Go source file was parsed from buf, with
+// positions in fset.
+//
+//    // ll2go: cannot use _2 (variable of type [4]int8) as *int8 value in assignment
+//    _3 = _2
+func annotateErrors(fset *token.FileSet, buf []byte, f *ast.File, errs []types.Error) []byte {
+	// Map from position of the annotated node to error messages.
+	msgs := make(map[token.Pos][]string)
+	for _, err := range errs {
+		pos := annotationPos(f, err.Pos)
+		msg := strings.Replace(err.Msg, "\n", " ", -1)
+		msgs[pos] = append(msgs[pos], msg)
+	}
+	lines := make(map[token.Pos][]string)
+	for pos, ms := range msgs {
+		lines[pos] = []string{"ll2go: " + strings.Join(ms, "; ")}
+	}
+	return insertComments(fset, buf, lines)
+}
+
+// annotationPos returns the position of the innermost statement or top-level
+// declaration of the given Go source file containing pos.
+func annotationPos(f *ast.File, pos token.Pos) token.Pos {
+	annot := f.Package
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || pos < n.Pos() || n.End() <= pos {
+			return false
+		}
+		switch n.(type) {
+		case ast.Decl:
+			annot = n.Pos()
+		case ast.Stmt:
+			if _, ok := n.(*ast.BlockStmt); !ok {
+				annot = n.Pos()
+			}
+		}
+		return true
+	})
+	return annot
+}
+
+// isInvalid reports whether the given type is invalid, as the result of a prior
+// type error.
+func isInvalid(t types.Type) bool {
+	return t == types.Typ[types.Invalid]
+}
+
+// isUntyped reports whether the given type is the type of an untyped constant
+// or nil.
+func isUntyped(t types.Type) bool {
+	b, ok := t.(*types.Basic)
+	return ok && b.Info()&types.IsUntyped != 0
+}
+
+// isInteger reports whether the given type is an integer type.
+func isInteger(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsInteger != 0
+}
+
+// isString reports whether the given type is a string type.
+func isString(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsString != 0
+}
+
+// isPointer reports whether the given type is a pointer type.
+func isPointer(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Pointer)
+	return ok
+}
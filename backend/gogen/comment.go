@@ -0,0 +1,192 @@
+package gogen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/decomp/decomp/srcmap"
+	"github.com/llir/llvm/ir"
+	"github.com/pkg/errors"
+)
+
+// comment records the given lines of LLVM IR to be attached as a comment of the
+// Go statement, if LLVM IR comments are enabled (see Decompiler.Comments).
+func (d *decompiler) comment(stmt ast.Stmt, lines ...string) {
+	if d.comments == nil {
+		return
+	}
+	d.comments[stmt] = append(d.comments[stmt], lines...)
+}
+
+// instComment records the given LLVM IR instruction or terminator as the
+// comment of the first of the Go statements it was converted to, and as the
+// source map origin of each of the Go statements.
+//
+//    // %3 = add i32 %1, %2
+//    _3 = _1 + _2
+func (d *decompiler) instComment(stmts []ast.Stmt, inst ir.LLStringer) {
+	if len(stmts) > 0 {
+		d.comment(stmts[0], "  "+inst.LLString())
+	}
+	if d.origins == nil {
+		return
+	}
+	origin, ok := d.instOrigins[inst]
+	if !ok {
+		return
+	}
+	for _, stmt := range stmts {
+		d.origins[stmt] = origin
+	}
+}
+
+// initOrigins records the source map origins of the instructions and
+// terminators of the given LLVM IR function, if source maps are enabled (see
+// Decompiler.SrcMap).
+func (d *decompiler) initOrigins(f *ir.Func) {
+	if d.origins == nil {
+		return
+	}
+	d.instOrigins = make(map[ir.LLStringer]srcmap.Origin)
+	for _, block := range f.Blocks {
+		for i, inst := range block.Insts {
+			d.instOrigins[inst] = srcmap.Origin{
+				Func:  f.Ident(),
+				Block: block.Ident(),
+				Index: i,
+				Inst:  inst.LLString(),
+			}
+		}
+		d.instOrigins[block.Term] = srcmap.Origin{
+			Func:  f.Ident(),
+			Block: block.Ident(),
+			Index: len(block.Insts),
+			Inst:  block.Term.LLString(),
+		}
+	}
+}
+
+// markOrigins records marker comments of the source map origins of the Go
+// statements of the given Go source file, and returns the origins of the
+// markers (see srcmap.Extract).
+func (d *decompiler) markOrigins(file *ast.File) []srcmap.Origin {
+	if d.comments == nil {
+		d.comments = make(map[ast.Stmt][]string)
+	}
+	var origins []srcmap.Origin
+	for _, stmt := range fileStmts(file) {
+		if origin, ok := d.origins[stmt]; ok {
+			d.comments[stmt] = append(d.comments[stmt], srcmap.Marker(len(origins)))
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// blockComment records the label of the given LLVM IR basic block as the
+// comment of the first Go statement of the basic block; ahead of the comment of
+// its first instruction.
+//
+//    // entry:
+//    //   %3 = add i32 %1, %2
+//    _3 = _1 + _2
+func (d *decompiler) blockComment(stmts []ast.Stmt, block *basicBlock) {
+	// Skip conceptual basic blocks of control flow primitives.
+	if d.comments == nil || len(stmts) == 0 || block.Parent == nil {
+		return
+	}
+	label := strings.TrimPrefix(block.Ident(), "%") + ":"
+	d.comments[stmts[0]] = append([]string{label}, d.comments[stmts[0]]...)
+}
+
+// attachComments attaches the given comments of LLVM IR to their Go statements
+// in the specified Go source file. The Go source file with the comments is
+// returned together with the file set of its positions.
+//
+// Statements are located by their positions in the formatted Go source file,
+// and each comment is placed on the lines preceding its statement; thus
+// associated with the statement by ast.NewCommentMap.
+func attachComments(file *ast.File, comments map[ast.Stmt][]string) (*token.FileSet, *ast.File, error) {
+	buf, err := formatFile(token.NewFileSet(), file)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	fset, f, err := parse(buf)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	// Statements of the parsed file are in one-to-one correspondence with the
+	// statements of the original file, in depth-first order.
+	orig, parsed := fileStmts(file), fileStmts(f)
+	if len(orig) != len(parsed) {
+		return nil, nil, errors.Errorf("mismatch between number of statements before (%d) and after (%d) formatting", len(orig), len(parsed))
+	}
+	lines := make(map[token.Pos][]string)
+	for i, stmt := range orig {
+		if cs, ok := comments[stmt]; ok {
+			pos := parsed[i].Pos()
+			lines[pos] = append(lines[pos], cs...)
+		}
+	}
+	fset, f, err = parse(insertComments(fset, buf, lines))
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return fset, f, nil
+}
+
+// fileStmts returns the statements of the given Go source file, in depth-first
+// order.
+func fileStmts(file *ast.File) []ast.Stmt {
+	var stmts []ast.Stmt
+	ast.Inspect(file, func(n ast.Node) bool {
+		if stmt, ok := n.(ast.Stmt); ok {
+			stmts = append(stmts, stmt)
+		}
+		return true
+	})
+	return stmts
+}
+
+// insertComments inserts line comments into the given formatted Go source code,
+// with positions in fset. The lines of each comment are inserted before the
+// line containing its position, with the same indentation.
+func insertComments(fset *token.FileSet, buf []byte, lines map[token.Pos][]string) []byte {
+	var poss []token.Pos
+	for pos := range lines {
+		poss = append(poss, pos)
+	}
+	sort.Slice(poss, func(i, j int) bool { return poss[i] < poss[j] })
+	// Map from offset of line to comment lines.
+	m := make(map[int][]string)
+	var offsets []int
+	for _, pos := range poss {
+		ls := lines[pos]
+		tf := fset.File(pos)
+		offset := tf.Offset(tf.LineStart(tf.Line(pos)))
+		if _, ok := m[offset]; !ok {
+			offsets = append(offsets, offset)
+		}
+		m[offset] = append(m[offset], ls...)
+	}
+	sort.Ints(offsets)
+	out := &bytes.Buffer{}
+	prev := 0
+	for _, offset := range offsets {
+		out.Write(buf[prev:offset])
+		// Indent the comment as the line it precedes.
+		line := buf[offset:]
+		indent := line[:len(line)-len(bytes.TrimLeft(line, "\t"))]
+		for _, l := range m[offset] {
+			out.Write(indent)
+			fmt.Fprintf(out, "// %s\n", l)
+		}
+		prev = offset
+	}
+	out.Write(buf[prev:])
+	return out.Bytes()
+}
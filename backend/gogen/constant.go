@@ -1,12 +1,14 @@
-package main
+package gogen
 
 import (
 	"fmt"
 	"go/ast"
 	"go/token"
+	"math/big"
 
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/value"
 )
 
 // constant converts the given LLVM IR constant to a corresponding Go
@@ -31,11 +33,18 @@ func (d *decompiler) constant(c constant.Constant) ast.Expr {
 		return d.constStruct(c)
 	case *constant.ZeroInitializer:
 		return d.constZeroInitializer(c)
+	case *constant.Undef:
+		return d.constUndef(c)
 	// Global variable and function addresses
 	case *ir.Global:
+		if _, _, ok := stringGlobal(c); ok {
+			return d.stringAddr(c)
+		}
 		return d.globalIdent(c.Name())
 	case *ir.Func:
 		return d.globalIdent(c.Name())
+	case *constant.BlockAddress:
+		return d.constBlockAddress(c)
 	// Constant expressions
 	case constant.Expression:
 		return d.expr(c)
@@ -46,19 +55,31 @@ func (d *decompiler) constant(c constant.Constant) ast.Expr {
 
 // constInt converts the given LLVM IR integer constant to a corresponding Go
 // expression.
+//
+// Integer constants are represented in two's complement form within the range
+// of the signed Go integer type; e.g. `i32 4294967295` is converted to -1.
 func (d *decompiler) constInt(c *constant.Int) ast.Expr {
-	return &ast.BasicLit{
-		Kind:  token.INT,
-		Value: c.X.String(),
+	if _, ok := wideInt(c.Typ); ok {
+		return d.constWideInt(c)
+	}
+	if c.Typ.BitSize == 1 {
+		if c.X.Sign() != 0 {
+			return ast.NewIdent("true")
+		}
+		return ast.NewIdent("false")
+	}
+	x := c.X
+	if bits := c.Typ.BitSize; bits > 1 {
+		// Wrap around values outside of the signed range [-2^(n-1), 2^(n-1)).
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+		x = new(big.Int).Mod(x, limit)
+		if x.Cmp(new(big.Int).Rsh(limit, 1)) >= 0 {
+			x.Sub(x, limit)
+		}
 	}
-}
-
-// constFloat converts the given LLVM IR floating-point constant to a
-// corresponding Go expression.
-func (d *decompiler) constFloat(c *constant.Float) ast.Expr {
 	return &ast.BasicLit{
-		Kind:  token.FLOAT,
-		Value: c.X.String(),
+		Kind:  token.INT,
+		Value: x.String(),
 	}
 }
 
@@ -94,21 +115,18 @@ func (d *decompiler) constArray(c *constant.Array) ast.Expr {
 	}
 }
 
-// constCharArray converts the given LLVM IR character array constant to a
-// corresponding Go expression.
-func (d *decompiler) constCharArray(c *constant.CharArray) ast.Expr {
-	return &ast.BasicLit{
-		Kind:  token.STRING,
-		Value: fmt.Sprintf("%q", string(c.X)),
-	}
-}
-
 // constStruct converts the given LLVM IR struct constant to a corresponding Go
 // expression.
 func (d *decompiler) constStruct(c *constant.Struct) ast.Expr {
+	// Struct types with padding fields are initialized by keyed elements.
+	keyed := d.layout.hasPadding(c.Typ)
 	var fields []ast.Expr
-	for _, field := range c.Fields {
-		fields = append(fields, d.constant(field))
+	for i, field := range c.Fields {
+		expr := d.constant(field)
+		if keyed {
+			expr = &ast.KeyValueExpr{Key: d.fieldIdent(c.Typ, i), Value: expr}
+		}
+		fields = append(fields, expr)
 	}
 	return &ast.CompositeLit{
 		Type: d.goType(c.Typ),
@@ -131,6 +149,13 @@ func (d *decompiler) constZeroInitializer(c *constant.ZeroInitializer) ast.Expr
 	}
 }
 
+// constUndef converts the given LLVM IR undefined value constant to a
+// corresponding Go expression. Undefined values are represented by the zero
+// value of their type.
+func (d *decompiler) constUndef(c *constant.Undef) ast.Expr {
+	return d.zeroValue(c.Typ)
+}
+
 // expr converts the given LLVM IR expression to a corresponding Go expression.
 func (d *decompiler) expr(expr constant.Expression) ast.Expr {
 	switch expr := expr.(type) {
@@ -172,6 +197,18 @@ func (d *decompiler) expr(expr constant.Expression) ast.Expr {
 		return d.exprOr(expr)
 	case *constant.ExprXor:
 		return d.exprXor(expr)
+	// Vector expressions
+	case *constant.ExprExtractElement:
+		return d.exprExtractElement(expr)
+	case *constant.ExprInsertElement:
+		return d.exprInsertElement(expr)
+	case *constant.ExprShuffleVector:
+		return d.exprShuffleVector(expr)
+	// Aggregate expressions
+	case *constant.ExprExtractValue:
+		return d.exprExtractValue(expr)
+	case *constant.ExprInsertValue:
+		return d.exprInsertValue(expr)
 	// Memory expressions
 	case *constant.ExprGetElementPtr:
 		return d.exprGetElementPtr(expr)
@@ -217,145 +254,179 @@ func (d *decompiler) expr(expr constant.Expression) ast.Expr {
 // exprAdd converts the given LLVM IR add expression to a corresponding Go
 // statement.
 func (d *decompiler) exprAdd(expr *constant.ExprAdd) ast.Expr {
-	return d.binaryOp(expr.X, token.ADD, expr.Y)
+	return d.intOp(expr.X, token.ADD, expr.Y)
 }
 
 // exprFAdd converts the given LLVM IR fadd expression to a corresponding Go
 // statement.
 func (d *decompiler) exprFAdd(expr *constant.ExprFAdd) ast.Expr {
-	return d.binaryOp(expr.X, token.ADD, expr.Y)
+	return d.floatOp(expr.X, token.ADD, expr.Y)
 }
 
 // exprSub converts the given LLVM IR sub expression to a corresponding Go
 // statement.
 func (d *decompiler) exprSub(expr *constant.ExprSub) ast.Expr {
-	return d.binaryOp(expr.X, token.SUB, expr.Y)
+	return d.intOp(expr.X, token.SUB, expr.Y)
 }
 
 // exprFSub converts the given LLVM IR fsub expression to a corresponding Go
 // statement.
 func (d *decompiler) exprFSub(expr *constant.ExprFSub) ast.Expr {
-	return d.binaryOp(expr.X, token.SUB, expr.Y)
+	return d.floatOp(expr.X, token.SUB, expr.Y)
 }
 
 // exprMul converts the given LLVM IR mul expression to a corresponding Go
 // statement.
 func (d *decompiler) exprMul(expr *constant.ExprMul) ast.Expr {
-	return d.binaryOp(expr.X, token.MUL, expr.Y)
+	return d.intOp(expr.X, token.MUL, expr.Y)
 }
 
 // exprFMul converts the given LLVM IR fmul expression to a corresponding Go
 // statement.
 func (d *decompiler) exprFMul(expr *constant.ExprFMul) ast.Expr {
-	return d.binaryOp(expr.X, token.MUL, expr.Y)
+	return d.floatOp(expr.X, token.MUL, expr.Y)
 }
 
 // exprUDiv converts the given LLVM IR udiv expression to a corresponding Go
 // statement.
 func (d *decompiler) exprUDiv(expr *constant.ExprUDiv) ast.Expr {
-	return d.binaryOp(expr.X, token.QUO, expr.Y)
+	return d.unsignedOp(expr.X, token.QUO, expr.Y)
 }
 
 // exprSDiv converts the given LLVM IR sdiv expression to a corresponding Go
 // statement.
 func (d *decompiler) exprSDiv(expr *constant.ExprSDiv) ast.Expr {
-	return d.binaryOp(expr.X, token.QUO, expr.Y)
+	return d.intOp(expr.X, token.QUO, expr.Y)
 }
 
 // exprFDiv converts the given LLVM IR fdiv expression to a corresponding Go
 // statement.
 func (d *decompiler) exprFDiv(expr *constant.ExprFDiv) ast.Expr {
-	return d.binaryOp(expr.X, token.QUO, expr.Y)
+	return d.floatOp(expr.X, token.QUO, expr.Y)
 }
 
 // exprURem converts the given LLVM IR urem expression to a corresponding Go
 // statement.
 func (d *decompiler) exprURem(expr *constant.ExprURem) ast.Expr {
-	return d.binaryOp(expr.X, token.REM, expr.Y)
+	return d.unsignedOp(expr.X, token.REM, expr.Y)
 }
 
 // exprSRem converts the given LLVM IR srem expression to a corresponding Go
 // statement.
 func (d *decompiler) exprSRem(expr *constant.ExprSRem) ast.Expr {
-	return d.binaryOp(expr.X, token.REM, expr.Y)
+	return d.intOp(expr.X, token.REM, expr.Y)
 }
 
 // exprFRem converts the given LLVM IR frem expression to a corresponding Go
 // statement.
 func (d *decompiler) exprFRem(expr *constant.ExprFRem) ast.Expr {
-	return d.binaryOp(expr.X, token.REM, expr.Y)
+	return d.floatOp(expr.X, token.REM, expr.Y)
 }
 
 // exprShl converts the given LLVM IR shl expression to a corresponding Go
 // statement.
 func (d *decompiler) exprShl(expr *constant.ExprShl) ast.Expr {
-	return d.binaryOp(expr.X, token.SHL, expr.Y)
+	return d.intOp(expr.X, token.SHL, expr.Y)
 }
 
 // exprLShr converts the given LLVM IR lshr expression to a corresponding Go
 // statement.
 func (d *decompiler) exprLShr(expr *constant.ExprLShr) ast.Expr {
-	return d.binaryOp(expr.X, token.SHR, expr.Y)
+	return d.unsignedOp(expr.X, token.SHR, expr.Y)
 }
 
 // exprAShr converts the given LLVM IR ashr expression to a corresponding Go
 // statement.
 func (d *decompiler) exprAShr(expr *constant.ExprAShr) ast.Expr {
-	return d.binaryOp(expr.X, token.SHR, expr.Y)
+	return d.intOp(expr.X, token.SHR, expr.Y)
 }
 
 // exprAnd converts the given LLVM IR and expression to a corresponding Go
 // statement.
 func (d *decompiler) exprAnd(expr *constant.ExprAnd) ast.Expr {
-	return d.binaryOp(expr.X, token.AND, expr.Y)
+	return d.intOp(expr.X, token.AND, expr.Y)
 }
 
 // exprOr converts the given LLVM IR or expression to a corresponding Go
 // statement.
 func (d *decompiler) exprOr(expr *constant.ExprOr) ast.Expr {
-	return d.binaryOp(expr.X, token.OR, expr.Y)
+	return d.intOp(expr.X, token.OR, expr.Y)
 }
 
 // exprXor converts the given LLVM IR xor expression to a corresponding Go
 // statement.
 func (d *decompiler) exprXor(expr *constant.ExprXor) ast.Expr {
-	return d.binaryOp(expr.X, token.XOR, expr.Y)
+	return d.intOp(expr.X, token.XOR, expr.Y)
+}
+
+// exprExtractElement converts the given LLVM IR extractelement expression to a
+// corresponding Go expression.
+func (d *decompiler) exprExtractElement(expr *constant.ExprExtractElement) ast.Expr {
+	return &ast.IndexExpr{
+		X:     d.constant(expr.X),
+		Index: d.constant(expr.Index),
+	}
+}
+
+// exprInsertElement converts the given LLVM IR insertelement expression to a
+// corresponding Go expression.
+func (d *decompiler) exprInsertElement(expr *constant.ExprInsertElement) ast.Expr {
+	index := func(dst ast.Expr) ast.Expr {
+		return &ast.IndexExpr{
+			X:     dst,
+			Index: d.constant(expr.Index),
+		}
+	}
+	return d.insertExpr(expr.X, index, expr.Elem)
+}
+
+// exprShuffleVector converts the given LLVM IR shufflevector expression to a
+// corresponding Go expression.
+func (d *decompiler) exprShuffleVector(expr *constant.ExprShuffleVector) ast.Expr {
+	return d.shuffleVector(expr.X, expr.Y, expr.Mask, expr.Type())
+}
+
+// exprExtractValue converts the given LLVM IR extractvalue expression to a
+// corresponding Go expression.
+func (d *decompiler) exprExtractValue(expr *constant.ExprExtractValue) ast.Expr {
+	return d.aggregateElem(d.constant(expr.X), expr.X.Type(), expr.Indices)
+}
+
+// exprInsertValue converts the given LLVM IR insertvalue expression to a
+// corresponding Go expression.
+func (d *decompiler) exprInsertValue(expr *constant.ExprInsertValue) ast.Expr {
+	index := func(dst ast.Expr) ast.Expr {
+		return d.aggregateElem(dst, expr.X.Type(), expr.Indices)
+	}
+	return d.insertExpr(expr.X, index, expr.Elem)
 }
 
 // exprGetElementPtr converts the given LLVM IR getelementptr expression to a
 // corresponding Go statement.
 func (d *decompiler) exprGetElementPtr(expr *constant.ExprGetElementPtr) ast.Expr {
-	src := d.constant(expr.Src)
-	// TODO: Validate if index expressions should be added in reverse order.
+	var indices []value.Value
 	for _, index := range expr.Indices {
-		src = &ast.IndexExpr{
-			X:     src,
-			Index: d.constant(index),
-		}
+		indices = append(indices, index)
 	}
-	e := &ast.UnaryExpr{
-		Op: token.AND,
-		X:  src,
-	}
-	return e
+	return d.gep(expr.Src, expr.ElemType, indices)
 }
 
 // exprTrunc converts the given LLVM IR trunc expression to a corresponding Go
 // statement.
 func (d *decompiler) exprTrunc(expr *constant.ExprTrunc) ast.Expr {
-	return d.convert(expr.From, expr.To)
+	return d.convertTrunc(expr.From, expr.To)
 }
 
 // exprZExt converts the given LLVM IR zext expression to a corresponding Go
 // statement.
 func (d *decompiler) exprZExt(expr *constant.ExprZExt) ast.Expr {
-	return d.convert(expr.From, expr.To)
+	return d.convertUnsigned(expr.From, expr.To)
 }
 
 // exprSExt converts the given LLVM IR sext expression to a corresponding Go
 // statement.
 func (d *decompiler) exprSExt(expr *constant.ExprSExt) ast.Expr {
-	return d.convert(expr.From, expr.To)
+	return d.convertSExt(expr.From, expr.To)
 }
 
 // exprFPTrunc converts the given LLVM IR fptrunc expression to a corresponding
@@ -373,25 +444,25 @@ func (d *decompiler) exprFPExt(expr *constant.ExprFPExt) ast.Expr {
 // exprFPToUI converts the given LLVM IR fptoui expression to a corresponding Go
 // statement.
 func (d *decompiler) exprFPToUI(expr *constant.ExprFPToUI) ast.Expr {
-	return d.convert(expr.From, expr.To)
+	return d.convertToUnsigned(expr.From, expr.To)
 }
 
 // exprFPToSI converts the given LLVM IR fptosi expression to a corresponding Go
 // statement.
 func (d *decompiler) exprFPToSI(expr *constant.ExprFPToSI) ast.Expr {
-	return d.convert(expr.From, expr.To)
+	return d.convertFPToSI(expr.From, expr.To)
 }
 
 // exprUIToFP converts the given LLVM IR uitofp expression to a corresponding Go
 // statement.
 func (d *decompiler) exprUIToFP(expr *constant.ExprUIToFP) ast.Expr {
-	return d.convert(expr.From, expr.To)
+	return d.convertUnsigned(expr.From, expr.To)
 }
 
 // exprSIToFP converts the given LLVM IR sitofp expression to a corresponding Go
 // statement.
 func (d *decompiler) exprSIToFP(expr *constant.ExprSIToFP) ast.Expr {
-	return d.convert(expr.From, expr.To)
+	return d.convertSIToFP(expr.From, expr.To)
 }
 
 // exprPtrToInt converts the given LLVM IR ptrtoint expression to a
@@ -421,15 +492,13 @@ func (d *decompiler) exprAddrSpaceCast(expr *constant.ExprAddrSpaceCast) ast.Exp
 // exprICmp converts the given LLVM IR icmp expression to a corresponding Go
 // statement.
 func (d *decompiler) exprICmp(expr *constant.ExprICmp) ast.Expr {
-	op := intPred(expr.Pred)
-	return d.binaryOp(expr.X, op, expr.Y)
+	return d.intCmp(expr.Pred, expr.X, expr.Y)
 }
 
 // exprFCmp converts the given LLVM IR fcmp expression to a corresponding Go
 // statement.
 func (d *decompiler) exprFCmp(expr *constant.ExprFCmp) ast.Expr {
-	op := floatPred(expr.Pred)
-	return d.binaryOp(expr.X, op, expr.Y)
+	return d.floatCmp(expr.Pred, expr.X, expr.Y)
 }
 
 // exprSelect converts the given LLVM IR select expression to a corresponding Go
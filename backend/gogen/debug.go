@@ -0,0 +1,463 @@
+package gogen
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// debugInfo records source names recovered from the debug metadata of an LLVM
+// IR module (e.g. as emitted by clang -g). Identifiers without a source name
+// are named after their LLVM IR identifiers.
+type debugInfo struct {
+	// Map from LLVM IR global identifier (without '@' prefix) to source name.
+	globalNames map[string]string
+	// Map from function name to map from LLVM IR local identifier (without '%'
+	// prefix) to source name.
+	localNames map[string]map[string]string
+	// Map from LLVM IR type name (without '%' prefix) to source name.
+	typeNames map[string]string
+	// Map from LLVM IR struct type name (without '%' prefix) to source field
+	// names.
+	fieldNames map[string][]string
+}
+
+// newDebugInfo recovers source names from the debug metadata of the given LLVM
+// IR module. Source names are made unique within their scope, and do not
+// conflict with Go keywords and predeclared identifiers.
+//
+// Function and global variable names are recovered from the !dbg attachments
+// of DISubprogram and DIGlobalVariableExpression metadata nodes; parameter and
+// local variable names from the DILocalVariable operands of llvm.dbg.declare
+// and llvm.dbg.value; and struct and field names from DICompositeType metadata
+// nodes.
+//
+// User-supplied type annotations take precedence over names recovered from debug
+// metadata.
+func newDebugInfo(m *ir.Module, types map[string]*TypeAnnotation) *debugInfo {
+	info := &debugInfo{
+		globalNames: make(map[string]string),
+		localNames:  make(map[string]map[string]string),
+		typeNames:   make(map[string]string),
+		fieldNames:  make(map[string][]string),
+	}
+	typeRenames := info.recoverTypes(m)
+	info.annotateTypes(m, types, typeRenames)
+	globalRenames := recoverGlobals(m)
+	// Types, global variables and functions share the package scope. Names of
+	// identifiers without a source name are reserved.
+	used := make(map[string]bool)
+	for _, t := range m.TypeDefs {
+		if _, ok := typeRenames[t.Name()]; !ok {
+			used[ident(t.Name()).Name] = true
+		}
+	}
+	for _, g := range m.Globals {
+		if _, ok := globalRenames[g.Name()]; !ok {
+			used[ident(g.Name()).Name] = true
+		}
+	}
+	for _, f := range m.Funcs {
+		if _, ok := globalRenames[f.Name()]; !ok {
+			used[ident(f.Name()).Name] = true
+		}
+	}
+	// Assign names in module order, for deterministic output.
+	for _, t := range m.TypeDefs {
+		if name, ok := typeRenames[t.Name()]; ok {
+			info.typeNames[t.Name()] = uniqueName(name, used)
+		}
+	}
+	for _, g := range m.Globals {
+		if name, ok := globalRenames[g.Name()]; ok {
+			info.globalNames[g.Name()] = uniqueName(name, used)
+		}
+	}
+	for _, f := range m.Funcs {
+		if name, ok := globalRenames[f.Name()]; ok {
+			info.globalNames[f.Name()] = uniqueName(name, used)
+		}
+	}
+	for _, f := range m.Funcs {
+		if len(f.Blocks) == 0 {
+			continue
+		}
+		if names := info.recoverLocals(f); len(names) > 0 {
+			info.localNames[f.Name()] = names
+		}
+	}
+	return info
+}
+
+// recoverTypes recovers the names of the fields of the struct types of the
+// given module, and returns a map from LLVM IR struct type name to source name.
+func (info *debugInfo) recoverTypes(m *ir.Module) map[string]string {
+	// Index named composite types by source name.
+	composites := make(map[string]*metadata.DICompositeType)
+	for _, def := range m.MetadataDefs {
+		switch md := def.(type) {
+		case *metadata.DICompositeType:
+			if isRecordTag(md.Tag) && len(md.Name) > 0 {
+				composites[md.Name] = md
+			}
+		case *metadata.DIDerivedType:
+			// Anonymous structs of typedefs (e.g. typedef struct { ... } T).
+			if md.Tag != enum.DwarfTagTypedef {
+				continue
+			}
+			if base, ok := md.BaseType.(*metadata.DICompositeType); ok && isRecordTag(base.Tag) && len(base.Name) == 0 {
+				composites[md.Name] = base
+			}
+		}
+	}
+	renames := make(map[string]string)
+	for _, t := range m.TypeDefs {
+		st, ok := t.(*irtypes.StructType)
+		if !ok {
+			continue
+		}
+		name := sourceTypeName(st.Name())
+		md, ok := composites[name]
+		if !ok {
+			continue
+		}
+		renames[st.Name()] = name
+		if fields := memberNames(md); len(fields) == len(st.Fields) {
+			used := make(map[string]bool)
+			for i, field := range fields {
+				if len(field) == 0 {
+					// Anonymous member (e.g. unnamed union).
+					field = fmt.Sprintf("field_%d", i)
+				}
+				fields[i] = uniqueName(field, used)
+			}
+			info.fieldNames[st.Name()] = fields
+		}
+	}
+	return renames
+}
+
+// A TypeAnnotation is a user-supplied annotation of an LLVM IR type definition,
+// which names the type and its struct fields.
+type TypeAnnotation struct {
+	// Go name of the type; or empty to keep the name of the LLVM IR type.
+	Name string `json:"name,omitempty"`
+	// Go names of struct fields, by field index; empty names and missing fields
+	// are named as if not annotated.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// LoadTypeAnnotations parses the given JSON file of type annotations, which
+// maps LLVM IR type names (without '%' prefix) to type annotations.
+//
+//    {
+//       "struct.point": {"name": "Point", "fields": ["X", "Y"]},
+//       "struct.list": {"fields": ["", "Next"]}
+//    }
+func LoadTypeAnnotations(jsonPath string) (map[string]*TypeAnnotation, error) {
+	buf, err := ioutil.ReadFile(jsonPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var types map[string]*TypeAnnotation
+	if err := json.Unmarshal(buf, &types); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse type annotations %q", jsonPath)
+	}
+	return types, nil
+}
+
+// annotateTypes overrides the type and field names of the given module with
+// the user-supplied type annotations. Type renames are recorded in renames.
+func (info *debugInfo) annotateTypes(m *ir.Module, types map[string]*TypeAnnotation, renames map[string]string) {
+	for _, t := range m.TypeDefs {
+		annot, ok := types[t.Name()]
+		if !ok || annot == nil {
+			continue
+		}
+		if len(annot.Name) > 0 {
+			renames[t.Name()] = annot.Name
+		}
+		st, ok := t.(*irtypes.StructType)
+		if !ok || len(annot.Fields) == 0 {
+			continue
+		}
+		fields := make([]string, len(st.Fields))
+		for i := range fields {
+			fields[i] = fmt.Sprintf("field_%d", i)
+			if names, ok := info.fieldNames[st.Name()]; ok {
+				fields[i] = names[i]
+			}
+			if i < len(annot.Fields) && len(annot.Fields[i]) > 0 {
+				fields[i] = annot.Fields[i]
+			}
+		}
+		// Annotated names take precedence over the remaining field names.
+		used := make(map[string]bool)
+		for i, field := range fields {
+			if i < len(annot.Fields) && len(annot.Fields[i]) > 0 {
+				fields[i] = uniqueName(field, used)
+			}
+		}
+		for i, field := range fields {
+			if i >= len(annot.Fields) || len(annot.Fields[i]) == 0 {
+				fields[i] = uniqueName(field, used)
+			}
+		}
+		info.fieldNames[st.Name()] = fields
+	}
+}
+
+// isRecordTag reports whether the given DWARF tag denotes a struct, class or
+// union type.
+func isRecordTag(tag enum.DwarfTag) bool {
+	switch tag {
+	case enum.DwarfTagStructureType, enum.DwarfTagClassType, enum.DwarfTagUnionType:
+		return true
+	}
+	return false
+}
+
+// sourceTypeName returns the source name of the given LLVM IR struct type name,
+// as emitted by clang (e.g. "point" of "struct.point").
+func sourceTypeName(name string) string {
+	for _, prefix := range []string{"struct.", "class.", "union."} {
+		if strings.HasPrefix(name, prefix) {
+			return name[len(prefix):]
+		}
+	}
+	return name
+}
+
+// memberNames returns the names of the non-static data members of the given
+// composite type, in order of declaration.
+func memberNames(md *metadata.DICompositeType) []string {
+	if md.Elements == nil {
+		return nil
+	}
+	var names []string
+	for _, elem := range md.Elements.Fields {
+		member, ok := elem.(*metadata.DIDerivedType)
+		if !ok || member.Tag != enum.DwarfTagMember || member.Flags&enum.DIFlagStaticMember != 0 {
+			continue
+		}
+		names = append(names, member.Name)
+	}
+	return names
+}
+
+// recoverGlobals returns a map from LLVM IR global identifier to source name
+// of the global variables and functions of the given module. The names of
+// external functions and the main function are kept as is.
+func recoverGlobals(m *ir.Module) map[string]string {
+	renames := make(map[string]string)
+	for _, g := range m.Globals {
+		for _, md := range g.Metadata {
+			if md.Name != "dbg" {
+				continue
+			}
+			if expr, ok := md.Node.(*metadata.DIGlobalVariableExpression); ok && expr.Var != nil {
+				renames[g.Name()] = expr.Var.Name
+			}
+		}
+	}
+	for _, f := range m.Funcs {
+		if len(f.Blocks) == 0 || f.Name() == "main" {
+			continue
+		}
+		for _, md := range f.Metadata {
+			if md.Name != "dbg" {
+				continue
+			}
+			if sp, ok := md.Node.(*metadata.DISubprogram); ok && len(sp.Name) > 0 {
+				renames[f.Name()] = sp.Name
+			}
+		}
+	}
+	return renames
+}
+
+// recoverLocals returns the source names of the parameters and local variables
+// of the given function, as recovered from calls to llvm.dbg.declare and
+// llvm.dbg.value. The memory of parameters spilled to the stack (e.g. by clang
+// -O0) is named after the parameter with an "_addr" suffix.
+func (info *debugInfo) recoverLocals(f *ir.Func) map[string]string {
+	// Force generate local IDs.
+	_ = f.String()
+	type rename struct {
+		v    value.Named
+		name string
+	}
+	var renames []rename
+	renamed := make(map[value.Named]bool)
+	add := func(v value.Named, name string) {
+		if renamed[v] {
+			return
+		}
+		renamed[v] = true
+		renames = append(renames, rename{v: v, name: name})
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			v, dv, ok := dbgVar(inst)
+			if !ok {
+				continue
+			}
+			if dv.Arg > 0 && int(dv.Arg) <= len(f.Params) {
+				param := f.Params[dv.Arg-1]
+				add(param, dv.Name)
+				if v != value.Named(param) {
+					add(v, dv.Name+"_addr")
+				}
+				continue
+			}
+			add(v, dv.Name)
+		}
+	}
+	if len(renames) == 0 {
+		return nil
+	}
+	// Names of package-level identifiers are reserved, as local names would
+	// otherwise shadow them.
+	used := make(map[string]bool)
+	if m := f.Parent; m != nil {
+		for _, t := range m.TypeDefs {
+			used[info.typeName(t.Name())] = true
+		}
+		for _, g := range m.Globals {
+			used[info.globalName(g.Name())] = true
+		}
+		for _, g := range m.Funcs {
+			used[info.globalName(g.Name())] = true
+		}
+	}
+	for _, param := range f.Params {
+		if !renamed[param] && !isID(param.Name()) {
+			used[ident(param.Name()).Name] = true
+		}
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if v, ok := inst.(value.Named); ok && !renamed[v] && !isID(v.Name()) {
+				used[ident(v.Name()).Name] = true
+			}
+		}
+	}
+	names := make(map[string]string)
+	for _, r := range renames {
+		names[r.v.Name()] = uniqueName(r.name, used)
+	}
+	return names
+}
+
+// dbgVar returns the local variable and its debug metadata described by the
+// given instruction, if the instruction is a call to llvm.dbg.declare or
+// llvm.dbg.value. The boolean return value indicates success.
+//
+//    call void @llvm.dbg.declare(metadata i32* %x, metadata !12, metadata !DIExpression())
+func dbgVar(inst ir.Instruction) (value.Named, *metadata.DILocalVariable, bool) {
+	call, ok := inst.(*ir.InstCall)
+	if !ok || len(call.Args) < 2 {
+		return nil, nil, false
+	}
+	callee, ok := call.Callee.(*ir.Func)
+	if !ok {
+		return nil, nil, false
+	}
+	switch callee.Name() {
+	case "llvm.dbg.declare", "llvm.dbg.value":
+	default:
+		return nil, nil, false
+	}
+	x, ok := call.Args[0].(*metadata.Value)
+	if !ok {
+		return nil, nil, false
+	}
+	v, ok := x.Value.(value.Named)
+	if !ok {
+		return nil, nil, false
+	}
+	switch v.(type) {
+	case *ir.Global, *ir.Func:
+		return nil, nil, false
+	}
+	y, ok := call.Args[1].(*metadata.Value)
+	if !ok {
+		return nil, nil, false
+	}
+	dv, ok := y.Value.(*metadata.DILocalVariable)
+	if !ok || len(dv.Name) == 0 {
+		return nil, nil, false
+	}
+	return v, dv, true
+}
+
+// uniqueName returns a valid Go identifier based on the given source name,
+// which is not present in used, and marks it as used. Conflicts are resolved
+// by adding a numeric suffix.
+func uniqueName(name string, used map[string]bool) string {
+	name = ident(name).Name
+	if len(name) == 0 || isID(name) || isReserved(name) {
+		name += "_"
+	}
+	unique := name
+	for i := 1; used[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// isReserved reports whether the given name is a Go keyword or a predeclared
+// identifier used by decompiled Go source code.
+func isReserved(name string) bool {
+	if token.Lookup(name).IsKeyword() {
+		return true
+	}
+	switch name {
+	case "bool", "byte", "copy", "false", "float32", "float64", "int", "len", "make", "new", "nil", "panic", "rune", "string", "true", "uintptr":
+		return true
+	}
+	// Integer types not part of Go builtin (e.g. int24), newIntNNN and
+	// newFloatNNN.
+	for _, prefix := range []string{"int", "uint", "newInt", "newFloat"} {
+		if strings.HasPrefix(name, prefix) && isID(name[len(prefix):]) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeName returns the Go name of the given LLVM IR type name.
+func (info *debugInfo) typeName(name string) string {
+	if name, ok := info.typeNames[name]; ok {
+		return name
+	}
+	return ident(name).Name
+}
+
+// globalName returns the Go name of the given LLVM IR global identifier.
+func (info *debugInfo) globalName(name string) string {
+	if name, ok := info.globalNames[name]; ok {
+		return name
+	}
+	return ident(name).Name
+}
+
+// fieldIdent returns the Go identifier of the i:th field of the given LLVM IR
+// struct type; the source name if known, and "field_i" otherwise.
+func (d *decompiler) fieldIdent(t *irtypes.StructType, i int) *ast.Ident {
+	if names, ok := d.debug.fieldNames[t.Name()]; ok {
+		return ast.NewIdent(names[i])
+	}
+	return ast.NewIdent(fmt.Sprintf("field_%d", i))
+}
@@ -0,0 +1,565 @@
+package gogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/decomp/decomp/internal/outssa"
+	"github.com/decomp/decomp/srcmap"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// A decompiler keeps track of relevant information during the decompilation
+// process.
+type decompiler struct {
+	// Global states.
+
+	// Tracks use of integer types not part of Go builtin.
+	intSizes map[uint64]bool
+	// Tracks use of unsigned integer types not part of Go builtin.
+	uintSizes map[uint64]bool
+	// Tracks use of newIntNNN function calls.
+	newIntSizes map[uint64]bool
+	// Tracks use of newFloatNNN function calls.
+	newFloatKinds map[irtypes.FloatKind]bool
+	// Tracks imported packages, by import path.
+	imports map[string]bool
+	// Tracks functions with a lowering, of which calls were converted as is.
+	unlowered map[string]bool
+	// Source names recovered from debug metadata; shared between decompilers.
+	debug *debugInfo
+	// Data layout of the LLVM IR module; shared between decompilers.
+	layout *dataLayout
+	// Map from Go statement to lines of the originating LLVM IR, attached as
+	// comments; nil if disabled.
+	comments map[ast.Stmt][]string
+	// Map from Go statement to its originating LLVM IR, as recorded in source
+	// maps; nil if disabled.
+	origins map[ast.Stmt]srcmap.Origin
+
+	// Per function states.
+
+	// Map from basic block label to conceptual basic block.
+	blocks map[string]*basicBlock
+	// Track use of basic block labels.
+	labels map[string]bool
+	// Map from invoke and callbr terminator to the outgoing values for PHI
+	// instructions of its normal destination; assigned after the call.
+	normal map[ir.Terminator][]ast.Stmt
+	// Name of the function being decompiled.
+	funcName string
+	// Map from local identifier to source name of the function being
+	// decompiled.
+	localNames map[string]string
+	// Variable declarations hoisted to the beginning of the function body.
+	decls []ast.Stmt
+	// Track hoisted variables.
+	hoisted map[string]bool
+	// Source map origins of the instructions and terminators of the function
+	// being decompiled.
+	instOrigins map[ir.LLStringer]srcmap.Origin
+}
+
+// newDecompiler returns a new decompiler.
+func newDecompiler() *decompiler {
+	return &decompiler{
+		intSizes:      make(map[uint64]bool),
+		uintSizes:     make(map[uint64]bool),
+		newIntSizes:   make(map[uint64]bool),
+		newFloatKinds: make(map[irtypes.FloatKind]bool),
+		imports:       make(map[string]bool),
+		unlowered:     make(map[string]bool),
+		debug:         &debugInfo{},
+		layout:        defaultDataLayout(),
+	}
+}
+
+// merge merges the global states of the given decompiler into d.
+func (d *decompiler) merge(other *decompiler) {
+	for intSize := range other.intSizes {
+		d.intSizes[intSize] = true
+	}
+	for uintSize := range other.uintSizes {
+		d.uintSizes[uintSize] = true
+	}
+	for newIntSize := range other.newIntSizes {
+		d.newIntSizes[newIntSize] = true
+	}
+	for newFloatKind := range other.newFloatKinds {
+		d.newFloatKinds[newFloatKind] = true
+	}
+	for path := range other.imports {
+		d.imports[path] = true
+	}
+	for name := range other.unlowered {
+		d.unlowered[name] = true
+	}
+	for stmt, lines := range other.comments {
+		d.comments[stmt] = lines
+	}
+	for stmt, origin := range other.origins {
+		d.origins[stmt] = origin
+	}
+}
+
+// typeDef converts the given LLVM IR type into a corresponding Go type
+// definition.
+func (d *decompiler) typeDef(t irtypes.Type) *ast.GenDecl {
+	spec := &ast.TypeSpec{
+		Name: d.typeIdent(t.Name()),
+		Type: d.goTypeDef(t),
+	}
+	return &ast.GenDecl{
+		Tok:   token.TYPE,
+		Specs: []ast.Spec{spec},
+	}
+}
+
+// globalDecl converts the given LLVM IR global into a corresponding Go variable
+// declaration.
+func (d *decompiler) globalDecl(g *ir.Global) *ast.GenDecl {
+	if g, s, ok := stringGlobal(g); ok {
+		return d.stringDecl(g, s)
+	}
+	spec := &ast.ValueSpec{
+		Names: []*ast.Ident{d.globalIdent(g.Name())},
+		Type:  d.goType(g.Typ),
+	}
+	if g.Init != nil {
+		// handle value initialization of global definition.
+		spec.Values = []ast.Expr{d.pointerToConst(g.Init)}
+	}
+	return &ast.GenDecl{
+		Tok:   token.VAR,
+		Specs: []ast.Spec{spec},
+	}
+}
+
+// pointerToConst converts the given LLVM IR constant to a pointer to c and
+// returns the corresponding Go expression.
+func (d *decompiler) pointerToConst(c constant.Constant) ast.Expr {
+	switch c := c.(type) {
+	// Simple constants
+	case *constant.Int:
+		callee := fmt.Sprintf("newInt%d", c.Typ.BitSize)
+		d.newIntSizes[c.Typ.BitSize] = true
+		return &ast.CallExpr{
+			Fun:  ast.NewIdent(callee),
+			Args: []ast.Expr{d.value(c)},
+		}
+	case *constant.Float:
+		d.newFloatKinds[c.Typ.Kind] = true
+		return &ast.CallExpr{
+			Fun:  ast.NewIdent(newFloatName(c.Typ)),
+			Args: []ast.Expr{d.value(c)},
+		}
+	case *constant.Null:
+		// nothing to do.
+		return d.value(c)
+	// Complex constants
+	case *constant.Vector, *constant.Array, *constant.CharArray, *constant.Struct:
+		return &ast.UnaryExpr{
+			Op: token.AND,
+			X:  d.value(c),
+		}
+	case *constant.ZeroInitializer:
+		return &ast.CallExpr{
+			Fun:  ast.NewIdent("new"),
+			Args: []ast.Expr{d.goType(c.Typ)},
+		}
+	// Global variable addresses and constant expressions
+	case *ir.Global, constant.Expression:
+		return d.newValue(c)
+	case *ir.Func:
+		// TODO: Check if `&f` should be returned instead of `f`.
+		return d.globalIdent(c.Name())
+	default:
+		panic(fmt.Sprintf("support for value %T not yet implemented", c))
+	}
+}
+
+// newValue returns a Go expression of a pointer to a new variable initialized
+// to the given LLVM IR constant.
+//
+//    &[]*int8{libc.CString(_str)}[0]
+func (d *decompiler) newValue(c constant.Constant) ast.Expr {
+	lit := &ast.CompositeLit{
+		Type: &ast.ArrayType{Elt: d.goType(c.Type())},
+		Elts: []ast.Expr{d.constant(c)},
+	}
+	return &ast.UnaryExpr{
+		Op: token.AND,
+		X:  &ast.IndexExpr{X: lit, Index: d.intLit(0)},
+	}
+}
+
+// funcDecl converts the given LLVM IR function into a corresponding Go function
+// declaration.
+func (d *decompiler) funcDecl(f *ir.Func, prims []*primitive.Primitive) (*ast.FuncDecl, error) {
+	// Force generate local IDs.
+	_ = f.String()
+
+	// Recover function declaration.
+	d.localNames = d.debug.localNames[f.Name()]
+	typ := d.goType(f.Sig)
+	sig := typ.(*ast.FuncType)
+	for i, p := range f.Params {
+		paramName := d.localIdent(p.Name())
+		if len(f.Blocks) == 0 && p.IsUnnamed() {
+			// Local IDs are not assigned to the parameters of function
+			// declarations; name them by index.
+			paramName = ast.NewIdent(fmt.Sprintf("_%d", i))
+		}
+		if len(sig.Params.List[i].Names) < 1 {
+			sig.Params.List[i].Names = make([]*ast.Ident, 1)
+		}
+		sig.Params.List[i].Names[0] = paramName
+	}
+	if f.Sig.Variadic {
+		// Name the variadic parameter, as accessed by llvm.va_start.
+		va := sig.Params.List[len(sig.Params.List)-1]
+		va.Names = []*ast.Ident{ast.NewIdent(vaArgsName)}
+	}
+	fn := &ast.FuncDecl{
+		Name: d.globalIdent(f.Name()),
+		Type: sig,
+	}
+	if len(f.Blocks) == 0 {
+		return fn, nil
+	}
+
+	// Reset labels tracker.
+	d.labels = make(map[string]bool)
+
+	// Reset hoisted variable declarations.
+	d.funcName = f.Name()
+	d.decls = nil
+	d.hoisted = make(map[string]bool)
+
+	// Reset source map origins.
+	d.initOrigins(f)
+
+	// Reset basic block mapping.
+	d.blocks = make(map[string]*basicBlock)
+	for i, block := range f.Blocks {
+		d.blocks[block.Name()] = &basicBlock{Block: block, num: i}
+	}
+
+	// Record incoming and outgoing PHI values. PHI instructions are replaced by
+	// copies at the end of predecessor basic blocks, and at the beginning of
+	// their basic block when assigned through temporary variables (see
+	// outssa.Eliminate).
+	copies := outssa.Eliminate(f)
+	d.normal = make(map[ir.Terminator][]ast.Stmt)
+	for _, block := range f.Blocks {
+		b := d.blocks[block.Name()]
+		b.in = d.copyStmts(copies.In[block])
+		b.out = d.copyStmts(copies.Out[block])
+		d.normal[block.Term] = d.copyStmts(copies.Normal[block])
+	}
+
+	// Recover control flow primitives.
+	for _, prim := range prims {
+		block, err := d.prim(prim)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		// Delete merged basic blocks.
+		for _, node := range prim.Nodes {
+			delete(d.blocks, node)
+		}
+		// Add primitive basic block.
+		d.blocks[block.Name()] = block
+	}
+
+	// A single remaining basic block indicates successful control flow recovery.
+	// If more than one basic block remains, unstructured control flow is added
+	// using goto-statements.
+	var blocks basicBlocks
+	for _, block := range d.blocks {
+		blocks = append(blocks, block)
+	}
+	sort.Sort(blocks)
+	for _, block := range blocks {
+		block.stmts = d.stmts(block)
+		block.stmts = append(block.stmts, d.terms(block.Term)...)
+	}
+
+	// Insert labels of target branches into corresponding basic blocks.
+	for label := range d.labels {
+		// Insert label.
+		block, ok := d.blocks[label]
+		if !ok {
+			return nil, errors.Errorf("unable to locate basic block %q", label)
+		}
+		if len(block.stmts) < 1 {
+			// A terminator statement should always be present.
+			return nil, errors.New("empty basic block; expected at least 1 statement")
+		}
+		labelStmt := &ast.LabeledStmt{
+			Label: d.label(block.Name()),
+			Stmt:  block.stmts[0],
+		}
+		block.stmts[0] = labelStmt
+	}
+
+	var stmts []ast.Stmt
+	for _, block := range blocks {
+		stmts = append(stmts, block.stmts...)
+	}
+	decls := append(d.decls, d.localDecls(f, stmts)...)
+	body := &ast.BlockStmt{
+		List: append(decls, stmts...),
+	}
+	fn.Body = body
+	return fn, nil
+}
+
+// localDecls returns variable declarations of the local variables of the given
+// function, as used by the specified statements of its body. Assignments to
+// local variables which are never used are replaced by assignments to the blank
+// identifier, as unused variables are not valid Go.
+//
+//    var _1 int32
+//    _ = libc.Count(fmt.Println(_str))
+func (d *decompiler) localDecls(f *ir.Func, stmts []ast.Stmt) []ast.Stmt {
+	// Identify assigned and used local variables.
+	assigned := make(map[string][]*ast.Ident)
+	lhs := make(map[*ast.Ident]bool)
+	declared := make(map[string]bool)
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				if n.Tok != token.ASSIGN {
+					break
+				}
+				for _, x := range n.Lhs {
+					if x, ok := x.(*ast.Ident); ok {
+						assigned[x.Name] = append(assigned[x.Name], x)
+						lhs[x] = true
+					}
+				}
+			case *ast.ValueSpec:
+				for _, name := range n.Names {
+					declared[name.Name] = true
+				}
+			}
+			return true
+		})
+	}
+	used := make(map[string]bool)
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if x, ok := n.(*ast.Ident); ok && !lhs[x] {
+				used[x.Name] = true
+			}
+			return true
+		})
+	}
+	var decls []ast.Stmt
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			v, ok := inst.(value.Named)
+			if !ok || irtypes.Equal(v.Type(), irtypes.Void) || d.hoisted[v.Name()] {
+				continue
+			}
+			name := d.localIdent(v.Name()).Name
+			if declared[name] {
+				continue
+			}
+			if !used[name] {
+				for _, x := range assigned[name] {
+					x.Name = "_"
+				}
+				continue
+			}
+			decls = append(decls, d.varDecl(v.Name(), d.goType(v.Type())))
+		}
+	}
+	return decls
+}
+
+// copyStmts converts the given copies replacing PHI instructions into a
+// corresponding list of Go statements.
+//
+//    _2_phi = _2
+//    _2 = _3
+//    _3 = _2_phi
+func (d *decompiler) copyStmts(copies []*outssa.Copy) []ast.Stmt {
+	var stmts []ast.Stmt
+	for _, c := range copies {
+		dst := c.Dst.Name()
+		if c.DstTemp {
+			dst = d.tempName(c.Dst)
+			d.hoist(dst, d.goType(c.Dst.Type()))
+		}
+		src := d.value(c.Src)
+		if c.SrcTemp {
+			src = d.localIdent(d.tempName(c.Src.(*ir.InstPhi)))
+		}
+		stmt := d.assign(dst, src)
+		d.instComment([]ast.Stmt{stmt}, c.Dst)
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+// tempName returns the name of the temporary variable of the given PHI
+// instruction.
+func (d *decompiler) tempName(phi *ir.InstPhi) string {
+	return d.localIdent(phi.Name()).Name + "_phi"
+}
+
+// globalIdent converts the given LLVM IR type identifier to a corresponding Go
+// identifier.
+func (d *decompiler) typeIdent(name string) *ast.Ident {
+	if name, ok := d.debug.typeNames[name]; ok {
+		return ast.NewIdent(name)
+	}
+	if isID(name) {
+		name = "_" + name
+	}
+	return ident(name)
+}
+
+// globalIdent converts the given LLVM IR global identifier to a corresponding
+// Go identifier.
+func (d *decompiler) globalIdent(name string) *ast.Ident {
+	if name, ok := d.debug.globalNames[name]; ok {
+		return ast.NewIdent(name)
+	}
+	if isID(name) {
+		name = "_" + name
+	}
+	return ident(name)
+}
+
+// localIdent converts the given LLVM IR local identifier to a corresponding Go
+// identifier.
+func (d *decompiler) localIdent(name string) *ast.Ident {
+	if name, ok := d.localNames[name]; ok {
+		return ast.NewIdent(name)
+	}
+	if isID(name) {
+		name = "_" + name
+	}
+	return ident(name)
+}
+
+// isID reports if the given string is an unnamed identifier.
+func isID(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ident returns a sanitized version of the given identifier.
+func ident(s string) *ast.Ident {
+	s = strings.Replace(s, ".", "dot", -1)
+	f := func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r):
+			// valid rune in identifier.
+			return r
+		}
+		return '_'
+	}
+	return ast.NewIdent(strings.Map(f, s))
+}
+
+// label converts the given LLVM IR basic block label to a corresponding Go
+// identifier.
+func (d *decompiler) label(name string) *ast.Ident {
+	name = "block_" + name
+	return ident(name)
+}
+
+// value converts the given LLVM IR value to a corresponding Go expression.
+func (d *decompiler) value(v value.Value) ast.Expr {
+	switch v := v.(type) {
+	case value.Named:
+		switch v.(type) {
+		case *ir.Global, *ir.Func:
+			if g, _, ok := stringGlobal(v); ok {
+				return d.stringAddr(g)
+			}
+			return d.globalIdent(v.Name())
+		default:
+			return d.localIdent(v.Name())
+		}
+	case constant.Constant:
+		return d.constant(v)
+	default:
+		panic(fmt.Sprintf("support for value %T not yet implemented", v))
+	}
+}
+
+// intLit converts the given integer literal into a corresponding Go expression.
+func (d *decompiler) intLit(i int64) ast.Expr {
+	return &ast.BasicLit{
+		Kind:  token.INT,
+		Value: fmt.Sprintf("%d", i),
+	}
+}
+
+// uintLit converts the given unsigned integer literal into a corresponding Go
+// expression.
+func (d *decompiler) uintLit(i uint64) ast.Expr {
+	return &ast.BasicLit{
+		Kind:  token.INT,
+		Value: fmt.Sprintf("%d", i),
+	}
+}
+
+// basicBlock represents a conceptual basic block, that may contain both LLVM IR
+// instructions and Go statements.
+type basicBlock struct {
+	*ir.Block
+	// Go statements.
+	stmts []ast.Stmt
+	// Incoming values for PHI instructions assigned through temporary
+	// variables. In other words, a list of assignment statements to appear at
+	// the beginning of the basic block.
+	in []ast.Stmt
+	// Outgoing values for PHI instructions. In other words, a list of assignment
+	// statements to appear at the end of the basic block.
+	out []ast.Stmt
+	// Track basic block number in f.Blocks slice, to be used for sorting basic
+	// blocks after incomplete control flow recovery.
+	num int
+}
+
+// basicBlocks implements the sort.Sort interface to sort basic blocks according
+// to their occurrence in f.Blocks.
+type basicBlocks []*basicBlock
+
+func (bs basicBlocks) Less(i, j int) bool { return bs[i].num < bs[j].num }
+func (bs basicBlocks) Len() int           { return len(bs) }
+func (bs basicBlocks) Swap(i, j int)      { bs[i], bs[j] = bs[j], bs[i] }
+
+// stmts converts the incoming PHI values, basic block instructions, recorded
+// statements and outgoing PHI values into a corresponding list of Go
+// statements.
+func (d *decompiler) stmts(block *basicBlock) []ast.Stmt {
+	var stmts []ast.Stmt
+	stmts = append(stmts, block.in...)
+	stmts = append(stmts, d.insts(block.Insts)...)
+	stmts = append(stmts, block.stmts...)
+	stmts = append(stmts, block.out...)
+	d.blockComment(stmts, block)
+	return stmts
+}
+
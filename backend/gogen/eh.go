@@ -0,0 +1,245 @@
+package gogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// ehPath is the import path of the runtime support package for exception
+// handling and indirect branches.
+const ehPath = "github.com/decomp/decomp/rt/eh"
+
+// excName is the name of the local variable holding the exception in flight,
+// as recovered by invoke terminators and consumed by landingpad instructions
+// and resume terminators.
+const excName = "_exc"
+
+// hoist declares the given local variable of the specified type at the
+// beginning of the function body. Variables assigned within function literals
+// (e.g. the result of invoke terminators) are hoisted, as they are not in scope
+// of their uses otherwise.
+//
+//    var _3 int32
+func (d *decompiler) hoist(name string, typ ast.Expr) {
+	if d.hoisted[name] {
+		return
+	}
+	d.hoisted[name] = true
+	d.decls = append(d.decls, d.varDecl(name, typ))
+}
+
+// exc returns a Go identifier of the local variable holding the exception in
+// flight.
+func (d *decompiler) exc() *ast.Ident {
+	d.hoist(excName, &ast.StarExpr{X: d.importSel(ehPath, "Exception")})
+	return ast.NewIdent(excName)
+}
+
+// termInvoke converts the given LLVM IR invoke terminator to a corresponding Go
+// statement. The exception raised by the callee, if any, is recovered by
+// eh.Invoke. Outgoing values for PHI instructions of the normal destination
+// are assigned after the call.
+//
+//    if _exc = eh.Invoke(func() { _3 = f(_1, _2) }); _exc != nil {
+//       goto lpad
+//    } else {
+//       _4 = _3
+//       goto normal
+//    }
+func (d *decompiler) termInvoke(term *ir.TermInvoke) ast.Stmt {
+	if !irtypes.Equal(term.Type(), irtypes.Void) {
+		d.hoist(term.Name(), d.goType(term.Type()))
+	}
+	// Calls to functions with a lowering (e.g. __cxa_throw) are lowered as by
+	// call instructions.
+	inst := ir.NewCall(term.Invokee, term.Args...)
+	inst.LocalIdent = term.LocalIdent
+	callStmts, ok := d.lowerCall(inst)
+	if !ok {
+		expr := d.callExpr(term.Invokee, term.Args)
+		var callStmt ast.Stmt = &ast.ExprStmt{X: expr}
+		if !irtypes.Equal(term.Type(), irtypes.Void) {
+			callStmt = d.assign(term.Name(), expr)
+		}
+		callStmts = []ast.Stmt{callStmt}
+	}
+	fn := &ast.FuncLit{
+		Type: &ast.FuncType{Params: &ast.FieldList{}},
+		Body: &ast.BlockStmt{List: callStmts},
+	}
+	var normalStmts []ast.Stmt
+	normalStmts = append(normalStmts, d.normal[term]...)
+	normalStmts = append(normalStmts, d.gotoStmt(term.NormalRetTarget))
+	exc := d.exc()
+	initStmt := assignExpr(exc, call(d.importSel(ehPath, "Invoke"), fn))
+	cond := &ast.BinaryExpr{
+		X:  exc,
+		Op: token.NEQ,
+		Y:  ast.NewIdent("nil"),
+	}
+	return &ast.IfStmt{
+		Init: initStmt,
+		Cond: cond,
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{d.gotoStmt(term.ExceptionRetTarget)},
+		},
+		Else: &ast.BlockStmt{
+			List: normalStmts,
+		},
+	}
+}
+
+// cxxFuncs maps from the name of external functions of the Itanium C++ ABI to
+// their lowering. Exceptions thrown by __cxa_throw are raised by eh.Throw and
+// caught by the landing pads of invoke terminators; the exception object is
+// passed to catch handlers as is.
+var cxxFuncs = map[string]lowering{
+	"__cxa_allocate_exception": lowerMalloc,
+	"__cxa_begin_catch":        lowerBeginCatch,
+	"__cxa_end_catch":          lowerNop,
+	"__cxa_throw":              lowerThrow,
+}
+
+// lowerThrow lowers calls to __cxa_throw. The destructor of the exception
+// object is ignored, as the exception object is garbage collected.
+//
+//    eh.Throw(ptr, _ZTIi)
+var lowerThrow = valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+	return call(d.importSel(ehPath, "Throw"), d.value(inst.Args[0]), d.value(inst.Args[1])), true
+})
+
+// lowerBeginCatch lowers calls to __cxa_begin_catch, which returns the
+// exception object of the exception caught.
+//
+//    _6 = ptr
+var lowerBeginCatch = valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+	return d.value(inst.Args[0]), true
+})
+
+// instLandingPad converts the given LLVM IR landingpad instruction to a
+// corresponding Go statement. The result is a pair of the exception object
+// pointer and the selector value of the exception in flight. Filter clauses are
+// ignored.
+//
+//    _5 = struct{ field_0 *int8; field_1 int32 }{_exc.Ptr, eh.Selector(_exc, _ZTIi)}
+func (d *decompiler) instLandingPad(inst *ir.InstLandingPad) ast.Stmt {
+	t, ok := inst.ResultType.(*irtypes.StructType)
+	if !ok || len(t.Fields) != 2 {
+		panic(fmt.Sprintf("support for landingpad result type %v not yet implemented", inst.ResultType))
+	}
+	exc := d.exc()
+	args := []ast.Expr{exc}
+	for _, clause := range inst.Clauses {
+		if clause.Type == enum.ClauseTypeCatch {
+			args = append(args, d.value(clause.X))
+		}
+	}
+	ptr := &ast.SelectorExpr{
+		X:   exc,
+		Sel: ast.NewIdent("Ptr"),
+	}
+	expr := &ast.CompositeLit{
+		Type: d.goType(inst.ResultType),
+		Elts: []ast.Expr{ptr, call(d.importSel(ehPath, "Selector"), args...)},
+	}
+	return d.assign(inst.Name(), expr)
+}
+
+// termResume converts the given LLVM IR resume terminator to a corresponding Go
+// statement, raising the exception in flight again. The panic is emitted
+// explicitly, as it is a terminating statement.
+//
+//    panic(eh.Resume(_exc))
+func (d *decompiler) termResume(term *ir.TermResume) ast.Stmt {
+	resume := call(d.importSel(ehPath, "Resume"), d.exc())
+	return &ast.ExprStmt{
+		X: call(ast.NewIdent("panic"), resume),
+	}
+}
+
+// termIndirectBr converts the given LLVM IR indirectbr terminator to a
+// corresponding Go statement.
+//
+//    switch _1 {
+//    case eh.BlockAddress("f", "a"):
+//       goto a
+//    default:
+//       panic("invalid indirect branch target")
+//    }
+func (d *decompiler) termIndirectBr(term *ir.TermIndirectBr) ast.Stmt {
+	var cases []ast.Stmt
+	for _, target := range term.ValidTargets {
+		cc := &ast.CaseClause{
+			List: []ast.Expr{d.blockAddress(d.funcName, target.(value.Named).Name())},
+			Body: []ast.Stmt{d.gotoStmt(target)},
+		}
+		cases = append(cases, cc)
+	}
+	invalid := &ast.BasicLit{
+		Kind:  token.STRING,
+		Value: `"invalid indirect branch target"`,
+	}
+	defaultCase := &ast.CaseClause{
+		Body: []ast.Stmt{&ast.ExprStmt{X: call(ast.NewIdent("panic"), invalid)}},
+	}
+	cases = append(cases, defaultCase)
+	return &ast.SwitchStmt{
+		Tag: d.value(term.Addr),
+		Body: &ast.BlockStmt{
+			List: cases,
+		},
+	}
+}
+
+// termCallBr converts the given LLVM IR callbr terminator to a corresponding
+// list of Go statements. Control flow is transferred to the normal return
+// point, as the callee (typically inline assembly) is not able to branch to the
+// other return points in Go. Outgoing values for PHI instructions of the normal
+// destination are assigned after the call.
+//
+//    _3 = f(_1, _2)
+//    _4 = _3
+//    goto normal
+func (d *decompiler) termCallBr(term *ir.TermCallBr) []ast.Stmt {
+	expr := d.callExpr(term.Callee, term.Args)
+	var callStmt ast.Stmt = &ast.ExprStmt{X: expr}
+	if !irtypes.Equal(term.Type(), irtypes.Void) {
+		callStmt = d.assign(term.Name(), expr)
+	}
+	stmts := []ast.Stmt{callStmt}
+	stmts = append(stmts, d.normal[term]...)
+	return append(stmts, d.gotoStmt(term.NormalRetTarget))
+}
+
+// constBlockAddress converts the given LLVM IR blockaddress constant to a
+// corresponding Go expression.
+func (d *decompiler) constBlockAddress(c *constant.BlockAddress) ast.Expr {
+	return d.blockAddress(c.Func.(*ir.Func).Name(), c.Block.Name())
+}
+
+// blockAddress returns a Go expression of the address of the given basic block
+// of the specified function.
+//
+//    eh.BlockAddress("f", "a")
+func (d *decompiler) blockAddress(funcName, blockName string) ast.Expr {
+	fn := &ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf("%q", funcName)}
+	block := &ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf("%q", blockName)}
+	return call(d.importSel(ehPath, "BlockAddress"), fn, block)
+}
+
+// gotoStmt returns a Go goto-statement to the given target basic block.
+func (d *decompiler) gotoStmt(target value.Value) ast.Stmt {
+	name := target.(value.Named).Name()
+	d.labels[name] = true
+	return &ast.BranchStmt{
+		Tok:   token.GOTO,
+		Label: d.label(name),
+	}
+}
@@ -0,0 +1,358 @@
+package gogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// floatnPath is the import path of the runtime support package for
+// floating-point types without a direct equivalent in Go.
+const floatnPath = "github.com/decomp/decomp/rt/floatn"
+
+// softFloat returns the floating-point type of the given LLVM IR type if not
+// part of Go builtin (i.e. half, x86_fp80, fp128 and ppc_fp128). The boolean
+// return value indicates success.
+//
+// Soft floating-point types are represented by floatn.Float, which rounds
+// results to the precision of the LLVM IR type.
+func softFloat(t irtypes.Type) (*irtypes.FloatType, bool) {
+	if t, ok := t.(*irtypes.FloatType); ok {
+		switch t.Kind {
+		case irtypes.FloatKindHalf, irtypes.FloatKindX86_FP80, irtypes.FloatKindFP128, irtypes.FloatKindPPC_FP128:
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// floatnSel returns a Go selector expression for the given identifier of the
+// floatn package, and records the import of the package.
+func (d *decompiler) floatnSel(name string) ast.Expr {
+	return d.importSel(floatnPath, name)
+}
+
+// precSel returns a Go selector expression of the precision of the given soft
+// floating-point type.
+//
+//    floatn.PrecX86_FP80
+func (d *decompiler) precSel(t *irtypes.FloatType) ast.Expr {
+	switch t.Kind {
+	case irtypes.FloatKindHalf:
+		return d.floatnSel("PrecHalf")
+	case irtypes.FloatKindX86_FP80:
+		return d.floatnSel("PrecX86_FP80")
+	case irtypes.FloatKindFP128:
+		return d.floatnSel("PrecFP128")
+	case irtypes.FloatKindPPC_FP128:
+		return d.floatnSel("PrecPPC_FP128")
+	default:
+		panic(fmt.Sprintf("invalid soft floating-point kind %v", t.Kind))
+	}
+}
+
+// elemFloat returns the floating-point type of the given LLVM IR
+// floating-point type, or of the elements of the given vector type.
+func elemFloat(t irtypes.Type) *irtypes.FloatType {
+	if vt, ok := t.(*irtypes.VectorType); ok {
+		t = vt.ElemType
+	}
+	ft, ok := t.(*irtypes.FloatType)
+	if !ok {
+		panic(fmt.Sprintf("invalid floating-point operand type; expected *types.FloatType, got %T", t))
+	}
+	return ft
+}
+
+// constFloat converts the given LLVM IR floating-point constant to a
+// corresponding Go expression. Constants are printed in their shortest decimal
+// form which converts back to the same value, and NaN, infinities and negative
+// zero are created using the math package.
+//
+//    0.1
+//    float32(math.Inf(-1))
+//    floatn.New(floatn.PrecX86_FP80, "0x.cccccccccccccccdp-3")
+func (d *decompiler) constFloat(c *constant.Float) ast.Expr {
+	if t, ok := softFloat(c.Typ); ok {
+		return d.constSoftFloat(t, c)
+	}
+	var expr ast.Expr
+	switch {
+	case c.NaN:
+		expr = call(d.importSel("math", "NaN"))
+	case c.X.IsInf():
+		sign := int64(1)
+		if c.X.Signbit() {
+			sign = -1
+		}
+		expr = call(d.importSel("math", "Inf"), d.intLit(sign))
+	case c.X.Sign() == 0 && c.X.Signbit():
+		expr = call(d.importSel("math", "Copysign"), d.intLit(0), d.intLit(-1))
+	default:
+		bits := 64
+		if c.Typ.Kind == irtypes.FloatKindFloat {
+			bits = 32
+		}
+		f, _ := c.X.Float64()
+		s := strconv.FormatFloat(f, 'g', -1, bits)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return &ast.BasicLit{
+			Kind:  token.FLOAT,
+			Value: s,
+		}
+	}
+	if c.Typ.Kind == irtypes.FloatKindFloat {
+		return call(ast.NewIdent("float32"), expr)
+	}
+	return expr
+}
+
+// constSoftFloat converts the given LLVM IR floating-point constant of soft
+// floating-point type to a corresponding Go expression. The value is given in
+// hexadecimal form, to preserve all bits of the significand.
+//
+//    floatn.New(floatn.PrecFP128, "0x.8p+1")
+func (d *decompiler) constSoftFloat(t *irtypes.FloatType, c *constant.Float) ast.Expr {
+	if c.NaN {
+		return call(d.floatnSel("NaN"), d.precSel(t))
+	}
+	x := c.X
+	if x == nil {
+		x = new(big.Float)
+	}
+	return call(d.floatnSel("New"), d.precSel(t), stringLit(x.Text('p', 0)))
+}
+
+// softOps maps from Go arithmetic operators to the corresponding methods of
+// floatn.Float.
+var softOps = map[token.Token]string{
+	token.ADD: "Add",
+	token.SUB: "Sub",
+	token.MUL: "Mul",
+	token.QUO: "Quo",
+	token.REM: "Rem",
+}
+
+// floatOp converts the given LLVM IR floating-point binary operation (e.g.
+// fadd, frem) to a corresponding Go expression. Operations on soft
+// floating-point types are converted to method calls, and the remainder to
+// math.Mod.
+//
+//    x + y
+//    math.Mod(x, y)
+//    x.Add(y)
+func (d *decompiler) floatOp(x value.Value, op token.Token, y value.Value) ast.Expr {
+	t := elemFloat(x.Type())
+	if vt, ok := x.Type().(*irtypes.VectorType); ok {
+		return d.vectorOp(vt, x, y, false, func(xi, yi ast.Expr) ast.Expr {
+			return d.floatOpExpr(t, xi, op, yi)
+		})
+	}
+	return d.floatOpExpr(t, d.value(x), op, d.value(y))
+}
+
+// floatOpExpr returns a Go expression of the given binary operation on the Go
+// expressions x and y of the specified floating-point type.
+func (d *decompiler) floatOpExpr(t *irtypes.FloatType, x ast.Expr, op token.Token, y ast.Expr) ast.Expr {
+	if _, ok := softFloat(t); ok {
+		return method(x, softOps[op], y)
+	}
+	if op != token.REM {
+		return &ast.BinaryExpr{X: x, Op: op, Y: y}
+	}
+	// The remainder of float operands is computed exactly in double precision.
+	if t.Kind == irtypes.FloatKindFloat {
+		f64 := ast.NewIdent("float64")
+		mod := call(d.importSel("math", "Mod"), call(f64, x), call(f64, y))
+		return call(ast.NewIdent("float32"), mod)
+	}
+	return call(d.importSel("math", "Mod"), x, y)
+}
+
+// floatNeg converts the given LLVM IR fneg operation to a corresponding Go
+// expression.
+//
+//    -x
+//    x.Neg()
+func (d *decompiler) floatNeg(x value.Value) ast.Expr {
+	t := elemFloat(x.Type())
+	neg := func(xi ast.Expr) ast.Expr {
+		if _, ok := softFloat(t); ok {
+			return method(xi, "Neg")
+		}
+		return &ast.UnaryExpr{Op: token.SUB, X: xi}
+	}
+	if vt, ok := x.Type().(*irtypes.VectorType); ok {
+		return d.convertVector(x, vt, neg)
+	}
+	return neg(d.value(x))
+}
+
+// floatCmp converts the given LLVM IR floating-point comparison to a
+// corresponding Go expression. Comparisons of vectors are element-wise.
+//
+// Go comparison operators on floating-point numbers are ordered, except for
+// !=, thus unordered predicates are converted to the negation of the inverse
+// ordered comparison, and ord and uno are converted to self-comparisons (only
+// NaN compares unequal to itself).
+//
+//    x < y || x > y     // one
+//    !(x >= y)          // ult
+//    x != x || y != y   // uno
+func (d *decompiler) floatCmp(pred enum.FPred, x, y value.Value) ast.Expr {
+	_, soft := softFloat(elemFloat(x.Type()))
+	if vt, ok := x.Type().(*irtypes.VectorType); ok {
+		return d.vectorOp(vt, x, y, true, func(xi, yi ast.Expr) ast.Expr {
+			return floatCmpExpr(pred, xi, yi, soft)
+		})
+	}
+	return floatCmpExpr(pred, d.value(x), d.value(y), soft)
+}
+
+// softCmps maps from Go comparison operators to the corresponding methods of
+// floatn.Float.
+var softCmps = map[token.Token]string{
+	token.EQL: "Eq",
+	token.NEQ: "Ne",
+	token.LSS: "Lt",
+	token.LEQ: "Le",
+	token.GTR: "Gt",
+	token.GEQ: "Ge",
+}
+
+// floatCmpExpr returns a Go expression of the given floating-point comparison
+// of the Go expressions x and y, which are of soft floating-point type if soft
+// is set.
+func floatCmpExpr(pred enum.FPred, x, y ast.Expr, soft bool) ast.Expr {
+	cmp := func(x ast.Expr, op token.Token, y ast.Expr) ast.Expr {
+		if soft {
+			return method(x, softCmps[op], y)
+		}
+		return &ast.BinaryExpr{X: x, Op: op, Y: y}
+	}
+	not := func(x ast.Expr) ast.Expr {
+		if _, ok := x.(*ast.BinaryExpr); ok {
+			x = &ast.ParenExpr{X: x}
+		}
+		return &ast.UnaryExpr{Op: token.NOT, X: x}
+	}
+	or := func(x, y ast.Expr) ast.Expr {
+		return &ast.BinaryExpr{X: x, Op: token.LOR, Y: y}
+	}
+	switch pred {
+	case enum.FPredFalse:
+		return ast.NewIdent("false")
+	case enum.FPredOEQ:
+		return cmp(x, token.EQL, y)
+	case enum.FPredOGT:
+		return cmp(x, token.GTR, y)
+	case enum.FPredOGE:
+		return cmp(x, token.GEQ, y)
+	case enum.FPredOLT:
+		return cmp(x, token.LSS, y)
+	case enum.FPredOLE:
+		return cmp(x, token.LEQ, y)
+	case enum.FPredONE:
+		return or(cmp(x, token.LSS, y), cmp(x, token.GTR, y))
+	case enum.FPredORD:
+		return &ast.BinaryExpr{X: cmp(x, token.EQL, x), Op: token.LAND, Y: cmp(y, token.EQL, y)}
+	case enum.FPredUEQ:
+		return not(or(cmp(x, token.LSS, y), cmp(x, token.GTR, y)))
+	case enum.FPredUGT:
+		return not(cmp(x, token.LEQ, y))
+	case enum.FPredUGE:
+		return not(cmp(x, token.LSS, y))
+	case enum.FPredULT:
+		return not(cmp(x, token.GEQ, y))
+	case enum.FPredULE:
+		return not(cmp(x, token.GTR, y))
+	case enum.FPredUNE:
+		return cmp(x, token.NEQ, y)
+	case enum.FPredUNO:
+		return or(cmp(x, token.NEQ, x), cmp(y, token.NEQ, y))
+	case enum.FPredTrue:
+		return ast.NewIdent("true")
+	default:
+		panic(fmt.Sprintf("support for floating-point predicate %v not yet implemented", pred))
+	}
+}
+
+// convertSoftFloat returns a Go expression for converting the given LLVM IR
+// value into the specified type, if either is of soft floating-point type.
+// Integers are interpreted as unsigned if unsigned is set (e.g. uitofp,
+// fptoui). The boolean return value indicates success.
+//
+//    floatn.FromFloat64(floatn.PrecX86_FP80, float64(x))
+//    int32(x.Int64())
+func (d *decompiler) convertSoftFloat(from value.Value, to irtypes.Type, unsigned bool) (ast.Expr, bool) {
+	fromType, fromSoft := softFloat(from.Type())
+	toType, toSoft := softFloat(to)
+	switch {
+	case fromSoft && toSoft:
+		// fpext, fptrunc
+		return method(d.value(from), "Convert", d.precSel(toType)), true
+	case toSoft:
+		var name string
+		var x ast.Expr
+		switch {
+		case isFloat(from.Type()):
+			name, x = "FromFloat64", call(ast.NewIdent("float64"), d.value(from))
+		case isWideInt(from.Type()):
+			name, x = "FromFloat64", method(d.value(from), "Float64")
+			if unsigned {
+				x = method(d.value(from), "UFloat64")
+			}
+		case unsigned:
+			name, x = "FromUint64", call(ast.NewIdent("uint64"), d.unsigned(from))
+		default:
+			name, x = "FromInt64", call(ast.NewIdent("int64"), d.value(from))
+		}
+		return call(d.floatnSel(name), d.precSel(toType), x), true
+	case fromSoft:
+		x := d.value(from)
+		switch to := to.(type) {
+		case *irtypes.FloatType:
+			if to.Kind == irtypes.FloatKindFloat {
+				return method(x, "Float32"), true
+			}
+			return method(x, "Float64"), true
+		case *irtypes.IntType:
+			if t, ok := wideInt(to); ok {
+				return call(d.intnSel("FromFloat64"), bitsLit(t), method(x, "Float64")), true
+			}
+			name := "Int64"
+			if unsigned {
+				name = "Uint64"
+			}
+			if t, ok := oddInt(to); ok {
+				return d.signExtend(method(x, name), t), true
+			}
+			return call(d.goType(to), method(x, name)), true
+		}
+		panic(fmt.Sprintf("support for conversion from %v to %v not yet implemented", fromType, to))
+	}
+	return nil, false
+}
+
+// isFloat reports whether the given LLVM IR type is a floating-point type.
+func isFloat(t irtypes.Type) bool {
+	_, ok := t.(*irtypes.FloatType)
+	return ok
+}
+
+// isWideInt reports whether the given LLVM IR type is an integer type wider
+// than 64 bits.
+func isWideInt(t irtypes.Type) bool {
+	_, ok := wideInt(t)
+	return ok
+}
@@ -0,0 +1,77 @@
+package gogen
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/decomp/decomp/internal/lower"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// memPath is the import path of the runtime support package for pointer
+// arithmetic.
+const memPath = "github.com/decomp/decomp/rt/mem"
+
+// gep converts the given LLVM IR getelementptr operation to a corresponding Go
+// expression of the address of the element located by indices, relative to the
+// source address src of element type elemType.
+//
+// The first index offsets the source address in units of the element type,
+// using pointer arithmetic if non-zero. Subsequent indices select struct fields
+// by field name, and array and vector elements by index.
+//
+//    &p.field_1[i]
+//    &(*T)(mem.Offset(unsafe.Pointer(p), i, unsafe.Sizeof(*p))).field_1
+func (d *decompiler) gep(src value.Value, elemType irtypes.Type, indices []value.Value) ast.Expr {
+	if expr, ok := d.stringGEP(src, indices); ok {
+		return expr
+	}
+	x := d.value(src)
+	if len(indices) == 0 {
+		return x
+	}
+	if !lower.IsZero(indices[0]) {
+		x = d.ptrOffset(x, elemType, lower.Index(indices[0]))
+	}
+	if len(indices) == 1 {
+		return x
+	}
+	path, err := lower.Path(elemType, indices[1:])
+	if err != nil {
+		panic(err.Error())
+	}
+	// Go selectors and index expressions implicitly dereference pointers to
+	// structs and arrays.
+	for _, step := range path {
+		if step.Struct != nil {
+			x = &ast.SelectorExpr{
+				X:   x,
+				Sel: d.fieldIdent(step.Struct, step.Field),
+			}
+			continue
+		}
+		x = &ast.IndexExpr{
+			X:     x,
+			Index: d.value(step.Index),
+		}
+	}
+	return &ast.UnaryExpr{
+		Op: token.AND,
+		X:  x,
+	}
+}
+
+// ptrOffset returns a Go expression of the address of the i:th element of type
+// elemType, relative to the pointer p.
+//
+//    (*T)(mem.Offset(unsafe.Pointer(p), i, unsafe.Sizeof(*p)))
+func (d *decompiler) ptrOffset(p ast.Expr, elemType irtypes.Type, i value.Value) ast.Expr {
+	ptr := call(d.importSel("unsafe", "Pointer"), p)
+	size := call(d.importSel("unsafe", "Sizeof"), &ast.StarExpr{X: p})
+	offset := call(d.importSel(memPath, "Offset"), ptr, d.int64Value(i), size)
+	ptrType := &ast.ParenExpr{
+		X: &ast.StarExpr{X: d.goType(elemType)},
+	}
+	return call(ptrType, offset)
+}
@@ -0,0 +1,473 @@
+// Package gogen implements a Go back-end of the decompiler, which translates
+// LLVM IR modules into Go source code.
+//
+// The control flow of each function is recovered by control flow analysis
+// (see cfa.Restructure), or from user-supplied control flow primitives, and
+// the decompiled Go source code is type-checked using go/types.
+//
+//    d := &gogen.Decompiler{Jobs: runtime.NumCPU()}
+//    f, err := d.Decompile(module, "foo")
+package gogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/decomp/decomp/cfa"
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/decomp/decomp/internal/par"
+	"github.com/decomp/decomp/srcmap"
+	"github.com/llir/llvm/ir"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// A Decompiler decompiles LLVM IR modules into Go source code. The zero value
+// is a decompiler which decompiles one function at the time and recovers
+// control flow primitives by control flow analysis.
+type Decompiler struct {
+	// Functions of the LLVM IR module to decompile; or all functions if nil.
+	Funcs []*ir.Func
+	// Prims returns the control flow primitives of the given function; or nil
+	// to recover the control flow primitives by control flow analysis (see
+	// cfa.RestructureFunc). Incomplete control flow recovery (see
+	// cfa.ErrIncomplete) is not an error, as unstructured control flow is
+	// decompiled into goto-statements.
+	Prims func(f *ir.Func) ([]*primitive.Primitive, error)
+	// Number of functions to decompile concurrently; or 1 if not positive.
+	Jobs int
+	// Pool of workers used to decompile functions concurrently, when shared
+	// with the caller (e.g. to bound the number of workers when decompiling
+	// several modules concurrently); or a pool of Jobs workers if nil.
+	Pool *par.Pool
+	// Check specifies whether to report type errors of the decompiled Go source
+	// code as errors; type errors are otherwise marked by "ll2go:" comments.
+	Check bool
+	// Comments specifies whether to annotate Go statements with comments of
+	// their originating LLVM IR instructions.
+	Comments bool
+	// SrcMap specifies whether to track the originating LLVM IR of Go
+	// statements for source maps (see File.Origins).
+	SrcMap bool
+	// Layout specifies whether to assert at compile time that the size and
+	// field offsets of Go struct types match those of their LLVM IR struct
+	// types.
+	Layout bool
+	// Map from LLVM IR type name (without '%' prefix) to user-supplied type
+	// annotation (see LoadTypeAnnotations); or nil if not annotated. Type
+	// annotations take precedence over source names of debug metadata.
+	Types map[string]*TypeAnnotation
+	// Logger of debug messages; or no logging if nil.
+	Logger *log.Logger
+}
+
+// A File is a Go source file decompiled from an LLVM IR module.
+type File struct {
+	// Go source file.
+	*ast.File
+	// File set of the positions of the Go source file.
+	Fset *token.FileSet
+	// Source map origins of the marker comments of the Go source file; nil if
+	// source maps are disabled. The marker comments are removed and the source
+	// map recovered from the formatted Go source code by srcmap.Extract.
+	Origins []srcmap.Origin
+}
+
+// Decompile decompiles the given LLVM IR module into a Go source file. The
+// package name of the Go source file is main if the module defines a main
+// function, and pkgName otherwise.
+func (dec *Decompiler) Decompile(module *ir.Module, pkgName string) (*File, error) {
+	funcs := dec.Funcs
+	if funcs == nil {
+		funcs = module.Funcs
+	}
+	// Omit declarations of LLVM intrinsic functions and C standard library
+	// functions lowered to Go.
+	lowered := funcs
+	funcs = omitLowered(funcs)
+
+	// Recover type definitions.
+	file := &ast.File{}
+	d := newDecompiler()
+	// Recover source names from debug metadata and type annotations.
+	d.debug = newDebugInfo(module, dec.Types)
+	dec.checkTypes(module)
+	layout, err := newDataLayout(module.DataLayout)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	d.layout = layout
+	if dec.Comments {
+		d.comments = make(map[ast.Stmt][]string)
+	}
+	if dec.SrcMap {
+		d.origins = make(map[ast.Stmt]srcmap.Origin)
+	}
+	// The comments of layout assertions are recorded separately, and added to
+	// d.comments only after all functions have been decompiled; a non-nil
+	// d.comments enables the LLVM IR comments of function bodies, which would
+	// otherwise be emitted even if dec.Comments is not set.
+	layoutComments := make(map[ast.Stmt][]string)
+	for _, t := range module.TypeDefs {
+		typ := d.typeDef(t)
+		file.Decls = append(file.Decls, typ)
+		st, ok := t.(*irtypes.StructType)
+		if !ok || st.Opaque {
+			continue
+		}
+		name := d.typeIdent(st.Name()).Name
+		if mismatch := d.layout.goStructLayout(st).mismatch; len(mismatch) > 0 {
+			dec.logf("WARNING: layout of Go struct type %q differs from LLVM IR struct type %v; %s", name, st, mismatch)
+			continue
+		}
+		if dec.Layout {
+			fn, stmt := d.layoutAssertions(st)
+			layoutComments[stmt] = []string{fmt.Sprintf("A compile-time error in this function signifies that the layout of %s differs from the LLVM IR struct type %v.", name, st)}
+			file.Decls = append(file.Decls, fn)
+		}
+	}
+
+	// Recover global variables.
+	for _, g := range module.Globals {
+		global := d.globalDecl(g)
+		file.Decls = append(file.Decls, global)
+	}
+
+	// Recover functions.
+	var hasMain bool
+	for _, f := range funcs {
+		if f.Name() == "main" {
+			hasMain = true
+		}
+	}
+	pool := dec.Pool
+	if pool == nil {
+		jobs := dec.Jobs
+		if jobs < 1 {
+			jobs = 1
+		}
+		pool = par.New(jobs)
+	}
+	fns := make([]*ast.FuncDecl, len(funcs))
+	ds := make([]*decompiler, len(funcs))
+	err = pool.Do(len(funcs), func(i int) error {
+		f := funcs[i]
+		var prims []*primitive.Primitive
+		if len(f.Blocks) > 0 {
+			var err error
+			prims, err = dec.prims(f)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		dec.logf("decompiling function %q.", f.Ident())
+		// Use one decompiler per function, as the per function states of the
+		// decompiler may not be shared between workers. The global states are
+		// merged once all functions have been decompiled.
+		fd := newDecompiler()
+		fd.debug = d.debug
+		fd.layout = d.layout
+		if d.comments != nil {
+			fd.comments = make(map[ast.Stmt][]string)
+		}
+		if d.origins != nil {
+			fd.origins = make(map[ast.Stmt]srcmap.Origin)
+		}
+		fn, err := fd.funcDecl(f, prims)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		fns[i] = fn
+		ds[i] = fd
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for i, fn := range fns {
+		d.merge(ds[i])
+		file.Decls = append(file.Decls, fn)
+	}
+	// Add declarations of lowered functions of which calls were converted as
+	// is (e.g. intrinsics with vector operands).
+	for _, f := range lowered {
+		if !d.unlowered[f.Name()] || len(f.Blocks) > 0 {
+			continue
+		}
+		fn, err := d.funcDecl(f, nil)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		file.Decls = append(file.Decls, fn)
+	}
+	if len(layoutComments) > 0 {
+		if d.comments == nil {
+			d.comments = make(map[ast.Stmt][]string)
+		}
+		for stmt, lines := range layoutComments {
+			d.comments[stmt] = lines
+		}
+	}
+
+	// Add newIntNNN function declarations.
+	var newIntSizes []uint64
+	for newIntSize := range d.newIntSizes {
+		newIntSizes = append(newIntSizes, newIntSize)
+	}
+	sort.Slice(newIntSizes, func(i, j int) bool {
+		return newIntSizes[i] < newIntSizes[j]
+	})
+	for _, newIntSize := range newIntSizes {
+		name := fmt.Sprintf("newInt%d", newIntSize)
+		fn := newFuncDecl(name, d.goType(irtypes.NewInt(newIntSize)))
+		file.Decls = append(file.Decls, fn)
+	}
+
+	// Add newFloatNNN function declarations.
+	var newFloatKinds []irtypes.FloatKind
+	for newFloatKind := range d.newFloatKinds {
+		newFloatKinds = append(newFloatKinds, newFloatKind)
+	}
+	sort.Slice(newFloatKinds, func(i, j int) bool {
+		return newFloatKinds[i] < newFloatKinds[j]
+	})
+	for _, newFloatKind := range newFloatKinds {
+		t := &irtypes.FloatType{Kind: newFloatKind}
+		fn := newFuncDecl(newFloatName(t), d.goType(t))
+		file.Decls = append(file.Decls, fn)
+	}
+
+	// Add types not part of builtin.
+	intDecls, err := intTypeDecls(d.intSizes, "int")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	file.Decls = append(file.Decls, intDecls...)
+	uintDecls, err := intTypeDecls(d.uintSizes, "uint")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	file.Decls = append(file.Decls, uintDecls...)
+
+	// Rename identifiers colliding with the names of imported packages.
+	renameImportCollisions(file, d.imports)
+
+	// Add imports.
+	if len(d.imports) > 0 {
+		var paths []string
+		for path := range d.imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		importDecl := &ast.GenDecl{
+			Tok: token.IMPORT,
+		}
+		if len(paths) > 1 {
+			// Use parenthesized import declaration for multiple imports.
+			importDecl.Lparen = 1
+		}
+		for _, path := range paths {
+			spec := &ast.ImportSpec{
+				Path: &ast.BasicLit{
+					Kind:  token.STRING,
+					Value: strconv.Quote(path),
+				},
+			}
+			importDecl.Specs = append(importDecl.Specs, spec)
+		}
+		file.Decls = append([]ast.Decl{importDecl}, file.Decls...)
+	}
+
+	// Set package name.
+	if hasMain {
+		file.Name = ast.NewIdent("main")
+	} else {
+		file.Name = ident(pkgName)
+	}
+
+	// Attach LLVM IR comments and source map markers.
+	var origins []srcmap.Origin
+	if dec.SrcMap {
+		origins = d.markOrigins(file)
+	}
+	fset := token.NewFileSet()
+	if len(d.comments) > 0 {
+		fset, file, err = attachComments(file, d.comments)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	// Type-check the Go source file.
+	dec.logf("type-checking package %q.", file.Name.Name)
+	file, fset, typeErrs, err := typeCheck(fset, file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(typeErrs) > 0 {
+		if dec.Check {
+			return nil, errors.Errorf("%d type errors in decompiled Go source code; first error: %v", len(typeErrs), typeErrs[0].Msg)
+		}
+		dec.logf("%d type errors in decompiled Go source code; marked by comments.", len(typeErrs))
+	}
+	f := &File{
+		File:    file,
+		Fset:    fset,
+		Origins: origins,
+	}
+	return f, nil
+}
+
+// prims returns the control flow primitives of the given function.
+func (dec *Decompiler) prims(f *ir.Func) ([]*primitive.Primitive, error) {
+	var prims []*primitive.Primitive
+	var err error
+	if dec.Prims != nil {
+		prims, err = dec.Prims(f)
+	}
+	if prims == nil && err == nil {
+		prims, err = cfa.RestructureFunc(f)
+	}
+	if err != nil {
+		if errors.Cause(err) != cfa.ErrIncomplete {
+			return nil, errors.WithStack(err)
+		}
+		dec.logf("WARNING: incomplete control flow recovery of %q", f.Ident())
+	}
+	return prims, nil
+}
+
+// checkTypes warns about type annotations of types not defined by the given
+// LLVM IR module.
+func (dec *Decompiler) checkTypes(module *ir.Module) {
+	defined := make(map[string]bool)
+	for _, t := range module.TypeDefs {
+		defined[t.Name()] = true
+	}
+	var unknown []string
+	for name := range dec.Types {
+		if !defined[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		dec.logf("WARNING: type annotation of undefined type %q", name)
+	}
+}
+
+// logf logs the given debug message, if logging is enabled.
+func (dec *Decompiler) logf(format string, args ...interface{}) {
+	if dec.Logger != nil {
+		dec.Logger.Printf(format, args...)
+	}
+}
+
+// intTypeDecls returns type declarations of the integer types with the given
+// bit sizes which are not part of Go builtin; using the specified prefix ("int"
+// or "uint") for both the integer type names and their underlying types.
+func intTypeDecls(sizes map[uint64]bool, prefix string) ([]ast.Decl, error) {
+	var intSizes []uint64
+	for intSize := range sizes {
+		switch intSize {
+		case 8, 16, 32, 64:
+			// already builtin type of Go.
+		default:
+			intSizes = append(intSizes, intSize)
+		}
+	}
+	sort.Slice(intSizes, func(i, j int) bool {
+		return intSizes[i] < intSizes[j]
+	})
+	var decls []ast.Decl
+	for _, intSize := range intSizes {
+		typeName := fmt.Sprintf("%s%d", prefix, intSize)
+		var underlying string
+		switch {
+		case intSize < 8:
+			underlying = prefix + "8"
+		case intSize < 16:
+			underlying = prefix + "16"
+		case intSize < 32:
+			underlying = prefix + "32"
+		case intSize < 64:
+			underlying = prefix + "64"
+		default:
+			return nil, errors.Errorf("support for integer type with bit size %d not yet implemented", intSize)
+		}
+		spec := &ast.TypeSpec{
+			Name: ast.NewIdent(typeName),
+			Type: ast.NewIdent(underlying),
+		}
+		typeDecl := &ast.GenDecl{
+			Tok:   token.TYPE,
+			Specs: []ast.Spec{spec},
+		}
+		decls = append(decls, typeDecl)
+	}
+	return decls, nil
+}
+
+// newFuncDecl returns a Go function declaration of the given name, which
+// returns a pointer to a new variable of the specified type initialized to the
+// value of its parameter; as used for initializers of global variables.
+//
+//    func newInt32(x int32) *int32 {
+//       return &x
+//    }
+func newFuncDecl(name string, typ ast.Expr) *ast.FuncDecl {
+	x := ast.NewIdent("x")
+	param := &ast.Field{
+		Names: []*ast.Ident{x},
+		Type:  typ,
+	}
+	retType := &ast.StarExpr{X: typ}
+	result := &ast.Field{
+		Type: retType,
+	}
+	sig := &ast.FuncType{
+		Params:  &ast.FieldList{List: []*ast.Field{param}},
+		Results: &ast.FieldList{List: []*ast.Field{result}},
+	}
+	expr := &ast.UnaryExpr{
+		Op: token.AND,
+		X:  x,
+	}
+	returnStmt := &ast.ReturnStmt{
+		Results: []ast.Expr{expr},
+	}
+	body := &ast.BlockStmt{
+		List: []ast.Stmt{returnStmt},
+	}
+	return &ast.FuncDecl{
+		Name: ast.NewIdent(name),
+		Type: sig,
+		Body: body,
+	}
+}
+
+// newFloatName returns the name of the newFloatNNN function of the given
+// floating-point type.
+func newFloatName(t *irtypes.FloatType) string {
+	switch t.Kind {
+	case irtypes.FloatKindHalf:
+		return "newFloat16"
+	case irtypes.FloatKindFloat:
+		return "newFloat32"
+	case irtypes.FloatKindDouble:
+		return "newFloat64"
+	case irtypes.FloatKindX86_FP80:
+		return "newFloat80"
+	case irtypes.FloatKindFP128:
+		return "newFloat128"
+	case irtypes.FloatKindPPC_FP128:
+		return "newFloatPPC128"
+	default:
+		panic(fmt.Sprintf("support for floating-point kind %v not yet implemented", t.Kind))
+	}
+}
+
@@ -0,0 +1,859 @@
+package gogen
+
+import (
+	"bytes"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/pkg/errors"
+)
+
+func TestDecompile(t *testing.T) {
+	const src = `
+define i32 @f(i32 %x) {
+entry:
+	%c = icmp slt i32 %x, 0
+	br i1 %c, label %neg, label %exit
+
+neg:
+	%y = sub i32 0, %x
+	br label %exit
+
+exit:
+	%z = phi i32 [ %y, %neg ], [ %x, %entry ]
+	ret i32 %z
+}
+`
+	const want = `package foo
+
+func f(x int32) int32 {
+	var c bool
+	var y int32
+	var z int32
+	c = x < 0
+	z = x
+	if c {
+		y = 0 - x
+		z = y
+	}
+	return z
+}
+`
+	testDecompile(t, []decompileTest{{name: "if", src: src, want: want}})
+}
+
+func TestDecompilePhi(t *testing.T) {
+	// Swap of variables used after the loop.
+	const src = `
+define i32 @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%x = phi i32 [ 1, %entry ], [ %y, %body ]
+	%y = phi i32 [ 2, %entry ], [ %x, %body ]
+	%i = phi i32 [ 0, %entry ], [ %i1, %body ]
+	br label %body
+
+body:
+	%i1 = add i32 %i, 1
+	%c = icmp slt i32 %i1, %n
+	br i1 %c, label %loop, label %exit
+
+exit:
+	%r = mul i32 %x, 10
+	%s = add i32 %r, %i
+	ret i32 %s
+}
+`
+	const want = `package foo
+
+func f(n int32) int32 {
+	var x_phi int32
+	var i_phi int32
+	var x int32
+	var y int32
+	var i int32
+	var i1 int32
+	var c bool
+	var r int32
+	var s int32
+	x_phi = 1
+	y = 2
+	i_phi = 0
+	for {
+		x = x_phi
+		i = i_phi
+		i1 = i + 1
+		c = i1 < n
+		x_phi = y
+		y = x
+		i_phi = i1
+		if !c {
+			break
+		}
+	}
+	r = x * 10
+	s = r + i
+	return s
+}
+`
+	testDecompile(t, []decompileTest{{name: "phi", src: src, want: want}})
+}
+
+func TestTypeCheckOutsideModule(t *testing.T) {
+	// The runtime support packages are type-checked independent of the working
+	// directory.
+	const src = `
+declare i32 @g(i32)
+
+declare i32 @__gxx_personality_v0(...)
+
+define i32 @f(i32 %x) personality i32 (...)* @__gxx_personality_v0 {
+entry:
+	%r = invoke i32 @g(i32 %x) to label %cont unwind label %lpad
+
+cont:
+	ret i32 %r
+
+lpad:
+	%lp = landingpad { i8*, i32 } cleanup
+	ret i32 0
+}
+`
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unable to get working directory; %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("unable to change working directory; %v", err)
+	}
+	defer os.Chdir(wd)
+	got, err := decompile("foo", src, &Decompiler{Check: true})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if !strings.Contains(got, `"github.com/decomp/decomp/rt/eh"`) {
+		t.Errorf("import of runtime support package missing; got\n%s", got)
+	}
+}
+
+func TestDecompileAtomic(t *testing.T) {
+	const src = `
+@x = global i32 0
+@b = global i8 0
+
+define i32 @f(i64* %p) {
+	%1 = load atomic i32, i32* @x seq_cst, align 4
+	store atomic i32 %1, i32* @x release, align 4
+	%2 = load atomic i64, i64* %p acquire, align 8
+	store atomic i64 %2, i64* %p monotonic, align 8
+	%3 = load atomic i8, i8* @b unordered, align 1
+	store atomic i8 %3, i8* @b seq_cst, align 1
+	%4 = atomicrmw add i32* @x, i32 1 seq_cst
+	ret i32 %4
+}
+`
+	const want = `package foo
+
+import (
+	"github.com/decomp/decomp/rt/atomics"
+	"sync/atomic"
+)
+
+var x *int32 = newInt32(0)
+var b *int8 = newInt8(0)
+
+func f(p *int64) int32 {
+	var _1 int32
+	var _2 int64
+	var _3 int8
+	var _4 int32
+	_1 = atomic.LoadInt32(x)
+	atomic.StoreInt32(x, _1)
+	_2 = atomic.LoadInt64(p)
+	atomic.StoreInt64(p, _2)
+	atomics.Do(func() {
+		_3 = *b
+	})
+	atomics.Do(func() {
+		*b = _3
+	})
+	_4 = atomic.AddInt32(x, 1) - 1
+	return _4
+}
+func newInt8(x int8) *int8 {
+	return &x
+}
+func newInt32(x int32) *int32 {
+	return &x
+}
+`
+	testDecompile(t, []decompileTest{{name: "atomic", src: src, want: want, dec: &Decompiler{Check: true}}})
+}
+
+func TestDecompileEH(t *testing.T) {
+	// C++ exceptions thrown by __cxa_throw are caught by the landing pads of
+	// invoke terminators, and raised again by resume terminators.
+	const src = `
+@_ZTIi = external constant i8*
+
+declare i8* @__cxa_allocate_exception(i64)
+
+declare void @__cxa_throw(i8*, i8*, i8*)
+
+declare i8* @__cxa_begin_catch(i8*)
+
+declare void @__cxa_end_catch()
+
+declare i32 @llvm.eh.typeid.for(i8*)
+
+declare i32 @__gxx_personality_v0(...)
+
+define void @g(i32 %x) {
+	%exc = call i8* @__cxa_allocate_exception(i64 4)
+	%p = bitcast i8* %exc to i32*
+	store i32 %x, i32* %p
+	call void @__cxa_throw(i8* %exc, i8* bitcast (i8** @_ZTIi to i8*), i8* null)
+	unreachable
+}
+
+define i32 @f(i32 %x) personality i32 (...)* @__gxx_personality_v0 {
+entry:
+	invoke void @g(i32 %x) to label %cont unwind label %lpad
+
+cont:
+	ret i32 0
+
+lpad:
+	%lp = landingpad { i8*, i32 } catch i8* bitcast (i8** @_ZTIi to i8*)
+	%ptr = extractvalue { i8*, i32 } %lp, 0
+	%sel = extractvalue { i8*, i32 } %lp, 1
+	%id = call i32 @llvm.eh.typeid.for(i8* bitcast (i8** @_ZTIi to i8*))
+	%match = icmp eq i32 %sel, %id
+	br i1 %match, label %catch, label %resume
+
+catch:
+	%obj = call i8* @__cxa_begin_catch(i8* %ptr)
+	%q = bitcast i8* %obj to i32*
+	%v = load i32, i32* %q
+	call void @__cxa_end_catch()
+	ret i32 %v
+
+resume:
+	resume { i8*, i32 } %lp
+}
+
+define void @h(i8* %exc) personality i32 (...)* @__gxx_personality_v0 {
+entry:
+	invoke void @__cxa_throw(i8* %exc, i8* bitcast (i8** @_ZTIi to i8*), i8* null) to label %unreachable unwind label %lpad
+
+unreachable:
+	unreachable
+
+lpad:
+	%lp = landingpad { i8*, i32 } cleanup
+	resume { i8*, i32 } %lp
+}
+`
+	const want = `package foo
+
+import (
+	"github.com/decomp/decomp/rt/eh"
+	"unsafe"
+)
+
+var _ZTIi **int8
+
+func __gxx_personality_v0(_va ...interface{}) int32
+func g(x int32) {
+	var exc *int8
+	var p *int32
+	exc = &make([]int8, 4)[0]
+	p = (*int32)(unsafe.Pointer(exc))
+	*p = x
+	eh.Throw(exc, (*int8)(unsafe.Pointer(_ZTIi)))
+	panic("unreachable")
+}
+func f(x int32) int32 {
+	var _exc *eh.Exception
+	var lp struct {
+		field_0 *int8
+		field_1 int32
+	}
+	var ptr *int8
+	var sel int32
+	var id int32
+	var match bool
+	var obj *int8
+	var q *int32
+	var v int32
+	if _exc = eh.Invoke(func() {
+		g(x)
+	}); _exc != nil {
+		goto block_lpad
+	} else {
+		goto block_cont
+	}
+block_cont:
+	return 0
+block_lpad:
+	lp = struct {
+		field_0 *int8
+		field_1 int32
+	}{_exc.Ptr, eh.Selector(_exc, (*int8)(unsafe.Pointer(_ZTIi)))}
+	ptr = lp.field_0
+	sel = lp.field_1
+	id = eh.TypeID((*int8)(unsafe.Pointer(_ZTIi)))
+	match = sel == id
+	if match {
+		obj = ptr
+		q = (*int32)(unsafe.Pointer(obj))
+		v = *q
+		return v
+	}
+	panic(eh.Resume(_exc))
+}
+func h(exc *int8) {
+	var _exc *eh.Exception
+	if _exc = eh.Invoke(func() {
+		eh.Throw(exc, (*int8)(unsafe.Pointer(_ZTIi)))
+	}); _exc != nil {
+		goto block_lpad
+	} else {
+		goto block_unreachable
+	}
+block_unreachable:
+	panic("unreachable")
+block_lpad:
+	_ = struct {
+		field_0 *int8
+		field_1 int32
+	}{_exc.Ptr, eh.Selector(_exc)}
+	panic(eh.Resume(_exc))
+}
+`
+	testDecompile(t, []decompileTest{{name: "eh", src: src, want: want, dec: &Decompiler{Check: true}}})
+}
+
+func TestDecompileInvokePhi(t *testing.T) {
+	// The result of an invoke terminator is assigned to PHI instructions of its
+	// normal destination after the call.
+	const src = `
+declare i32 @g(i32)
+
+declare i32 @__gxx_personality_v0(...)
+
+define i32 @f(i32 %x) personality i32 (...)* @__gxx_personality_v0 {
+entry:
+	%r = invoke i32 @g(i32 %x) to label %cont unwind label %lpad
+
+lpad:
+	%lp = landingpad { i8*, i32 } cleanup
+	br label %cont
+
+cont:
+	%p = phi i32 [ %r, %entry ], [ 0, %lpad ]
+	ret i32 %p
+}
+`
+	const want = `package foo
+
+import "github.com/decomp/decomp/rt/eh"
+
+func g(_0 int32) int32
+func __gxx_personality_v0(_va ...interface{}) int32
+func f(x int32) int32 {
+	var r int32
+	var _exc *eh.Exception
+	var p int32
+	if _exc = eh.Invoke(func() {
+		r = g(x)
+	}); _exc != nil {
+		goto block_lpad
+	} else {
+		p = r
+		goto block_cont
+	}
+block_lpad:
+	_ = struct {
+		field_0 *int8
+		field_1 int32
+	}{_exc.Ptr, eh.Selector(_exc)}
+	p = 0
+	goto block_cont
+block_cont:
+	return p
+}
+`
+	testDecompile(t, []decompileTest{{name: "invoke_phi", src: src, want: want, dec: &Decompiler{Check: true}}})
+}
+
+func TestDecompilePolarity(t *testing.T) {
+	// The conditions of control flow primitives are negated when their body is
+	// on the false branch, or when loops exit on the true branch.
+	golden := []decompileTest{
+		// Post-test loop exiting on the true branch.
+		{
+			name: "post_loop",
+			src: `
+define i32 @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %i1, %loop ]
+	%i1 = add i32 %i, 1
+	%c = icmp sge i32 %i1, %n
+	br i1 %c, label %exit, label %loop
+
+exit:
+	ret i32 %i1
+}
+`,
+			want: `package foo
+
+func f(n int32) int32 {
+	var i int32
+	var i1 int32
+	var c bool
+	i = 0
+	for {
+		i1 = i + 1
+		c = i1 >= n
+		i = i1
+		if c {
+			break
+		}
+	}
+	return i1
+}
+`,
+		},
+		// Pre-test loop exiting on the true branch.
+		{
+			name: "pre_loop",
+			src: `
+define i32 @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %i1, %body ]
+	%c = icmp sge i32 %i, %n
+	br i1 %c, label %exit, label %body
+
+body:
+	%i1 = add i32 %i, 1
+	br label %loop
+
+exit:
+	ret i32 %i
+}
+`,
+			want: `package foo
+
+func f(n int32) int32 {
+	var i int32
+	var c bool
+	var i1 int32
+	i = 0
+	for {
+		c = i >= n
+		if c {
+			break
+		}
+		i1 = i + 1
+		i = i1
+	}
+	return i
+}
+`,
+		},
+		// Early return on the false branch.
+		{
+			name: "if_return",
+			src: `
+define i32 @f(i32 %x) {
+entry:
+	%c = icmp sgt i32 %x, 0
+	br i1 %c, label %cont, label %early
+
+early:
+	ret i32 -1
+
+cont:
+	%y = add i32 %x, 1
+	br label %exit
+
+exit:
+	ret i32 %y
+}
+`,
+			want: `package foo
+
+func f(x int32) int32 {
+	var c bool
+	var y int32
+	c = x > 0
+	if !c {
+		return -1
+	}
+	y = x + 1
+	return y
+}
+`,
+		},
+		// If body on the false branch.
+		{
+			name: "if",
+			src: `
+define i32 @f(i32 %x) {
+entry:
+	%c = icmp sgt i32 %x, 0
+	br i1 %c, label %exit, label %body
+
+body:
+	%y = sub i32 0, %x
+	br label %exit
+
+exit:
+	%z = phi i32 [ %y, %body ], [ %x, %entry ]
+	ret i32 %z
+}
+`,
+			want: `package foo
+
+func f(x int32) int32 {
+	var c bool
+	var y int32
+	var z int32
+	c = x > 0
+	z = x
+	if !c {
+		y = 0 - x
+		z = y
+	}
+	return z
+}
+`,
+		},
+	}
+	for i := range golden {
+		golden[i].dec = &Decompiler{Check: true}
+	}
+	testDecompile(t, golden)
+}
+
+// A decompileTest is a test case of decompiling LLVM IR assembly into Go source
+// code.
+type decompileTest struct {
+	// Test case name.
+	name string
+	// LLVM IR assembly.
+	src string
+	// Expected Go source code.
+	want string
+	// Decompiler; or the zero value of Decompiler if nil.
+	dec *Decompiler
+}
+
+// testDecompile decompiles the LLVM IR assembly of the given test cases, and
+// compares the output against the expected Go source code.
+func testDecompile(t *testing.T, golden []decompileTest) {
+	for _, g := range golden {
+		d := g.dec
+		if d == nil {
+			d = &Decompiler{}
+		}
+		got, err := decompile(g.name, g.src, d)
+		if err != nil {
+			t.Errorf("%s: %+v", g.name, err)
+			continue
+		}
+		if got != g.want {
+			t.Errorf("%s: Go source code mismatch; expected\n%s\ngot\n%s", g.name, g.want, got)
+		}
+	}
+}
+
+// decompile decompiles the given LLVM IR assembly into Go source code, using
+// the specified decompiler.
+func decompile(name, src string, d *Decompiler) (string, error) {
+	module, err := asm.ParseString(name+".ll", src)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to parse LLVM IR")
+	}
+	f, err := d.Decompile(module, "foo")
+	if err != nil {
+		return "", errors.Wrap(err, "unable to decompile LLVM IR")
+	}
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, f.Fset, f.File); err != nil {
+		return "", errors.Wrap(err, "unable to format Go source code")
+	}
+	return buf.String(), nil
+}
+
+func TestDecompileImportCollision(t *testing.T) {
+	// Identifiers colliding with the names of imported packages are renamed.
+	const src = `
+@fmt = global i32 0
+@.str = private unnamed_addr constant [3 x i8] c"hi\00"
+
+declare i32 @puts(i8*)
+
+declare double @llvm.sqrt.f64(double)
+
+define double @math(double %x) {
+	%y = call double @llvm.sqrt.f64(double %x)
+	ret double %y
+}
+
+define i32 @f() {
+	%v = load i32, i32* @fmt
+	%1 = call i32 @puts(i8* getelementptr ([3 x i8], [3 x i8]* @.str, i64 0, i64 0))
+	ret i32 %v
+}
+`
+	const want = `package foo
+
+import (
+	"fmt"
+	"github.com/decomp/decomp/rt/libc"
+	"math"
+)
+
+var fmt_ *int32 = newInt32(0)
+
+const dotstr = "hi"
+
+func math_(x float64) float64 {
+	var y float64
+	y = math.Sqrt(x)
+	return y
+}
+func f() int32 {
+	var v int32
+	v = *fmt_
+	_ = libc.Count(fmt.Println(dotstr))
+	return v
+}
+func newInt32(x int32) *int32 {
+	return &x
+}
+`
+	testDecompile(t, []decompileTest{{name: "import_collision", src: src, want: want, dec: &Decompiler{Check: true}}})
+}
+
+func TestDecompileStringImportCollision(t *testing.T) {
+	// String constants colliding with the names of imported packages are
+	// renamed.
+	const src = `
+@fmt = private unnamed_addr constant [4 x i8] c"%d\0A\00"
+@libc = private unnamed_addr constant [3 x i8] c"hi\00"
+
+declare i32 @printf(i8*, ...)
+
+declare i32 @puts(i8*)
+
+define void @f(i32 %x) {
+	%1 = call i32 (i8*, ...) @printf(i8* getelementptr ([4 x i8], [4 x i8]* @fmt, i64 0, i64 0), i32 %x)
+	%2 = call i32 @puts(i8* getelementptr ([3 x i8], [3 x i8]* @libc, i64 0, i64 0))
+	ret void
+}
+`
+	const want = `package foo
+
+import (
+	"fmt"
+	"github.com/decomp/decomp/rt/libc"
+)
+
+const fmt_ = "%d\n"
+const libc_ = "hi"
+
+func f(x int32) {
+	_ = libc.Count(fmt.Printf(fmt_, x))
+	_ = libc.Count(fmt.Println(libc_))
+	return
+}
+`
+	testDecompile(t, []decompileTest{{name: "string_import_collision", src: src, want: want, dec: &Decompiler{Check: true}}})
+}
+
+func TestDecompileIntIntrinsics(t *testing.T) {
+	// Integer intrinsics are lowered for integer types of Go, and are otherwise
+	// called through declarations with distinct parameter names.
+	const src = `
+declare i32 @llvm.smax.i32(i32, i32)
+declare i64 @llvm.umin.i64(i64, i64)
+declare i16 @llvm.abs.i16(i16, i1)
+declare i32 @llvm.fshl.i32(i32, i32, i32)
+declare i8 @llvm.fshr.i8(i8, i8, i8)
+declare i24 @llvm.umax.i24(i24, i24)
+
+define i32 @f(i32 %x, i32 %y, i64 %z, i16 %w, i8 %v, i24 %u) {
+	%a = call i32 @llvm.smax.i32(i32 %x, i32 %y)
+	%b = call i64 @llvm.umin.i64(i64 %z, i64 %z)
+	%c = call i16 @llvm.abs.i16(i16 %w, i1 false)
+	%d = call i32 @llvm.fshl.i32(i32 %a, i32 %a, i32 3)
+	%e = call i32 @llvm.fshl.i32(i32 %x, i32 %y, i32 %a)
+	%g = call i8 @llvm.fshr.i8(i8 %v, i8 %v, i8 1)
+	%h = call i24 @llvm.umax.i24(i24 %u, i24 %u)
+	ret i32 %e
+}
+`
+	const want = `package foo
+
+import (
+	"github.com/decomp/decomp/rt/intrinsics"
+	"math/bits"
+)
+
+func f(x int32, y int32, z int64, w int16, v int8, u int24) int32 {
+	var a int32
+	var e int32
+	a = int32(intrinsics.SMax(int64(x), int64(y)))
+	_ = int64(intrinsics.UMin(uint64(z), uint64(z)))
+	_ = int16(intrinsics.Abs(int64(w)))
+	_ = int32(bits.RotateLeft32(uint32(a), int(3)))
+	e = int32(intrinsics.FShl(uint64(uint32(x)), uint64(uint32(y)), uint64(uint32(a)), 32))
+	_ = int8(bits.RotateLeft8(uint8(v), -int(1)))
+	_ = llvmdotumaxdoti24(u, u)
+	return e
+}
+func llvmdotumaxdoti24(_0 int24, _1 int24) int24
+
+type int24 int32
+`
+	testDecompile(t, []decompileTest{{name: "int_intrinsics", src: src, want: want, dec: &Decompiler{Check: true}}})
+}
+
+func TestTypeCheckIntToString(t *testing.T) {
+	// Integers assigned to strings are annotated rather than converted, as the
+	// conversion yields the UTF-8 encoding of a rune.
+	const src = `package p
+
+func f(x int32) string {
+	var s string
+	s = x
+	return s
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("unable to parse Go source code; %v", err)
+	}
+	f, fset, errs, err := typeCheck(fset, file)
+	if err != nil {
+		t.Fatalf("unable to type-check Go source code; %+v", err)
+	}
+	if len(errs) != 1 {
+		t.Errorf("number of type errors mismatch; expected 1, got %d", len(errs))
+	}
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, fset, f); err != nil {
+		t.Fatalf("unable to format Go source code; %v", err)
+	}
+	got := buf.String()
+	if strings.Contains(got, "string(x)") {
+		t.Errorf("unexpected conversion of integer to string; got\n%s", got)
+	}
+	if !strings.Contains(got, "// ll2go: cannot use x") {
+		t.Errorf("missing annotation of type error; got\n%s", got)
+	}
+}
+
+func TestDecompileConstVector(t *testing.T) {
+	// The elements of constant vector operands are folded in lane-wise
+	// operations.
+	const src = `
+define <4 x i32> @f(<4 x i32> %x) {
+	%a = add <4 x i32> %x, <i32 3, i32 3, i32 3, i32 3>
+	%b = mul <4 x i32> %a, zeroinitializer
+	%c = icmp slt <4 x i32> %b, <i32 1, i32 2, i32 3, i32 4>
+	%d = select <4 x i1> %c, <4 x i32> %a, <4 x i32> %b
+	%e = shufflevector <4 x i32> %d, <4 x i32> <i32 7, i32 8, i32 9, i32 10>, <4 x i32> <i32 0, i32 5, i32 1, i32 6>
+	ret <4 x i32> %e
+}
+`
+	const want = `package foo
+
+func f(x [4]int32) [4]int32 {
+	var a [4]int32
+	var b [4]int32
+	var c [4]bool
+	var e [4]int32
+	a = [4]int32{x[0] + 3, x[1] + 3, x[2] + 3, x[3] + 3}
+	b = [4]int32{a[0] * 0, a[1] * 0, a[2] * 0, a[3] * 0}
+	c = [4]bool{b[0] < 1, b[1] < 2, b[2] < 3, b[3] < 4}
+	var d [4]int32
+	d = b
+	for i := range d {
+		if c[i] {
+			d[i] = a[i]
+		}
+	}
+	e = [4]int32{d[0], 8, d[1], 9}
+	return e
+}
+`
+	testDecompile(t, []decompileTest{{name: "const_vector", src: src, want: want, dec: &Decompiler{Check: true}}})
+}
+
+func TestDecompileAtomicWidth(t *testing.T) {
+	// Atomic operations on floating-point values and pointers of 32 and 64 bits
+	// use sync/atomic, as do integer operations of the same width.
+	const src = `
+define double @f(double* %p, float* %q, i8** %r, i8* %s) {
+	%1 = load atomic double, double* %p seq_cst, align 8
+	store atomic double %1, double* %p seq_cst, align 8
+	%2 = load atomic float, float* %q seq_cst, align 4
+	store atomic float %2, float* %q seq_cst, align 4
+	%3 = atomicrmw fadd double* %p, double 1.0 seq_cst
+	%4 = load atomic i8*, i8** %r seq_cst, align 8
+	store atomic i8* %s, i8** %r seq_cst, align 8
+	%5 = atomicrmw xchg i8** %r, i8* %s seq_cst
+	%6 = cmpxchg i8** %r, i8* %4, i8* %s seq_cst seq_cst
+	ret double %3
+}
+`
+	const want = `package foo
+
+import (
+	"github.com/decomp/decomp/rt/atomics"
+	"unsafe"
+)
+
+func f(p *float64, q *float32, r **int8, s *int8) float64 {
+	var _1 float64
+	var _2 float32
+	var _3 float64
+	var _4 *int8
+	_1 = atomics.LoadFloat64(p)
+	atomics.StoreFloat64(p, _1)
+	_2 = atomics.LoadFloat32(q)
+	atomics.StoreFloat32(q, _2)
+	_3 = atomics.UpdateFloat64(p, func(old float64) float64 {
+		return old + 1.0
+	})
+	_4 = (*int8)(atomics.LoadPointer(unsafe.Pointer(r)))
+	atomics.StorePointer(unsafe.Pointer(r), unsafe.Pointer(s))
+	_ = (*int8)(atomics.SwapPointer(unsafe.Pointer(r), unsafe.Pointer(s)))
+	var _6 struct {
+		field_0 *int8
+		field_1 bool
+	}
+	_6.field_1 = atomics.CompareAndSwapPointer(unsafe.Pointer(r), unsafe.Pointer(_4), unsafe.Pointer(s), unsafe.Pointer(&_6.field_0))
+	return _3
+}
+`
+	testDecompile(t, []decompileTest{{name: "atomic_width", src: src, want: want, dec: &Decompiler{Check: true}}})
+}
@@ -0,0 +1,76 @@
+package gogen
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"strings"
+
+	"github.com/decomp/decomp/rt"
+	"github.com/pkg/errors"
+)
+
+// rtImporter is a Go package importer which type-checks the runtime support
+// packages from their Go source code embedded in package rt, independent of the
+// working directory; and imports other packages (i.e. of the standard library)
+// from source.
+type rtImporter struct {
+	// Positions of the imported packages.
+	fset *token.FileSet
+	// Importer of packages other than the runtime support packages.
+	std types.Importer
+	// Map from import path to runtime support package.
+	pkgs map[string]*types.Package
+}
+
+// newImporter returns a new Go package importer of the runtime support
+// packages and the standard library.
+func newImporter() *rtImporter {
+	fset := token.NewFileSet()
+	return &rtImporter{
+		fset: fset,
+		std:  importer.ForCompiler(fset, "source", nil),
+		pkgs: make(map[string]*types.Package),
+	}
+}
+
+// Import returns the type-checked package of the given import path.
+func (imp *rtImporter) Import(pkgPath string) (*types.Package, error) {
+	if !strings.HasPrefix(pkgPath, rt.Path+"/") {
+		return imp.std.Import(pkgPath)
+	}
+	if pkg, ok := imp.pkgs[pkgPath]; ok {
+		return pkg, nil
+	}
+	dir := strings.TrimPrefix(pkgPath, rt.Path+"/")
+	entries, err := rt.FS.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Errorf("unable to locate runtime support package %q", pkgPath)
+	}
+	var files []*ast.File
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		buf, err := rt.FS.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		file, err := parser.ParseFile(imp.fset, path.Join(pkgPath, name), buf, 0)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		files = append(files, file)
+	}
+	conf := &types.Config{Importer: imp}
+	pkg, err := conf.Check(pkgPath, imp.fset, files, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to type-check runtime support package %q", pkgPath)
+	}
+	imp.pkgs[pkgPath] = pkg
+	return pkg, nil
+}
@@ -1,9 +1,10 @@
-package main
+package gogen
 
 import (
 	"fmt"
 	"go/ast"
 	"go/token"
+	"math/big"
 
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/constant"
@@ -17,25 +18,57 @@ import (
 func (d *decompiler) insts(insts []ir.Instruction) []ast.Stmt {
 	var stmts []ast.Stmt
 	for _, inst := range insts {
-		switch inst := inst.(type) {
-		case *ir.InstPhi:
-			// PHI instructions are handled during the pre-processing of basic
-			// blocks.
-			continue
-		case *ir.InstSelect:
-			// A select instruction corresponds to more than one Go statement, thus
-			// it is handled outside of d.inst.
-			stmts = append(stmts, d.instSelect(inst)...)
-			continue
-		}
-		stmts = append(stmts, d.inst(inst))
+		instStmts := d.instStmts(inst)
+		d.instComment(instStmts, inst)
+		stmts = append(stmts, instStmts...)
 	}
 	return stmts
 }
 
+// instStmts converts the given LLVM IR instruction to a corresponding list of
+// Go statements.
+func (d *decompiler) instStmts(inst ir.Instruction) []ast.Stmt {
+	switch inst := inst.(type) {
+	case *ir.InstPhi:
+		// PHI instructions are handled during the pre-processing of basic
+		// blocks.
+		return nil
+	case *ir.InstSelect:
+		// A select instruction corresponds to more than one Go statement, thus
+		// it is handled outside of d.inst.
+		return d.instSelect(inst)
+	case *ir.InstInsertElement:
+		// An insertelement instruction corresponds to more than one Go
+		// statement, thus it is handled outside of d.inst.
+		return d.instInsertElement(inst)
+	case *ir.InstInsertValue:
+		// An insertvalue instruction corresponds to more than one Go statement,
+		// thus it is handled outside of d.inst.
+		return d.instInsertValue(inst)
+	case *ir.InstCmpXchg:
+		// A cmpxchg instruction corresponds to more than one Go statement, thus
+		// it is handled outside of d.inst.
+		return d.instCmpXchg(inst)
+	case *ir.InstCall:
+		// Calls to LLVM intrinsic functions and C standard library functions are
+		// lowered to a list of Go statements, which may be empty.
+		if loweredStmts, ok := d.lowerCall(inst); ok {
+			return loweredStmts
+		}
+	case *ir.InstAtomicRMW:
+		// An atomicrmw instruction may correspond to more than one Go statement,
+		// thus it is handled outside of d.inst.
+		return d.instAtomicRMW(inst)
+	}
+	return []ast.Stmt{d.inst(inst)}
+}
+
 // inst converts the given LLVM IR instruction to a corresponding Go statement.
 func (d *decompiler) inst(inst ir.Instruction) ast.Stmt {
 	switch inst := inst.(type) {
+	// Unary instructions
+	case *ir.InstFNeg:
+		return d.instFNeg(inst)
 	// Binary instructions
 	case *ir.InstAdd:
 		return d.instAdd(inst)
@@ -78,14 +111,16 @@ func (d *decompiler) inst(inst ir.Instruction) ast.Stmt {
 	case *ir.InstExtractElement:
 		return d.instExtractElement(inst)
 	case *ir.InstInsertElement:
-		return d.instInsertElement(inst)
+		// insertelement instructions are handled by d.insts.
+		panic(fmt.Sprintf("unexpected insertelement instruction `%v`", inst))
 	case *ir.InstShuffleVector:
 		return d.instShuffleVector(inst)
 	// Aggregate instructions
 	case *ir.InstExtractValue:
 		return d.instExtractValue(inst)
 	case *ir.InstInsertValue:
-		return d.instInsertValue(inst)
+		// insertvalue instructions are handled by d.insts.
+		panic(fmt.Sprintf("unexpected insertvalue instruction `%v`", inst))
 	// Memory instructions
 	case *ir.InstAlloca:
 		return d.instAlloca(inst)
@@ -95,6 +130,14 @@ func (d *decompiler) inst(inst ir.Instruction) ast.Stmt {
 		return d.instStore(inst)
 	case *ir.InstGetElementPtr:
 		return d.instGetElementPtr(inst)
+	case *ir.InstCmpXchg:
+		// cmpxchg instructions are handled by d.insts.
+		panic(fmt.Sprintf("unexpected cmpxchg instruction `%v`", inst))
+	case *ir.InstAtomicRMW:
+		// atomicrmw instructions are handled by d.insts.
+		panic(fmt.Sprintf("unexpected atomicrmw instruction `%v`", inst))
+	case *ir.InstFence:
+		return d.instFence(inst)
 	// Conversion instructions
 	case *ir.InstTrunc:
 		return d.instTrunc(inst)
@@ -133,45 +176,58 @@ func (d *decompiler) inst(inst ir.Instruction) ast.Stmt {
 	case *ir.InstSelect:
 		// select instructions are handled by d.insts.
 		panic(fmt.Sprintf("unexpected select instruction `%v`", inst))
+	case *ir.InstFreeze:
+		return d.instFreeze(inst)
 	case *ir.InstCall:
 		return d.instCall(inst)
+	case *ir.InstVAArg:
+		return d.instVAArg(inst)
+	case *ir.InstLandingPad:
+		return d.instLandingPad(inst)
 	default:
 		panic(fmt.Sprintf("support for instruction %T not yet implemented", inst))
 	}
 }
 
+// instFNeg converts the given LLVM IR fneg instruction to a corresponding Go
+// statement.
+func (d *decompiler) instFNeg(inst *ir.InstFNeg) ast.Stmt {
+	expr := d.floatNeg(inst.X)
+	return d.assign(inst.Name(), expr)
+}
+
 // instAdd converts the given LLVM IR add instruction to a corresponding Go
 // statement.
 func (d *decompiler) instAdd(inst *ir.InstAdd) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.ADD, inst.Y)
+	expr := d.intOp(inst.X, token.ADD, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instFAdd converts the given LLVM IR fadd instruction to a corresponding Go
 // statement.
 func (d *decompiler) instFAdd(inst *ir.InstFAdd) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.ADD, inst.Y)
+	expr := d.floatOp(inst.X, token.ADD, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instSub converts the given LLVM IR sub instruction to a corresponding Go
 // statement.
 func (d *decompiler) instSub(inst *ir.InstSub) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.SUB, inst.Y)
+	expr := d.intOp(inst.X, token.SUB, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instFSub converts the given LLVM IR fsub instruction to a corresponding Go
 // statement.
 func (d *decompiler) instFSub(inst *ir.InstFSub) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.SUB, inst.Y)
+	expr := d.floatOp(inst.X, token.SUB, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instMul converts the given LLVM IR mul instruction to a corresponding Go
 // statement.
 func (d *decompiler) instMul(inst *ir.InstMul) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.MUL, inst.Y)
+	expr := d.intOp(inst.X, token.MUL, inst.Y)
 	return d.assign(inst.Name(), expr)
 
 }
@@ -179,93 +235,92 @@ func (d *decompiler) instMul(inst *ir.InstMul) ast.Stmt {
 // instFMul converts the given LLVM IR fmul instruction to a corresponding Go
 // statement.
 func (d *decompiler) instFMul(inst *ir.InstFMul) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.MUL, inst.Y)
+	expr := d.floatOp(inst.X, token.MUL, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instUDiv converts the given LLVM IR udiv instruction to a corresponding Go
 // statement.
 func (d *decompiler) instUDiv(inst *ir.InstUDiv) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.QUO, inst.Y)
+	expr := d.unsignedOp(inst.X, token.QUO, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instSDiv converts the given LLVM IR sdiv instruction to a corresponding Go
 // statement.
 func (d *decompiler) instSDiv(inst *ir.InstSDiv) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.QUO, inst.Y)
+	expr := d.intOp(inst.X, token.QUO, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instFDiv converts the given LLVM IR fdiv instruction to a corresponding Go
 // statement.
 func (d *decompiler) instFDiv(inst *ir.InstFDiv) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.QUO, inst.Y)
+	expr := d.floatOp(inst.X, token.QUO, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instURem converts the given LLVM IR urem instruction to a corresponding Go
 // statement.
 func (d *decompiler) instURem(inst *ir.InstURem) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.REM, inst.Y)
+	expr := d.unsignedOp(inst.X, token.REM, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instSRem converts the given LLVM IR srem instruction to a corresponding Go
 // statement.
 func (d *decompiler) instSRem(inst *ir.InstSRem) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.REM, inst.Y)
+	expr := d.intOp(inst.X, token.REM, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instFRem converts the given LLVM IR frem instruction to a corresponding Go
 // statement.
 func (d *decompiler) instFRem(inst *ir.InstFRem) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.REM, inst.Y)
+	expr := d.floatOp(inst.X, token.REM, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instShl converts the given LLVM IR shl instruction to a corresponding Go
 // statement.
 func (d *decompiler) instShl(inst *ir.InstShl) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.SHL, inst.Y)
+	expr := d.intOp(inst.X, token.SHL, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instLShr converts the given LLVM IR lshr instruction to a corresponding Go
 // statement.
 func (d *decompiler) instLShr(inst *ir.InstLShr) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.SHR, inst.Y)
+	expr := d.unsignedOp(inst.X, token.SHR, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instAShr converts the given LLVM IR ashr instruction to a corresponding Go
 // statement.
 func (d *decompiler) instAShr(inst *ir.InstAShr) ast.Stmt {
-	// TODO: Differentiate between logical shift right and arithmetic shift
-	// right.
-	expr := d.binaryOp(inst.X, token.SHR, inst.Y)
+	// Right shift of signed Go integers is arithmetic.
+	expr := d.intOp(inst.X, token.SHR, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instAnd converts the given LLVM IR and instruction to a corresponding Go
 // statement.
 func (d *decompiler) instAnd(inst *ir.InstAnd) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.AND, inst.Y)
+	expr := d.intOp(inst.X, token.AND, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instOr converts the given LLVM IR or instruction to a corresponding Go
 // statement.
 func (d *decompiler) instOr(inst *ir.InstOr) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.OR, inst.Y)
+	expr := d.intOp(inst.X, token.OR, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instXor converts the given LLVM IR xor instruction to a corresponding Go
 // statement.
 func (d *decompiler) instXor(inst *ir.InstXor) ast.Stmt {
-	expr := d.binaryOp(inst.X, token.XOR, inst.Y)
+	expr := d.intOp(inst.X, token.XOR, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
@@ -280,37 +335,38 @@ func (d *decompiler) instExtractElement(inst *ir.InstExtractElement) ast.Stmt {
 }
 
 // instInsertElement converts the given LLVM IR insertelement instruction to a
-// corresponding Go statement.
-func (d *decompiler) instInsertElement(inst *ir.InstInsertElement) ast.Stmt {
-	// TODO: Implement insertelement.
-	panic("not yet implemented")
+// corresponding list of Go statements.
+func (d *decompiler) instInsertElement(inst *ir.InstInsertElement) []ast.Stmt {
+	index := func(dst ast.Expr) ast.Expr {
+		return &ast.IndexExpr{
+			X:     dst,
+			Index: d.value(inst.Index),
+		}
+	}
+	return d.insertStmts(inst.Name(), inst.X, index, inst.Elem)
 }
 
 // instShuffleVector converts the given LLVM IR shufflevector instruction to a
 // corresponding Go statement.
 func (d *decompiler) instShuffleVector(inst *ir.InstShuffleVector) ast.Stmt {
-	// TODO: Implement shufflevector.
-	panic("not yet implemented")
+	expr := d.shuffleVector(inst.X, inst.Y, inst.Mask, inst.Type())
+	return d.assign(inst.Name(), expr)
 }
 
 // instExtractValue converts the given LLVM IR extractvalue instruction to a
 // corresponding Go statement.
 func (d *decompiler) instExtractValue(inst *ir.InstExtractValue) ast.Stmt {
-	src := d.value(inst.X)
-	for _, index := range inst.Indices {
-		src = &ast.IndexExpr{
-			X:     src,
-			Index: d.uintLit(index),
-		}
-	}
+	src := d.aggregateElem(d.value(inst.X), inst.X.Type(), inst.Indices)
 	return d.assign(inst.Name(), src)
 }
 
 // instInsertValue converts the given LLVM IR insertvalue instruction to a
-// corresponding Go statement.
-func (d *decompiler) instInsertValue(inst *ir.InstInsertValue) ast.Stmt {
-	// TODO: Implement insertvalue.
-	panic("not yet implemented")
+// corresponding list of Go statements.
+func (d *decompiler) instInsertValue(inst *ir.InstInsertValue) []ast.Stmt {
+	index := func(dst ast.Expr) ast.Expr {
+		return d.aggregateElem(dst, inst.X.Type(), inst.Indices)
+	}
+	return d.insertStmts(inst.Name(), inst.X, index, inst.Elem)
 }
 
 // instAlloca converts the given LLVM IR alloca instruction to a corresponding
@@ -333,6 +389,9 @@ func (d *decompiler) instAlloca(inst *ir.InstAlloca) ast.Stmt {
 // instLoad converts the given LLVM IR load instruction to a corresponding Go
 // statement.
 func (d *decompiler) instLoad(inst *ir.InstLoad) ast.Stmt {
+	if inst.Atomic {
+		return d.atomicLoad(inst)
+	}
 	// TODO: Handle type (inst.Typ).
 	expr := &ast.StarExpr{
 		X: d.value(inst.Src),
@@ -343,6 +402,9 @@ func (d *decompiler) instLoad(inst *ir.InstLoad) ast.Stmt {
 // instStore converts the given LLVM IR store instruction to a corresponding Go
 // statement.
 func (d *decompiler) instStore(inst *ir.InstStore) ast.Stmt {
+	if inst.Atomic {
+		return d.atomicStore(inst)
+	}
 	dst := &ast.StarExpr{
 		X: d.value(inst.Dst),
 	}
@@ -356,39 +418,28 @@ func (d *decompiler) instStore(inst *ir.InstStore) ast.Stmt {
 // instGetElementPtr converts the given LLVM IR getelementptr instruction to a
 // corresponding Go statement.
 func (d *decompiler) instGetElementPtr(inst *ir.InstGetElementPtr) ast.Stmt {
-	src := d.value(inst.Src)
-	// TODO: Validate if index expressions should be added in reverse order.
-	for _, index := range inst.Indices {
-		src = &ast.IndexExpr{
-			X:     src,
-			Index: d.value(index),
-		}
-	}
-	expr := &ast.UnaryExpr{
-		Op: token.AND,
-		X:  src,
-	}
+	expr := d.gep(inst.Src, inst.ElemType, inst.Indices)
 	return d.assign(inst.Name(), expr)
 }
 
 // instTrunc converts the given LLVM IR trunc instruction to a corresponding Go
 // statement.
 func (d *decompiler) instTrunc(inst *ir.InstTrunc) ast.Stmt {
-	expr := d.convert(inst.From, inst.To)
+	expr := d.convertTrunc(inst.From, inst.To)
 	return d.assign(inst.Name(), expr)
 }
 
 // instZExt converts the given LLVM IR zext instruction to a corresponding Go
 // statement.
 func (d *decompiler) instZExt(inst *ir.InstZExt) ast.Stmt {
-	expr := d.convert(inst.From, inst.To)
+	expr := d.convertUnsigned(inst.From, inst.To)
 	return d.assign(inst.Name(), expr)
 }
 
 // instSExt converts the given LLVM IR sext instruction to a corresponding Go
 // statement.
 func (d *decompiler) instSExt(inst *ir.InstSExt) ast.Stmt {
-	expr := d.convert(inst.From, inst.To)
+	expr := d.convertSExt(inst.From, inst.To)
 	return d.assign(inst.Name(), expr)
 }
 
@@ -409,28 +460,28 @@ func (d *decompiler) instFPExt(inst *ir.InstFPExt) ast.Stmt {
 // instFPToUI converts the given LLVM IR fptoui instruction to a corresponding
 // Go statement.
 func (d *decompiler) instFPToUI(inst *ir.InstFPToUI) ast.Stmt {
-	expr := d.convert(inst.From, inst.To)
+	expr := d.convertToUnsigned(inst.From, inst.To)
 	return d.assign(inst.Name(), expr)
 }
 
 // instFPToSI converts the given LLVM IR fptosi instruction to a corresponding
 // Go statement.
 func (d *decompiler) instFPToSI(inst *ir.InstFPToSI) ast.Stmt {
-	expr := d.convert(inst.From, inst.To)
+	expr := d.convertFPToSI(inst.From, inst.To)
 	return d.assign(inst.Name(), expr)
 }
 
 // instUIToFP converts the given LLVM IR uitofp instruction to a corresponding
 // Go statement.
 func (d *decompiler) instUIToFP(inst *ir.InstUIToFP) ast.Stmt {
-	expr := d.convert(inst.From, inst.To)
+	expr := d.convertUnsigned(inst.From, inst.To)
 	return d.assign(inst.Name(), expr)
 }
 
 // instSIToFP converts the given LLVM IR sitofp instruction to a corresponding
 // Go statement.
 func (d *decompiler) instSIToFP(inst *ir.InstSIToFP) ast.Stmt {
-	expr := d.convert(inst.From, inst.To)
+	expr := d.convertSIToFP(inst.From, inst.To)
 	return d.assign(inst.Name(), expr)
 }
 
@@ -465,16 +516,14 @@ func (d *decompiler) instAddrSpaceCast(inst *ir.InstAddrSpaceCast) ast.Stmt {
 // instICmp converts the given LLVM IR icmp instruction to a corresponding Go
 // statement.
 func (d *decompiler) instICmp(inst *ir.InstICmp) ast.Stmt {
-	op := intPred(inst.Pred)
-	expr := d.binaryOp(inst.X, op, inst.Y)
+	expr := d.intCmp(inst.Pred, inst.X, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
 // instFCmp converts the given LLVM IR fcmp instruction to a corresponding Go
 // statement.
 func (d *decompiler) instFCmp(inst *ir.InstFCmp) ast.Stmt {
-	op := floatPred(inst.Pred)
-	expr := d.binaryOp(inst.X, op, inst.Y)
+	expr := d.floatCmp(inst.Pred, inst.X, inst.Y)
 	return d.assign(inst.Name(), expr)
 }
 
@@ -491,6 +540,37 @@ func (d *decompiler) instSelect(inst *ir.InstSelect) []ast.Stmt {
 			Specs: []ast.Spec{spec},
 		},
 	}
+	if _, ok := inst.Cond.Type().(*irtypes.VectorType); ok {
+		// Select vector elements based on the elements of the condition vector.
+		//
+		//    _3 = _2
+		//    for i := range _3 {
+		//       if _0[i] {
+		//          _3[i] = _1[i]
+		//       }
+		//    }
+		i := ast.NewIdent("i")
+		index := func(x ast.Expr) ast.Expr {
+			return &ast.IndexExpr{X: x, Index: i}
+		}
+		ifStmt := &ast.IfStmt{
+			Cond: index(d.value(inst.Cond)),
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{&ast.AssignStmt{
+					Lhs: []ast.Expr{index(d.localIdent(inst.Name()))},
+					Tok: token.ASSIGN,
+					Rhs: []ast.Expr{index(d.value(inst.ValueTrue))},
+				}},
+			},
+		}
+		rangeStmt := &ast.RangeStmt{
+			Key:  i,
+			Tok:  token.DEFINE,
+			X:    d.localIdent(inst.Name()),
+			Body: &ast.BlockStmt{List: []ast.Stmt{ifStmt}},
+		}
+		return []ast.Stmt{declStmt, d.assign(inst.Name(), d.value(inst.ValueFalse)), rangeStmt}
+	}
 	ifStmt := &ast.IfStmt{
 		Cond: d.value(inst.Cond),
 		Body: &ast.BlockStmt{
@@ -506,40 +586,75 @@ func (d *decompiler) instSelect(inst *ir.InstSelect) []ast.Stmt {
 // instCall converts the given LLVM IR call instruction to a corresponding Go
 // statement.
 func (d *decompiler) instCall(inst *ir.InstCall) ast.Stmt {
-	var callee ast.Expr
-	switch c := inst.Callee.(type) {
+	expr := d.callExpr(inst.Callee, inst.Args)
+	if irtypes.Equal(inst.Type(), irtypes.Void) {
+		return &ast.ExprStmt{X: expr}
+	}
+	return d.assign(inst.Name(), expr)
+}
+
+// callExpr converts the given LLVM IR function call to a corresponding Go call
+// expression.
+func (d *decompiler) callExpr(callee value.Value, args []value.Value) ast.Expr {
+	var fun ast.Expr
+	switch c := callee.(type) {
 	case *ir.Func:
 		// global function identifier.
-		callee = d.globalIdent(c.Name())
+		fun = d.globalIdent(c.Name())
 	case *ir.Param:
 		// local function identifier.
-		callee = d.localIdent(c.Name())
+		fun = d.localIdent(c.Name())
 	case *constant.ExprBitCast:
-		callee = d.value(c)
+		fun = d.value(c)
 	case *ir.InstBitCast:
-		callee = d.value(c)
+		fun = d.value(c)
 	case *ir.InstLoad:
-		callee = d.value(c)
+		fun = d.value(c)
 	default:
 		panic(fmt.Sprintf("support for callee type %T not yet implemented", c))
 	}
-	var args []ast.Expr
-	for _, a := range inst.Args {
-		args = append(args, d.value(a))
+	var exprs []ast.Expr
+	for i, a := range args {
+		expr := d.value(a)
+		if _, ok := a.(constant.Constant); ok && isVarArg(callee, i) {
+			// Pass variadic arguments of constant value with explicit type, as
+			// untyped constants default to int and float64 when converted to
+			// interface{}.
+			expr = call(d.goType(a.Type()), expr)
+		}
+		exprs = append(exprs, expr)
 	}
-	expr := &ast.CallExpr{
-		Fun:  callee,
-		Args: args,
+	return &ast.CallExpr{
+		Fun:  fun,
+		Args: exprs,
 	}
-	if irtypes.Equal(inst.Type(), irtypes.Void) {
-		return &ast.ExprStmt{X: expr}
+}
+
+// isVarArg reports whether the i:th argument of a call to the given callee is
+// passed as a variadic argument.
+func isVarArg(callee value.Value, i int) bool {
+	ptr, ok := callee.Type().(*irtypes.PointerType)
+	if !ok {
+		return false
 	}
-	return d.assign(inst.Name(), expr)
+	sig, ok := ptr.ElemType.(*irtypes.FuncType)
+	if !ok {
+		return false
+	}
+	return sig.Variadic && i >= len(sig.Params)
 }
 
 // binaryOp converts the given LLVM IR binary operation to a corresponding Go
 // expression.
+//
+// Operations on vectors are converted element-wise, and vector comparisons
+// produce [N]bool results.
 func (d *decompiler) binaryOp(x value.Value, op token.Token, y value.Value) ast.Expr {
+	if t, ok := x.Type().(*irtypes.VectorType); ok {
+		return d.vectorOp(t, x, y, isCmpOp(op), func(xi, yi ast.Expr) ast.Expr {
+			return &ast.BinaryExpr{X: xi, Op: op, Y: yi}
+		})
+	}
 	return &ast.BinaryExpr{
 		X:  d.value(x),
 		Op: op,
@@ -547,9 +662,196 @@ func (d *decompiler) binaryOp(x value.Value, op token.Token, y value.Value) ast.
 	}
 }
 
+// unsignedOp converts the given LLVM IR binary operation with unsigned
+// semantics (e.g. udiv, urem, lshr) to a corresponding Go expression. The
+// operands of integer type are converted to unsigned Go integers and the result
+// is converted back to the signed Go integer type of the operation.
+//
+//    int32(uint32(x) / uint32(y))
+func (d *decompiler) unsignedOp(x value.Value, op token.Token, y value.Value) ast.Expr {
+	if _, ok := wideInt(x.Type()); ok {
+		return method(d.value(x), wideMethod(op, true), d.value(y))
+	}
+	if t, ok := x.Type().(*irtypes.VectorType); ok {
+		elemType, ok := t.ElemType.(*irtypes.IntType)
+		if !ok {
+			return d.binaryOp(x, op, y)
+		}
+		return d.vectorOp(t, x, y, false, func(xi, yi ast.Expr) ast.Expr {
+			expr := &ast.BinaryExpr{
+				X:  call(d.goUintType(elemType), xi),
+				Op: op,
+				Y:  call(d.goUintType(elemType), yi),
+			}
+			return call(d.goType(elemType), expr)
+		})
+	}
+	if _, ok := x.Type().(*irtypes.IntType); !ok {
+		return d.binaryOp(x, op, y)
+	}
+	expr := &ast.BinaryExpr{
+		X:  d.unsigned(x),
+		Op: op,
+		Y:  d.unsigned(y),
+	}
+	if t, ok := oddInt(x.Type()); ok {
+		return d.signExtend(expr, t)
+	}
+	return &ast.CallExpr{
+		Fun:  d.goType(x.Type()),
+		Args: []ast.Expr{expr},
+	}
+}
+
+// intCmp converts the given LLVM IR integer comparison to a corresponding Go
+// expression. Operands of integer type are converted to unsigned Go integers for
+// unsigned predicates.
+//
+//    uint32(x) < uint32(y)
+func (d *decompiler) intCmp(pred enum.IPred, x, y value.Value) ast.Expr {
+	op := intPred(pred)
+	if _, ok := wideInt(x.Type()); ok {
+		// Compare wide integers using their Cmp or UCmp methods.
+		//
+		//    x.UCmp(y) < 0
+		name := "Cmp"
+		if isUnsignedPred(pred) {
+			name = "UCmp"
+		}
+		return &ast.BinaryExpr{
+			X:  method(d.value(x), name, d.value(y)),
+			Op: op,
+			Y:  d.intLit(0),
+		}
+	}
+	if t, ok := x.Type().(*irtypes.VectorType); ok && isUnsignedPred(pred) {
+		if elemType, ok := t.ElemType.(*irtypes.IntType); ok {
+			return d.vectorOp(t, x, y, true, func(xi, yi ast.Expr) ast.Expr {
+				return &ast.BinaryExpr{
+					X:  call(d.goUintType(elemType), xi),
+					Op: op,
+					Y:  call(d.goUintType(elemType), yi),
+				}
+			})
+		}
+	}
+	if _, ok := x.Type().(*irtypes.IntType); !ok || !isUnsignedPred(pred) {
+		return d.binaryOp(x, op, y)
+	}
+	return &ast.BinaryExpr{
+		X:  d.unsigned(x),
+		Op: op,
+		Y:  d.unsigned(y),
+	}
+}
+
+// unsigned converts the given LLVM IR value to a corresponding Go expression of
+// unsigned integer type. Values not of integer type, of type i1, or of integer
+// types wider than 64 bits, are returned unconverted.
+func (d *decompiler) unsigned(v value.Value) ast.Expr {
+	t, ok := v.Type().(*irtypes.IntType)
+	if !ok || t.BitSize == 1 || t.BitSize > 64 {
+		return d.value(v)
+	}
+	x := d.value(v)
+	if _, ok := oddInt(t); ok {
+		if _, ok := v.(*constant.Int); !ok {
+			// Discard the sign-extended bits of odd integers.
+			return d.zeroExtend(x, t)
+		}
+	}
+	if c, ok := v.(*constant.Int); ok {
+		// Use the unsigned representation of integer constants, as constant
+		// conversions of negative values to unsigned Go integers are invalid.
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(t.BitSize))
+		x = &ast.BasicLit{
+			Kind:  token.INT,
+			Value: new(big.Int).Mod(c.X, limit).String(),
+		}
+	}
+	return &ast.CallExpr{
+		Fun:  d.goUintType(t),
+		Args: []ast.Expr{x},
+	}
+}
+
+// convertUnsigned returns a Go expression for converting the given LLVM IR value
+// into the specified type, interpreting the value as unsigned (e.g. zext,
+// uitofp).
+//
+//    int64(uint32(x))
+func (d *decompiler) convertUnsigned(from value.Value, to irtypes.Type) ast.Expr {
+	if isBool(from.Type()) {
+		return d.convertBool(from, to, false)
+	}
+	if expr, ok := d.convertSoftFloat(from, to, true); ok {
+		return expr
+	}
+	if t, ok := wideInt(to); ok {
+		if _, ok := wideInt(from.Type()); ok {
+			return method(d.value(from), "ZExt", bitsLit(t))
+		}
+		x := call(ast.NewIdent("uint64"), d.unsigned(from))
+		return call(d.intnSel("FromUint64"), bitsLit(t), x)
+	}
+	if _, ok := wideInt(from.Type()); ok {
+		return call(d.goType(to), method(d.value(from), "UFloat64"))
+	}
+	if fromType, toType, ok := vectorConv(from, to); ok {
+		if elemType, ok := fromType.ElemType.(*irtypes.IntType); ok {
+			return d.convertVector(from, toType, func(xi ast.Expr) ast.Expr {
+				return call(d.goType(toType.ElemType), call(d.goUintType(elemType), xi))
+			})
+		}
+	}
+	return &ast.CallExpr{
+		Fun:  d.goType(to),
+		Args: []ast.Expr{d.unsigned(from)},
+	}
+}
+
+// convertToUnsigned returns a Go expression for converting the given LLVM IR
+// value into the specified type, interpreting the result as unsigned (e.g.
+// fptoui).
+//
+//    int64(uint64(x))
+func (d *decompiler) convertToUnsigned(from value.Value, to irtypes.Type) ast.Expr {
+	if expr, ok := d.convertSoftFloat(from, to, true); ok {
+		return expr
+	}
+	if t, ok := wideInt(to); ok {
+		x := call(ast.NewIdent("float64"), d.value(from))
+		return call(d.intnSel("FromFloat64"), bitsLit(t), x)
+	}
+	t, ok := to.(*irtypes.IntType)
+	if !ok {
+		return d.convert(from, to)
+	}
+	expr := &ast.CallExpr{
+		Fun:  d.goUintType(t),
+		Args: []ast.Expr{d.value(from)},
+	}
+	if _, ok := oddInt(t); ok {
+		return d.signExtend(expr, t)
+	}
+	return &ast.CallExpr{
+		Fun:  d.goType(to),
+		Args: []ast.Expr{expr},
+	}
+}
+
 // convert returns a Go statement for converting the given LLVM IR value into
 // the specified type.
 func (d *decompiler) convert(from value.Value, to irtypes.Type) ast.Expr {
+	if expr, ok := d.convertSoftFloat(from, to, false); ok {
+		return expr
+	}
+	// Convert vectors element-wise.
+	if _, toType, ok := vectorConv(from, to); ok {
+		return d.convertVector(from, toType, func(xi ast.Expr) ast.Expr {
+			return call(d.goType(toType.ElemType), xi)
+		})
+	}
 	// Type conversion represented as a Go call expression.
 	return &ast.CallExpr{
 		Fun:  d.goType(to),
@@ -569,8 +871,10 @@ func (d *decompiler) assign(name string, expr ast.Expr) *ast.AssignStmt {
 
 // intPred converts the given LLVM IR integer predicate to a corresponding Go
 // token.
+//
+// Note, the signedness of the predicate is handled by converting the operands
+// of unsigned predicates to unsigned Go integers; see d.intCmp.
 func intPred(pred enum.IPred) token.Token {
-	// TODO: Differentiate between unsigned and signed.
 	switch pred {
 	case enum.IPredEQ:
 		return token.EQL
@@ -597,44 +901,12 @@ func intPred(pred enum.IPred) token.Token {
 	}
 }
 
-// floatPred converts the given LLVM IR floating-point predicate to a
-// corresponding Go token.
-func floatPred(pred enum.FPred) token.Token {
-	// TODO: Differentiate between ordered and unordered.
+// isUnsignedPred reports whether the given LLVM IR integer predicate is an
+// unsigned comparison.
+func isUnsignedPred(pred enum.IPred) bool {
 	switch pred {
-	case enum.FPredFalse:
-		panic(`support for floating-point predicate "false" not yet implemented`)
-	case enum.FPredOEQ:
-		return token.EQL
-	case enum.FPredOGT:
-		return token.GTR
-	case enum.FPredOGE:
-		return token.GEQ
-	case enum.FPredOLT:
-		return token.LSS
-	case enum.FPredOLE:
-		return token.LEQ
-	case enum.FPredONE:
-		return token.NEQ
-	case enum.FPredORD:
-		panic(`support for floating-point predicate "ord" not yet implemented`)
-	case enum.FPredUEQ:
-		return token.EQL
-	case enum.FPredUGT:
-		return token.GTR
-	case enum.FPredUGE:
-		return token.GEQ
-	case enum.FPredULT:
-		return token.LSS
-	case enum.FPredULE:
-		return token.LEQ
-	case enum.FPredUNE:
-		return token.NEQ
-	case enum.FPredUNO:
-		panic(`support for floating-point predicate "uno" not yet implemented`)
-	case enum.FPredTrue:
-		panic(`support for floating-point predicate "true" not yet implemented`)
-	default:
-		panic(fmt.Sprintf("support for floating-point predicate %v not yet implemented", pred))
+	case enum.IPredUGT, enum.IPredUGE, enum.IPredULT, enum.IPredULE:
+		return true
 	}
+	return false
 }
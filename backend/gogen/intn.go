@@ -0,0 +1,306 @@
+package gogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path"
+
+	"github.com/llir/llvm/ir/constant"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// intnPath is the import path of the runtime support package for
+// arbitrary-width integers.
+const intnPath = "github.com/decomp/decomp/rt/intn"
+
+// wideInt returns the integer type of the given LLVM IR type if wider than 64
+// bits. Wide integers are represented by intn.Int in Go. The boolean return
+// value indicates success.
+func wideInt(t irtypes.Type) (*irtypes.IntType, bool) {
+	if t, ok := t.(*irtypes.IntType); ok && t.BitSize > 64 {
+		return t, true
+	}
+	return nil, false
+}
+
+// oddInt returns the integer type of the given LLVM IR type if of a bit width
+// not part of Go builtin and at most 64 bits (e.g. i24, i48). The boolean
+// return value indicates success.
+//
+// Odd integers are represented by Go integers of the next larger width, kept in
+// sign-extended form. Note, i1 is not considered an odd integer, as its values
+// are predominantly produced by comparisons.
+func oddInt(t irtypes.Type) (*irtypes.IntType, bool) {
+	if t, ok := t.(*irtypes.IntType); ok {
+		switch t.BitSize {
+		case 1, 8, 16, 32, 64:
+			return nil, false
+		}
+		if t.BitSize < 64 {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// intnSel returns a Go selector expression for the given identifier of the
+// runtime support package for arbitrary-width integers.
+func (d *decompiler) intnSel(name string) ast.Expr {
+	return d.importSel(intnPath, name)
+}
+
+// importSel returns a Go selector expression for the given identifier of the
+// specified package, and records the import of the package. The package name
+// is marked by pkgObj (see renameImportCollisions).
+func (d *decompiler) importSel(pkgPath, name string) ast.Expr {
+	d.imports[pkgPath] = true
+	return &ast.SelectorExpr{
+		X:   &ast.Ident{Name: path.Base(pkgPath), Obj: pkgObj},
+		Sel: ast.NewIdent(name),
+	}
+}
+
+// pkgObj marks the package names of qualified identifiers created by
+// importSel.
+var pkgObj = ast.NewObj(ast.Pkg, "")
+
+// renameImportCollisions renames the identifiers of the given Go source file
+// which collide with the names of the imported packages (e.g. a global variable
+// named fmt), as they would otherwise conflict with or shadow the imports. The
+// unsafe package is always reserved, as it may be imported by conversions
+// inserted during type-checking.
+//
+//    var fmt_ int32
+func renameImportCollisions(file *ast.File, imports map[string]bool) {
+	pkgNames := map[string]bool{"unsafe": true}
+	for pkgPath := range imports {
+		pkgNames[path.Base(pkgPath)] = true
+	}
+	used := make(map[string]bool)
+	inspect := func(f func(id *ast.Ident)) {
+		for _, decl := range file.Decls {
+			ast.Inspect(decl, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					f(id)
+				}
+				return true
+			})
+		}
+	}
+	inspect(func(id *ast.Ident) {
+		used[id.Name] = true
+	})
+	renamed := make(map[string]string)
+	rename := func(name string) string {
+		if newName, ok := renamed[name]; ok {
+			return newName
+		}
+		newName := name + "_"
+		for used[newName] {
+			newName += "_"
+		}
+		used[newName] = true
+		renamed[name] = newName
+		return newName
+	}
+	inspect(func(id *ast.Ident) {
+		if pkgNames[id.Name] && id.Obj != pkgObj {
+			id.Name = rename(id.Name)
+		}
+	})
+}
+
+// call returns a Go call expression of fun with the given arguments.
+func call(fun ast.Expr, args ...ast.Expr) ast.Expr {
+	return &ast.CallExpr{
+		Fun:  fun,
+		Args: args,
+	}
+}
+
+// method returns a Go method call expression of the named method on x with the
+// given arguments.
+func method(x ast.Expr, name string, args ...ast.Expr) ast.Expr {
+	sel := &ast.SelectorExpr{
+		X:   x,
+		Sel: ast.NewIdent(name),
+	}
+	return call(sel, args...)
+}
+
+// bitsLit returns a Go integer literal of the bit width of the given integer
+// type.
+func bitsLit(t *irtypes.IntType) ast.Expr {
+	return &ast.BasicLit{
+		Kind:  token.INT,
+		Value: fmt.Sprintf("%d", t.BitSize),
+	}
+}
+
+// signExtend returns a Go expression sign-extending the n least significant
+// bits of expr, where n is the bit width of the given odd integer type.
+//
+//    int24(intn.SignExtend(int64(expr), 24))
+func (d *decompiler) signExtend(expr ast.Expr, t *irtypes.IntType) ast.Expr {
+	x := call(ast.NewIdent("int64"), expr)
+	return call(d.goType(t), call(d.intnSel("SignExtend"), x, bitsLit(t)))
+}
+
+// zeroExtend returns a Go expression of unsigned integer type zero-extending
+// the n least significant bits of expr, where n is the bit width of the given
+// odd integer type.
+//
+//    uint24(intn.ZeroExtend(uint64(expr), 24))
+func (d *decompiler) zeroExtend(expr ast.Expr, t *irtypes.IntType) ast.Expr {
+	x := call(ast.NewIdent("uint64"), expr)
+	return call(d.goUintType(t), call(d.intnSel("ZeroExtend"), x, bitsLit(t)))
+}
+
+// intOp converts the given LLVM IR integer binary operation with signed or
+// sign-agnostic semantics (e.g. add, sdiv, ashr) to a corresponding Go
+// expression. Wide integer operations are converted to method calls, and the
+// results of odd integer operations which may overflow are sign-extended.
+func (d *decompiler) intOp(x value.Value, op token.Token, y value.Value) ast.Expr {
+	if _, ok := wideInt(x.Type()); ok {
+		return method(d.value(x), wideMethod(op, false), d.value(y))
+	}
+	if isBool(x.Type()) {
+		if op, ok := boolOp(op); ok {
+			return d.binaryOp(x, op, y)
+		}
+	}
+	expr := d.binaryOp(x, op, y)
+	if t, ok := oddInt(x.Type()); ok {
+		switch op {
+		case token.ADD, token.SUB, token.MUL, token.SHL, token.QUO:
+			return d.signExtend(expr, t)
+		}
+	}
+	return expr
+}
+
+// wideMethod returns the name of the intn.Int method corresponding to the given
+// Go operator token, with signed or unsigned semantics.
+func wideMethod(op token.Token, unsigned bool) string {
+	switch op {
+	case token.ADD:
+		return "Add"
+	case token.SUB:
+		return "Sub"
+	case token.MUL:
+		return "Mul"
+	case token.QUO:
+		if unsigned {
+			return "UDiv"
+		}
+		return "SDiv"
+	case token.REM:
+		if unsigned {
+			return "URem"
+		}
+		return "SRem"
+	case token.SHL:
+		return "Shl"
+	case token.SHR:
+		if unsigned {
+			return "LShr"
+		}
+		return "AShr"
+	case token.AND:
+		return "And"
+	case token.OR:
+		return "Or"
+	case token.XOR:
+		return "Xor"
+	default:
+		panic(fmt.Sprintf("support for wide integer operator %v not yet implemented", op))
+	}
+}
+
+// constWideInt converts the given LLVM IR integer constant wider than 64 bits
+// to a corresponding Go expression.
+//
+//    intn.New(128, "-1")
+func (d *decompiler) constWideInt(c *constant.Int) ast.Expr {
+	s := &ast.BasicLit{
+		Kind:  token.STRING,
+		Value: fmt.Sprintf("%q", c.X.String()),
+	}
+	return call(d.intnSel("New"), bitsLit(c.Typ), s)
+}
+
+// convertTrunc returns a Go expression for truncating the given LLVM IR integer
+// value to the specified type.
+func (d *decompiler) convertTrunc(from value.Value, to irtypes.Type) ast.Expr {
+	if t, ok := to.(*irtypes.IntType); ok && t.BitSize == 1 {
+		return d.truncBool(from)
+	}
+	if _, ok := wideInt(from.Type()); ok {
+		if t, ok := wideInt(to); ok {
+			return method(d.value(from), "Trunc", bitsLit(t))
+		}
+		// Truncate to 64 bits before converting to the target type.
+		expr := call(d.goType(to), method(d.value(from), "Int64"))
+		if t, ok := oddInt(to); ok {
+			return d.signExtend(expr, t)
+		}
+		return expr
+	}
+	expr := d.convert(from, to)
+	if t, ok := oddInt(to); ok {
+		return d.signExtend(expr, t)
+	}
+	return expr
+}
+
+// convertSExt returns a Go expression for sign-extending the given LLVM IR
+// integer value to the specified type.
+func (d *decompiler) convertSExt(from value.Value, to irtypes.Type) ast.Expr {
+	if isBool(from.Type()) {
+		return d.convertBool(from, to, true)
+	}
+	t, ok := wideInt(to)
+	if !ok {
+		// Odd integers are already kept in sign-extended form.
+		return d.convert(from, to)
+	}
+	if _, ok := wideInt(from.Type()); ok {
+		return method(d.value(from), "SExt", bitsLit(t))
+	}
+	x := call(ast.NewIdent("int64"), d.value(from))
+	return call(d.intnSel("FromInt64"), bitsLit(t), x)
+}
+
+// convertFPToSI returns a Go expression for converting the given LLVM IR
+// floating-point value to the specified signed integer type.
+func (d *decompiler) convertFPToSI(from value.Value, to irtypes.Type) ast.Expr {
+	if expr, ok := d.convertSoftFloat(from, to, false); ok {
+		return expr
+	}
+	if t, ok := wideInt(to); ok {
+		x := call(ast.NewIdent("float64"), d.value(from))
+		return call(d.intnSel("FromFloat64"), bitsLit(t), x)
+	}
+	expr := d.convert(from, to)
+	if t, ok := oddInt(to); ok {
+		return d.signExtend(expr, t)
+	}
+	return expr
+}
+
+// convertSIToFP returns a Go expression for converting the given LLVM IR
+// signed integer value to the specified floating-point type.
+func (d *decompiler) convertSIToFP(from value.Value, to irtypes.Type) ast.Expr {
+	if isBool(from.Type()) {
+		return d.convertBool(from, to, true)
+	}
+	if expr, ok := d.convertSoftFloat(from, to, false); ok {
+		return expr
+	}
+	if _, ok := wideInt(from.Type()); ok {
+		return call(d.goType(to), method(d.value(from), "Float64"))
+	}
+	return d.convert(from, to)
+}
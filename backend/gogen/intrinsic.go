@@ -0,0 +1,414 @@
+package gogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"github.com/decomp/decomp/internal/lower"
+	"github.com/llir/llvm/ir"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// intrinsicsPath is the import path of the runtime support package for LLVM
+// intrinsic functions.
+const intrinsicsPath = "github.com/decomp/decomp/rt/intrinsics"
+
+// A lowering lowers a call to an LLVM intrinsic function or C standard library
+// function to a corresponding list of Go statements. The boolean return value is false if the call is not
+// supported by the lowering (e.g. for vector operands), in which case the call
+// is converted as is.
+type lowering func(d *decompiler, inst *ir.InstCall) ([]ast.Stmt, bool)
+
+// intrinsics maps from LLVM intrinsic function name, without overloaded type
+// suffixes (e.g. "llvm.ctpop" of "llvm.ctpop.i32"), to its lowering.
+//
+// Support for further intrinsic functions is added by extending this table.
+var intrinsics = map[string]lowering{
+	// Memory intrinsics.
+	"llvm.memcpy":  lowerMemCopy,
+	"llvm.memmove": lowerMemCopy,
+	"llvm.memset":  lowerMemSet,
+	// Bit manipulation intrinsics.
+	"llvm.bitreverse": bitsOp("Reverse"),
+	"llvm.bswap":      bitsOp("ReverseBytes"),
+	"llvm.ctlz":       bitsOp("LeadingZeros"),
+	"llvm.ctpop":      bitsOp("OnesCount"),
+	"llvm.cttz":       bitsOp("TrailingZeros"),
+	// Integer arithmetic intrinsics.
+	"llvm.abs":  intFunc("Abs", false, 1),
+	"llvm.smax": intFunc("SMax", false, 2),
+	"llvm.smin": intFunc("SMin", false, 2),
+	"llvm.umax": intFunc("UMax", true, 2),
+	"llvm.umin": intFunc("UMin", true, 2),
+	"llvm.fshl": funnelShift("FShl", false),
+	"llvm.fshr": funnelShift("FShr", true),
+	// Arithmetic with overflow intrinsics.
+	"llvm.sadd.with.overflow": overflowOp(token.ADD, "SAddOverflow", false),
+	"llvm.uadd.with.overflow": overflowOp(token.ADD, "UAddOverflow", true),
+	"llvm.ssub.with.overflow": overflowOp(token.SUB, "SSubOverflow", false),
+	"llvm.usub.with.overflow": overflowOp(token.SUB, "USubOverflow", true),
+	"llvm.smul.with.overflow": overflowOp(token.MUL, "SMulOverflow", false),
+	"llvm.umul.with.overflow": overflowOp(token.MUL, "UMulOverflow", true),
+	// Floating-point intrinsics.
+	"llvm.ceil":      mathFunc("math", "Ceil"),
+	"llvm.copysign":  mathFunc("math", "Copysign"),
+	"llvm.cos":       mathFunc("math", "Cos"),
+	"llvm.exp":       mathFunc("math", "Exp"),
+	"llvm.exp2":      mathFunc("math", "Exp2"),
+	"llvm.fabs":      mathFunc("math", "Abs"),
+	"llvm.floor":     mathFunc("math", "Floor"),
+	"llvm.fma":       mathFunc("math", "FMA"),
+	"llvm.fmuladd":   mathFunc("math", "FMA"),
+	"llvm.log":       mathFunc("math", "Log"),
+	"llvm.log10":     mathFunc("math", "Log10"),
+	"llvm.log2":      mathFunc("math", "Log2"),
+	"llvm.maxnum":    mathFunc(intrinsicsPath, "MaxNum"),
+	"llvm.minnum":    mathFunc(intrinsicsPath, "MinNum"),
+	"llvm.nearbyint": mathFunc("math", "RoundToEven"),
+	"llvm.pow":       mathFunc("math", "Pow"),
+	"llvm.rint":      mathFunc("math", "RoundToEven"),
+	"llvm.round":     mathFunc("math", "Round"),
+	"llvm.sin":       mathFunc("math", "Sin"),
+	"llvm.sqrt":      mathFunc("math", "Sqrt"),
+	"llvm.trunc":     mathFunc("math", "Trunc"),
+	// Exception handling intrinsics.
+	"llvm.eh.typeid.for": lowerTypeIDFor,
+	// Variable argument handling intrinsics.
+	"llvm.va_copy":  lowerVACopy,
+	"llvm.va_end":   lowerVAEnd,
+	"llvm.va_start": lowerVAStart,
+	// Miscellaneous intrinsics.
+	"llvm.debugtrap": lowerTrap,
+	"llvm.expect":    lowerExpect,
+	"llvm.trap":      lowerTrap,
+	// Intrinsics without effect on the semantics of Go programs.
+	"llvm.assume":         lowerNop,
+	"llvm.dbg.declare":    lowerNop,
+	"llvm.dbg.label":      lowerNop,
+	"llvm.dbg.value":      lowerNop,
+	"llvm.donothing":      lowerNop,
+	"llvm.lifetime.end":   lowerNop,
+	"llvm.lifetime.start": lowerNop,
+	"llvm.prefetch":       lowerNop,
+	"llvm.sideeffect":     lowerNop,
+}
+
+// lookupIntrinsic returns the lowering of the given LLVM intrinsic function
+// name. Overloaded type suffixes are stripped until a match is found (e.g.
+// "llvm.memcpy.p0i8.p0i8.i64" matches "llvm.memcpy"). The boolean return value
+// indicates success.
+func lookupIntrinsic(name string) (lowering, bool) {
+	name, ok := lower.Intrinsic(name, func(name string) bool {
+		_, ok := intrinsics[name]
+		return ok
+	})
+	if !ok {
+		return nil, false
+	}
+	return intrinsics[name], true
+}
+
+// lookupLowering returns the lowering of calls to the given function, if the
+// function is an LLVM intrinsic function or an external C standard library or
+// C++ ABI function with a lowering. The boolean return value indicates success.
+func lookupLowering(f *ir.Func) (lowering, bool) {
+	if fn, ok := lookupIntrinsic(f.Name()); ok {
+		return fn, true
+	}
+	if lower.Libc(f, func(name string) bool { _, ok := cxxFuncs[name]; return ok }) {
+		return cxxFuncs[f.Name()], true
+	}
+	if lower.Libc(f, func(name string) bool { _, ok := libcFuncs[name]; return ok }) {
+		return libcFuncs[f.Name()], true
+	}
+	return nil, false
+}
+
+// omitLowered returns the given functions, omitting the declarations of
+// functions lowered to Go. Declarations of functions with calls not supported
+// by their lowering are added once all functions have been decompiled.
+func omitLowered(funcs []*ir.Func) []*ir.Func {
+	var fs []*ir.Func
+	for _, f := range funcs {
+		if _, ok := lookupLowering(f); ok && len(f.Blocks) == 0 {
+			continue
+		}
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// lowerCall lowers the given LLVM IR call instruction to a corresponding list
+// of Go statements, if the callee is an LLVM intrinsic function or C standard
+// library function with a lowering. The boolean return value indicates
+// success.
+func (d *decompiler) lowerCall(inst *ir.InstCall) ([]ast.Stmt, bool) {
+	f, ok := inst.Callee.(*ir.Func)
+	if !ok {
+		return nil, false
+	}
+	fn, ok := lookupLowering(f)
+	if !ok {
+		return nil, false
+	}
+	stmts, ok := fn(d, inst)
+	if !ok {
+		// Retain the declaration of the callee.
+		d.unlowered[f.Name()] = true
+	}
+	return stmts, ok
+}
+
+// valueLowering returns the lowering of a function, where f
+// lowers the call to a Go expression of the result.
+func valueLowering(f func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool)) lowering {
+	return func(d *decompiler, inst *ir.InstCall) ([]ast.Stmt, bool) {
+		expr, ok := f(d, inst)
+		if !ok {
+			return nil, false
+		}
+		if irtypes.Equal(inst.Type(), irtypes.Void) {
+			return []ast.Stmt{&ast.ExprStmt{X: expr}}, true
+		}
+		return []ast.Stmt{d.assign(inst.Name(), expr)}, true
+	}
+}
+
+// lowerMemCopy lowers calls to llvm.memcpy and llvm.memmove.
+//
+//    copy(intrinsics.Slice(dst, n), intrinsics.Slice(src, n))
+var lowerMemCopy = valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+	dst, src, n := inst.Args[0], inst.Args[1], d.int64Value(inst.Args[2])
+	slice := d.importSel(intrinsicsPath, "Slice")
+	return call(ast.NewIdent("copy"), call(slice, d.value(dst), n), call(slice, d.value(src), n)), true
+})
+
+// lowerMemSet lowers calls to llvm.memset.
+//
+//    intrinsics.Set(dst, c, n)
+var lowerMemSet = valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+	dst, c, n := inst.Args[0], inst.Args[1], d.int64Value(inst.Args[2])
+	return call(d.importSel(intrinsicsPath, "Set"), d.value(dst), d.value(c), n), true
+})
+
+// int64Value returns a Go expression of type int64 of the given LLVM IR integer
+// value.
+func (d *decompiler) int64Value(v value.Value) ast.Expr {
+	if t, ok := v.Type().(*irtypes.IntType); ok && t.BitSize == 64 {
+		return d.value(v)
+	}
+	return call(ast.NewIdent("int64"), d.value(v))
+}
+
+// uint64Value returns a Go expression of type uint64 of the given LLVM IR
+// integer value, zero-extended.
+func (d *decompiler) uint64Value(v value.Value) ast.Expr {
+	if t, ok := v.Type().(*irtypes.IntType); ok && t.BitSize == 64 {
+		return d.unsigned(v)
+	}
+	return call(ast.NewIdent("uint64"), d.unsigned(v))
+}
+
+// bitsOp returns the lowering of an LLVM bit manipulation intrinsic to the
+// math/bits function of the given name and the bit width of the operand.
+//
+//    int32(bits.OnesCount32(uint32(x)))
+func bitsOp(name string) lowering {
+	return valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+		t, ok := inst.Args[0].Type().(*irtypes.IntType)
+		if !ok {
+			return nil, false
+		}
+		switch t.BitSize {
+		case 8:
+			if name == "ReverseBytes" {
+				return nil, false
+			}
+		case 16, 32, 64:
+			// valid bit width.
+		default:
+			return nil, false
+		}
+		x := call(d.goUintType(t), d.value(inst.Args[0]))
+		fn := d.importSel("math/bits", fmt.Sprintf("%s%d", name, t.BitSize))
+		return call(d.goType(inst.Type()), call(fn, x)), true
+	})
+}
+
+// intFunc returns the lowering of an LLVM integer intrinsic to the intrinsics
+// function of the given name, applied to the first nargs operands with signed
+// or unsigned semantics.
+//
+//    int32(intrinsics.SMax(int64(x), int64(y)))
+func intFunc(name string, unsigned bool, nargs int) lowering {
+	return valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+		t, ok := stdInt(inst.Type())
+		if !ok {
+			return nil, false
+		}
+		var args []ast.Expr
+		for _, arg := range inst.Args[:nargs] {
+			if unsigned {
+				args = append(args, d.uint64Value(arg))
+			} else {
+				args = append(args, d.int64Value(arg))
+			}
+		}
+		return call(d.goType(t), call(d.importSel(intrinsicsPath, name), args...)), true
+	})
+}
+
+// funnelShift returns the lowering of an LLVM funnel shift intrinsic, shifting
+// left or right, to the intrinsics function of the given name. Funnel shifts of
+// an operand with itself are lowered to rotations.
+//
+//    int32(bits.RotateLeft32(uint32(x), int(s)))
+//    int32(intrinsics.FShl(uint64(uint32(x)), uint64(uint32(y)), uint64(uint32(s)), 32))
+func funnelShift(name string, right bool) lowering {
+	return valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+		t, ok := stdInt(inst.Type())
+		if !ok {
+			return nil, false
+		}
+		x, y, s := inst.Args[0], inst.Args[1], inst.Args[2]
+		if x == y {
+			k := call(ast.NewIdent("int"), d.value(s))
+			if right {
+				k = &ast.UnaryExpr{Op: token.SUB, X: k}
+			}
+			fn := d.importSel("math/bits", fmt.Sprintf("RotateLeft%d", t.BitSize))
+			return call(d.goType(t), call(fn, call(d.goUintType(t), d.value(x)), k)), true
+		}
+		fn := d.importSel(intrinsicsPath, name)
+		return call(d.goType(t), call(fn, d.uint64Value(x), d.uint64Value(y), d.uint64Value(s), bitsLit(t))), true
+	})
+}
+
+// stdInt returns the given type as an integer type, if it has the bit width of
+// an integer type of Go other than bool. The boolean return value indicates
+// success.
+func stdInt(t irtypes.Type) (*irtypes.IntType, bool) {
+	if t, ok := t.(*irtypes.IntType); ok {
+		switch t.BitSize {
+		case 8, 16, 32, 64:
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// overflowOp returns the lowering of an LLVM arithmetic with overflow intrinsic
+// to the given Go operation and the intrinsics function of the specified name
+// reporting overflow, with signed or unsigned semantics.
+//
+//    struct{ field_0 int32; field_1 bool }{x + y, intrinsics.SAddOverflow(int64(x), int64(y), 32)}
+func overflowOp(op token.Token, check string, unsigned bool) lowering {
+	return valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+		t, ok := inst.Args[0].Type().(*irtypes.IntType)
+		if !ok || t.BitSize == 1 || t.BitSize > 64 {
+			return nil, false
+		}
+		x, y := inst.Args[0], inst.Args[1]
+		conv := func(v value.Value) ast.Expr {
+			if unsigned {
+				return call(ast.NewIdent("uint64"), d.unsigned(v))
+			}
+			return call(ast.NewIdent("int64"), d.value(v))
+		}
+		flag := call(d.importSel(intrinsicsPath, check), conv(x), conv(y), bitsLit(t))
+		return &ast.CompositeLit{
+			Type: d.flagStructType(t),
+			Elts: []ast.Expr{d.intOp(x, op, y), flag},
+		}, true
+	})
+}
+
+// mathFunc returns the lowering of an LLVM floating-point intrinsic to the
+// given float64 function of the specified package. Operands and results of
+// type float and of soft floating-point types are converted.
+//
+//    float32(math.Sqrt(float64(x)))
+//    floatn.FromFloat64(floatn.PrecFP128, math.Sqrt(x.Float64()))
+func mathFunc(pkgPath, name string) lowering {
+	return valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+		t, ok := inst.Type().(*irtypes.FloatType)
+		if !ok {
+			return nil, false
+		}
+		single := t.Kind == irtypes.FloatKindFloat
+		_, soft := softFloat(t)
+		var args []ast.Expr
+		for _, arg := range inst.Args {
+			expr := d.value(arg)
+			switch {
+			case single:
+				expr = call(ast.NewIdent("float64"), expr)
+			case soft:
+				expr = method(expr, "Float64")
+			}
+			args = append(args, expr)
+		}
+		expr := call(d.importSel(pkgPath, name), args...)
+		switch {
+		case single:
+			expr = call(ast.NewIdent("float32"), expr)
+		case soft:
+			expr = call(d.floatnSel("FromFloat64"), d.precSel(t), expr)
+		}
+		return expr, true
+	})
+}
+
+// lowerTypeIDFor lowers calls to llvm.eh.typeid.for.
+//
+//    eh.TypeID(typ)
+var lowerTypeIDFor = valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+	return call(d.importSel(ehPath, "TypeID"), d.value(inst.Args[0])), true
+})
+
+// lowerVAStart lowers calls to llvm.va_start, associating the va_list with the
+// variadic arguments of the enclosing function.
+//
+//    va.Start(ap, _va)
+var lowerVAStart = valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+	return call(d.importSel(vaPath, "Start"), d.value(inst.Args[0]), ast.NewIdent(vaArgsName)), true
+})
+
+// lowerVAEnd lowers calls to llvm.va_end.
+//
+//    va.End(ap)
+var lowerVAEnd = valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+	return call(d.importSel(vaPath, "End"), d.value(inst.Args[0])), true
+})
+
+// lowerVACopy lowers calls to llvm.va_copy.
+//
+//    va.Copy(dst, src)
+var lowerVACopy = valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+	return call(d.importSel(vaPath, "Copy"), d.value(inst.Args[0]), d.value(inst.Args[1])), true
+})
+
+// lowerExpect lowers calls to llvm.expect, which evaluate to their first
+// operand.
+var lowerExpect = valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+	return d.value(inst.Args[0]), true
+})
+
+// lowerTrap lowers calls to llvm.trap and llvm.debugtrap.
+//
+//    panic("trap")
+func lowerTrap(d *decompiler, inst *ir.InstCall) ([]ast.Stmt, bool) {
+	trap := &ast.BasicLit{
+		Kind:  token.STRING,
+		Value: `"trap"`,
+	}
+	return []ast.Stmt{&ast.ExprStmt{X: call(ast.NewIdent("panic"), trap)}}, true
+}
+
+// lowerNop lowers calls to intrinsics without effect on the semantics of Go
+// programs (e.g. llvm.lifetime.start, llvm.dbg.value) to nothing.
+func lowerNop(d *decompiler, inst *ir.InstCall) ([]ast.Stmt, bool) {
+	return nil, true
+}
@@ -0,0 +1,472 @@
+package gogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// A dataLayout describes the memory layout of LLVM IR types on the target
+// architecture, as specified by the data layout string of an LLVM IR module.
+// Sizes and alignments are given in bytes.
+type dataLayout struct {
+	// Size of pointers.
+	ptrSize uint64
+	// ABI alignment of pointers.
+	ptrAlign uint64
+	// Map from integer bit size to ABI alignment.
+	intAligns map[uint64]uint64
+	// Map from floating-point bit size to ABI alignment.
+	floatAligns map[uint64]uint64
+}
+
+// defaultDataLayout returns the data layout of LLVM IR modules without a data
+// layout string; i.e. the data layout of x86-64 (as emitted by clang), rather
+// than the defaults of LLVM, as the data layout is predominantly omitted from
+// hand-written LLVM IR.
+func defaultDataLayout() *dataLayout {
+	return &dataLayout{
+		ptrSize:     8,
+		ptrAlign:    8,
+		intAligns:   map[uint64]uint64{1: 1, 8: 1, 16: 2, 32: 4, 64: 8},
+		floatAligns: map[uint64]uint64{16: 2, 32: 4, 64: 8, 80: 16, 128: 16},
+	}
+}
+
+// newDataLayout parses the given LLVM IR data layout string. Alignments not
+// specified by the data layout string default to those of defaultDataLayout.
+//
+//    e-m:e-i64:64-f80:128-n8:16:32:64-S128
+func newDataLayout(s string) (*dataLayout, error) {
+	l := defaultDataLayout()
+	for _, spec := range strings.Split(s, "-") {
+		if len(spec) == 0 {
+			continue
+		}
+		// Parse bit sizes of specification (e.g. "i64:64" and "p:32:32").
+		parts := strings.Split(spec[1:], ":")
+		var bits []uint64
+		for _, part := range parts {
+			if len(part) == 0 {
+				// Default address space of pointer specification (e.g. "p:64:64").
+				bits = append(bits, 0)
+				continue
+			}
+			x, err := strconv.ParseUint(part, 10, 64)
+			if err != nil {
+				// Ignore specifications not affecting the memory layout of types
+				// (e.g. "m:e").
+				bits = nil
+				break
+			}
+			bits = append(bits, x)
+		}
+		switch spec[0] {
+		case 'p':
+			// Pointers of the default address space.
+			if len(bits) < 3 || bits[0] != 0 {
+				continue
+			}
+			l.ptrSize, l.ptrAlign = bits[1]/8, bits[2]/8
+		case 'i':
+			if len(bits) < 2 {
+				return nil, errors.Errorf("invalid integer alignment specification %q of data layout %q", spec, s)
+			}
+			l.intAligns[bits[0]] = bits[1] / 8
+		case 'f':
+			if len(bits) < 2 {
+				return nil, errors.Errorf("invalid floating-point alignment specification %q of data layout %q", spec, s)
+			}
+			l.floatAligns[bits[0]] = bits[1] / 8
+		}
+	}
+	return l, nil
+}
+
+// sizeof returns the allocation size of the given LLVM IR type; i.e. the offset
+// between successive array elements of the type, including padding.
+func (l *dataLayout) sizeof(t irtypes.Type) uint64 {
+	return alignTo(l.storeSize(t), l.alignof(t))
+}
+
+// storeSize returns the number of bytes written when storing a value of the
+// given LLVM IR type; i.e. excluding tail padding.
+func (l *dataLayout) storeSize(t irtypes.Type) uint64 {
+	switch t := t.(type) {
+	case *irtypes.IntType:
+		return (t.BitSize + 7) / 8
+	case *irtypes.FloatType:
+		return (floatBits(t) + 7) / 8
+	case *irtypes.PointerType:
+		return l.ptrSize
+	case *irtypes.VectorType:
+		return (t.Len*l.elemBits(t.ElemType) + 7) / 8
+	case *irtypes.ArrayType:
+		return t.Len * l.sizeof(t.ElemType)
+	case *irtypes.StructType:
+		return l.structLayout(t).size
+	default:
+		// Types without size (e.g. opaque struct types and function types).
+		return 0
+	}
+}
+
+// alignof returns the ABI alignment of the given LLVM IR type.
+func (l *dataLayout) alignof(t irtypes.Type) uint64 {
+	switch t := t.(type) {
+	case *irtypes.IntType:
+		return l.intAlign(t.BitSize)
+	case *irtypes.FloatType:
+		if align, ok := l.floatAligns[floatBits(t)]; ok {
+			return align
+		}
+		return l.storeSize(t)
+	case *irtypes.PointerType:
+		return l.ptrAlign
+	case *irtypes.VectorType:
+		// Vectors are naturally aligned; i.e. to their size rounded up to the
+		// next power of two.
+		align := uint64(1)
+		for align < l.storeSize(t) {
+			align *= 2
+		}
+		return align
+	case *irtypes.ArrayType:
+		return l.alignof(t.ElemType)
+	case *irtypes.StructType:
+		return l.structLayout(t).align
+	default:
+		return 1
+	}
+}
+
+// intAlign returns the ABI alignment of integers of the given bit size; i.e. the
+// alignment of the smallest specified integer type of at least the bit size, or
+// of the largest specified integer type if none.
+func (l *dataLayout) intAlign(bitSize uint64) uint64 {
+	var best, largest uint64
+	for bits := range l.intAligns {
+		if bits >= bitSize && (best == 0 || bits < best) {
+			best = bits
+		}
+		if bits > largest {
+			largest = bits
+		}
+	}
+	if best == 0 {
+		best = largest
+	}
+	return l.intAligns[best]
+}
+
+// elemBits returns the bit size of elements of the given LLVM IR type in
+// vectors.
+func (l *dataLayout) elemBits(t irtypes.Type) uint64 {
+	switch t := t.(type) {
+	case *irtypes.IntType:
+		return t.BitSize
+	case *irtypes.FloatType:
+		return floatBits(t)
+	default:
+		return 8 * l.storeSize(t)
+	}
+}
+
+// floatBits returns the bit size of the given LLVM IR floating-point type.
+func floatBits(t *irtypes.FloatType) uint64 {
+	switch t.Kind {
+	case irtypes.FloatKindHalf:
+		return 16
+	case irtypes.FloatKindFloat:
+		return 32
+	case irtypes.FloatKindDouble:
+		return 64
+	case irtypes.FloatKindX86_FP80:
+		return 80
+	default:
+		// fp128 and ppc_fp128.
+		return 128
+	}
+}
+
+// llvmLayout is the memory layout of an LLVM IR struct type.
+type llvmLayout struct {
+	// Field offsets.
+	offsets []uint64
+	// Allocation size, including tail padding.
+	size uint64
+	// ABI alignment.
+	align uint64
+}
+
+// structLayout returns the memory layout of the given LLVM IR struct type.
+// Fields of packed struct types are laid out without padding.
+func (l *dataLayout) structLayout(t *irtypes.StructType) *llvmLayout {
+	layout := &llvmLayout{align: 1}
+	var off uint64
+	for _, field := range t.Fields {
+		if !t.Packed {
+			align := l.alignof(field)
+			if align > layout.align {
+				layout.align = align
+			}
+			off = alignTo(off, align)
+		}
+		layout.offsets = append(layout.offsets, off)
+		off += l.sizeof(field)
+	}
+	layout.size = alignTo(off, layout.align)
+	return layout
+}
+
+// alignTo rounds x up to the nearest multiple of align.
+func alignTo(x, align uint64) uint64 {
+	if align == 0 {
+		return x
+	}
+	return (x + align - 1) / align * align
+}
+
+// goLayout is the memory layout of a Go struct type corresponding to an LLVM IR
+// struct type.
+type goLayout struct {
+	// Offsets of the fields of the LLVM IR struct type.
+	offsets []uint64
+	// Size of padding fields to insert before each field of the LLVM IR struct
+	// type, and after its last field (at index len(offsets)).
+	pads []uint64
+	// Size of the LLVM IR struct type.
+	size uint64
+	// Size and alignment of the Go struct type, including padding fields.
+	goSize, goAlign uint64
+	// Mismatch between the Go and LLVM IR struct layouts, which cannot be
+	// resolved by padding (e.g. misaligned fields of packed struct types); or
+	// empty if the layouts match.
+	mismatch string
+}
+
+// goStructLayout returns the memory layout of the Go struct type corresponding
+// to the given LLVM IR struct type. Padding fields are inserted such that the
+// field offsets and size of the Go struct type match those of the LLVM IR
+// struct type; or omitted if the layouts cannot be matched.
+//
+// Go types are laid out as by the gc compiler for a target architecture of the
+// same word size as the pointer size of the data layout.
+func (l *dataLayout) goStructLayout(t *irtypes.StructType) *goLayout {
+	ll := l.structLayout(t)
+	layout := &goLayout{
+		offsets: ll.offsets,
+		pads:    make([]uint64, len(t.Fields)+1),
+		size:    ll.size,
+	}
+	if t.Opaque {
+		layout.goAlign = 1
+		return layout
+	}
+	if layout.mismatch = l.pad(t, layout, true); len(layout.mismatch) > 0 {
+		// Use the Go struct layout without padding fields.
+		layout.pads = make([]uint64, len(t.Fields)+1)
+		l.pad(t, layout, false)
+	}
+	return layout
+}
+
+// pad lays out the Go struct type corresponding to the given LLVM IR struct
+// type, inserting padding fields if insert is set. The mismatch between the Go
+// and LLVM IR struct layouts is returned; or empty if the layouts match.
+func (l *dataLayout) pad(t *irtypes.StructType, layout *goLayout, insert bool) string {
+	var mismatch string
+	var off uint64
+	layout.goAlign = 1
+	lastSize := uint64(1)
+	for i, field := range t.Fields {
+		want := layout.offsets[i]
+		size, align := l.goSizeof(field), l.goAlignof(field)
+		if align > layout.goAlign {
+			layout.goAlign = align
+		}
+		if insert && alignTo(off, align) < want {
+			layout.pads[i] = want - off
+			off = want
+		}
+		off = alignTo(off, align)
+		if off != want && len(mismatch) == 0 {
+			mismatch = fmt.Sprintf("offset of field %d is %d in LLVM IR and %d in Go", i, want, off)
+		}
+		off += size
+		lastSize = size
+	}
+	if insert && alignTo(off, layout.goAlign) < layout.size {
+		layout.pads[len(t.Fields)] = layout.size - off
+		off = layout.size
+		lastSize = layout.pads[len(t.Fields)]
+	}
+	// The gc compiler pads zero-sized final fields, as pointers to them would
+	// otherwise point past the struct.
+	if lastSize == 0 && off > 0 {
+		off++
+	}
+	layout.goSize = alignTo(off, layout.goAlign)
+	if layout.goSize != layout.size && len(mismatch) == 0 {
+		mismatch = fmt.Sprintf("size is %d in LLVM IR and %d in Go", layout.size, layout.goSize)
+	}
+	return mismatch
+}
+
+// goSizeof returns the size of the Go type corresponding to the given LLVM IR
+// type (see goTypeDef).
+func (l *dataLayout) goSizeof(t irtypes.Type) uint64 {
+	switch t := t.(type) {
+	case *irtypes.IntType:
+		switch {
+		case t.BitSize <= 8:
+			return 1
+		case t.BitSize <= 16:
+			return 2
+		case t.BitSize <= 32:
+			return 4
+		case t.BitSize <= 64:
+			return 8
+		default:
+			// intn.Int of bit width and pointer.
+			return 2 * l.ptrSize
+		}
+	case *irtypes.FloatType:
+		if _, ok := softFloat(t); ok {
+			// floatn.Float of pointer and NaN flag.
+			return 2 * l.ptrSize
+		}
+		return floatBits(t) / 8
+	case *irtypes.PointerType:
+		return l.ptrSize
+	case *irtypes.VectorType:
+		return t.Len * l.goSizeof(t.ElemType)
+	case *irtypes.ArrayType:
+		return t.Len * l.goSizeof(t.ElemType)
+	case *irtypes.StructType:
+		return l.goStructLayout(t).goSize
+	default:
+		return 0
+	}
+}
+
+// goAlignof returns the alignment of the Go type corresponding to the given
+// LLVM IR type (see goTypeDef).
+func (l *dataLayout) goAlignof(t irtypes.Type) uint64 {
+	switch t := t.(type) {
+	case *irtypes.IntType, *irtypes.FloatType:
+		align := l.goSizeof(t)
+		if _, ok := wideInt(t); ok {
+			align = l.ptrSize
+		}
+		if _, ok := softFloat(t); ok {
+			align = l.ptrSize
+		}
+		// The alignment of Go types is at most the word size.
+		if align > l.ptrSize {
+			align = l.ptrSize
+		}
+		return align
+	case *irtypes.PointerType:
+		return l.ptrSize
+	case *irtypes.VectorType:
+		return l.goAlignof(t.ElemType)
+	case *irtypes.ArrayType:
+		return l.goAlignof(t.ElemType)
+	case *irtypes.StructType:
+		return l.goStructLayout(t).goAlign
+	default:
+		return 1
+	}
+}
+
+// hasPadding reports whether the Go struct type corresponding to the given LLVM
+// IR struct type has padding fields.
+func (l *dataLayout) hasPadding(t *irtypes.StructType) bool {
+	for _, pad := range l.goStructLayout(t).pads {
+		if pad > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// padField returns a padding field of the given size.
+//
+//    _ [4]byte
+func padField(size uint64) *ast.Field {
+	return &ast.Field{
+		Names: []*ast.Ident{ast.NewIdent("_")},
+		Type: &ast.ArrayType{
+			Len: &ast.BasicLit{
+				Kind:  token.INT,
+				Value: strconv.FormatUint(size, 10),
+			},
+			Elt: ast.NewIdent("byte"),
+		},
+	}
+}
+
+// layoutAssertions returns a Go function declaration asserting at compile time
+// that the size and field offsets of the Go struct type of the given LLVM IR
+// struct type match those of the LLVM IR struct type. The first statement of
+// the function is returned to be annotated by a comment.
+//
+//    func _() {
+//       var x [1]struct{}
+//       _ = x[unsafe.Sizeof(point{})-8]
+//       _ = x[unsafe.Offsetof(point{}.y)-4]
+//    }
+func (d *decompiler) layoutAssertions(t *irtypes.StructType) (*ast.FuncDecl, ast.Stmt) {
+	layout := d.layout.goStructLayout(t)
+	x := ast.NewIdent("x")
+	declStmt := &ast.DeclStmt{
+		Decl: &ast.GenDecl{
+			Tok: token.VAR,
+			Specs: []ast.Spec{&ast.ValueSpec{
+				Names: []*ast.Ident{x},
+				Type: &ast.ArrayType{
+					Len: &ast.BasicLit{Kind: token.INT, Value: "1"},
+					// Valid brace positions print the empty field list on a single
+					// line.
+					Elt: &ast.StructType{Fields: &ast.FieldList{Opening: 1, Closing: 1}},
+				},
+			}},
+		},
+	}
+	// assert asserts that the given size or offset is equal to want.
+	assert := func(expr ast.Expr, want uint64) ast.Stmt {
+		index := &ast.BinaryExpr{
+			X:  expr,
+			Op: token.SUB,
+			Y:  &ast.BasicLit{Kind: token.INT, Value: strconv.FormatUint(want, 10)},
+		}
+		return &ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("_")},
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{&ast.IndexExpr{X: x, Index: index}},
+		}
+	}
+	zero := func() ast.Expr {
+		return &ast.CompositeLit{Type: d.typeIdent(t.Name())}
+	}
+	stmts := []ast.Stmt{
+		declStmt,
+		assert(call(d.importSel("unsafe", "Sizeof"), zero()), layout.size),
+	}
+	for i, off := range layout.offsets {
+		field := &ast.SelectorExpr{X: zero(), Sel: d.fieldIdent(t, i)}
+		stmts = append(stmts, assert(call(d.importSel("unsafe", "Offsetof"), field), off))
+	}
+	fn := &ast.FuncDecl{
+		Name: ast.NewIdent("_"),
+		Type: &ast.FuncType{Params: &ast.FieldList{}},
+		Body: &ast.BlockStmt{List: stmts},
+	}
+	return fn, declStmt
+}
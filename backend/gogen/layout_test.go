@@ -0,0 +1,142 @@
+package gogen
+
+import (
+	"bytes"
+	"go/format"
+	"reflect"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	irtypes "github.com/llir/llvm/ir/types"
+)
+
+func TestGoStructLayout(t *testing.T) {
+	golden := []struct {
+		layout   string
+		src      string
+		pads     []uint64
+		size     uint64
+		mismatch bool
+	}{
+		// Matching layouts.
+		{src: "%t = type { i8, i32, i64 }", pads: []uint64{0, 0, 0, 0}, size: 16},
+		{src: "%t = type { i32, i8 }", pads: []uint64{0, 0, 0}, size: 8},
+		{src: "%t = type { i8*, [3 x i16] }", pads: []uint64{0, 0, 0}, size: 16},
+		// Vectors are naturally aligned in LLVM IR.
+		{src: "%t = type { <3 x float>, i8 }", pads: []uint64{0, 4, 15}, size: 32},
+		// x86_fp80 is 16-byte aligned on x86-64.
+		{src: "%t = type { i8, x86_fp80 }", pads: []uint64{0, 15, 0}, size: 32},
+		// i64 is 4-byte aligned by the data layout.
+		{layout: "e-p:32:32-i64:32", src: "%t = type { i32, i64 }", pads: []uint64{0, 0, 0}, size: 12},
+		// i64 is 16-byte aligned by the data layout.
+		{layout: "e-i64:128", src: "%t = type { i8, i64 }", pads: []uint64{0, 15, 8}, size: 32},
+		// Packed structs.
+		{src: "%t = type <{ i8, i8, i16 }>", pads: []uint64{0, 0, 0, 0}, size: 4},
+		{src: "%t = type <{ i32, i8 }>", pads: []uint64{0, 0, 0}, size: 5, mismatch: true},
+		{src: "%t = type <{ i8, i32 }>", pads: []uint64{0, 0, 0}, size: 5, mismatch: true},
+	}
+	for _, g := range golden {
+		src := g.src
+		if len(g.layout) > 0 {
+			src = "target datalayout = \"" + g.layout + "\"\n" + src
+		}
+		module, err := asm.ParseString("foo.ll", src)
+		if err != nil {
+			t.Errorf("%q: unable to parse LLVM IR; %v", g.src, err)
+			continue
+		}
+		l, err := newDataLayout(module.DataLayout)
+		if err != nil {
+			t.Errorf("%q: unable to parse data layout; %v", g.src, err)
+			continue
+		}
+		layout := l.goStructLayout(module.TypeDefs[0].(*irtypes.StructType))
+		if !g.mismatch && !reflect.DeepEqual(layout.pads, g.pads) {
+			t.Errorf("%q: padding mismatch; expected %v, got %v", g.src, g.pads, layout.pads)
+		}
+		if layout.size != g.size {
+			t.Errorf("%q: size mismatch; expected %d, got %d", g.src, g.size, layout.size)
+		}
+		if mismatch := len(layout.mismatch) > 0; mismatch != g.mismatch {
+			t.Errorf("%q: layout mismatch; expected %v, got %v (%s)", g.src, g.mismatch, mismatch, layout.mismatch)
+		}
+	}
+}
+
+func TestDecompileLayout(t *testing.T) {
+	const src = `
+%struct.point = type { i32, i32 }
+%struct.vec = type { <2 x double>, i8 }
+
+@v = global %struct.vec { <2 x double> <double 1.0, double 2.0>, i8 3 }
+
+define i32 @f(%struct.point* %p) {
+	%x = getelementptr %struct.point, %struct.point* %p, i32 0, i32 1
+	%y = load i32, i32* %x
+	ret i32 %y
+}
+`
+	const want = `package foo
+
+import "unsafe"
+
+type Point struct {
+	X int32
+	Y int32
+}
+
+func _() {
+	// A compile-time error in this function signifies that the layout of Point differs from the LLVM IR struct type %struct.point.
+	var x [1]struct{}
+	_ = x[unsafe.Sizeof(Point{})-8]
+	_ = x[unsafe.Offsetof(Point{}.X)-0]
+	_ = x[unsafe.Offsetof(Point{}.Y)-4]
+}
+
+type structdotvec struct {
+	field_0 [2]float64
+	tag     int8
+	_       [15]byte
+}
+
+func _() {
+	// A compile-time error in this function signifies that the layout of structdotvec differs from the LLVM IR struct type %struct.vec.
+	var x [1]struct{}
+	_ = x[unsafe.Sizeof(structdotvec{})-32]
+	_ = x[unsafe.Offsetof(structdotvec{}.field_0)-0]
+	_ = x[unsafe.Offsetof(structdotvec{}.tag)-16]
+}
+
+var v *structdotvec = &structdotvec{field_0: [2]float64{1.0, 2.0}, tag: 3}
+
+func f(p *Point) int32 {
+	var x *int32
+	var y int32
+	x = &p.Y
+	y = *x
+	return y
+}
+`
+	module, err := asm.ParseString("foo.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse LLVM IR; %v", err)
+	}
+	d := &Decompiler{
+		Layout: true,
+		Types: map[string]*TypeAnnotation{
+			"struct.point": {Name: "Point", Fields: []string{"X", "Y"}},
+			"struct.vec":   {Fields: []string{"", "tag"}},
+		},
+	}
+	f, err := d.Decompile(module, "foo")
+	if err != nil {
+		t.Fatalf("unable to decompile LLVM IR; %+v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, f.Fset, f.File); err != nil {
+		t.Fatalf("unable to format Go source code; %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Go source code mismatch; expected\n%s\ngot\n%s", want, got)
+	}
+}
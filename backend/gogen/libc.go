@@ -0,0 +1,224 @@
+package gogen
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/decomp/decomp/internal/lower"
+	"github.com/decomp/decomp/rt/libc"
+	"github.com/llir/llvm/ir"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// libcPath is the import path of the runtime support package for C standard
+// library functions.
+const libcPath = "github.com/decomp/decomp/rt/libc"
+
+// libcFuncs maps from the name of external C standard library functions to
+// their lowering. Functions are lowered to Go built-in functions and standard
+// library packages where possible, and to the libc runtime support package
+// otherwise.
+//
+// The table is extended by user-supplied mappings (see LoadLibcMap).
+var libcFuncs = map[string]lowering{
+	"abort":   libcCall(libcPath, "Abort"),
+	"atoi":    libcCall(libcPath, "Atoi"),
+	"calloc":  lowerCalloc,
+	"exit":    libcCall(libcPath, "Exit"),
+	"free":    lowerNop,
+	"getchar": libcCall(libcPath, "Getchar"),
+	"malloc":  lowerMalloc,
+	"memcmp":  libcCall(libcPath, "Memcmp"),
+	"printf":  lowerPrintf,
+	"putchar": libcCall(libcPath, "Putchar"),
+	"puts":    lowerPuts,
+	"strcat":  libcCall(libcPath, "Strcat"),
+	"strchr":  libcCall(libcPath, "Strchr"),
+	"strcmp":  libcCall(libcPath, "Strcmp"),
+	"strcpy":  libcCall(libcPath, "Strcpy"),
+	"strlen":  lowerStrlen,
+	"strncmp": libcCall(libcPath, "Strncmp"),
+}
+
+// LoadLibcMap extends the lowering of C standard library functions with the
+// user-supplied mapping of the given JSON file. The mapping is from function
+// name to the qualified name of a Go function with a compatible signature, or
+// to the empty string to omit calls to the function. The lowering is shared by
+// all decompilers.
+//
+//    {
+//       "rand": "math/rand.Int31",
+//       "strdup": "example.com/shim.Strdup",
+//       "fflush": ""
+//    }
+func LoadLibcMap(jsonPath string) error {
+	buf, err := ioutil.ReadFile(jsonPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var m map[string]string
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return errors.Wrapf(err, "unable to parse libc mapping %q", jsonPath)
+	}
+	for name, target := range m {
+		if len(target) == 0 {
+			libcFuncs[name] = lowerNop
+			continue
+		}
+		pos := strings.LastIndex(target, ".")
+		if pos <= 0 || pos == len(target)-1 {
+			return errors.Errorf("invalid Go function %q of %q in libc mapping %q; expected qualified name (e.g. \"math/rand.Int31\")", target, name, jsonPath)
+		}
+		libcFuncs[name] = libcCall(target[:pos], target[pos+1:])
+	}
+	return nil
+}
+
+// libcCall returns the lowering of a C standard library function to the Go
+// function of the given name in the specified package. Arguments are passed as
+// is.
+//
+//    libc.Puts(s)
+func libcCall(pkgPath, name string) lowering {
+	return valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+		var args []ast.Expr
+		for _, arg := range inst.Args {
+			args = append(args, d.value(arg))
+		}
+		return call(d.importSel(pkgPath, name), args...), true
+	})
+}
+
+// lowerMalloc lowers calls to malloc.
+//
+//    &make([]int8, n)[0]
+var lowerMalloc = valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+	return d.alloc(d.value(inst.Args[0])), true
+})
+
+// lowerCalloc lowers calls to calloc.
+//
+//    &make([]int8, n*size)[0]
+var lowerCalloc = valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+	n := &ast.BinaryExpr{
+		X:  d.value(inst.Args[0]),
+		Op: token.MUL,
+		Y:  d.value(inst.Args[1]),
+	}
+	return d.alloc(n), true
+})
+
+// alloc returns a Go expression allocating n bytes of zeroed memory. The memory
+// is garbage collected, thus calls to free are omitted.
+//
+//    &make([]int8, n)[0]
+func (d *decompiler) alloc(n ast.Expr) ast.Expr {
+	buf := call(ast.NewIdent("make"), &ast.ArrayType{Elt: ast.NewIdent("int8")}, n)
+	return &ast.UnaryExpr{
+		Op: token.AND,
+		X: &ast.IndexExpr{
+			X:     buf,
+			Index: &ast.BasicLit{Kind: token.INT, Value: "0"},
+		},
+	}
+}
+
+// lowerStrlen lowers calls to strlen. The length of constant strings is
+// computed using len.
+//
+//    int64(len(_str))
+//    libc.Strlen(s)
+var lowerStrlen = valueLowering(func(d *decompiler, inst *ir.InstCall) (ast.Expr, bool) {
+	if s, ok := d.stringExpr(inst.Args[0]); ok {
+		return call(d.goType(inst.Type()), call(ast.NewIdent("len"), s)), true
+	}
+	expr := call(d.importSel(libcPath, "Strlen"), d.value(inst.Args[0]))
+	if t, ok := inst.Type().(*irtypes.IntType); ok && t.BitSize == 64 {
+		return expr, true
+	}
+	return call(d.goType(inst.Type()), expr), true
+})
+
+// lowerPrintf lowers calls to printf. Calls with a constant format string are
+// lowered to fmt.Printf, translating the format string and arguments to Go.
+// Calls with a variable format string are lowered to libc.Printf, which
+// translates the format string at run time.
+//
+//    _1 = libc.Count(fmt.Printf("%s: %d\n", "foo", x))
+func lowerPrintf(d *decompiler, inst *ir.InstCall) ([]ast.Stmt, bool) {
+	format, ok := lower.CString(inst.Args[0])
+	if !ok {
+		return libcCall(libcPath, "Printf")(d, inst)
+	}
+	goFormat, convs := libc.Format(format)
+	args := []ast.Expr{stringLit(goFormat)}
+	if goFormat == format {
+		// Refer to the string constant of the format string if unchanged by the
+		// translation.
+		args[0], _ = d.stringExpr(inst.Args[0])
+	}
+	for i, arg := range inst.Args[1:] {
+		if i < len(convs) {
+			args = append(args, d.formatArg(convs[i], arg))
+		} else {
+			args = append(args, d.value(arg))
+		}
+	}
+	printf := call(d.importSel("fmt", "Printf"), args...)
+	expr := call(d.importSel(libcPath, "Count"), printf)
+	return []ast.Stmt{d.assign(inst.Name(), expr)}, true
+}
+
+// lowerPuts lowers calls to puts. Calls with a constant string are lowered to
+// fmt.Println.
+//
+//    _1 = libc.Count(fmt.Println(_str))
+//    _1 = libc.Puts(s)
+func lowerPuts(d *decompiler, inst *ir.InstCall) ([]ast.Stmt, bool) {
+	s, ok := d.stringExpr(inst.Args[0])
+	if !ok {
+		return libcCall(libcPath, "Puts")(d, inst)
+	}
+	println := call(d.importSel("fmt", "Println"), s)
+	expr := call(d.importSel(libcPath, "Count"), println)
+	return []ast.Stmt{d.assign(inst.Name(), expr)}, true
+}
+
+// formatArg converts the given argument of a C formatting function to a Go
+// expression of the representation expected by the Go verb corresponding to
+// the specified C conversion specifier (see libc.Arg).
+func (d *decompiler) formatArg(conv byte, arg value.Value) ast.Expr {
+	switch conv {
+	case 's':
+		if s, ok := d.stringExpr(arg); ok {
+			return s
+		}
+		return call(d.importSel(libcPath, "GoString"), d.value(arg))
+	case 'c':
+		if t, ok := arg.Type().(*irtypes.IntType); ok && t.BitSize == 8 {
+			return call(ast.NewIdent("rune"), d.unsigned(arg))
+		}
+		return call(ast.NewIdent("rune"), d.value(arg))
+	case 'u', 'o', 'x', 'X':
+		return d.unsigned(arg)
+	case '*':
+		return call(ast.NewIdent("int"), d.value(arg))
+	case 'n':
+		return stringLit("")
+	}
+	return d.value(arg)
+}
+
+// stringLit returns a Go string literal of the given string.
+func stringLit(s string) ast.Expr {
+	return &ast.BasicLit{
+		Kind:  token.STRING,
+		Value: strconv.Quote(s),
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package gogen
 
 import (
 	"fmt"
@@ -6,6 +6,7 @@ import (
 	"go/token"
 
 	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/decomp/decomp/internal/lower"
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/constant"
 	"github.com/pkg/errors"
@@ -153,18 +154,39 @@ func (d *decompiler) prim(prim *primitive.Primitive) (*basicBlock, error) {
 	}
 }
 
+// condBr returns the Go condition of the given conditional branch terminator,
+// negated if the specified target is the false branch.
+func (d *decompiler) condBr(condBlock, target *basicBlock) (ast.Expr, error) {
+	term, ok := condBlock.Term.(*ir.TermCondBr)
+	if !ok {
+		return nil, errors.Errorf("invalid cond terminator type; expected *ir.TermCondBr, got %T", condBlock.Term)
+	}
+	cond := d.value(term.Cond)
+	if name, _ := lower.TrueTarget(term); name != target.Name() {
+		cond = not(cond)
+	}
+	return cond, nil
+}
+
+// not returns the logical negation of the given Go expression.
+func not(x ast.Expr) ast.Expr {
+	if u, ok := x.(*ast.UnaryExpr); ok && u.Op == token.NOT {
+		return u.X
+	}
+	if _, ok := x.(*ast.BinaryExpr); ok {
+		x = &ast.ParenExpr{X: x}
+	}
+	return &ast.UnaryExpr{Op: token.NOT, X: x}
+}
+
 // primIf merges the basic blocks of the given if-primitive into a corresponding
 // conceputal basic block for the primitive.
 func (d *decompiler) primIf(condBlock, bodyBlock, exitBlock *basicBlock) (*basicBlock, error) {
 	// Handle terminators.
-	condTerm, ok := condBlock.Term.(*ir.TermCondBr)
-	if !ok {
-		return nil, errors.Errorf("invalid cond terminator type; expected *ir.TermCondBr, got %T", condBlock.Term)
+	cond, err := d.condBr(condBlock, bodyBlock)
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
-	cond := d.value(condTerm.Cond)
-	// TODO: Figure out a clean way to check if the body basic block is the true
-	// branch or the false branch. If body is the false branch, negate the
-	// condition.
 	if _, ok := bodyBlock.Term.(*ir.TermBr); !ok {
 		return nil, errors.Errorf("invalid body terminator type; expected *ir.TermBr, got %T", bodyBlock.Term)
 	}
@@ -179,6 +201,7 @@ func (d *decompiler) primIf(condBlock, bodyBlock, exitBlock *basicBlock) (*basic
 		Cond: cond,
 		Body: body,
 	}
+	d.instComment([]ast.Stmt{ifStmt}, condBlock.Term)
 	block.stmts = append(block.stmts, ifStmt)
 	block.stmts = append(block.stmts, d.stmts(exitBlock)...)
 	return block, nil
@@ -205,9 +228,9 @@ func (d *decompiler) primIfElse(condBlock, bodyTrueBlock, bodyFalseBlock, exitBl
 	default:
 		return nil, errors.Errorf("invalid cond terminator type; expected *ir.TermCondBr, got %T", condBlock.Term)
 	}
-	// TODO: Figure out a clean way to check if the body_true basic block is the
-	// true branch or the false branch. If body_true is the false branch, use
-	// body_true for the else body of the if-statement.
+	if name, _ := lower.TrueTarget(condBlock.Term); name != bodyTrueBlock.Name() {
+		bodyTrueBlock, bodyFalseBlock = bodyFalseBlock, bodyTrueBlock
+	}
 	if _, ok := bodyTrueBlock.Term.(*ir.TermBr); !ok {
 		return nil, errors.Errorf("invalid body_true terminator type; expected *ir.TermBr, got %T", bodyTrueBlock.Term)
 	}
@@ -229,6 +252,7 @@ func (d *decompiler) primIfElse(condBlock, bodyTrueBlock, bodyFalseBlock, exitBl
 		Body: bodyTrue,
 		Else: bodyFalse,
 	}
+	d.instComment([]ast.Stmt{ifElseStmt}, condBlock.Term)
 	block.stmts = append(block.stmts, ifElseStmt)
 	block.stmts = append(block.stmts, d.stmts(exitBlock)...)
 	return block, nil
@@ -238,15 +262,11 @@ func (d *decompiler) primIfElse(condBlock, bodyTrueBlock, bodyFalseBlock, exitBl
 // corresponding conceputal basic block for the primitive.
 func (d *decompiler) primIfReturn(condBlock, bodyBlock, exitBlock *basicBlock) (*basicBlock, error) {
 	// Handle terminators.
-	condTerm, ok := condBlock.Term.(*ir.TermCondBr)
-	if !ok {
-		return nil, errors.Errorf("invalid cond terminator type; expected *ir.TermCondBr, got %T", condBlock.Term)
+	cond, err := d.condBr(condBlock, bodyBlock)
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
-	cond := d.value(condTerm.Cond)
-	// TODO: Figure out a clean way to check if the body basic block is the true
-	// branch or the false branch. If body is the false branch, negate the
-	// condition.
-	bodyTermStmt := d.term(bodyBlock.Term)
+	bodyTermStmts := d.terms(bodyBlock.Term)
 	block := &basicBlock{Block: &ir.Block{}}
 	block.Term = exitBlock.Term
 	// Handle instructions.
@@ -254,11 +274,12 @@ func (d *decompiler) primIfReturn(condBlock, bodyBlock, exitBlock *basicBlock) (
 	body := &ast.BlockStmt{
 		List: d.stmts(bodyBlock),
 	}
-	body.List = append(body.List, bodyTermStmt)
+	body.List = append(body.List, bodyTermStmts...)
 	ifReturnStmt := &ast.IfStmt{
 		Cond: cond,
 		Body: body,
 	}
+	d.instComment([]ast.Stmt{ifReturnStmt}, condBlock.Term)
 	block.stmts = append(block.stmts, ifReturnStmt)
 	block.stmts = append(block.stmts, d.stmts(exitBlock)...)
 	return block, nil
@@ -268,21 +289,17 @@ func (d *decompiler) primIfReturn(condBlock, bodyBlock, exitBlock *basicBlock) (
 // corresponding conceputal basic block for the primitive.
 func (d *decompiler) primPreLoop(condBlock, bodyBlock, exitBlock *basicBlock) (*basicBlock, error) {
 	// Handle terminators.
-	condTerm, ok := condBlock.Term.(*ir.TermCondBr)
-	if !ok {
-		return nil, errors.Errorf("invalid cond terminator type; expected *ir.TermCondBr, got %T", condBlock.Term)
+	cond, err := d.condBr(condBlock, bodyBlock)
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
-	cond := d.value(condTerm.Cond)
-	// TODO: Figure out a clean way to check if the exit basic block is the true
-	// branch or the false branch. If exit is the true branch, negate the
-	// condition.
 	if _, ok := bodyBlock.Term.(*ir.TermBr); !ok {
 		return nil, errors.Errorf("invalid body terminator type; expected *ir.TermBr, got %T", bodyBlock.Term)
 	}
 	block := &basicBlock{Block: &ir.Block{}}
 	block.Term = exitBlock.Term
 	// Handle instructions.
-	block.stmts = append(block.stmts, d.stmts(condBlock)...)
+	condStmts := d.stmts(condBlock)
 	body := &ast.BlockStmt{
 		List: d.stmts(bodyBlock),
 	}
@@ -290,6 +307,26 @@ func (d *decompiler) primPreLoop(condBlock, bodyBlock, exitBlock *basicBlock) (*
 		Cond: cond,
 		Body: body,
 	}
+	if len(condStmts) > 0 {
+		// Evaluate the statements of the cond basic block on each iteration.
+		//
+		//    for {
+		//       cond_stmts
+		//       if !cond {
+		//          break
+		//       }
+		//       body
+		//    }
+		ifBreakStmt := &ast.IfStmt{
+			Cond: not(cond),
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{&ast.BranchStmt{Tok: token.BREAK}},
+			},
+		}
+		forStmt.Cond = nil
+		body.List = append(append(condStmts, ifBreakStmt), body.List...)
+	}
+	d.instComment([]ast.Stmt{forStmt}, condBlock.Term)
 	block.stmts = append(block.stmts, forStmt)
 	block.stmts = append(block.stmts, d.stmts(exitBlock)...)
 	return block, nil
@@ -299,18 +336,12 @@ func (d *decompiler) primPreLoop(condBlock, bodyBlock, exitBlock *basicBlock) (*
 // corresponding conceputal basic block for the primitive.
 func (d *decompiler) primPostLoop(condBlock, exitBlock *basicBlock) (*basicBlock, error) {
 	// Handle terminators.
-	condTerm, ok := condBlock.Term.(*ir.TermCondBr)
-	if !ok {
-		return nil, errors.Errorf("invalid cond terminator type; expected *ir.TermCondBr, got %T", condBlock.Term)
-	}
-	cond := d.value(condTerm.Cond)
-	cond = &ast.UnaryExpr{
-		Op: token.NOT,
-		X:  cond,
+	// The loop is repeated while control is transferred back to the cond basic
+	// block.
+	cond, err := d.condBr(condBlock, condBlock)
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
-	// TODO: Figure out a clean way to check if the exit basic block is the true
-	// branch or the false branch. If exit is the true branch, negate the
-	// condition.
 	block := &basicBlock{Block: &ir.Block{}}
 	block.Term = exitBlock.Term
 	// Handle instructions.
@@ -319,11 +350,12 @@ func (d *decompiler) primPostLoop(condBlock, exitBlock *basicBlock) (*basicBlock
 	}
 	breakStmt := &ast.BranchStmt{Tok: token.BREAK}
 	ifBreakStmt := &ast.IfStmt{
-		Cond: cond,
+		Cond: not(cond),
 		Body: &ast.BlockStmt{
 			List: []ast.Stmt{breakStmt},
 		},
 	}
+	d.instComment([]ast.Stmt{ifBreakStmt}, condBlock.Term)
 	body.List = append(body.List, ifBreakStmt)
 	forStmt := &ast.ForStmt{
 		Body: body,
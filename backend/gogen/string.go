@@ -0,0 +1,120 @@
+package gogen
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+
+	"github.com/decomp/decomp/internal/lower"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/value"
+)
+
+// stringGlobal returns the contents of the given LLVM IR value, if it is a
+// global constant of character array type holding a C string (see
+// lower.StringGlobal). The boolean return value indicates success.
+//
+// String globals are represented by Go string constants, without the
+// terminating NUL character.
+func stringGlobal(v value.Value) (*ir.Global, string, bool) {
+	return lower.StringGlobal(v)
+}
+
+// stringDecl converts the given LLVM IR string global into a corresponding Go
+// constant declaration. Like other globals, the constant is renamed if it
+// collides with the name of an imported package (see renameImportCollisions).
+//
+//    const _str = "foo"
+func (d *decompiler) stringDecl(g *ir.Global, s string) *ast.GenDecl {
+	spec := &ast.ValueSpec{
+		Names:  []*ast.Ident{d.globalIdent(g.Name())},
+		Values: []ast.Expr{stringLit(s)},
+	}
+	return &ast.GenDecl{
+		Tok:   token.CONST,
+		Specs: []ast.Spec{spec},
+	}
+}
+
+// stringAddr returns a Go expression of the address of the given LLVM IR string
+// global, for code which requires a pointer to the underlying character array.
+//
+//    (*[4]int8)(unsafe.Pointer(libc.CString(_str)))
+func (d *decompiler) stringAddr(g *ir.Global) ast.Expr {
+	ptr := call(d.importSel("unsafe", "Pointer"), d.cStringAt(g, nil))
+	return call(&ast.ParenExpr{X: d.goType(g.Typ)}, ptr)
+}
+
+// cStringAt returns a Go expression of a C string (i.e. *int8) of the contents
+// of the given LLVM IR string global, starting at the character with the
+// specified index (or the first character if index is nil).
+//
+//    libc.CString(_str)
+//    libc.CString(_str[i:])
+func (d *decompiler) cStringAt(g *ir.Global, index ast.Expr) ast.Expr {
+	var s ast.Expr = d.globalIdent(g.Name())
+	if index != nil {
+		s = &ast.SliceExpr{X: s, Low: index}
+	}
+	return call(d.importSel(libcPath, "CString"), s)
+}
+
+// stringGEP returns a Go expression of the given LLVM IR getelementptr
+// operation, if it locates a character of a string global. The boolean return
+// value indicates success.
+//
+//    getelementptr ([4 x i8], [4 x i8]* @.str, i64 0, i64 0)
+func (d *decompiler) stringGEP(src value.Value, indices []value.Value) (ast.Expr, bool) {
+	g, _, ok := stringGlobal(src)
+	if !ok {
+		return nil, false
+	}
+	index, ok := lower.CharIndex(indices)
+	if !ok {
+		return nil, false
+	}
+	if lower.IsZero(index) {
+		return d.cStringAt(g, nil), true
+	}
+	return d.cStringAt(g, d.value(index)), true
+}
+
+// stringExpr returns a Go string expression of the given LLVM IR value, if it
+// is a pointer to the first character of a constant C string; the Go string
+// constant of string globals, and a Go string literal otherwise. The boolean
+// return value indicates success.
+func (d *decompiler) stringExpr(v value.Value) (ast.Expr, bool) {
+	s, ok := lower.CString(v)
+	if !ok {
+		return nil, false
+	}
+	src := v
+	if expr, ok := v.(*constant.ExprGetElementPtr); ok {
+		src = expr.Src
+	}
+	if g, _, ok := stringGlobal(src); ok {
+		return d.globalIdent(g.Name()), true
+	}
+	return stringLit(s), true
+}
+
+// constCharArray converts the given LLVM IR character array constant to a
+// corresponding Go expression. Printable ASCII characters are given as
+// character literals.
+//
+//    [4]int8{'f', 'o', 'o', 0}
+func (d *decompiler) constCharArray(c *constant.CharArray) ast.Expr {
+	var elems []ast.Expr
+	for _, b := range c.X {
+		if ' ' <= b && b <= '~' {
+			elems = append(elems, &ast.BasicLit{Kind: token.CHAR, Value: strconv.QuoteRune(rune(b))})
+		} else {
+			elems = append(elems, d.intLit(int64(int8(b))))
+		}
+	}
+	return &ast.CompositeLit{
+		Type: d.goType(c.Typ),
+		Elts: elems,
+	}
+}
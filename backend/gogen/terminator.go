@@ -1,4 +1,4 @@
-package main
+package gogen
 
 import (
 	"fmt"
@@ -9,6 +9,21 @@ import (
 	"github.com/llir/llvm/ir/value"
 )
 
+// terms converts the given LLVM IR terminator to a corresponding list of Go
+// statements.
+func (d *decompiler) terms(term ir.Terminator) []ast.Stmt {
+	var stmts []ast.Stmt
+	if callbr, ok := term.(*ir.TermCallBr); ok {
+		// A callbr terminator corresponds to more than one Go statement, thus it
+		// is handled outside of d.term.
+		stmts = d.termCallBr(callbr)
+	} else {
+		stmts = []ast.Stmt{d.term(term)}
+	}
+	d.instComment(stmts, term)
+	return stmts
+}
+
 // term converts the given LLVM IR terminator to a corresponding Go statement.
 func (d *decompiler) term(term ir.Terminator) ast.Stmt {
 	switch term := term.(type) {
@@ -20,6 +35,15 @@ func (d *decompiler) term(term ir.Terminator) ast.Stmt {
 		return d.termCondBr(term)
 	case *ir.TermSwitch:
 		return d.termSwitch(term)
+	case *ir.TermIndirectBr:
+		return d.termIndirectBr(term)
+	case *ir.TermInvoke:
+		return d.termInvoke(term)
+	case *ir.TermCallBr:
+		// callbr terminators are handled by d.terms.
+		panic(fmt.Sprintf("unexpected callbr terminator `%v`", term.LLString()))
+	case *ir.TermResume:
+		return d.termResume(term)
 	case *ir.TermUnreachable:
 		return d.termUnreachable(term)
 	default:
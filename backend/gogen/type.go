@@ -1,4 +1,4 @@
-package main
+package gogen
 
 import (
 	"fmt"
@@ -51,12 +51,27 @@ func (d *decompiler) goTypeDef(t irtypes.Type) ast.Expr {
 				List: []*ast.Field{result},
 			}
 		}
-		// TODO: Handle t.Variadic.
+		if t.Variadic {
+			// Variadic arguments are passed as ...interface{}.
+			param := &ast.Field{
+				Type: &ast.Ellipsis{
+					Elt: emptyInterface(),
+				},
+			}
+			params.List = append(params.List, param)
+		}
 		return &ast.FuncType{
 			Params:  params,
 			Results: results,
 		}
 	case *irtypes.IntType:
+		if t.BitSize == 1 {
+			return ast.NewIdent("bool")
+		}
+		if t.BitSize > 64 {
+			// Integer types wider than 64 bits are represented by intn.Int.
+			return d.intnSel("Int")
+		}
 		d.intSizes[t.BitSize] = true
 		return &ast.Ident{
 			Name: fmt.Sprintf("int%d", t.BitSize),
@@ -68,8 +83,9 @@ func (d *decompiler) goTypeDef(t irtypes.Type) ast.Expr {
 		case irtypes.FloatKindDouble:
 			return ast.NewIdent("float64")
 		case irtypes.FloatKindHalf, irtypes.FloatKindFP128, irtypes.FloatKindX86_FP80, irtypes.FloatKindPPC_FP128:
-			// TODO: Add proper support for non-builtin float types.
-			return ast.NewIdent("float64")
+			// Floating-point types not part of Go builtin are represented by
+			// floatn.Float.
+			return d.floatnSel("Float")
 		default:
 			panic(fmt.Sprintf("support for floating-point kind %v not yet implemented", t.Kind))
 		}
@@ -98,15 +114,23 @@ func (d *decompiler) goTypeDef(t irtypes.Type) ast.Expr {
 			Elt: d.goType(t.ElemType),
 		}
 	case *irtypes.StructType:
+		// Padding fields preserve the field offsets and size of the LLVM IR
+		// struct type (see goStructLayout).
+		layout := d.layout.goStructLayout(t)
 		var fs []*ast.Field
 		for i, f := range t.Fields {
-			name := fmt.Sprintf("field_%d", i)
+			if pad := layout.pads[i]; pad > 0 {
+				fs = append(fs, padField(pad))
+			}
 			field := &ast.Field{
-				Names: []*ast.Ident{d.localIdent(name)},
+				Names: []*ast.Ident{d.fieldIdent(t, i)},
 				Type:  d.goType(f),
 			}
 			fs = append(fs, field)
 		}
+		if pad := layout.pads[len(t.Fields)]; pad > 0 {
+			fs = append(fs, padField(pad))
+		}
 		fields := &ast.FieldList{
 			List: fs,
 		}
@@ -117,3 +141,24 @@ func (d *decompiler) goTypeDef(t irtypes.Type) ast.Expr {
 		panic(fmt.Sprintf("support for type %T not yet implemented", t))
 	}
 }
+
+// emptyInterface returns the Go empty interface type.
+//
+//    interface{}
+func emptyInterface() ast.Expr {
+	// Valid brace positions print the empty method list on a single line.
+	methods := &ast.FieldList{
+		Opening: 1,
+		Closing: 1,
+	}
+	return &ast.InterfaceType{Methods: methods}
+}
+
+// goUintType returns the unsigned Go integer type corresponding to the given
+// LLVM IR integer type.
+func (d *decompiler) goUintType(t *irtypes.IntType) ast.Expr {
+	d.uintSizes[t.BitSize] = true
+	return &ast.Ident{
+		Name: fmt.Sprintf("uint%d", t.BitSize),
+	}
+}
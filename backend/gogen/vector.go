@@ -0,0 +1,272 @@
+package gogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"github.com/llir/llvm/ir/constant"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// vectorOp converts the given LLVM IR element-wise operation on vectors of type
+// t to a corresponding Go composite literal, using elem to convert the
+// operation on the i:th elements of x and y. The result is of type [N]bool if
+// cmp is set, and of the vector type t otherwise.
+//
+//    [4]int32{x[0] + y[0], x[1] + y[1], x[2] + y[2], x[3] + y[3]}
+func (d *decompiler) vectorOp(t *irtypes.VectorType, x, y value.Value, cmp bool, elem func(xi, yi ast.Expr) ast.Expr) ast.Expr {
+	var typ ast.Expr
+	if cmp {
+		typ = &ast.ArrayType{
+			Len: d.uintLit(t.Len),
+			Elt: ast.NewIdent("bool"),
+		}
+	} else {
+		typ = d.goType(t)
+	}
+	var elems []ast.Expr
+	for i := uint64(0); i < t.Len; i++ {
+		elems = append(elems, elem(d.elem(x, i), d.elem(y, i)))
+	}
+	return &ast.CompositeLit{
+		Type: typ,
+		Elts: elems,
+	}
+}
+
+// elem returns a Go expression of the i:th element of the given LLVM IR vector
+// or array value. The elements of constants are folded, rather than indexing
+// the composite literal of the constant.
+//
+//    x[2]
+//    3
+func (d *decompiler) elem(v value.Value, i uint64) ast.Expr {
+	switch v := v.(type) {
+	case *constant.Vector:
+		return d.value(v.Elems[i])
+	case *constant.Array:
+		return d.value(v.Elems[i])
+	case *constant.ZeroInitializer, *constant.Undef:
+		elemType, ok := elemTypeOf(v.Type())
+		if !ok {
+			break
+		}
+		switch elemType := elemType.(type) {
+		case *irtypes.IntType:
+			return d.value(constant.NewInt(elemType, 0))
+		case *irtypes.FloatType:
+			return d.value(constant.NewFloat(elemType, 0))
+		}
+		return d.zeroValue(elemType)
+	}
+	return &ast.IndexExpr{
+		X:     d.value(v),
+		Index: d.uintLit(i),
+	}
+}
+
+// elemTypeOf returns the element type of the given vector or array type. The
+// boolean return value indicates success.
+func elemTypeOf(t irtypes.Type) (irtypes.Type, bool) {
+	switch t := t.(type) {
+	case *irtypes.VectorType:
+		return t.ElemType, true
+	case *irtypes.ArrayType:
+		return t.ElemType, true
+	}
+	return nil, false
+}
+
+// convertVector returns a Go expression for converting the given LLVM IR
+// vector value element-wise into the specified vector type, using elem to
+// convert the i:th element.
+//
+//    [4]int64{int64(x[0]), int64(x[1]), int64(x[2]), int64(x[3])}
+func (d *decompiler) convertVector(from value.Value, to *irtypes.VectorType, elem func(xi ast.Expr) ast.Expr) ast.Expr {
+	var elems []ast.Expr
+	for i := uint64(0); i < to.Len; i++ {
+		elems = append(elems, elem(d.elem(from, i)))
+	}
+	return &ast.CompositeLit{
+		Type: d.goType(to),
+		Elts: elems,
+	}
+}
+
+// vectorConv returns the vector types of the given LLVM IR vector conversion,
+// if both from and to are vectors of the same length. The boolean return value
+// indicates success.
+func vectorConv(from value.Value, to irtypes.Type) (fromType, toType *irtypes.VectorType, ok bool) {
+	fromType, ok = from.Type().(*irtypes.VectorType)
+	if !ok {
+		return nil, nil, false
+	}
+	toType, ok = to.(*irtypes.VectorType)
+	if !ok || fromType.Len != toType.Len {
+		return nil, nil, false
+	}
+	return fromType, toType, true
+}
+
+// isCmpOp reports whether the given Go operator token is a comparison.
+func isCmpOp(op token.Token) bool {
+	switch op {
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return true
+	}
+	return false
+}
+
+// shuffleVector converts the given LLVM IR shufflevector operation to a
+// corresponding Go composite literal. Mask elements select elements of x if
+// less than the length of x, and elements of y otherwise. Undefined mask
+// elements select the zero value.
+//
+//    [4]int32{x[0], y[0], x[1], y[1]}
+func (d *decompiler) shuffleVector(x, y, mask value.Value, typ irtypes.Type) ast.Expr {
+	t, ok := typ.(*irtypes.VectorType)
+	if !ok {
+		panic(fmt.Sprintf("invalid shufflevector type; expected *types.VectorType, got %T", typ))
+	}
+	xType, ok := x.Type().(*irtypes.VectorType)
+	if !ok {
+		panic(fmt.Sprintf("invalid shufflevector operand type; expected *types.VectorType, got %T", x.Type()))
+	}
+	var elems []ast.Expr
+	for i := uint64(0); i < t.Len; i++ {
+		index, ok := maskIndex(mask, i)
+		switch {
+		case !ok:
+			elems = append(elems, d.zeroValue(t.ElemType))
+		case index < xType.Len:
+			elems = append(elems, d.elem(x, index))
+		default:
+			elems = append(elems, d.elem(y, index-xType.Len))
+		}
+	}
+	return &ast.CompositeLit{
+		Type: d.goType(t),
+		Elts: elems,
+	}
+}
+
+// maskIndex returns the i:th element of the given shufflevector mask. The
+// boolean return value is false if the mask element is undefined.
+func maskIndex(mask value.Value, i uint64) (uint64, bool) {
+	switch mask := mask.(type) {
+	case *constant.Vector:
+		if c, ok := mask.Elems[i].(*constant.Int); ok {
+			return c.X.Uint64(), true
+		}
+		return 0, false
+	case *constant.ZeroInitializer:
+		return 0, true
+	case *constant.Undef:
+		return 0, false
+	default:
+		panic(fmt.Sprintf("support for shufflevector mask %T not yet implemented", mask))
+	}
+}
+
+// zeroValue returns the zero value of the given LLVM IR type as a Go
+// expression.
+//
+//    *new(T)
+func (d *decompiler) zeroValue(t irtypes.Type) ast.Expr {
+	expr := &ast.CallExpr{
+		Fun:  ast.NewIdent("new"),
+		Args: []ast.Expr{d.goType(t)},
+	}
+	return &ast.StarExpr{
+		X: expr,
+	}
+}
+
+// aggregateElem returns a Go expression of the element of the given aggregate
+// expression x of type t, as located by the specified extractvalue or
+// insertvalue indices. Struct fields are accessed by field name and array
+// elements by index.
+//
+//    x.field_1[2]
+func (d *decompiler) aggregateElem(x ast.Expr, t irtypes.Type, indices []uint64) ast.Expr {
+	for _, index := range indices {
+		switch tt := t.(type) {
+		case *irtypes.StructType:
+			x = &ast.SelectorExpr{
+				X:   x,
+				Sel: d.fieldIdent(tt, int(index)),
+			}
+			t = tt.Fields[index]
+		case *irtypes.ArrayType:
+			x = &ast.IndexExpr{
+				X:     x,
+				Index: d.uintLit(index),
+			}
+			t = tt.ElemType
+		case *irtypes.VectorType:
+			x = &ast.IndexExpr{
+				X:     x,
+				Index: d.uintLit(index),
+			}
+			t = tt.ElemType
+		default:
+			panic(fmt.Sprintf("invalid aggregate type; expected *types.StructType or *types.ArrayType, got %T", t))
+		}
+	}
+	return x
+}
+
+// insertStmts returns Go statements assigning a copy of the aggregate or vector
+// value x to the given local variable, and then assigning elem to the element
+// of the copy denoted by index.
+//
+//    _2 = _1
+//    _2.field_1[2] = elem
+func (d *decompiler) insertStmts(name string, x value.Value, index func(dst ast.Expr) ast.Expr, elem value.Value) []ast.Stmt {
+	copyStmt := d.assign(name, d.value(x))
+	dst := index(d.localIdent(name))
+	assignStmt := &ast.AssignStmt{
+		Lhs: []ast.Expr{dst},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{d.value(elem)},
+	}
+	return []ast.Stmt{copyStmt, assignStmt}
+}
+
+// insertExpr returns a Go expression evaluating to a copy of the aggregate or
+// vector constant x with elem assigned to the element of the copy denoted by
+// index.
+//
+//    func() T { v := x; v[2] = elem; return v }()
+func (d *decompiler) insertExpr(x constant.Constant, index func(dst ast.Expr) ast.Expr, elem constant.Constant) ast.Expr {
+	v := ast.NewIdent("v")
+	body := &ast.BlockStmt{
+		List: []ast.Stmt{
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{v},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{d.constant(x)},
+			},
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{index(v)},
+				Tok: token.ASSIGN,
+				Rhs: []ast.Expr{d.constant(elem)},
+			},
+			&ast.ReturnStmt{
+				Results: []ast.Expr{v},
+			},
+		},
+	}
+	fn := &ast.FuncLit{
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{},
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: d.goType(x.Type())}},
+			},
+		},
+		Body: body,
+	}
+	return &ast.CallExpr{Fun: fn}
+}
@@ -3,6 +3,7 @@ package cfa
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/decomp/decomp/cfa/primitive"
 	"github.com/decomp/decomp/graph/cfg"
@@ -121,6 +122,32 @@ func Merge(g *cfg.Graph, prim *primitive.Primitive) error {
 	return nil
 }
 
+// isStructured reports whether the outgoing edges of the given node may be
+// recovered as structured control flow. The normal, unwind and indirect edges
+// of exception handling and indirect branch terminators are left unstructured.
+func isStructured(g graph.Directed, n graph.Node) bool {
+	succs := g.From(n.ID())
+	for succs.Next() {
+		e, ok := g.Edge(n.ID(), succs.Node().ID()).(*cfg.Edge)
+		if !ok {
+			continue
+		}
+		switch e.Label {
+		case cfg.LabelNormal, cfg.LabelUnwind, cfg.LabelIndirect:
+			return false
+		}
+	}
+	return true
+}
+
+// sortedNodes returns the given nodes sorted by ID, so that control flow
+// primitives are located independent of the iteration order of the graph.
+func sortedNodes(nodes graph.Nodes) []graph.Node {
+	ns := graph.NodesOf(nodes)
+	sort.Slice(ns, func(i, j int) bool { return ns[i].ID() < ns[j].ID() })
+	return ns
+}
+
 // label returns the label of the node.
 func label(n graph.Node) string {
 	if n, ok := n.(*cfg.Node); ok {
@@ -72,11 +72,12 @@ digraph if {
 // and a boolean indicating if such a primitive was found.
 func FindIf(g graph.Directed, dom cfg.DominatorTree) (prim If, ok bool) {
 	// Range through cond node candidates.
-	condNodes := g.Nodes()
-	for condNodes.Next() {
-		cond := condNodes.Node()
+	for _, cond := range sortedNodes(g.Nodes()) {
+		if !isStructured(g, cond) {
+			continue
+		}
 		// Verify that cond has two successors (body and exit).
-		condSuccs := graph.NodesOf(g.From(cond.ID()))
+		condSuccs := sortedNodes(g.From(cond.ID()))
 		if len(condSuccs) != 2 {
 			continue
 		}
@@ -80,11 +80,12 @@ digraph if_else {
 // g, and a boolean indicating if such a primitive was found.
 func FindIfElse(g graph.Directed, dom cfg.DominatorTree) (prim IfElse, ok bool) {
 	// Range through cond node candidates.
-	condNodes := g.Nodes()
-	for condNodes.Next() {
-		cond := condNodes.Node()
+	for _, cond := range sortedNodes(g.Nodes()) {
+		if !isStructured(g, cond) {
+			continue
+		}
 		// Verify that cond has two successors (body_true and body_false).
-		condSuccs := graph.NodesOf(g.From(cond.ID()))
+		condSuccs := sortedNodes(g.From(cond.ID()))
 		if len(condSuccs) != 2 {
 			continue
 		}
@@ -94,7 +95,7 @@ func FindIfElse(g graph.Directed, dom cfg.DominatorTree) (prim IfElse, ok bool)
 		prim.BodyTrue, prim.BodyFalse = condSuccs[0], condSuccs[1]
 
 		// Verify that body_true has one successor (exit).
-		bodyTrueSuccs := graph.NodesOf(g.From(prim.BodyTrue.ID()))
+		bodyTrueSuccs := sortedNodes(g.From(prim.BodyTrue.ID()))
 		if len(bodyTrueSuccs) != 1 {
 			continue
 		}
@@ -65,11 +65,12 @@ digraph post_loop {
 // boolean indicating if such a primitive was found.
 func FindPostLoop(g graph.Directed, dom cfg.DominatorTree) (prim PostLoop, ok bool) {
 	// Range through cond node candidates.
-	condNodes := g.Nodes()
-	for condNodes.Next() {
-		cond := condNodes.Node()
+	for _, cond := range sortedNodes(g.Nodes()) {
+		if !isStructured(g, cond) {
+			continue
+		}
 		// Verify that cond has two successors (cond and exit).
-		condSuccs := graph.NodesOf(g.From(cond.ID()))
+		condSuccs := sortedNodes(g.From(cond.ID()))
 		if len(condSuccs) != 2 {
 			continue
 		}
@@ -0,0 +1,97 @@
+package cfa
+
+import (
+	goerrors "errors"
+
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/decomp/decomp/graph/cfg"
+	"github.com/llir/llvm/ir"
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/graph"
+)
+
+// ErrIncomplete signals an incomplete control flow recovery.
+var ErrIncomplete = goerrors.New("incomplete control flow recovery")
+
+// FindEntry attempts to locate the entry node of the control flow graph by
+// searching for a single node in the control flow graph with no incoming edges.
+func FindEntry(g graph.Directed) (graph.Node, error) {
+	var entry graph.Node
+	nodes := g.Nodes()
+	for nodes.Next() {
+		n := nodes.Node()
+		preds := g.To(n.ID())
+		if preds.Len() == 0 {
+			if entry != nil {
+				return nil, errors.Errorf("more than one candidate for the entry node located; prev %q, new %q", label(entry), label(n))
+			}
+			entry = n
+		}
+	}
+	if entry == nil {
+		return nil, errors.New("unable to locate entry node")
+	}
+	return entry, nil
+}
+
+// A StepFunc is invoked at each step of the control flow recovery of Restructure,
+// once before (merged is false) and once after (merged is true) merging the
+// nodes of the located primitive into a single node. Steps are numbered from 1.
+type StepFunc func(g *cfg.Graph, step int, prim *primitive.Primitive, merged bool) error
+
+// Restructure attempts to recover the control flow primitives of a given
+// control flow graph. It does so by repeatedly locating and merging structured
+// subgraphs (graph representations of control flow primitives) into single
+// nodes until the entire graph is reduced into a single node or no structured
+// subgraphs may be located. The optional step function is invoked at each step
+// (e.g. to record the intermediate control flow graphs). The returned list of
+// primitives is ordered in the same sequence as they were located.
+//
+// An error with cause ErrIncomplete is returned together with the primitives
+// located so far if the control flow graph could not be reduced into a single
+// node.
+func Restructure(g *cfg.Graph, entry graph.Node, step StepFunc) ([]*primitive.Primitive, error) {
+	prims := make([]*primitive.Primitive, 0)
+	// Locate control flow primitives.
+	for i := 1; g.Nodes().Len() > 1; i++ {
+		// Locate primitive.
+		dom := cfg.NewDom(g, entry)
+		prim, err := FindPrim(g, dom)
+		if err != nil {
+			return prims, errors.Wrap(ErrIncomplete, err.Error())
+		}
+		prims = append(prims, prim)
+		if step != nil {
+			if err := step(g, i, prim, false); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+
+		// Merge the nodes of the primitive into a single node.
+		if err := Merge(g, prim); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		// Handle special case where entry node has been replaced by primitive
+		// node.
+		if g.Node(entry.ID()) == nil {
+			var ok bool
+			entry, ok = g.NodeByLabel(prim.Entry)
+			if !ok {
+				return nil, errors.Errorf("unable to locate entry node %q", prim.Entry)
+			}
+		}
+		if step != nil {
+			if err := step(g, i, prim, true); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+	return prims, nil
+}
+
+// RestructureFunc attempts to recover the control flow primitives of the given
+// LLVM IR function (see Restructure), starting at its entry basic block.
+func RestructureFunc(f *ir.Func) ([]*primitive.Primitive, error) {
+	g := cfg.New(f)
+	return Restructure(g, g.Entry(), nil)
+}
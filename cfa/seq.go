@@ -62,11 +62,12 @@ digraph seq {
 // and a boolean indicating if such a primitive was found.
 func FindSeq(g graph.Directed, dom cfg.DominatorTree) (prim Seq, ok bool) {
 	// Range through entry node candidates.
-	entryNodes := g.Nodes()
-	for entryNodes.Next() {
-		entry := entryNodes.Node()
+	for _, entry := range sortedNodes(g.Nodes()) {
+		if !isStructured(g, entry) {
+			continue
+		}
 		// Verify that entry has one successor (exit).
-		entrySuccs := graph.NodesOf(g.From(entry.ID()))
+		entrySuccs := sortedNodes(g.From(entry.ID()))
 		if len(entrySuccs) != 1 {
 			continue
 		}
@@ -3,6 +3,9 @@
 //
 // The input of go-post is unpolished Go source code and the output is more
 // idiomatic Go source code.
+//
+// The source map of a Go source file (e.g. "foo.go.map", as output by ll2go
+// -srcmap) is updated to reflect the rewritten Go source code.
 package main
 
 //go:generate usagen -o z_usage.go go-post
@@ -3,6 +3,7 @@ package main
 import (
 	"go/ast"
 	"go/token"
+	"sort"
 )
 
 func init() {
@@ -74,12 +75,31 @@ func forloop(file *ast.File) bool {
 			if postPos == -1 {
 				continue
 			}
+			// Move the comments of "i := 0" and "i++" in front of the for-loop.
+			if initPos == i-1 {
+				comments := stmtComments(file, *blockStmt, initPos, true)
+				forComments := stmtComments(file, *blockStmt, i, false)
+				comments = append(comments, stmtComments(file, forStmt.Body, postPos, true)...)
+				comments = append(comments, forComments...)
+				prev := (*blockStmt).Lbrace
+				if initPos > 0 {
+					prev = list[initPos-1].End()
+				}
+				moveComments(file, comments, prev, forStmt)
+			}
 			// Remove "i := 0" from the block statement list.
 			forStmt.Init = list[initPos]
 			(*blockStmt).List = listDel(list, initPos)
-			// Remove "i++" from the for-body.
-			forStmt.Post = forStmt.Body.List[postPos]
+			// Remove "i++" from the for-body. The positions of "i++" are
+			// cleared, as it now precedes the statements of the body.
+			post := forStmt.Body.List[postPos].(*ast.IncDecStmt)
+			forStmt.Post = &ast.IncDecStmt{
+				X:   ast.NewIdent(x.Name),
+				Tok: post.Tok,
+			}
 			forStmt.Body.List = listDel(forStmt.Body.List, postPos)
+			trimBlock(file, forStmt.Body)
+			trimBlock(file, *blockStmt)
 			fixed = true
 		}
 	})
@@ -87,6 +107,88 @@ func forloop(file *ast.File) bool {
 	return fixed
 }
 
+// stmtComments returns the comment groups of the i:th statement of block; i.e.
+// the comments preceding the statement, and if trailing is set, the comments on
+// the line of its end.
+func stmtComments(file *ast.File, block *ast.BlockStmt, i int, trailing bool) []*ast.CommentGroup {
+	stmt := block.List[i]
+	prev := block.Lbrace
+	if i > 0 {
+		prev = block.List[i-1].End()
+	}
+	prevLine := fset.Position(prev).Line
+	endLine := fset.Position(stmt.End()).Line
+	var comments []*ast.CommentGroup
+	for _, c := range file.Comments {
+		if c.Pos() < prev || c.Pos() >= block.Rbrace {
+			continue
+		}
+		line := fset.Position(c.Pos()).Line
+		if line == prevLine {
+			// Comment of the preceding statement.
+			continue
+		}
+		if c.Pos() < stmt.Pos() || (trailing && line == endLine) {
+			comments = append(comments, c)
+		}
+	}
+	return comments
+}
+
+// moveComments moves the given comment groups to the lines following the
+// position prev, in order, and moves the for-loop to the line following them.
+// Comments which don't fit in front of the for-loop share the line preceding
+// it; the printer separates line comments by newlines.
+func moveComments(file *ast.File, comments []*ast.CommentGroup, prev token.Pos, forStmt *ast.ForStmt) {
+	f := fset.File(forStmt.Pos())
+	line := fset.Position(prev).Line + 1
+	last := fset.Position(forStmt.Pos()).Line - 1
+	for _, g := range comments {
+		for _, c := range g.List {
+			span := fset.Position(c.End()).Line - fset.Position(c.Pos()).Line + 1
+			if line > last {
+				c.Slash = f.LineStart(last) + token.Pos(line-last)
+				if c.Slash >= forStmt.For {
+					c.Slash = forStmt.For - 1
+				}
+			} else {
+				c.Slash = f.LineStart(line)
+			}
+			line += span
+		}
+	}
+	if line <= last {
+		forStmt.For = f.LineStart(line)
+	}
+	sort.Slice(file.Comments, func(i, j int) bool {
+		return file.Comments[i].Pos() < file.Comments[j].Pos()
+	})
+}
+
+// trimBlock removes blank lines at the beginning and end of block, as left by
+// removed statements.
+func trimBlock(file *ast.File, block *ast.BlockStmt) {
+	first, last := block.Rbrace, block.Lbrace
+	if len(block.List) > 0 {
+		first, last = block.List[0].Pos(), block.List[len(block.List)-1].End()
+	}
+	for _, c := range file.Comments {
+		if c.Pos() > block.Lbrace && c.Pos() < first {
+			first = c.Pos()
+		}
+		if c.End() > last && c.End() < block.Rbrace {
+			last = c.End()
+		}
+	}
+	f := fset.File(block.Lbrace)
+	if line := fset.Position(first).Line - 1; line > fset.Position(block.Lbrace).Line {
+		block.Lbrace = f.LineStart(line)
+	}
+	if line := fset.Position(last).Line + 1; line < fset.Position(block.Rbrace).Line {
+		block.Rbrace = f.LineStart(line)
+	}
+}
+
 // listDel removes the i:th statement of list.
 func listDel(list []ast.Stmt, i int) []ast.Stmt {
 	return append(list[:i], list[i+1:]...)
@@ -20,9 +20,7 @@ func main() {
 		Out: `package main
 
 func main() {
-
 	for i := 0; i < 10; i++ {
-
 	}
 }
 `,
@@ -45,10 +43,8 @@ func main() {
 
 func main() {
 	sum := 0
-
 	for i := 0; i < 10; i++ {
 		sum += i
-
 	}
 }
 `,
@@ -75,12 +71,78 @@ import "fmt"
 
 func main() {
 	xs := []int{1, 2, 3, 4, 5}
-
 	for i := len(xs) - 1; i >= 0; i-- {
 		fmt.Println(i)
+	}
+}
+`,
+	},
+	// i=3,
+	{
+		Name: "forloop.3",
+		In: `package main
+
+func main() {
+	sum := 0
+	//   %i = phi i32 [ 0, %entry ], [ %i.next, %loop ]
+	i := 0
+	for i < 10 {
+		//   %sum.next = add i32 %sum, %i
+		sum += i
+		//   %i.next = add i32 %i, 1
+		i++
+	}
+}
+`,
+		Out: `package main
 
+func main() {
+	sum := 0
+	//   %i = phi i32 [ 0, %entry ], [ %i.next, %loop ]
+	//   %i.next = add i32 %i, 1
+	for i := 0; i < 10; i++ {
+		//   %sum.next = add i32 %sum, %i
+		sum += i
 	}
 }
+`,
+	},
+	// i=4,
+	{
+		Name: "forloop.4",
+		In: `package main
+
+func main() {
+	sum := 0
+	// loop:
+	//   %i = phi i32 [ 0, %entry ], [ %i.next, %loop ]
+	i := 0 // i
+	// for
+	for i < 10 {
+		//   %i.next = add i32 %i, 1
+		i++ // i++
+		//   %sum.next = add i32 %sum, %i
+		sum += i
+	} // end
+	//   ret void
+}
+`,
+		Out: `package main
+
+func main() {
+	sum := 0
+	// loop:
+	//   %i = phi i32 [ 0, %entry ], [ %i.next, %loop ]
+	// i
+	//   %i.next = add i32 %i, 1
+	// i++
+	// for
+	for i := 0; i < 10; i++ {
+		//   %sum.next = add i32 %sum, %i
+		sum += i
+	} // end
+	//   ret void
+}
 `,
 	},
 }
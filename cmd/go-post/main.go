@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"github.com/decomp/decomp/cmd/go-post/internal/diff"
+	"github.com/decomp/decomp/srcmap"
 )
 
 var (
@@ -142,7 +143,22 @@ func processFile(filename string, useStdin bool) error {
 		return err
 	}
 
-	file, err := parser.ParseFile(fset, filename, src, parserMode)
+	// Track the origins of statements in the source map of the file, if any,
+	// through marker comments.
+	var m *srcmap.Map
+	var origins []srcmap.Origin
+	markedSrc := src
+	if !useStdin {
+		m, err = srcmap.Load(filename)
+		if err != nil {
+			return err
+		}
+		if m != nil {
+			markedSrc, origins = srcmap.Insert(src, m)
+		}
+	}
+
+	file, err := parser.ParseFile(fset, filename, markedSrc, parserMode)
 	if err != nil {
 		return err
 	}
@@ -197,6 +213,15 @@ func processFile(filename string, useStdin bool) error {
 		return err
 	}
 
+	// Remove marker comments and update the source map.
+	var mappings []srcmap.Mapping
+	if m != nil {
+		newSrc, mappings, err = srcmap.Extract(newSrc, origins)
+		if err != nil {
+			return err
+		}
+	}
+
 	if *doDiff {
 		data, err := diff.Diff("go-fix", src, newSrc)
 		if err != nil {
@@ -212,7 +237,14 @@ func processFile(filename string, useStdin bool) error {
 		return nil
 	}
 
-	return ioutil.WriteFile(f.Name(), newSrc, 0)
+	if err := ioutil.WriteFile(f.Name(), newSrc, 0); err != nil {
+		return err
+	}
+	if m != nil {
+		m.Mappings = mappings
+		return m.Store(filename)
+	}
+	return nil
 }
 
 var gofmtBuf bytes.Buffer
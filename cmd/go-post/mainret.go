@@ -2,6 +2,7 @@ package main
 
 import (
 	"go/ast"
+	"go/token"
 	"log"
 )
 
@@ -84,7 +85,7 @@ func mainret(file *ast.File) bool {
 				// Add "os" import if needed.
 				addImport(file, "os")
 				// Replace "return 42" with "os.Exit(42)".
-				exit := createExit(result)
+				exit := createExit(retStmt.Pos(), result)
 				*stmt = exit
 			}
 			fixed = true
@@ -116,14 +117,15 @@ func mainret(file *ast.File) bool {
 	return fixed
 }
 
-// createExit creates and returns an "os.Exit" call with the specified argument.
-func createExit(arg ast.Expr) *ast.ExprStmt {
+// createExit creates and returns an "os.Exit" call with the specified argument,
+// at the position of the replaced statement.
+func createExit(pos token.Pos, arg ast.Expr) *ast.ExprStmt {
 	return &ast.ExprStmt{
 		X: &ast.CallExpr{
 			Fun: &ast.SelectorExpr{
 				// TODO: Locate the original identifier of "os" instead of creating
 				// a new one.
-				X:   ast.NewIdent("os"),
+				X:   &ast.Ident{NamePos: pos, Name: "os"},
 				Sel: ast.NewIdent("Exit"),
 			},
 			Args: []ast.Expr{
@@ -99,6 +99,26 @@ func main() {
 	}
 
 }
+`,
+	},
+	// i=5,
+	{
+		Name: "mainret.5",
+		In: `package main
+
+func main() {
+	//   ret i32 42
+	return 42
+}
+`,
+		Out: `package main
+
+import "os"
+
+func main() {
+	//   ret i32 42
+	os.Exit(int(42))
+}
 `,
 	},
 }
@@ -83,7 +83,10 @@ func mem2varFuncDecl(funcDecl ast.Node) bool {
 		// var foo int32
 		*stmt = &ast.DeclStmt{
 			Decl: &ast.GenDecl{
-				Tok: token.VAR,
+				// Keep the position of the replaced statement, to retain its
+				// comments.
+				TokPos: assignStmt.Pos(),
+				Tok:    token.VAR,
 				Specs: []ast.Spec{
 					&ast.ValueSpec{
 						Names: []*ast.Ident{ident},
@@ -130,6 +133,8 @@ func rewriteMem2Var(funcDecl ast.Node, identName string) {
 		if identName != starIdent.Name {
 			return
 		}
-		*expr = ast.NewIdent(identName)
+		// Keep the position of the memory use, to retain the comments of the
+		// statement in which it occurs.
+		*expr = &ast.Ident{NamePos: starExpr.Pos(), Name: identName}
 	})
 }
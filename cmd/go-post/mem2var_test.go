@@ -25,6 +25,36 @@ func main() {
 	_7 = 0
 	_12 = _7
 }
+`,
+	},
+	// i=1,
+	{
+		Name: "mem2var.1",
+		In: `package main
+
+func main() {
+	var _12 int32
+	// entry:
+	//   %7 = alloca i32
+	_7 := new(int32)
+	//   store i32 0, i32* %7
+	*_7 = 0
+	//   %12 = load i32, i32* %7
+	_12 = *_7
+}
+`,
+		Out: `package main
+
+func main() {
+	var _12 int32
+	// entry:
+	//   %7 = alloca i32
+	var _7 int32
+	//   store i32 0, i32* %7
+	_7 = 0
+	//   %12 = load i32, i32* %7
+	_12 = _7
+}
 `,
 	},
 }
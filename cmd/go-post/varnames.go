@@ -70,9 +70,9 @@ func varnamesFuncDecl(funcDecl ast.Node) bool {
 		}
 		oldName := oldIdent.Name
 		newName := fmt.Sprintf("v%s", oldName)
-		newIdent := ast.NewIdent(newName)
-		_ = newIdent
-		valueSpec.Names[0] = newIdent
+		// Keep the positions of renamed identifiers, to retain the comments of
+		// the statements in which they occur.
+		valueSpec.Names[0] = &ast.Ident{NamePos: oldIdent.NamePos, Name: newName}
 		// rewrite memory uses to variable uses.
 		//
 		// from:
@@ -81,12 +81,13 @@ func varnamesFuncDecl(funcDecl ast.Node) bool {
 		// to:
 		//    _16
 		f := func(pos token.Pos) ast.Expr {
-			return newIdent
+			return &ast.Ident{NamePos: pos, Name: newName}
 		}
 		fnot := func(pos token.Pos) ast.Expr {
 			return &ast.UnaryExpr{
-				Op: token.NOT,
-				X:  newIdent,
+				OpPos: pos,
+				Op:    token.NOT,
+				X:     &ast.Ident{NamePos: pos + 1, Name: newName},
 			}
 		}
 		scope := getFuncScope(funcDecl, oldIdent)
@@ -25,6 +25,34 @@ func main() {
 	v_7 = 0
 	v_12 = v_7
 }
+`,
+	},
+	// i=1,
+	{
+		Name: "varnames.1",
+		In: `package main
+
+func main() {
+	var _12 int32
+	var _7 int32
+	// entry:
+	//   store i32 0, i32* %7
+	_7 = 0
+	//   %12 = load i32, i32* %7
+	_12 = _7
+}
+`,
+		Out: `package main
+
+func main() {
+	var v_12 int32
+	var v_7 int32
+	// entry:
+	//   store i32 0, i32* %7
+	v_7 = 0
+	//   %12 = load i32, i32* %7
+	v_12 = v_7
+}
 `,
 	},
 }
@@ -0,0 +1,223 @@
+// The ll2c tool decompiles LLVM IR assembly to C source code (*.ll -> *.c).
+//
+// The input of ll2c is LLVM IR assembly and the output is C99 source code,
+// using the same control flow recovery as ll2go.
+//
+// Usage:
+//
+//    ll2c [OPTION]... FILE.ll...
+//
+// Flags:
+//
+//    -addrs string
+//          comma-separated list of address ranges of functions to parse (e.g. "0x401000-0x402000")
+//    -exclude string
+//          comma-separated list of functions to skip
+//    -exclude-regex string
+//          regular expression of functions to skip
+//    -funcs string
+//          comma-separated list of functions to parse
+//    -funcs-file string
+//          file containing functions to parse, one per line
+//    -funcs-regex string
+//          regular expression of functions to parse
+//    -j int
+//          number of files to process concurrently (default number of CPUs)
+//    -o string
+//          output path (requires a single input file) (default "-")
+//    -outdir string
+//          output directory
+//    -q    suppress non-error messages
+//    -reachable string
+//          comma-separated list of functions from which reachable functions in the call graph are parsed (e.g. "main")
+//
+// Functions are selected as by ll2go.
+//
+// The C source code of a single input file is written to standard output, or
+// to the path specified by -o. When -outdir is set, the C source code of each
+// input file "foo.ll" is written to "foo.c" in the output directory.
+//
+// Control flow primitives are parsed from the graph directory "foo_graphs" of
+// each input file, if present (see restructure), and recovered by control flow
+// analysis otherwise. Unstructured control flow is decompiled into
+// goto-statements. Struct types are recovered as C struct definitions, and
+// declarations of known C standard library functions as includes of their
+// system headers.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/decomp/decomp/backend/cgen"
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/decomp/decomp/internal/funcsel"
+	"github.com/decomp/decomp/internal/manifest"
+	"github.com/decomp/decomp/internal/par"
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/mewkiz/pkg/pathutil"
+	"github.com/mewkiz/pkg/term"
+	"github.com/pkg/errors"
+)
+
+// dbg represents a logger with the "ll2c:" prefix, which logs debug messages
+// to standard error.
+var dbg = log.New(os.Stderr, term.GreenBold("ll2c:")+" ", 0)
+
+func usage() {
+	const use = `
+Decompile LLVM IR assembly to C source code (*.ll -> *.c).
+
+Usage:
+
+	ll2c [OPTION]... FILE.ll...
+
+Flags:
+`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line flags.
+	var (
+		// jobs specifies the number of files to process concurrently.
+		jobs int
+		// output specifies the output path.
+		output string
+		// outDir specifies the output directory.
+		outDir string
+		// quiet specifies whether to suppress non-error messages.
+		quiet bool
+	)
+	flag.IntVar(&jobs, "j", runtime.NumCPU(), "number of files to process concurrently")
+	flag.StringVar(&output, "o", "-", "output path (requires a single input file)")
+	flag.StringVar(&outDir, "outdir", "", "output directory")
+	flag.BoolVar(&quiet, "q", false, "suppress non-error messages")
+	selFlags := funcsel.RegisterFlags(flag.CommandLine)
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if len(outDir) == 0 {
+		if flag.NArg() > 1 {
+			log.Fatal("decompiling multiple input files requires -outdir")
+		}
+	} else if output != "-" {
+		log.Fatal("-o and -outdir are mutually exclusive")
+	}
+	// Parse function selection flags.
+	sel, err := selFlags.Selector(dbg)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	// Mute debug messages if `-q` is set.
+	if quiet {
+		dbg.SetOutput(ioutil.Discard)
+	}
+
+	// Decompile LLVM IR files to C source code.
+	llPaths := flag.Args()
+	srcs := make([][]byte, len(llPaths))
+	err = par.Do(len(llPaths), jobs, func(i int) error {
+		src, err := ll2c(llPaths[i], sel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		srcs[i] = src
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+
+	// Store C source files.
+	if len(outDir) == 0 {
+		if output == "-" {
+			if _, err := os.Stdout.Write(srcs[0]); err != nil {
+				log.Fatalf("%+v", errors.WithStack(err))
+			}
+			return
+		}
+		if err := ioutil.WriteFile(output, srcs[0], 0644); err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		return
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+	for i, src := range srcs {
+		cPath := filepath.Join(outDir, pathutil.FileName(llPaths[i])+".c")
+		dbg.Printf("creating %q", cPath)
+		if err := ioutil.WriteFile(cPath, src, 0644); err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+	}
+}
+
+// ll2c converts the given LLVM IR assembly file into corresponding C source
+// code.
+func ll2c(llPath string, sel *funcsel.Selector) ([]byte, error) {
+	dbg.Printf("parsing file %q.", llPath)
+	module, err := asm.ParseFile(llPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Get functions selected by the function selection flags (e.g. `-funcs`),
+	// or all functions if no selection flags are used.
+	funcs := sel.Funcs(module)
+
+	// Parse control flow primitives from the graph directory of the file, if
+	// present.
+	srcName := pathutil.FileName(llPath)
+	graphsDir := fmt.Sprintf("%s_graphs", srcName)
+	man, err := manifest.Load(graphsDir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	d := &cgen.Decompiler{
+		Funcs: funcs,
+		Prims: func(f *ir.Func) ([]*primitive.Primitive, error) {
+			return parsePrims(graphsDir, f, man)
+		},
+		Logger: dbg,
+	}
+	file, err := d.Decompile(module)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decompile %q", llPath)
+	}
+	buf := &bytes.Buffer{}
+	if err := file.Print(buf); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// parsePrims parses the JSON file containing a mapping of control flow
+// primitives for the given function from the specified graph directory. No
+// primitives are returned, and thus generated by control flow analysis, if the
+// JSON file is not present on the file system, or if it is stale with regards to
+// the manifest of the graph directory.
+func parsePrims(graphsDir string, f *ir.Func, man *manifest.Manifest) ([]*primitive.Primitive, error) {
+	prims, err := man.Prims(graphsDir, f)
+	if err != nil {
+		if errors.Cause(err) != manifest.ErrStale {
+			return nil, errors.WithStack(err)
+		}
+		// Recover primitives by control flow analysis (see cgen.Decompiler).
+		dbg.Printf("WARNING: ignoring %v", err)
+		return nil, nil
+	}
+	return prims, nil
+}
@@ -13,18 +13,60 @@
 //    * foo_graphs/bar.dot
 //    * foo_graphs/baz.dot
 //
+// The graph directory also contains a manifest ("foo_graphs/manifest.json")
+// with content hashes of the LLVM IR of each function. When ll2dot is re-run,
+// only the control flow graphs of functions whose LLVM IR has changed are
+// regenerated, and stale output files of those functions (e.g. control flow
+// primitives "foo_graphs/bar.json" generated by restructure) are removed.
+//
+// When the -callgraph flag is set, ll2dot instead generates the call graph of
+// the module, using one node per function; e.g. "foo_callgraph.dot". External
+// function declarations are represented by dashed box nodes, address-taken
+// functions by double-bordered nodes and potential indirect calls by dashed
+// edges.
+//
 // Usage:
 //
 //    ll2dot [OPTION]... FILE.ll...
 //
 // Flags:
 //
+//    -addrs string
+//          comma-separated list of address ranges of functions to parse (e.g. "0x401000-0x402000")
+//    -callgraph
+//          generate the call graph of the module instead of control flow graphs
+//    -exclude string
+//          comma-separated list of functions to skip
+//    -exclude-regex string
+//          regular expression of functions to skip
 //    -f    force overwrite existing graph directories
 //    -funcs string
 //          comma-separated list of functions to parse
+//    -funcs-file string
+//          file containing functions to parse, one per line
+//    -funcs-regex string
+//          regular expression of functions to parse
+//    -graphviz
+//          use the Graphviz dot tool to generate images
 //    -img
 //          generate an image representation of the control flow graph
+//    -imgfmt string
+//          image format of generated images ("png" or "svg") (default "png")
+//    -j int
+//          number of files and functions to process concurrently (default number of CPUs)
 //    -q    suppress non-error messages
+//    -reachable string
+//          comma-separated list of functions from which reachable functions in the call graph are parsed (e.g. "main")
+//
+// Functions are selected if they match any of -funcs, -funcs-file,
+// -funcs-regex, -addrs and -reachable (or all functions if none are set), and
+// none of -exclude and -exclude-regex. The address of a function is derived
+// from the hexadecimal suffix of its name (e.g. "sub_401000"). Functions of
+// -reachable not defined in an input file are ignored, with a warning. The file
+// of -funcs-file contains one function name per line.
+//
+// Images are rendered by a built-in layered graph layout (see package
+// github.com/decomp/decomp/graph/render), unless -graphviz is set.
 package main
 
 import (
@@ -35,11 +77,17 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
+	"runtime"
 
-	"github.com/graphism/exp/cfg"
+	"github.com/decomp/decomp/graph/callgraph"
+	"github.com/decomp/decomp/graph/cfg"
+	"github.com/decomp/decomp/graph/render"
+	"github.com/decomp/decomp/internal/funcsel"
+	"github.com/decomp/decomp/internal/manifest"
+	"github.com/decomp/decomp/internal/par"
 	"github.com/llir/llvm/asm"
 	"github.com/llir/llvm/ir"
+	"github.com/mewkiz/pkg/osutil"
 	"github.com/mewkiz/pkg/pathutil"
 	"github.com/mewkiz/pkg/term"
 	"github.com/pkg/errors"
@@ -51,6 +99,15 @@ import (
 // to standard error.
 var dbg = log.New(os.Stderr, term.RedBold("ll2dot:")+" ", 0)
 
+// imgOpts specifies how to generate image representations of graphs.
+var imgOpts struct {
+	// graphviz specifies whether to use the Graphviz dot tool to generate
+	// images.
+	graphviz bool
+	// format specifies the image format of generated images.
+	format string
+}
+
 func usage() {
 	const use = `
 Generate control flow graphs from LLVM IR assembly (*.ll -> *.dot).
@@ -68,95 +125,176 @@ Flags:
 func main() {
 	// Parse command line flags.
 	var (
+		// callGraph specifies whether to generate the call graph of the module
+		// instead of control flow graphs.
+		callGraph bool
 		// force specifies whether to force overwrite existing graph directories.
 		force bool
-		// funcs represents a comma-separated list of functions to parse.
-		funcs string
 		// img specifies whether to generate an image representation of the
 		// control flow graph.
 		img bool
+		// jobs specifies the number of files and functions to process
+		// concurrently.
+		jobs int
 		// quiet specifies whether to suppress non-error messages.
 		quiet bool
 	)
+	flag.BoolVar(&callGraph, "callgraph", false, "generate the call graph of the module instead of control flow graphs")
 	flag.BoolVar(&force, "f", false, "force overwrite existing graph directories")
-	flag.StringVar(&funcs, "funcs", "", "comma-separated list of functions to parse")
+	flag.BoolVar(&imgOpts.graphviz, "graphviz", false, "use the Graphviz dot tool to generate images")
 	flag.BoolVar(&img, "img", false, "generate an image representation of the control flow graph")
+	flag.StringVar(&imgOpts.format, "imgfmt", "png", `image format of generated images ("png" or "svg")`)
+	flag.IntVar(&jobs, "j", runtime.NumCPU(), "number of files and functions to process concurrently")
 	flag.BoolVar(&quiet, "q", false, "suppress non-error messages")
+	selFlags := funcsel.RegisterFlags(flag.CommandLine)
 	flag.Usage = usage
 	flag.Parse()
 	if flag.NArg() == 0 {
 		flag.Usage()
 		os.Exit(1)
 	}
-	// Parse functions specified by the `-funcs` flag.
-	funcNames := make(map[string]bool)
-	for _, funcName := range strings.Split(funcs, ",") {
-		if len(funcName) == 0 {
-			continue
-		}
-		funcNames[funcName] = true
+	switch imgOpts.format {
+	case "png", "svg":
+		// valid image format.
+	default:
+		log.Fatalf("invalid image format %q; expected png or svg", imgOpts.format)
+	}
+	// Parse function selection flags.
+	sel, err := selFlags.Selector(dbg)
+	if err != nil {
+		log.Fatalf("%+v", err)
 	}
 	// Mute debug messages if `-q` is set.
 	if quiet {
 		dbg.SetOutput(ioutil.Discard)
 	}
 
-	// Generate control flow graphs from LLVM IR files.
-	for _, llPath := range flag.Args() {
-		if err := ll2dot(llPath, funcNames, force, img); err != nil {
+	// Generate call graphs from LLVM IR files if `-callgraph` is set.
+	llPaths := flag.Args()
+	if callGraph {
+		err = par.Do(len(llPaths), jobs, func(i int) error {
+			return ll2callgraph(llPaths[i], img)
+		})
+		if err != nil {
 			log.Fatalf("%+v", err)
 		}
+		return
+	}
+
+	// Generate control flow graphs from LLVM IR files.
+	pool := par.New(jobs)
+	err = pool.Do(len(llPaths), func(i int) error {
+		return ll2dot(llPaths[i], sel, force, img, pool)
+	})
+	if err != nil {
+		log.Fatalf("%+v", err)
 	}
 }
 
-// ll2dot parses the provided LLVM IR assembly file and generates a control flow
-// graph for each of its defined functions using one node per basic block.
-func ll2dot(llPath string, funcNames map[string]bool, force, img bool) error {
+// ll2callgraph parses the provided LLVM IR assembly file and generates a call
+// graph of the module using one node per function.
+//
+// For a source file "foo.ll" the call graph is stored in "foo_callgraph.dot".
+func ll2callgraph(llPath string, img bool) error {
 	dbg.Printf("parsing file %q.", llPath)
 	module, err := asm.ParseFile(llPath)
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	g := callgraph.New(module)
+	name := pathutil.FileName(llPath)
+	dotPath := pathutil.TrimExt(llPath) + "_callgraph.dot"
+	if err := storeDOT(g, name, dotPath, img); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
 
-	// Get functions set by `-funcs` or all functions if `-funcs` not used.
-	var funcs []*ir.Func
-	for _, f := range module.Funcs {
-		if len(funcNames) > 0 && !funcNames[f.Name()] {
-			dbg.Printf("skipping function %q.", f.Ident())
-			continue
-		}
-		funcs = append(funcs, f)
+// ll2dot parses the provided LLVM IR assembly file and generates a control flow
+// graph for each of its defined functions using one node per basic block. The
+// functions are processed concurrently by the workers of the given pool.
+func ll2dot(llPath string, sel *funcsel.Selector, force, img bool, pool *par.Pool) error {
+	dbg.Printf("parsing file %q.", llPath)
+	module, err := asm.ParseFile(llPath)
+	if err != nil {
+		return errors.WithStack(err)
 	}
 
+	// Get functions selected by the function selection flags (e.g. `-funcs`),
+	// or all functions if no selection flags are used.
+	funcs := sel.Funcs(module)
+
 	// Generate a control flow graph for each function.
 	dotDir, err := createDotDir(llPath, force)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	for _, f := range funcs {
+	man, err := manifest.Load(dotDir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// Remove output files of functions no longer present in the module.
+	moduleFuncs := funcsByName(module)
+	for funcName := range man.Funcs {
+		if _, ok := moduleFuncs[funcName]; ok {
+			continue
+		}
+		dbg.Printf("removing output files of function %q.", funcName)
+		if err := removeOutput(dotDir, funcName, true); err != nil {
+			return errors.WithStack(err)
+		}
+		delete(man.Funcs, funcName)
+	}
+	hashes := make([]string, len(funcs))
+	for i, f := range funcs {
+		hashes[i] = manifest.Hash(f)
+	}
+	err = pool.Do(len(funcs), func(i int) error {
+		f := funcs[i]
 		// Skip function declarations.
 		if len(f.Blocks) == 0 {
-			continue
+			return nil
+		}
+		// Skip functions whose LLVM IR is unchanged since the last run.
+		if man.UpToDate(f.Name(), hashes[i]) && hasOutput(dotDir, f.Name(), img) {
+			dbg.Printf("skipping unchanged function %q.", f.Ident())
+			return nil
+		}
+		// Remove stale output files (e.g. control flow primitives generated by
+		// restructure) of the function.
+		if err := removeOutput(dotDir, f.Name(), false); err != nil {
+			return errors.WithStack(err)
 		}
 
 		// Generate control flow graph.
 		dbg.Printf("parsing function %q.", f.Ident())
-		g := cfg.NewGraphFromFunc(f)
+		g := cfg.New(f)
 
 		// Store DOT graph.
 		if err := storeCFG(g, f.Name(), dotDir, img); err != nil {
 			return errors.WithStack(err)
 		}
+		return nil
+	})
+	if err != nil {
+		return errors.WithStack(err)
 	}
-	return nil
+	// Update manifest.
+	for i, f := range funcs {
+		if len(f.Blocks) == 0 {
+			continue
+		}
+		man.Funcs[f.Name()] = hashes[i]
+	}
+	return man.Store(dotDir)
 }
 
 // createDotDir creates and returns an output directory based on the path of the
 // given LLVM IR file.
 //
-// For a source file "foo.ll" the output directory "foo_graphs/" is created. If
-// the `-force` flag is set, existing graph directories are overwritten by
-// force.
+// For a source file "foo.ll" the output directory "foo_graphs/" is created,
+// unless already present. If the `-force` flag is set, existing graph
+// directories are overwritten by force.
 func createDotDir(llPath string, force bool) (string, error) {
 	dotDir := pathutil.TrimExt(llPath) + "_graphs"
 	if force {
@@ -165,12 +303,52 @@ func createDotDir(llPath string, force bool) (string, error) {
 			return "", errors.WithStack(err)
 		}
 	}
-	if err := os.Mkdir(dotDir, 0755); err != nil {
+	if err := os.MkdirAll(dotDir, 0755); err != nil {
 		return "", errors.WithStack(err)
 	}
 	return dotDir, nil
 }
 
+// hasOutput reports whether the output files of the given function are present
+// in the graph directory. If img is set, the image representation of the
+// control flow graph is also required.
+func hasOutput(dotDir, funcName string, img bool) bool {
+	if !osutil.Exists(filepath.Join(dotDir, funcName+".dot")) {
+		return false
+	}
+	if img && !osutil.Exists(filepath.Join(dotDir, funcName+"."+imgOpts.format)) {
+		return false
+	}
+	return true
+}
+
+// removeOutput removes the stale output files of the given function from the
+// graph directory; i.e. control flow primitives and images. If all is set, the
+// DOT file of the function is also removed.
+func removeOutput(dotDir, funcName string, all bool) error {
+	exts := []string{".json", ".png", ".svg"}
+	if all {
+		exts = append(exts, ".dot")
+	}
+	for _, ext := range exts {
+		path := filepath.Join(dotDir, funcName+ext)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// funcsByName returns a map from function name to function of the given
+// module.
+func funcsByName(module *ir.Module) map[string]*ir.Func {
+	m := make(map[string]*ir.Func, len(module.Funcs))
+	for _, f := range module.Funcs {
+		m[f.Name()] = f
+	}
+	return m
+}
+
 // storeCFG stores the given control flow graph as a DOT file. If `-img` is set,
 // it also stores an image representation of the control flow graph.
 //
@@ -180,27 +358,64 @@ func createDotDir(llPath string, force bool) (string, error) {
 //    foo_graphs/bar.dot
 //    foo_graphs/baz.dot
 func storeCFG(g graph.Directed, funcName, dotDir string, img bool) error {
-	buf, err := dot.Marshal(g, fmt.Sprintf("%q", funcName), "", "\t")
+	dotName := funcName + ".dot"
+	dotPath := filepath.Join(dotDir, dotName)
+	return storeDOT(g, funcName, dotPath, img)
+}
+
+// storeDOT stores the given graph as a DOT file. If `-img` is set, it also
+// stores an image representation of the graph, using the same file name but
+// with the extension of the image format (e.g. ".png").
+func storeDOT(g graph.Directed, name, dotPath string, img bool) error {
+	buf, err := dot.Marshal(g, fmt.Sprintf("%q", name), "", "\t")
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	dotName := funcName + ".dot"
-	dotPath := filepath.Join(dotDir, dotName)
 	dbg.Printf("creating file %q.", dotPath)
 	if err := ioutil.WriteFile(dotPath, buf, 0644); err != nil {
 		return errors.WithStack(err)
 	}
-	// Store an image representation of the CFG if `-img` is set.
+	// Store an image representation of the graph if `-img` is set.
 	if img {
-		pngName := funcName + ".png"
-		pngPath := filepath.Join(dotDir, pngName)
-		dbg.Printf("creating file %q.", pngPath)
-		cmd := exec.Command("dot", "-Tpng", "-o", pngPath, dotPath)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
+		imgPath := pathutil.TrimExt(dotPath) + "." + imgOpts.format
+		dbg.Printf("creating file %q.", imgPath)
+		if imgOpts.graphviz {
+			// Use Graphviz to render image.
+			cmd := exec.Command("dot", "-T"+imgOpts.format, "-o", imgPath, dotPath)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return errors.WithStack(err)
+			}
+			return nil
+		}
+		if err := storeImage(g, imgPath, imgOpts.format); err != nil {
 			return errors.WithStack(err)
 		}
 	}
 	return nil
 }
+
+// storeImage stores an image representation of the given graph, using the
+// built-in graph layout.
+func storeImage(g graph.Directed, imgPath, format string) error {
+	f, err := os.Create(imgPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	layout := render.NewLayout(g)
+	switch format {
+	case "svg":
+		err = layout.WriteSVG(f)
+	default:
+		err = layout.WritePNG(f)
+	}
+	if err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -9,41 +9,111 @@
 //
 // Flags:
 //
+//    -addrs string
+//          comma-separated list of address ranges of functions to parse (e.g. "0x401000-0x402000")
+//    -check
+//          fail on type errors in the decompiled Go source code
+//    -exclude string
+//          comma-separated list of functions to skip
+//    -exclude-regex string
+//          regular expression of functions to skip
 //    -funcs string
 //          comma-separated list of functions to parse
+//    -funcs-file string
+//          file containing functions to parse, one per line
+//    -funcs-regex string
+//          regular expression of functions to parse
+//    -ir
+//          annotate Go statements with the originating LLVM IR instructions as comments
+//    -j int
+//          number of files and functions to process concurrently (default number of CPUs)
+//    -layout
+//          assert the size and field offsets of Go struct types at compile time
+//    -libc string
+//          JSON file mapping C standard library functions to Go functions
+//    -mod string
+//          module path of go.mod file to create in the output directory
+//    -o string
+//          output path (requires a single input file) (default "-")
+//    -outdir string
+//          output directory
 //    -q    suppress non-error messages
+//    -reachable string
+//          comma-separated list of functions from which reachable functions in the call graph are parsed (e.g. "main")
+//    -split int
+//          maximum number of functions per output file (requires -outdir; 0 disables splitting)
+//    -srcmap
+//          write source maps from the Go source code to LLVM IR (requires -o or -outdir)
+//    -types string
+//          JSON file naming struct types and fields
+//
+// Functions are selected if they match any of -funcs, -funcs-file,
+// -funcs-regex, -addrs and -reachable (or all functions if none are set), and
+// none of -exclude and -exclude-regex. The address of a function is derived
+// from the hexadecimal suffix of its name (e.g. "sub_401000"). Functions of
+// -reachable not defined in an input file are ignored, with a warning. The file
+// of -funcs-file contains one function name per line.
+//
+// The Go source code of a single input file is written to standard output, or
+// to the path specified by -o. When -outdir is set, the Go source code of each
+// input file "foo.ll" is written to "foo.go" in the output directory, or to
+// "foo/foo.go" when decompiling multiple input files. Large modules may be split
+// into several files using -split, and a go.mod file is created if -mod is set;
+// together with a copy of the runtime support packages (e.g. rt/eh) in the rt
+// subdirectory if imported.
+//
+// Source names of functions, parameters, local variables, global variables,
+// struct types and struct fields are recovered from debug metadata when present
+// (e.g. in LLVM IR emitted by clang -g). Type and field names may be supplied
+// using -types, which specifies a JSON file mapping LLVM IR type names to Go
+// names, and takes precedence over debug metadata.
+//
+//    {"struct.point": {"name": "Point", "fields": ["X", "Y"]}}
+//
+// Go struct types preserve the field offsets and size of LLVM IR struct types,
+// as specified by the data layout of the module, by explicit padding fields.
+// Layouts which cannot be preserved (e.g. misaligned fields of packed structs)
+// are reported as warnings. When -layout is set, the layout of each Go struct
+// type is asserted at compile time.
+//
+// Calls to known C standard library functions are rewritten to Go equivalents
+// (e.g. printf to fmt.Printf) or to the libc runtime support package. The
+// mapping is extended using -libc, which specifies a JSON file mapping function
+// names to qualified Go function names (e.g. {"rand": "math/rand.Int31"}).
+//
+// The decompiled Go source code is type-checked using go/types. Missing
+// conversions are inserted automatically, and remaining type errors are marked
+// by "ll2go:" comments; or reported as errors if -check is set.
+//
+// When -ir is set, each Go statement is preceded by a comment of its
+// originating LLVM IR instruction, and the first statement of each basic block
+// by its label. The comments are retained by the rewrites of go-post.
+//
+// When -srcmap is set, the source map of each Go source file "foo.go" is written
+// to "foo.go.map"; a JSON file mapping the line and column ranges of Go
+// statements to the function, basic block and instruction of their originating
+// LLVM IR. Source maps are updated by go-post as it rewrites the Go source code.
 package main
 
 import (
-	"bufio"
-	"encoding/json"
-	goerrors "errors"
 	"flag"
 	"fmt"
-	"go/ast"
-	"go/printer"
-	"go/token"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
-	"sort"
-	"strings"
-	"unicode"
+	"runtime"
 
-	"github.com/decomp/decomp/cfa"
+	"github.com/decomp/decomp/backend/gogen"
 	"github.com/decomp/decomp/cfa/primitive"
-	"github.com/decomp/decomp/graph/cfg"
+	"github.com/decomp/decomp/internal/funcsel"
+	"github.com/decomp/decomp/internal/manifest"
+	"github.com/decomp/decomp/internal/par"
 	"github.com/llir/llvm/asm"
 	"github.com/llir/llvm/ir"
-	"github.com/llir/llvm/ir/constant"
-	irtypes "github.com/llir/llvm/ir/types"
-	"github.com/llir/llvm/ir/value"
-	"github.com/mewkiz/pkg/osutil"
 	"github.com/mewkiz/pkg/pathutil"
 	"github.com/mewkiz/pkg/term"
 	"github.com/pkg/errors"
-	"gonum.org/v1/gonum/graph"
 )
 
 // dbg represents a logger with the "ll2go:" prefix, which logs debug messages
@@ -67,622 +137,203 @@ Flags:
 func main() {
 	// Parse command line flags.
 	var (
-		// funcs represents a comma-separated list of functions to parse.
-		funcs string
+		// check specifies whether to fail on type errors in the decompiled Go
+		// source code.
+		check bool
+		// irComments specifies whether to annotate Go statements with the
+		// originating LLVM IR instructions as comments.
+		irComments bool
+		// srcMap specifies whether to write source maps from the Go source code
+		// to LLVM IR.
+		srcMap bool
+		// jobs specifies the number of files and functions to process
+		// concurrently.
+		jobs int
+		// layout specifies whether to assert the size and field offsets of Go
+		// struct types at compile time.
+		layout bool
+		// libcMap specifies a JSON file mapping C standard library functions to
+		// Go functions.
+		libcMap string
+		// modPath specifies the module path of the go.mod file to create in the
+		// output directory.
+		modPath string
+		// output specifies the output path.
+		output string
+		// outDir specifies the output directory.
+		outDir string
 		// quiet specifies whether to suppress non-error messages.
 		quiet bool
+		// split specifies the maximum number of functions per output file.
+		split int
+		// typesPath specifies a JSON file naming struct types and fields.
+		typesPath string
 	)
-	flag.StringVar(&funcs, "funcs", "", "comma-separated list of functions to parse")
+	flag.BoolVar(&check, "check", false, "fail on type errors in the decompiled Go source code")
+	flag.BoolVar(&irComments, "ir", false, "annotate Go statements with the originating LLVM IR instructions as comments")
+	flag.IntVar(&jobs, "j", runtime.NumCPU(), "number of files and functions to process concurrently")
+	flag.BoolVar(&layout, "layout", false, "assert the size and field offsets of Go struct types at compile time")
+	flag.StringVar(&libcMap, "libc", "", "JSON file mapping C standard library functions to Go functions")
+	flag.StringVar(&modPath, "mod", "", "module path of go.mod file to create in the output directory")
+	flag.StringVar(&output, "o", "-", "output path (requires a single input file)")
+	flag.StringVar(&outDir, "outdir", "", "output directory")
 	flag.BoolVar(&quiet, "q", false, "suppress non-error messages")
+	flag.BoolVar(&srcMap, "srcmap", false, "write source maps from the Go source code to LLVM IR (requires -o or -outdir)")
+	flag.IntVar(&split, "split", 0, "maximum number of functions per output file (requires -outdir; 0 disables splitting)")
+	flag.StringVar(&typesPath, "types", "", "JSON file naming struct types and fields")
+	selFlags := funcsel.RegisterFlags(flag.CommandLine)
 	flag.Usage = usage
 	flag.Parse()
 	if flag.NArg() == 0 {
 		flag.Usage()
 		os.Exit(1)
 	}
-	// Parse specified functions if `-funcs` is set.
-	funcNames := make(map[string]bool)
-	for _, funcName := range strings.Split(funcs, ",") {
-		if len(funcName) == 0 {
-			continue
+	if len(outDir) == 0 {
+		if flag.NArg() > 1 {
+			log.Fatal("decompiling multiple input files requires -outdir")
 		}
-		funcNames[funcName] = true
-	}
-	// Mute debug messages if `-q` is set.
-	if quiet {
-		dbg.SetOutput(ioutil.Discard)
-	}
-
-	// Decompile LLVM IR files to Go source code.
-	for _, llPath := range flag.Args() {
-		file, err := ll2go(llPath, funcNames)
-		if err != nil {
-			log.Fatalf("%+v", err)
+		if split > 0 || len(modPath) > 0 {
+			log.Fatal("-split and -mod require -outdir")
 		}
-		// TODO: Remove debug output.
-		if err := printer.Fprint(os.Stdout, token.NewFileSet(), file); err != nil {
-			log.Fatalf("%+v", err)
+		if srcMap && output == "-" {
+			log.Fatal("-srcmap requires -o or -outdir")
 		}
-		fmt.Println()
+	} else if output != "-" {
+		log.Fatal("-o and -outdir are mutually exclusive")
 	}
-}
-
-// ll2go converts the given LLVM IR assembly file into a corresponding Go source
-// file.
-func ll2go(llPath string, funcNames map[string]bool) (*ast.File, error) {
-	dbg.Printf("parsing file %q.", llPath)
-	module, err := asm.ParseFile(llPath)
+	// Parse function selection flags.
+	sel, err := selFlags.Selector(dbg)
 	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-
-	// Get functions set by `-funcs` or all functions if `-funcs` not used.
-	var funcs []*ir.Func
-	for _, f := range module.Funcs {
-		if len(funcNames) > 0 && !funcNames[f.Name()] {
-			dbg.Printf("skipping function %q.", f.Ident())
-			continue
-		}
-		funcs = append(funcs, f)
-	}
-
-	// Recover type definitions.
-	srcName := pathutil.FileName(llPath)
-	file := &ast.File{}
-	d := newDecompiler()
-	for _, t := range module.TypeDefs {
-		typ := d.typeDef(t)
-		file.Decls = append(file.Decls, typ)
-	}
-
-	// Recover global variables.
-	for _, g := range module.Globals {
-		global := d.globalDecl(g)
-		file.Decls = append(file.Decls, global)
-	}
-
-	// Recover functions.
-	var hasMain bool
-	for _, f := range funcs {
-		if f.Name() == "main" {
-			hasMain = true
-		}
-		var prims []*primitive.Primitive
-		if len(f.Blocks) > 0 {
-			// TODO: Clean up parsing of primitives.
-			//
-			//    1. Check if JSON file present on file system
-			//    2. If present, parse prims from file and log to dbg that
-			//       primitives are read from the JSON file.
-			//    3. If not present, perform control flow analysis in memory.
-			//
-			// Move parts shared between restructure and ll2go to decomp/cfa.
-			prims, err = parsePrims(srcName, f)
-			if err != nil {
-				return nil, errors.WithStack(err)
-			}
-		}
-		dbg.Printf("decompiling function %q.", f.Ident())
-		fn, err := d.funcDecl(f, prims)
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
-		file.Decls = append(file.Decls, fn)
-	}
-
-	// Add newIntNNN function declarations.
-	var newIntSizes []uint64
-	for newIntSize := range d.newIntSizes {
-		newIntSizes = append(newIntSizes, newIntSize)
-	}
-	for _, newIntSize := range newIntSizes {
-		x := ast.NewIdent("x")
-		intType := ast.NewIdent(fmt.Sprintf("int%d", newIntSize))
-		param := &ast.Field{
-			Names: []*ast.Ident{x},
-			Type:  intType,
-		}
-		retType := &ast.StarExpr{X: intType}
-		result := &ast.Field{
-			Type: retType,
-		}
-		sig := &ast.FuncType{
-			Params:  &ast.FieldList{List: []*ast.Field{param}},
-			Results: &ast.FieldList{List: []*ast.Field{result}},
-		}
-		expr := &ast.UnaryExpr{
-			Op: token.AND,
-			X:  x,
-		}
-		returnStmt := &ast.ReturnStmt{
-			Results: []ast.Expr{expr},
-		}
-		body := &ast.BlockStmt{
-			List: []ast.Stmt{returnStmt},
-		}
-		name := fmt.Sprintf("newInt%d", newIntSize)
-		fn := &ast.FuncDecl{
-			Name: ast.NewIdent(name),
-			Type: sig,
-			Body: body,
-		}
-		file.Decls = append(file.Decls, fn)
-	}
-
-	// Add types not part of builtin.
-	var intSizes []uint64
-	for intSize := range d.intSizes {
-		switch intSize {
-		case 8, 16, 32, 64:
-			// already builtin type of Go.
-		default:
-			intSizes = append(intSizes, intSize)
-		}
-	}
-	sort.Slice(intSizes, func(i, j int) bool {
-		return intSizes[i] < intSizes[j]
-	})
-	for _, intSize := range intSizes {
-		typeName := fmt.Sprintf("int%d", intSize)
-		var underlying string
-		switch {
-		case intSize < 8:
-			underlying = "int8"
-		case intSize < 16:
-			underlying = "int16"
-		case intSize < 32:
-			underlying = "int32"
-		case intSize < 64:
-			underlying = "int64"
-		default:
-			return nil, errors.Errorf("support for integer type with bit size %d not yet implemented", intSize)
-		}
-		spec := &ast.TypeSpec{
-			Name: ast.NewIdent(typeName),
-			Type: ast.NewIdent(underlying),
-		}
-		typeDecl := &ast.GenDecl{
-			Tok:   token.TYPE,
-			Specs: []ast.Spec{spec},
-		}
-		file.Decls = append(file.Decls, typeDecl)
-	}
-
-	// Set package name.
-	if hasMain {
-		file.Name = ast.NewIdent("main")
-	} else {
-		file.Name = ident(srcName)
-	}
-
-	return file, nil
-}
-
-// A decompiler keeps track of relevant information during the decompilation
-// process.
-type decompiler struct {
-	// Global states.
-
-	// Tracks use of integer types not part of Go builtin.
-	intSizes map[uint64]bool
-	// Tracks use of newIntNNN function calls.
-	newIntSizes map[uint64]bool
-
-	// Per function states.
-
-	// Map from basic block label to conceptual basic block.
-	blocks map[string]*basicBlock
-	// Track use of basic block labels.
-	labels map[string]bool
-}
-
-// newDecompiler returns a new decompiler.
-func newDecompiler() *decompiler {
-	return &decompiler{
-		intSizes:    make(map[uint64]bool),
-		newIntSizes: make(map[uint64]bool),
-	}
-}
-
-// typeDef converts the given LLVM IR type into a corresponding Go type
-// definition.
-func (d *decompiler) typeDef(t irtypes.Type) *ast.GenDecl {
-	spec := &ast.TypeSpec{
-		Name: d.typeIdent(t.Name()),
-		Type: d.goTypeDef(t),
-	}
-	return &ast.GenDecl{
-		Tok:   token.TYPE,
-		Specs: []ast.Spec{spec},
-	}
-}
-
-// globalDecl converts the given LLVM IR global into a corresponding Go variable
-// declaration.
-func (d *decompiler) globalDecl(g *ir.Global) *ast.GenDecl {
-	spec := &ast.ValueSpec{
-		Names: []*ast.Ident{d.globalIdent(g.Name())},
-		Type:  d.goType(g.Typ),
-	}
-	if g.Init != nil {
-		// handle value initialization of global definition.
-		spec.Values = []ast.Expr{d.pointerToConst(g.Init)}
-	}
-	return &ast.GenDecl{
-		Tok:   token.VAR,
-		Specs: []ast.Spec{spec},
+		log.Fatalf("%+v", err)
 	}
-}
-
-// pointerToConst converts the given LLVM IR constant to a pointer to c and
-// returns the corresponding Go expression.
-func (d *decompiler) pointerToConst(c constant.Constant) ast.Expr {
-	switch c := c.(type) {
-	// Simple constants
-	case *constant.Int:
-		callee := fmt.Sprintf("newInt%d", c.Typ.BitSize)
-		d.newIntSizes[c.Typ.BitSize] = true
-		return &ast.CallExpr{
-			Fun:  ast.NewIdent(callee),
-			Args: []ast.Expr{d.value(c)},
-		}
-	case *constant.Float:
-		panic("support for value *constant.Float not yet implemented")
-	case *constant.Null:
-		// nothing to do.
-		return d.value(c)
-	// Complex constants
-	case *constant.Vector, *constant.Array, *constant.Struct:
-		return &ast.UnaryExpr{
-			Op: token.AND,
-			X:  d.value(c),
-		}
-	case *constant.ZeroInitializer:
-		return &ast.CallExpr{
-			Fun:  ast.NewIdent("new"),
-			Args: []ast.Expr{d.goType(c.Typ)},
+	// Extend the lowering of C standard library functions.
+	if len(libcMap) > 0 {
+		if err := gogen.LoadLibcMap(libcMap); err != nil {
+			log.Fatalf("%+v", err)
 		}
-	// Global variable and function addresses
-	case *ir.Global:
-		// TODO: Check if `&g` should be returned instead of `g`.
-		return d.globalIdent(c.Name())
-	case *ir.Func:
-		// TODO: Check if `&f` should be returned instead of `f`.
-		return d.globalIdent(c.Name())
-	// Constant expressions
-	case constant.Expression:
-		return d.expr(c)
-	default:
-		panic(fmt.Sprintf("support for value %T not yet implemented", c))
 	}
-}
-
-// funcDecl converts the given LLVM IR function into a corresponding Go function
-// declaration.
-func (d *decompiler) funcDecl(f *ir.Func, prims []*primitive.Primitive) (*ast.FuncDecl, error) {
-	// Force generate local IDs.
-	_ = f.String()
-
-	// Recover function declaration.
-	typ := d.goType(f.Sig)
-	sig := typ.(*ast.FuncType)
-	for i, p := range f.Params {
-		paramName := d.localIdent(p.Name())
-		if len(sig.Params.List[i].Names) < 1 {
-			sig.Params.List[i].Names = make([]*ast.Ident, 1)
+	// Parse type annotations.
+	var types map[string]*gogen.TypeAnnotation
+	if len(typesPath) > 0 {
+		var err error
+		if types, err = gogen.LoadTypeAnnotations(typesPath); err != nil {
+			log.Fatalf("%+v", err)
 		}
-		sig.Params.List[i].Names[0] = paramName
 	}
-	fn := &ast.FuncDecl{
-		Name: d.globalIdent(f.Name()),
-		Type: sig,
-	}
-	if len(f.Blocks) == 0 {
-		return fn, nil
-	}
-
-	// Reset labels tracker.
-	d.labels = make(map[string]bool)
-
-	// Reset basic block mapping.
-	d.blocks = make(map[string]*basicBlock)
-	for i, block := range f.Blocks {
-		d.blocks[block.Name()] = &basicBlock{Block: block, num: i}
-	}
-
-	// Record outgoing PHI values.
-	for _, block := range f.Blocks {
-		for _, inst := range block.Insts {
-			phi, ok := inst.(*ir.InstPhi)
-			if !ok {
-				continue
-			}
-			// The incoming values of PHI instructions are propagated as assignment
-			// statements to the predecessor basic blocks of the incoming values.
-			for _, inc := range phi.Incs {
-				pred := d.blocks[inc.Pred.(value.Named).Name()]
-				assignStmt := d.assign(phi.Name(), d.value(inc.X))
-				pred.out = append(pred.out, assignStmt)
-			}
-		}
+	// Mute debug messages if `-q` is set.
+	if quiet {
+		dbg.SetOutput(ioutil.Discard)
 	}
 
-	// Recover control flow primitives.
-	for _, prim := range prims {
-		block, err := d.prim(prim)
+	// Decompile LLVM IR files to Go source code.
+	llPaths := flag.Args()
+	files := make([]*goFile, len(llPaths))
+	pool := par.New(jobs)
+	err = pool.Do(len(llPaths), func(i int) error {
+		file, err := ll2go(llPaths[i], sel, pool, check, irComments, srcMap, layout, types)
 		if err != nil {
-			return nil, errors.WithStack(err)
-		}
-		// Delete merged basic blocks.
-		for _, node := range prim.Nodes {
-			delete(d.blocks, node)
+			return errors.WithStack(err)
 		}
-		// Add primitive basic block.
-		d.blocks[block.Name()] = block
-	}
-
-	// A single remaining basic block indicates successful control flow recovery.
-	// If more than one basic block remains, unstructured control flow is added
-	// using goto-statements.
-	var blocks basicBlocks
-	for _, block := range d.blocks {
-		blocks = append(blocks, block)
-	}
-	sort.Sort(blocks)
-	for _, block := range blocks {
-		block.stmts = d.stmts(block)
-		block.stmts = append(block.stmts, d.term(block.Term))
+		files[i] = file
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("%+v", err)
 	}
 
-	// Insert labels of target branches into corresponding basic blocks.
-	for label := range d.labels {
-		// Insert label.
-		block, ok := d.blocks[label]
-		if !ok {
-			return nil, errors.Errorf("unable to locate basic block %q", label)
-		}
-		if len(block.stmts) < 1 {
-			// A terminator statement should always be present.
-			return nil, errors.New("empty basic block; expected at least 1 statement")
-		}
-		labelStmt := &ast.LabeledStmt{
-			Label: d.label(block.Name()),
-			Stmt:  block.stmts[0],
+	// Store Go source files.
+	if len(outDir) == 0 {
+		if err := writeFile(output, files[0]); err != nil {
+			log.Fatalf("%+v", err)
 		}
-		block.stmts[0] = labelStmt
-	}
-
-	var stmts []ast.Stmt
-	for _, block := range blocks {
-		stmts = append(stmts, block.stmts...)
+		return
 	}
-	body := &ast.BlockStmt{
-		List: stmts,
-	}
-	fn.Body = body
-	return fn, nil
-}
-
-// globalIdent converts the given LLVM IR type identifier to a corresponding Go
-// identifier.
-func (d *decompiler) typeIdent(name string) *ast.Ident {
-	if isID(name) {
-		name = "_" + name
-	}
-	return ident(name)
-}
-
-// globalIdent converts the given LLVM IR global identifier to a corresponding
-// Go identifier.
-func (d *decompiler) globalIdent(name string) *ast.Ident {
-	if isID(name) {
-		name = "_" + name
-	}
-	return ident(name)
-}
-
-// localIdent converts the given LLVM IR local identifier to a corresponding Go
-// identifier.
-func (d *decompiler) localIdent(name string) *ast.Ident {
-	if isID(name) {
-		name = "_" + name
-	}
-	return ident(name)
-}
-
-// isID reports if the given string is an unnamed identifier.
-func isID(s string) bool {
-	for _, r := range s {
-		if !strings.ContainsRune("0123456789", r) {
-			return false
+	for i, file := range files {
+		srcName := pathutil.FileName(llPaths[i])
+		// Store each module as a separate package when decompiling multiple
+		// input files.
+		dir := outDir
+		if len(files) > 1 {
+			dir = filepath.Join(outDir, srcName)
 		}
-	}
-	return true
-}
-
-// ident returns a sanitized version of the given identifier.
-func ident(s string) *ast.Ident {
-	s = strings.Replace(s, ".", "dot", -1)
-	f := func(r rune) rune {
-		switch {
-		case unicode.IsLetter(r), unicode.IsNumber(r):
-			// valid rune in identifier.
-			return r
+		if err := writePackage(dir, file, srcName, split); err != nil {
+			log.Fatalf("%+v", err)
 		}
-		return '_'
 	}
-	return ast.NewIdent(strings.Map(f, s))
-}
-
-// label converts the given LLVM IR basic block label to a corresponding Go
-// identifier.
-func (d *decompiler) label(name string) *ast.Ident {
-	name = "block_" + name
-	return ident(name)
-}
-
-// value converts the given LLVM IR value to a corresponding Go expression.
-func (d *decompiler) value(v value.Value) ast.Expr {
-	switch v := v.(type) {
-	case value.Named:
-		switch v.(type) {
-		case *ir.Global, *ir.Func:
-			return d.globalIdent(v.Name())
-		default:
-			return d.localIdent(v.Name())
+	if len(modPath) > 0 {
+		if err := writeGoMod(outDir, modPath, files); err != nil {
+			log.Fatalf("%+v", err)
 		}
-	case constant.Constant:
-		return d.constant(v)
-	default:
-		panic(fmt.Sprintf("support for value %T not yet implemented", v))
 	}
 }
 
-// intLit converts the given integer literal into a corresponding Go expression.
-func (d *decompiler) intLit(i int64) ast.Expr {
-	return &ast.BasicLit{
-		Kind:  token.INT,
-		Value: fmt.Sprintf("%d", i),
-	}
-}
-
-// uintLit converts the given unsigned integer literal into a corresponding Go
-// expression.
-func (d *decompiler) uintLit(i uint64) ast.Expr {
-	return &ast.BasicLit{
-		Kind:  token.INT,
-		Value: fmt.Sprintf("%d", i),
+// ll2go converts the given LLVM IR assembly file into a corresponding Go source
+// file. The functions of the file are decompiled concurrently by the workers of
+// the given pool. Type errors of the Go source file are reported as errors if
+// check is set, and marked by comments otherwise. Go statements are annotated
+// with comments of their originating LLVM IR instructions if irComments is set,
+// and tracked for source maps if srcMap is set. The layout of Go struct types is
+// asserted if layout is set, and types and fields named by the given type
+// annotations.
+func ll2go(llPath string, sel *funcsel.Selector, pool *par.Pool, check, irComments, srcMap, layout bool, types map[string]*gogen.TypeAnnotation) (*goFile, error) {
+	dbg.Printf("parsing file %q.", llPath)
+	module, err := asm.ParseFile(llPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
-}
-
-// basicBlock represents a conceptual basic block, that may contain both LLVM IR
-// instructions and Go statements.
-type basicBlock struct {
-	*ir.Block
-	// Go statements.
-	stmts []ast.Stmt
-	// Outgoing values for PHI instructions. In other words, a list of assignment
-	// statements to appear at the end of the basic block.
-	out []ast.Stmt
-	// Track basic block number in f.Blocks slice, to be used for sorting basic
-	// blocks after incomplete control flow recovery.
-	num int
-}
-
-// basicBlocks implements the sort.Sort interface to sort basic blocks according
-// to their occurrence in f.Blocks.
-type basicBlocks []*basicBlock
 
-func (bs basicBlocks) Less(i, j int) bool { return bs[i].num < bs[j].num }
-func (bs basicBlocks) Len() int           { return len(bs) }
-func (bs basicBlocks) Swap(i, j int)      { bs[i], bs[j] = bs[j], bs[i] }
+	// Get functions selected by the function selection flags (e.g. `-funcs`),
+	// or all functions if no selection flags are used.
+	funcs := sel.Funcs(module)
 
-// stmts converts the basic block instructions, recorded statements and outgoing
-// PHI values into a corresponding list of Go statements.
-func (d *decompiler) stmts(block *basicBlock) []ast.Stmt {
-	var stmts []ast.Stmt
-	stmts = append(stmts, d.insts(block.Insts)...)
-	stmts = append(stmts, block.stmts...)
-	stmts = append(stmts, block.out...)
-	return stmts
-}
-
-// parsePrims parses the JSON file containing a mapping of control flow
-// primitives for the given function.
-func parsePrims(srcName string, f *ir.Func) ([]*primitive.Primitive, error) {
+	// Parse control flow primitives from the graph directory of the file, if
+	// present.
+	srcName := pathutil.FileName(llPath)
 	graphsDir := fmt.Sprintf("%s_graphs", srcName)
-	jsonName := f.Name() + ".json"
-	jsonPath := filepath.Join(graphsDir, jsonName)
-	// Generate primitives if not present on file system.
-	if !osutil.Exists(jsonPath) {
-		prims, err := genPrims(f)
-		if err != nil {
-			if errors.Cause(err) == ErrIncomplete {
-				dbg.Printf("WARNING: incomplete control flow recovery of %q", f.Ident())
-			} else {
-				return nil, errors.WithStack(err)
-			}
-		}
-		return prims, nil
-	}
-	// Parse primitives from file system.
-	var prims []*primitive.Primitive
-	fr, err := os.Open(jsonPath)
+	man, err := manifest.Load(graphsDir)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	defer fr.Close()
-	r := bufio.NewReader(fr)
-	dec := json.NewDecoder(r)
-	if err := dec.Decode(&prims); err != nil {
-		return nil, errors.WithStack(err)
-	}
-	return prims, nil
-}
-
-// ErrIncomplete signals an incomplete control flow recovery.
-var ErrIncomplete = goerrors.New("incomplete control flow recovery")
-
-// locateEntryNode attempts to locate the entry node of the control flow graph
-// by searching for a single node in the control flow graph with no incoming
-// edges.
-func locateEntryNode(g *cfg.Graph) (graph.Node, error) {
-	var entry graph.Node
-	nodes := g.Nodes()
-	for nodes.Next() {
-		n := nodes.Node()
-		preds := g.To(n.ID())
-		if preds.Len() == 0 {
-			if entry != nil {
-				return nil, errors.Errorf("more than one candidate for the entry node located; prev %q, new %q", label(entry), label(n))
-			}
-			entry = n
-		}
+	d := &gogen.Decompiler{
+		Funcs: funcs,
+		Prims: func(f *ir.Func) ([]*primitive.Primitive, error) {
+			return parsePrims(graphsDir, f, man)
+		},
+		Pool:     pool,
+		Check:    check,
+		Comments: irComments,
+		SrcMap:   srcMap,
+		Layout:   layout,
+		Types:    types,
+		Logger:   dbg,
+	}
+	file, err := d.Decompile(module, srcName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decompile %q", llPath)
 	}
-	if entry == nil {
-		return nil, errors.Errorf("unable to locate entry node; try specifying an entry node label using the -entry flag")
+	f := &goFile{
+		file:    file.File,
+		fset:    file.Fset,
+		llPath:  llPath,
+		origins: file.Origins,
 	}
-	return entry, nil
+	return f, nil
 }
 
-// genPrims returns the high-level primitives of the given function discovered
-// by control flow analysis.
-func genPrims(f *ir.Func) ([]*primitive.Primitive, error) {
-	g := cfg.New(f)
-	entry, err := locateEntryNode(g)
+// parsePrims parses the JSON file containing a mapping of control flow
+// primitives for the given function from the specified graph directory. No
+// primitives are returned, and thus generated by control flow analysis, if the
+// JSON file is not present on the file system, or if it is stale with regards to
+// the manifest of the graph directory.
+func parsePrims(graphsDir string, f *ir.Func, man *manifest.Manifest) ([]*primitive.Primitive, error) {
+	prims, err := man.Prims(graphsDir, f)
 	if err != nil {
-		log.Fatalf("%+v", err)
-	}
-	var prims []*primitive.Primitive
-	for g.Nodes().Len() > 1 {
-		// Locate primitive.
-		dom := cfg.NewDom(g, entry)
-		prim, err := cfa.FindPrim(g, dom)
-		if err != nil {
-			return prims, errors.Wrap(ErrIncomplete, err.Error())
-		}
-		prims = append(prims, prim)
-		// Merge the nodes of the primitive into a single node.
-		if err := cfa.Merge(g, prim); err != nil {
+		if errors.Cause(err) != manifest.ErrStale {
 			return nil, errors.WithStack(err)
 		}
-		// Handle special case where entry node has been replaced by primitive
-		// node.
-		if g.Node(entry.ID()) == nil {
-			var ok bool
-			entry, ok = g.NodeByLabel(prim.Entry)
-			if !ok {
-				return nil, errors.Errorf("unable to locate entry node %q", prim.Entry)
-			}
-		}
+		// Recover primitives by control flow analysis (see gogen.Decompiler).
+		dbg.Printf("WARNING: ignoring %v", err)
+		return nil, nil
 	}
 	return prims, nil
 }
-
-// label returns the label of the node.
-func label(n graph.Node) string {
-	if n, ok := n.(*cfg.Node); ok {
-		return n.Label
-	}
-	panic(fmt.Sprintf("invalid node type; expected *cfg.Node, got %T", n))
-}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/decomp/decomp/rt"
+	"github.com/decomp/decomp/srcmap"
+	"github.com/pkg/errors"
+)
+
+// outputFile represents a Go source file to be written by ll2go.
+type outputFile struct {
+	// File name of the Go source file (e.g. "foo.go").
+	name string
+	// Go source file.
+	file *ast.File
+}
+
+// splitFile splits the given Go source file, with positions in fset, as
+// decompiled from the LLVM IR module with the specified source name, into output
+// files containing at most n function declarations each. Type and variable
+// declarations are kept in the first output file (e.g. "foo.go") and function
+// declarations are stored in subsequent output files (e.g. "foo_funcs1.go",
+// "foo_funcs2.go"). The file is not split if n is less than 1 or if it contains
+// at most n functions.
+func splitFile(fset *token.FileSet, file *ast.File, srcName string, n int) []*outputFile {
+	mainName := srcName + ".go"
+	var genDecls, imports, funcDecls []ast.Decl
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			funcDecls = append(funcDecls, decl)
+		case *ast.GenDecl:
+			if decl.Tok == token.IMPORT {
+				imports = append(imports, decl)
+			} else {
+				genDecls = append(genDecls, decl)
+			}
+		default:
+			panic(fmt.Errorf("support for declaration %T not yet implemented", decl))
+		}
+	}
+	if n < 1 || len(funcDecls) <= n {
+		return []*outputFile{{name: mainName, file: file}}
+	}
+	cmap := ast.NewCommentMap(fset, file, file.Comments)
+	// newFile returns a new Go source file in the same package as file, with
+	// the given declarations and the imports used by them.
+	newFile := func(decls []ast.Decl) *ast.File {
+		f := &ast.File{
+			Name: ast.NewIdent(file.Name.Name),
+		}
+		f.Decls = append(f.Decls, imports...)
+		f.Decls = append(f.Decls, decls...)
+		// Keep the comments associated with the declarations.
+		for _, decl := range decls {
+			f.Comments = append(f.Comments, cmap.Filter(decl).Comments()...)
+		}
+		pruneImports(f)
+		return f
+	}
+	files := []*outputFile{{name: mainName, file: newFile(genDecls)}}
+	for i := 0; i < len(funcDecls); i += n {
+		end := i + n
+		if end > len(funcDecls) {
+			end = len(funcDecls)
+		}
+		name := fmt.Sprintf("%s_funcs%d.go", srcName, i/n+1)
+		files = append(files, &outputFile{name: name, file: newFile(funcDecls[i:end])})
+	}
+	return files
+}
+
+// pruneImports removes the imports of the given Go source file which are not
+// referred to by any of its declarations.
+func pruneImports(file *ast.File) {
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				used[x.Name] = true
+			}
+		}
+		return true
+	})
+	var decls []ast.Decl
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			decls = append(decls, decl)
+			continue
+		}
+		var specs []ast.Spec
+		for _, spec := range genDecl.Specs {
+			spec := spec.(*ast.ImportSpec)
+			if used[importName(spec)] {
+				specs = append(specs, spec)
+			}
+		}
+		if len(specs) == 0 {
+			continue
+		}
+		genDecl.Specs = specs
+		decls = append(decls, genDecl)
+	}
+	file.Decls = decls
+}
+
+// importName returns the name used to refer to the package of the given import
+// specifier.
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	path, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		panic(fmt.Errorf("unable to unquote import path %s; %v", spec.Path.Value, err))
+	}
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// formatFile returns the gofmt'd Go source code of the given file, with
+// positions in the specified file set.
+func formatFile(fset *token.FileSet, file *ast.File) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, fset, file); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// A goFile is a Go source file decompiled from an LLVM IR module.
+type goFile struct {
+	// Go source file.
+	file *ast.File
+	// File set of the positions of the Go source file.
+	fset *token.FileSet
+	// Path of the LLVM IR assembly file.
+	llPath string
+	// Source map origins of the marker comments of the Go source file; nil if
+	// source maps are disabled.
+	origins []srcmap.Origin
+}
+
+// writeFile writes the gofmt'd Go source code of the given file to path. The Go
+// source code is written to standard output if path is "-". The source map of
+// the Go source file is written to path.map if source maps are enabled.
+func writeFile(path string, f *goFile) error {
+	buf, err := formatFile(f.fset, f.file)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if f.origins != nil {
+		// Remove source map markers.
+		var mappings []srcmap.Mapping
+		buf, mappings, err = srcmap.Extract(buf, f.origins)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if path != "-" {
+			m := &srcmap.Map{
+				Source:   f.llPath,
+				Mappings: mappings,
+			}
+			dbg.Printf("creating file %q.", path+srcmap.Ext)
+			if err := m.Store(path); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+	if path == "-" {
+		if _, err := os.Stdout.Write(buf); err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+	dbg.Printf("creating file %q.", path)
+	if err := ioutil.WriteFile(path, buf, 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// writePackage writes the given Go source file, as decompiled from the LLVM IR
+// module with the specified source name, to the output directory; split into
+// output files containing at most n function declarations each.
+func writePackage(dir string, f *goFile, srcName string, n int) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, out := range splitFile(f.fset, f.file, srcName, n) {
+		path := filepath.Join(dir, out.name)
+		outFile := &goFile{
+			file:    out.file,
+			fset:    f.fset,
+			llPath:  f.llPath,
+			origins: f.origins,
+		}
+		if err := writeFile(path, outFile); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// writeGoMod writes a go.mod file with the given module path to the output
+// directory. If the given Go source files import runtime support packages
+// (e.g. rt/eh), the runtime support packages are written to the rt
+// subdirectory of the output directory as a nested module, which is required by
+// the go.mod file and replaced by the rt subdirectory; so that the output
+// directory builds independent of the module providing ll2go.
+//
+//    module foo
+//
+//    go 1.16
+//
+//    require github.com/decomp/decomp/rt v0.0.0
+//
+//    replace github.com/decomp/decomp/rt => ./rt
+func writeGoMod(dir, modPath string, files []*goFile) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "module %s\n\ngo 1.16\n", modPath)
+	if importsRT(files) {
+		if err := writeRT(filepath.Join(dir, "rt")); err != nil {
+			return errors.WithStack(err)
+		}
+		fmt.Fprintf(buf, "\nrequire %s v0.0.0\n", rt.Path)
+		fmt.Fprintf(buf, "\nreplace %s => ./rt\n", rt.Path)
+	}
+	path := filepath.Join(dir, "go.mod")
+	dbg.Printf("creating file %q.", path)
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// importsRT reports whether any of the given Go source files imports a runtime
+// support package.
+func importsRT(files []*goFile) bool {
+	for _, f := range files {
+		for _, spec := range f.file.Imports {
+			pkgPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				panic(fmt.Errorf("unable to unquote import path %s; %v", spec.Path.Value, err))
+			}
+			if strings.HasPrefix(pkgPath, rt.Path+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeRT writes the Go source files of the runtime support packages, excluding
+// test files, to the given directory as a module with the import path of
+// package rt.
+func writeRT(dir string) error {
+	dirs, err := rt.FS.ReadDir(".")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, pkgDir := range dirs {
+		entries, err := rt.FS.ReadDir(pkgDir.Name())
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := os.MkdirAll(filepath.Join(dir, pkgDir.Name()), 0755); err != nil {
+			return errors.WithStack(err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			buf, err := rt.FS.ReadFile(path.Join(pkgDir.Name(), name))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, pkgDir.Name(), name), buf, 0644); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+	modPath := filepath.Join(dir, "go.mod")
+	dbg.Printf("creating file %q.", modPath)
+	buf := []byte(fmt.Sprintf("module %s\n\ngo 1.16\n", rt.Path))
+	if err := ioutil.WriteFile(modPath, buf, 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestWriteGoMod(t *testing.T) {
+	// The output directory of decompiled Go source code importing runtime
+	// support packages builds as a module of its own.
+	const src = `package foo
+
+import "github.com/decomp/decomp/rt/eh"
+
+func f(g func()) *eh.Exception {
+	return eh.Invoke(g)
+}
+`
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "foo.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("unable to parse Go source code; %v", err)
+	}
+	f := &goFile{file: file, fset: fset, llPath: "foo.ll"}
+	dir := t.TempDir()
+	if err := writePackage(dir, f, "foo", 0); err != nil {
+		t.Fatalf("unable to write package; %+v", err)
+	}
+	if err := writeGoMod(dir, "example.com/foo", []*goFile{f}); err != nil {
+		t.Fatalf("unable to write go.mod file; %+v", err)
+	}
+	cmd := exec.Command(goCmd, "build", "./...")
+	cmd.Dir = dir
+	// Build without network access, independent of the enclosing module.
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("unable to build output directory; %v\n%s", err, out)
+	}
+}
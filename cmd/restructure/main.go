@@ -16,6 +16,8 @@
 //
 //    -entry string
 //          entry node of the control flow graph
+//    -img
+//          generate PNG images of the intermediate control flow graphs (requires -steps)
 //    -indent
 //          indent JSON output
 //    -o string
@@ -28,7 +30,6 @@ package main
 import (
 	"bytes"
 	"encoding/json"
-	goerrors "errors"
 	"flag"
 	"fmt"
 	"io"
@@ -40,6 +41,7 @@ import (
 	"github.com/decomp/decomp/cfa"
 	"github.com/decomp/decomp/cfa/primitive"
 	"github.com/decomp/decomp/graph/cfg"
+	"github.com/decomp/decomp/graph/render"
 	"github.com/mewkiz/pkg/pathutil"
 	"github.com/mewkiz/pkg/term"
 	"github.com/pkg/errors"
@@ -70,6 +72,9 @@ func main() {
 	var (
 		// entryLabel specifies the entry node of the control flow graph.
 		entryLabel string
+		// img specifies whether to generate PNG images of the intermediate
+		// control flow graphs.
+		img bool
 		// indent specifies whether to indent JSON output.
 		indent bool
 		// output specifies the output path.
@@ -81,6 +86,7 @@ func main() {
 		steps bool
 	)
 	flag.StringVar(&entryLabel, "entry", "", "entry node of the control flow graph")
+	flag.BoolVar(&img, "img", false, "generate PNG images of the intermediate control flow graphs (requires -steps)")
 	flag.BoolVar(&indent, "indent", false, "indent JSON output")
 	flag.StringVar(&output, "o", "", "output path")
 	flag.BoolVar(&quiet, "q", false, "suppress non-error messages")
@@ -111,9 +117,9 @@ func main() {
 	}
 
 	// Perform control flow analysis.
-	prims, err := restructure(g, entry, steps, name)
+	prims, err := restructure(g, entry, steps, img, name)
 	if err != nil {
-		if errors.Cause(err) == ErrIncomplete {
+		if errors.Cause(err) == cfa.ErrIncomplete {
 			// Do _not_ terminate on incomplete control flow recovery. Instead
 			// print partial results.
 			dbg.Printf("WARNING: %v", err)
@@ -148,87 +154,42 @@ func locateEntryNode(g *cfg.Graph, entryLabel string) (graph.Node, error) {
 		}
 		return entry, nil
 	}
-	var entry graph.Node
-	nodes := g.Nodes()
-	for nodes.Next() {
-		n := nodes.Node()
-		preds := g.To(n.ID())
-		if preds.Len() == 0 {
-			if entry != nil {
-				return nil, errors.Errorf("more than one candidate for the entry node located; prev %q, new %q", label(entry), label(n))
-			}
-			entry = n
-		}
-	}
-	if entry == nil {
-		return nil, errors.Errorf("unable to locate entry node; try specifying an entry node label using the -entry flag")
+	entry, err := cfa.FindEntry(g)
+	if err != nil {
+		return nil, errors.Wrap(err, "try specifying an entry node label using the -entry flag")
 	}
 	return entry, nil
 }
 
-// ErrIncomplete signals an incomplete control flow recovery.
-var ErrIncomplete = goerrors.New("incomplete control flow recovery")
-
 // restructure attempts to recover the control flow primitives of a given
-// control flow graph. It does so by repeatedly locating and merging structured
-// subgraphs (graph representations of control flow primitives) into single
-// nodes until the entire graph is reduced into a single node or no structured
-// subgraphs may be located. The steps argument specifies whether to record the
-// intermediate CFGs at each step. The returned list of primitives is ordered in
-// the same sequence as they were located.
-func restructure(g *cfg.Graph, entry graph.Node, steps bool, name string) ([]*primitive.Primitive, error) {
-	prims := make([]*primitive.Primitive, 0)
-	// Locate control flow primitives.
-	for step := 1; g.Nodes().Len() > 1; step++ {
-		// Locate primitive.
-		dom := cfg.NewDom(g, entry)
-		prim, err := cfa.FindPrim(g, dom)
-		if err != nil {
-			return prims, errors.Wrap(ErrIncomplete, err.Error())
-		}
-		prims = append(prims, prim)
-
-		// Output pre-merge intermediate CFG.
-		if steps {
-			path := fmt.Sprintf("%s_%04da.dot", name, step)
-			var highlight []string
-			for _, node := range prim.Nodes {
-				highlight = append(highlight, node)
-			}
-			if err := storeStep(g, name, path, highlight); err != nil {
-				return nil, errors.WithStack(err)
-			}
-		}
-
-		// Merge the nodes of the primitive into a single node.
-		if err := cfa.Merge(g, prim); err != nil {
-			return nil, errors.WithStack(err)
-		}
-		// Handle special case where entry node has been replaced by primitive
-		// node.
-		if g.Node(entry.ID()) == nil {
-			var ok bool
-			entry, ok = g.NodeByLabel(prim.Entry)
-			if !ok {
-				return nil, errors.Errorf("unable to locate entry node %q", prim.Entry)
-			}
-		}
-
-		// Output post-merge intermediate CFG.
-		if steps {
-			path := fmt.Sprintf("%s_%04db.dot", name, step)
+// control flow graph (see cfa.Restructure). The steps argument specifies
+// whether to record the intermediate CFGs at each step, and img whether to also
+// render them as PNG images.
+func restructure(g *cfg.Graph, entry graph.Node, steps, img bool, name string) ([]*primitive.Primitive, error) {
+	if !steps {
+		return cfa.Restructure(g, entry, nil)
+	}
+	// Output pre-merge and post-merge intermediate CFGs.
+	step := func(g *cfg.Graph, i int, prim *primitive.Primitive, merged bool) error {
+		if merged {
+			path := fmt.Sprintf("%s_%04db.dot", name, i)
 			highlight := []string{prim.Entry}
-			if err := storeStep(g, name, path, highlight); err != nil {
-				return nil, errors.WithStack(err)
-			}
+			return storeStep(g, name, path, highlight, img)
+		}
+		path := fmt.Sprintf("%s_%04da.dot", name, i)
+		var highlight []string
+		for _, node := range prim.Nodes {
+			highlight = append(highlight, node)
 		}
+		return storeStep(g, name, path, highlight, img)
 	}
-	return prims, nil
+	return cfa.Restructure(g, entry, step)
 }
 
 // storeStep stores a DOT representation of g to path with the specified nodes
-// highlighted in red.
-func storeStep(g *cfg.Graph, name, path string, highlight []string) error {
+// highlighted in red. If img is set, a PNG image of g is also stored, using the
+// same file name but with a ".png" extension.
+func storeStep(g *cfg.Graph, name, path string, highlight []string, img bool) error {
 	for _, h := range highlight {
 		n, ok := g.NodeByLabel(h)
 		if !ok {
@@ -241,6 +202,12 @@ func storeStep(g *cfg.Graph, name, path string, highlight []string) error {
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	if img {
+		pngPath := pathutil.TrimExt(path) + ".png"
+		if err := storePNG(g, pngPath); err != nil {
+			return errors.WithStack(err)
+		}
+	}
 	for _, h := range highlight {
 		n, ok := g.NodeByLabel(h)
 		if !ok {
@@ -255,6 +222,22 @@ func storeStep(g *cfg.Graph, name, path string, highlight []string) error {
 	return nil
 }
 
+// storePNG stores a PNG image of g to path.
+func storePNG(g *cfg.Graph, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := render.PNG(f, g); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
 // writeJSON writes the primitives in JSON format to w.
 func writeJSON(w io.Writer, prims []*primitive.Primitive, indent bool) error {
 	// Output indented JSON.
@@ -277,11 +260,3 @@ func writeJSON(w io.Writer, prims []*primitive.Primitive, indent bool) error {
 	}
 	return nil
 }
-
-// label returns the label of the node.
-func label(n graph.Node) string {
-	if n, ok := n.(*cfg.Node); ok {
-		return n.Label
-	}
-	panic(fmt.Sprintf("invalid node type; expected *cfg.Node, got %T", n))
-}
@@ -305,7 +305,7 @@ func TestRestructure(t *testing.T) {
 			t.Errorf("%q: unable to locate entry node; %v", gold.path, err)
 		}
 
-		got, err := restructure(g, entry, false, "")
+		got, err := restructure(g, entry, false, false, "")
 		if err != nil {
 			t.Errorf("%q: unable to restructure; %v", gold.path, err)
 			continue
@@ -1,11 +1,11 @@
 module github.com/decomp/decomp
 
 require (
-	github.com/graphism/exp v0.0.0-20191230020605-0ca8b8623ec4
 	github.com/graphism/simple v0.0.0-20191230020715-536a1bccf827
 	github.com/llir/llvm v0.3.3
 	github.com/mewkiz/pkg v0.0.0-20210112042322-0b163ae15d52
 	github.com/pkg/errors v0.9.1
+	golang.org/x/image v0.0.0-20210220032944-ac19c3e999fb
 	golang.org/x/mod v0.4.2 // indirect
 	golang.org/x/sys v0.1.0 // indirect
 	golang.org/x/tools v0.1.0 // indirect
@@ -0,0 +1,401 @@
+// Package callgraph provides access to call graphs of LLVM IR modules.
+//
+// The call graph of a module contains one node per function (both function
+// definitions and external function declarations) and one edge per caller and
+// callee pair. Direct calls give rise to direct edges. Indirect calls (through
+// function pointers) give rise to indirect edges, from the caller to every
+// address-taken function with a compatible signature.
+package callgraph
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/decomp/decomp/graph/cfg"
+	"github.com/graphism/simple"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"gonum.org/v1/gonum/graph"
+	"gonum.org/v1/gonum/graph/encoding"
+)
+
+// Graph represents a call graph.
+type Graph struct {
+	*simple.DirectedGraph
+	// nodes maps from function name to graph node.
+	nodes map[string]*Node
+	// funcs tracks the nodes of the graph in order of occurrence in the module.
+	funcs []*Node
+}
+
+// New returns a new call graph based on the given module.
+func New(m *ir.Module) *Graph {
+	g := &Graph{
+		DirectedGraph: simple.NewDirectedGraph(),
+		nodes:         make(map[string]*Node),
+	}
+	// Add one node per function, in order of occurrence in the module.
+	for _, f := range m.Funcs {
+		g.newNodeWithFunc(f)
+	}
+	// Locate address-taken functions; i.e. functions which may be the target of
+	// indirect calls.
+	for _, global := range m.Globals {
+		if global.Init != nil {
+			g.markAddrTaken(global.Init)
+		}
+	}
+	for _, f := range m.Funcs {
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				for _, v := range instOperands(inst) {
+					g.markAddrTaken(v)
+				}
+			}
+			for _, v := range termOperands(block.Term) {
+				g.markAddrTaken(v)
+			}
+		}
+	}
+	// Add call edges.
+	for _, f := range m.Funcs {
+		from := g.nodes[f.Name()]
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				if inst, ok := inst.(*ir.InstCall); ok {
+					g.addCall(from, inst.Callee)
+				}
+			}
+			switch term := block.Term.(type) {
+			case *ir.TermInvoke:
+				g.addCall(from, term.Invokee)
+			case *ir.TermCallBr:
+				g.addCall(from, term.Callee)
+			}
+		}
+	}
+	return g
+}
+
+// addCall adds a call edge from the given caller to the callee. Indirect calls
+// give rise to edges from the caller to each compatible address-taken
+// function.
+func (g *Graph) addCall(from *Node, callee value.Value) {
+	if f, ok := Callee(callee); ok {
+		to, ok := g.nodes[f.Name()]
+		if !ok {
+			panic(fmt.Errorf("unable to locate callee function %q in call graph", f.Name()))
+		}
+		g.newEdgeWithKind(from, to, KindDirect)
+		return
+	}
+	if _, ok := callee.(*ir.InlineAsm); ok {
+		// Inline assembly is not part of the call graph.
+		return
+	}
+	sig := calleeSig(callee)
+	for _, to := range g.funcs {
+		if !to.AddrTaken {
+			continue
+		}
+		if sig != nil && !compatible(sig, to.Func.Sig) {
+			continue
+		}
+		g.newEdgeWithKind(from, to, KindIndirect)
+	}
+}
+
+// markAddrTaken marks the functions referenced by the given operand as
+// address-taken.
+func (g *Graph) markAddrTaken(v value.Value) {
+	switch v := v.(type) {
+	case *ir.Func:
+		if n, ok := g.nodes[v.Name()]; ok {
+			n.AddrTaken = true
+		}
+	case *constant.Struct:
+		for _, field := range v.Fields {
+			g.markAddrTaken(field)
+		}
+	case *constant.Array:
+		for _, elem := range v.Elems {
+			g.markAddrTaken(elem)
+		}
+	case *constant.Vector:
+		for _, elem := range v.Elems {
+			g.markAddrTaken(elem)
+		}
+	case *constant.ExprBitCast:
+		g.markAddrTaken(v.From)
+	case *constant.ExprPtrToInt:
+		g.markAddrTaken(v.From)
+	case *constant.ExprAddrSpaceCast:
+		g.markAddrTaken(v.From)
+	case *constant.ExprGetElementPtr:
+		g.markAddrTaken(v.Src)
+	case *constant.ExprSelect:
+		g.markAddrTaken(v.X)
+		g.markAddrTaken(v.Y)
+	}
+}
+
+// Callee returns the function called directly by the given callee operand of a
+// call instruction, stripping pointer casts. The boolean return value
+// indicates whether the call is a direct call.
+func Callee(callee value.Value) (*ir.Func, bool) {
+	switch c := callee.(type) {
+	case *ir.Func:
+		return c, true
+	case *constant.ExprBitCast:
+		return Callee(c.From)
+	case *constant.ExprAddrSpaceCast:
+		return Callee(c.From)
+	}
+	return nil, false
+}
+
+// calleeSig returns the function signature of the given indirect callee, or nil
+// if unknown.
+func calleeSig(callee value.Value) *irtypes.FuncType {
+	if t, ok := callee.Type().(*irtypes.PointerType); ok {
+		if sig, ok := t.ElemType.(*irtypes.FuncType); ok {
+			return sig
+		}
+	}
+	return nil
+}
+
+// compatible reports whether a call through a function pointer of signature a
+// may target a function of signature b. Parameter and return types are
+// compared by number only, as lifted code frequently casts between pointer
+// and integer types.
+func compatible(a, b *irtypes.FuncType) bool {
+	if irtypes.Equal(a.RetType, irtypes.Void) != irtypes.Equal(b.RetType, irtypes.Void) {
+		return false
+	}
+	if b.Variadic {
+		return len(a.Params) >= len(b.Params)
+	}
+	return len(a.Params) == len(b.Params)
+}
+
+// instOperands returns the operands of the given instruction which may refer to
+// the address of a function, excluding the callee of direct calls.
+func instOperands(inst ir.Instruction) []value.Value {
+	switch inst := inst.(type) {
+	case *ir.InstCall:
+		ops := append([]value.Value{}, inst.Args...)
+		if _, ok := Callee(inst.Callee); !ok {
+			ops = append(ops, inst.Callee)
+		}
+		return ops
+	case *ir.InstStore:
+		return []value.Value{inst.Src}
+	case *ir.InstSelect:
+		return []value.Value{inst.ValueTrue, inst.ValueFalse}
+	case *ir.InstPhi:
+		var ops []value.Value
+		for _, inc := range inst.Incs {
+			ops = append(ops, inc.X)
+		}
+		return ops
+	case *ir.InstBitCast:
+		return []value.Value{inst.From}
+	case *ir.InstPtrToInt:
+		return []value.Value{inst.From}
+	case *ir.InstAddrSpaceCast:
+		return []value.Value{inst.From}
+	case *ir.InstInsertValue:
+		return []value.Value{inst.Elem}
+	case *ir.InstInsertElement:
+		return []value.Value{inst.Elem}
+	case *ir.InstICmp:
+		return []value.Value{inst.X, inst.Y}
+	}
+	return nil
+}
+
+// termOperands returns the operands of the given terminator which may refer to
+// the address of a function, excluding the callee of direct calls.
+func termOperands(term ir.Terminator) []value.Value {
+	switch term := term.(type) {
+	case *ir.TermRet:
+		if term.X != nil {
+			return []value.Value{term.X}
+		}
+	case *ir.TermInvoke:
+		ops := append([]value.Value{}, term.Args...)
+		if _, ok := Callee(term.Invokee); !ok {
+			ops = append(ops, term.Invokee)
+		}
+		return ops
+	case *ir.TermCallBr:
+		ops := append([]value.Value{}, term.Args...)
+		if _, ok := Callee(term.Callee); !ok {
+			ops = append(ops, term.Callee)
+		}
+		return ops
+	}
+	return nil
+}
+
+// NodeByName returns the node of the function with the given name in the
+// graph. The boolean return value indicates success.
+func (g *Graph) NodeByName(name string) (*Node, bool) {
+	n, ok := g.nodes[name]
+	return n, ok
+}
+
+// Funcs returns the nodes of the graph in order of occurrence in the module.
+func (g *Graph) Funcs() []*Node {
+	return g.funcs
+}
+
+// Callees returns the callees of the given node, sorted in order of occurrence
+// in the module.
+func (g *Graph) Callees(n graph.Node) []*Node {
+	return g.sorted(g.From(n.ID()))
+}
+
+// Callers returns the callers of the given node, sorted in order of occurrence
+// in the module.
+func (g *Graph) Callers(n graph.Node) []*Node {
+	return g.sorted(g.To(n.ID()))
+}
+
+// sorted returns the given nodes sorted in order of occurrence in the module.
+func (g *Graph) sorted(nodes graph.Nodes) []*Node {
+	var ns []*Node
+	for nodes.Next() {
+		ns = append(ns, node(nodes.Node()))
+	}
+	sort.Slice(ns, func(i, j int) bool {
+		return ns[i].index < ns[j].index
+	})
+	return ns
+}
+
+// Node represents a node of a call graph.
+type Node struct {
+	graph.Node
+	// Function name.
+	Name string
+	// Function definition or declaration.
+	Func *ir.Func
+	// AddrTaken specifies whether the address of the function is taken; i.e.
+	// whether the function may be the target of indirect calls.
+	AddrTaken bool
+	// DOT attributes.
+	cfg.Attrs
+	// index specifies the index of the function in the module.
+	index int
+}
+
+// newNodeWithFunc returns a new node for the given function in the graph.
+func (g *Graph) newNodeWithFunc(f *ir.Func) *Node {
+	n := &Node{
+		Node:  g.DirectedGraph.NewNode(),
+		Name:  f.Name(),
+		Func:  f,
+		Attrs: make(cfg.Attrs),
+		index: len(g.funcs),
+	}
+	g.nodes[n.Name] = n
+	g.funcs = append(g.funcs, n)
+	g.AddNode(n)
+	return n
+}
+
+// IsDecl reports whether the node represents an external function declaration.
+func (n *Node) IsDecl() bool {
+	return len(n.Func.Blocks) == 0
+}
+
+// DOTID returns the DOT node ID of the node.
+func (n *Node) DOTID() string {
+	return n.Name
+}
+
+// Attributes returns the DOT attributes of the node; the attributes of n.Attrs
+// together with the attributes of function declarations and address-taken
+// functions. n.Attrs is left unchanged.
+func (n *Node) Attributes() []encoding.Attribute {
+	attrs := make(cfg.Attrs, len(n.Attrs)+3)
+	for key, val := range n.Attrs {
+		attrs[key] = val
+	}
+	if n.IsDecl() {
+		attrs["shape"] = "box"
+		attrs["style"] = "dashed"
+	}
+	if n.AddrTaken {
+		attrs["peripheries"] = "2"
+	}
+	return attrs.Attributes()
+}
+
+// Kind specifies the kind of a call edge.
+type Kind uint8
+
+// Call edge kinds.
+const (
+	// KindDirect specifies a direct call.
+	KindDirect Kind = iota + 1
+	// KindIndirect specifies a potential call through a function pointer.
+	KindIndirect
+)
+
+// String returns a string representation of the call edge kind.
+func (kind Kind) String() string {
+	switch kind {
+	case KindDirect:
+		return "direct"
+	case KindIndirect:
+		return "indirect"
+	}
+	return fmt.Sprintf("Kind(%d)", uint8(kind))
+}
+
+// Edge represents an edge of a call graph.
+type Edge struct {
+	graph.Edge
+	// Call edge kind.
+	Kind Kind
+}
+
+// newEdgeWithKind returns a new edge of the given kind from the caller to the
+// callee in the graph, or the existing edge if already present. Direct calls
+// take precedence over indirect calls.
+func (g *Graph) newEdgeWithKind(from, to graph.Node, kind Kind) *Edge {
+	if e := g.Edge(from.ID(), to.ID()); e != nil {
+		ee := e.(*Edge)
+		if kind == KindDirect {
+			ee.Kind = KindDirect
+		}
+		return ee
+	}
+	e := &Edge{
+		Edge: g.DirectedGraph.NewEdge(from, to),
+		Kind: kind,
+	}
+	g.SetEdge(e)
+	return e
+}
+
+// Attributes returns the DOT attributes of the edge.
+func (e *Edge) Attributes() []encoding.Attribute {
+	if e.Kind == KindIndirect {
+		return []encoding.Attribute{{Key: "style", Value: "dashed"}}
+	}
+	return nil
+}
+
+// node asserts that the given node is a call graph node.
+func node(n graph.Node) *Node {
+	if n, ok := n.(*Node); ok {
+		return n
+	}
+	panic(fmt.Errorf("invalid node type; expected *callgraph.Node, got %T", n))
+}
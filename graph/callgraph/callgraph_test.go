@@ -0,0 +1,131 @@
+package callgraph
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"gonum.org/v1/gonum/graph/encoding"
+)
+
+func TestGraph(t *testing.T) {
+	const src = `
+@handler = global void ()* @g
+
+declare i32 @puts(i8*)
+
+define void @main() {
+	call void @f()
+	%fp = load void ()*, void ()** @handler
+	call void %fp()
+	ret void
+}
+
+define void @f() {
+	call void @h()
+	ret void
+}
+
+define void @g() {
+	%1 = call i32 @puts(i8* null)
+	ret void
+}
+
+define void @h() {
+	call void @f()
+	ret void
+}
+`
+	m, err := asm.ParseString("test.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %v", err)
+	}
+	g := New(m)
+
+	// Check call edges.
+	golden := []struct {
+		caller  string
+		callees []string
+	}{
+		{caller: "puts", callees: nil},
+		{caller: "main", callees: []string{"f", "g"}},
+		{caller: "f", callees: []string{"h"}},
+		{caller: "g", callees: []string{"puts"}},
+		{caller: "h", callees: []string{"f"}},
+	}
+	for _, gold := range golden {
+		n, ok := g.NodeByName(gold.caller)
+		if !ok {
+			t.Errorf("unable to locate node of function %q", gold.caller)
+			continue
+		}
+		got := names(g.Callees(n))
+		if !reflect.DeepEqual(got, gold.callees) {
+			t.Errorf("callees of %q mismatch; expected %q, got %q", gold.caller, gold.callees, got)
+		}
+	}
+	mainNode, _ := g.NodeByName("main")
+	gNode, _ := g.NodeByName("g")
+	if e := g.Edge(mainNode.ID(), gNode.ID()).(*Edge); e.Kind != KindIndirect {
+		t.Errorf("kind of call edge from %q to %q mismatch; expected %v, got %v", "main", "g", KindIndirect, e.Kind)
+	}
+	if !gNode.AddrTaken {
+		t.Errorf("expected address of function %q to be taken", "g")
+	}
+
+	// Check strongly connected components in bottom-up order.
+	var sccs [][]string
+	for _, scc := range g.SCCs() {
+		sccs = append(sccs, names(scc))
+	}
+	want := [][]string{{"puts"}, {"f", "h"}, {"g"}, {"main"}}
+	if !reflect.DeepEqual(sccs, want) {
+		t.Errorf("strongly connected components mismatch; expected %q, got %q", want, sccs)
+	}
+	if got, want := names(g.TopDown()), []string{"main", "g", "f", "h", "puts"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("top-down order mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestNodeAttributes(t *testing.T) {
+	const src = `
+@handler = global i32 (i8*)* @puts
+
+declare i32 @puts(i8*)
+`
+	m, err := asm.ParseString("test.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %v", err)
+	}
+	g := New(m)
+	n, ok := g.NodeByName("puts")
+	if !ok {
+		t.Fatalf("unable to locate node of function %q", "puts")
+	}
+	n.Attrs["label"] = "puts"
+	want := []encoding.Attribute{
+		{Key: "label", Value: "puts"},
+		{Key: "peripheries", Value: "2"},
+		{Key: "shape", Value: "box"},
+		{Key: "style", Value: "dashed"},
+	}
+	// Attributes are stable across calls, and leave the attributes of the node
+	// unchanged.
+	for i := 0; i < 2; i++ {
+		if got := n.Attributes(); !reflect.DeepEqual(got, want) {
+			t.Errorf("attributes mismatch; expected %v, got %v", want, got)
+		}
+	}
+	if len(n.Attrs) != 1 {
+		t.Errorf("node attributes modified; got %v", n.Attrs)
+	}
+}
+
+// names returns the function names of the given nodes.
+func names(ns []*Node) []string {
+	var names []string
+	for _, n := range ns {
+		names = append(names, n.Name)
+	}
+	return names
+}
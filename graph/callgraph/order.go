@@ -0,0 +1,138 @@
+package callgraph
+
+import (
+	"sort"
+)
+
+// SCCs returns the strongly connected components of the call graph in
+// bottom-up order; i.e. the components of callees are ordered before the
+// components of their callers. A strongly connected component with more than
+// one node (or with a self-loop) represents a set of mutually recursive
+// functions.
+//
+// The order is deterministic; ties are broken by the order of occurrence of
+// functions in the module, and the nodes of each component are sorted in order
+// of occurrence in the module.
+func (g *Graph) SCCs() [][]*Node {
+	t := &tarjan{
+		g:       g,
+		index:   make(map[int64]int),
+		lowLink: make(map[int64]int),
+		onStack: make(map[int64]bool),
+	}
+	for _, n := range g.funcs {
+		if _, ok := t.index[n.ID()]; !ok {
+			t.strongConnect(n)
+		}
+	}
+	for _, scc := range t.sccs {
+		sort.Slice(scc, func(i, j int) bool {
+			return scc[i].index < scc[j].index
+		})
+	}
+	return t.sccs
+}
+
+// BottomUp returns the functions of the call graph in bottom-up order; i.e.
+// callees are ordered before their callers, except for mutually recursive
+// functions which are ordered by occurrence in the module.
+//
+// This is the order in which to decompile functions, so that the signatures of
+// callees have been recovered before their call sites are analyzed.
+func (g *Graph) BottomUp() []*Node {
+	var ns []*Node
+	for _, scc := range g.SCCs() {
+		ns = append(ns, scc...)
+	}
+	return ns
+}
+
+// TopDown returns the functions of the call graph in top-down order; i.e.
+// callers are ordered before their callees, except for mutually recursive
+// functions which are ordered by occurrence in the module.
+func (g *Graph) TopDown() []*Node {
+	sccs := g.SCCs()
+	var ns []*Node
+	for i := len(sccs) - 1; i >= 0; i-- {
+		ns = append(ns, sccs[i]...)
+	}
+	return ns
+}
+
+// IsRecursive reports whether the given strongly connected component
+// represents a set of (mutually) recursive functions.
+func (g *Graph) IsRecursive(scc []*Node) bool {
+	if len(scc) > 1 {
+		return true
+	}
+	for _, n := range scc {
+		if g.HasEdgeFromTo(n.ID(), n.ID()) {
+			return true
+		}
+	}
+	return false
+}
+
+// tarjan implements Tarjan's strongly connected components algorithm.
+//
+// ref: https://en.wikipedia.org/wiki/Tarjan%27s_strongly_connected_components_algorithm
+type tarjan struct {
+	// Call graph.
+	g *Graph
+	// Next DFS index.
+	next int
+	// index maps from node ID to DFS index.
+	index map[int64]int
+	// lowLink maps from node ID to the smallest DFS index reachable from the
+	// node.
+	lowLink map[int64]int
+	// onStack tracks nodes present on the stack.
+	onStack map[int64]bool
+	// Stack of visited nodes not yet assigned to a strongly connected component.
+	stack []*Node
+	// Strongly connected components, in reverse topological order.
+	sccs [][]*Node
+}
+
+// strongConnect locates the strongly connected component containing v.
+func (t *tarjan) strongConnect(v *Node) {
+	vid := v.ID()
+	t.index[vid] = t.next
+	t.lowLink[vid] = t.next
+	t.next++
+	t.stack = append(t.stack, v)
+	t.onStack[vid] = true
+
+	for _, w := range t.g.Callees(v) {
+		wid := w.ID()
+		if _, ok := t.index[wid]; !ok {
+			t.strongConnect(w)
+			t.lowLink[vid] = min(t.lowLink[vid], t.lowLink[wid])
+		} else if t.onStack[wid] {
+			t.lowLink[vid] = min(t.lowLink[vid], t.index[wid])
+		}
+	}
+
+	// v is the root node of a strongly connected component.
+	if t.lowLink[vid] == t.index[vid] {
+		var scc []*Node
+		for {
+			w := t.stack[len(t.stack)-1]
+			t.stack = t.stack[:len(t.stack)-1]
+			delete(t.onStack, w.ID())
+			scc = append(scc, w)
+			if w.ID() == vid {
+				break
+			}
+		}
+		t.sccs = append(t.sccs, scc)
+	}
+}
+
+// min returns the smaller of a and b.
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -14,6 +14,20 @@ import (
 	"gonum.org/v1/gonum/graph/encoding"
 )
 
+// Labels of the outgoing edges of exception handling and indirect branch
+// terminators.
+const (
+	// LabelNormal is the label of edges to the normal return point of invoke and
+	// callbr terminators.
+	LabelNormal = "normal"
+	// LabelUnwind is the label of edges to the exception return point of invoke
+	// terminators.
+	LabelUnwind = "unwind"
+	// LabelIndirect is the label of edges to the targets of indirectbr
+	// terminators, and to the other return points of callbr terminators.
+	LabelIndirect = "indirect"
+)
+
 // Graph represents a control flow graph.
 type Graph struct {
 	*simple.DirectedGraph
@@ -59,6 +73,25 @@ func New(f *ir.Func) *Graph {
 			g.NewEdgeWithLabel(from, to, "default case")
 		case *ir.TermUnreachable:
 			// nothing to do.
+		case *ir.TermIndirectBr:
+			for _, target := range term.ValidTargets {
+				to := g.NewNodeWithLabel(target.(value.Named).Name())
+				g.NewEdgeWithLabel(from, to, LabelIndirect)
+			}
+		case *ir.TermInvoke:
+			normal := g.NewNodeWithLabel(term.NormalRetTarget.(value.Named).Name())
+			unwind := g.NewNodeWithLabel(term.ExceptionRetTarget.(value.Named).Name())
+			g.NewEdgeWithLabel(from, normal, LabelNormal)
+			g.NewEdgeWithLabel(from, unwind, LabelUnwind)
+		case *ir.TermCallBr:
+			normal := g.NewNodeWithLabel(term.NormalRetTarget.(value.Named).Name())
+			g.NewEdgeWithLabel(from, normal, LabelNormal)
+			for _, target := range term.OtherRetTargets {
+				to := g.NewNodeWithLabel(target.(value.Named).Name())
+				g.NewEdgeWithLabel(from, to, LabelIndirect)
+			}
+		case *ir.TermResume:
+			// nothing to do; the exception is propagated to the caller.
 		default:
 			panic(fmt.Errorf("support for terminator %T not yet implemented", term))
 		}
@@ -221,6 +254,13 @@ func (g *Graph) NewEdgeWithLabel(from, to graph.Node, label string) *Edge {
 	return e
 }
 
+// edgeColors maps from the label of exceptional and indirect edges to the DOT
+// color of edges with the label.
+var edgeColors = map[string]string{
+	LabelUnwind:   "orange",
+	LabelIndirect: "blue",
+}
+
 // Attributes returns the attributes of the edge.
 func (e *Edge) Attributes() []encoding.Attribute {
 	if len(e.Label) > 0 {
@@ -228,7 +268,11 @@ func (e *Edge) Attributes() []encoding.Attribute {
 		if !(strings.HasPrefix(val, `"`) && strings.HasSuffix(val, `"`)) && strings.ContainsAny(val, "\t ") {
 			val = strconv.Quote(val)
 		}
-		return []encoding.Attribute{{Key: "label", Value: val}}
+		attrs := []encoding.Attribute{{Key: "label", Value: val}}
+		if color, ok := edgeColors[e.Label]; ok {
+			attrs = append([]encoding.Attribute{{Key: "color", Value: color}}, attrs...)
+		}
+		return attrs
 	}
 	return nil
 }
@@ -0,0 +1,46 @@
+package render
+
+import (
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// colors maps from the names of commonly used DOT colors to RGB colors.
+//
+// ref: https://graphviz.org/doc/info/colors.html
+var colors = map[string]color.RGBA{
+	"black":     {R: 0x00, G: 0x00, B: 0x00, A: 0xFF},
+	"blue":      {R: 0x00, G: 0x00, B: 0xFF, A: 0xFF},
+	"brown":     {R: 0xA5, G: 0x2A, B: 0x2A, A: 0xFF},
+	"cyan":      {R: 0x00, G: 0xFF, B: 0xFF, A: 0xFF},
+	"darkgreen": {R: 0x00, G: 0x64, B: 0x00, A: 0xFF},
+	"gray":      {R: 0xC0, G: 0xC0, B: 0xC0, A: 0xFF},
+	"green":     {R: 0x00, G: 0xFF, B: 0x00, A: 0xFF},
+	"grey":      {R: 0xC0, G: 0xC0, B: 0xC0, A: 0xFF},
+	"lightblue": {R: 0xAD, G: 0xD8, B: 0xE6, A: 0xFF},
+	"lightgray": {R: 0xD3, G: 0xD3, B: 0xD3, A: 0xFF},
+	"lightgrey": {R: 0xD3, G: 0xD3, B: 0xD3, A: 0xFF},
+	"magenta":   {R: 0xFF, G: 0x00, B: 0xFF, A: 0xFF},
+	"orange":    {R: 0xFF, G: 0xA5, B: 0x00, A: 0xFF},
+	"purple":    {R: 0xA0, G: 0x20, B: 0xF0, A: 0xFF},
+	"red":       {R: 0xFF, G: 0x00, B: 0x00, A: 0xFF},
+	"white":     {R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+	"yellow":    {R: 0xFF, G: 0xFF, B: 0x00, A: 0xFF},
+}
+
+// parseColor parses the given DOT color, either a color name or an RGB color
+// of the form "#RRGGBB". The boolean return value indicates success.
+func parseColor(s string) (color.RGBA, bool) {
+	if c, ok := colors[strings.ToLower(s)]; ok {
+		return c, true
+	}
+	if len(s) == len("#RRGGBB") && strings.HasPrefix(s, "#") {
+		x, err := strconv.ParseUint(s[1:], 16, 32)
+		if err != nil {
+			return color.RGBA{}, false
+		}
+		return color.RGBA{R: uint8(x >> 16), G: uint8(x >> 8), B: uint8(x), A: 0xFF}, true
+	}
+	return color.RGBA{}, false
+}
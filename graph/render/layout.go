@@ -0,0 +1,577 @@
+// Package render implements a layered graph layout and renders directed graphs
+// as SVG and PNG images, without depending on external tools.
+//
+// The layout follows the Sugiyama framework, as popularized by Graphviz dot:
+//
+//    1. Cycle removal; back edges are temporarily reversed.
+//    2. Layer assignment; longest path layering from the source nodes.
+//    3. Dummy node insertion; edges spanning several layers are split.
+//    4. Crossing minimization; barycenter heuristic using alternating sweeps.
+//    5. Coordinate assignment; nodes are placed close to their neighbours.
+//
+// Node labels and DOT attributes (e.g. "label", "shape", "style", "color",
+// "fillcolor") are read from nodes and edges implementing the
+// encoding.Attributer and dot.Node interfaces.
+package render
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/graph"
+	"gonum.org/v1/gonum/graph/encoding"
+	"gonum.org/v1/gonum/graph/encoding/dot"
+)
+
+// Layout dimensions in pixels.
+const (
+	// Width and height of a character of a label.
+	charWidth, charHeight = 7, 13
+	// Horizontal and vertical padding of node labels.
+	padX, padY = 14, 9
+	// Minimum width of nodes.
+	minNodeWidth = 44
+	// Horizontal space between adjacent nodes of a layer.
+	nodeSep = 28
+	// Vertical space between adjacent layers.
+	rankSep = 56
+	// Margin surrounding the drawing.
+	margin = 16
+	// Number of crossing minimization iterations.
+	orderIters = 8
+	// Number of coordinate assignment iterations.
+	coordIters = 8
+)
+
+// A Layout is a layered drawing of a directed graph.
+type Layout struct {
+	// Graph name.
+	Name string
+	// Nodes of the drawing, in order of node ID.
+	Nodes []*Node
+	// Edges of the drawing, in order of source and destination node ID.
+	Edges []*Edge
+	// Width and height of the drawing.
+	Width, Height float64
+}
+
+// A Node is a positioned node of a layout.
+type Node struct {
+	// Graph node.
+	graph.Node
+	// Node label.
+	Label string
+	// DOT attributes of the node.
+	Attrs map[string]string
+	// Center coordinates of the node.
+	X, Y float64
+	// Width and height of the node.
+	W, H float64
+	// Layer and position within the layer.
+	layer, pos int
+	// dummy specifies whether the node is a dummy node of a long edge.
+	dummy bool
+}
+
+// An Edge is a routed edge of a layout.
+type Edge struct {
+	// Graph edge.
+	graph.Edge
+	// Edge label.
+	Label string
+	// DOT attributes of the edge.
+	Attrs map[string]string
+	// Polyline points of the edge, from source to destination.
+	Points []Point
+	// chain specifies the nodes visited by the edge, including dummy nodes,
+	// from the top-most to the bottom-most layer.
+	chain []*Node
+	// reversed specifies whether the edge was reversed during cycle removal.
+	reversed bool
+	// self specifies whether the edge is a self-loop.
+	self bool
+}
+
+// A Point is a point in the drawing.
+type Point struct {
+	X, Y float64
+}
+
+// NewLayout returns a layered layout of the given directed graph.
+func NewLayout(g graph.Directed) *Layout {
+	l := &Layout{}
+	if named, ok := g.(dot.Graph); ok {
+		l.Name = unquote(named.DOTID())
+	}
+	// Add nodes in order of node ID, to produce deterministic output.
+	nodes := graph.NodesOf(g.Nodes())
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID() < nodes[j].ID()
+	})
+	index := make(map[int64]*Node)
+	for _, n := range nodes {
+		attrs := attributes(n)
+		label, ok := attrs["label"]
+		if !ok {
+			label = nodeName(n)
+		}
+		nn := &Node{
+			Node:  n,
+			Label: unquote(label),
+			Attrs: attrs,
+		}
+		nn.W = float64(textWidth(nn.Label) + 2*padX)
+		if nn.W < minNodeWidth {
+			nn.W = minNodeWidth
+		}
+		nn.H = float64(textHeight(nn.Label) + 2*padY)
+		index[n.ID()] = nn
+		l.Nodes = append(l.Nodes, nn)
+	}
+	// Add edges in order of source and destination node ID.
+	for _, from := range l.Nodes {
+		succs := graph.NodesOf(g.From(from.ID()))
+		sort.Slice(succs, func(i, j int) bool {
+			return succs[i].ID() < succs[j].ID()
+		})
+		for _, succ := range succs {
+			e := g.Edge(from.ID(), succ.ID())
+			attrs := attributes(e)
+			ee := &Edge{
+				Edge:  e,
+				Label: unquote(attrs["label"]),
+				Attrs: attrs,
+				self:  from.ID() == succ.ID(),
+			}
+			ee.chain = []*Node{from, index[succ.ID()]}
+			l.Edges = append(l.Edges, ee)
+		}
+	}
+	l.removeCycles()
+	layers := l.assignLayers()
+	layers = l.insertDummies(layers)
+	l.orderLayers(layers)
+	l.assignCoords(layers)
+	l.routeEdges()
+	return l
+}
+
+// removeCycles reverses the back edges of a depth-first traversal, starting at
+// nodes without predecessors, so that the graph becomes acyclic.
+func (l *Layout) removeCycles() {
+	succs := make(map[*Node][]*Edge)
+	hasPreds := make(map[*Node]bool)
+	for _, e := range l.Edges {
+		if e.self {
+			continue
+		}
+		from, to := e.chain[0], e.chain[1]
+		succs[from] = append(succs[from], e)
+		hasPreds[to] = true
+	}
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := make(map[*Node]int)
+	var visit func(n *Node)
+	visit = func(n *Node) {
+		state[n] = active
+		for _, e := range succs[n] {
+			to := e.chain[1]
+			switch state[to] {
+			case unvisited:
+				visit(to)
+			case active:
+				// Back edge.
+				e.reversed = true
+			}
+		}
+		state[n] = done
+	}
+	// Start at nodes without predecessors (e.g. the entry node of a control
+	// flow graph), then at remaining unvisited nodes.
+	for _, n := range l.Nodes {
+		if !hasPreds[n] && state[n] == unvisited {
+			visit(n)
+		}
+	}
+	for _, n := range l.Nodes {
+		if state[n] == unvisited {
+			visit(n)
+		}
+	}
+	for _, e := range l.Edges {
+		if e.reversed {
+			e.chain[0], e.chain[1] = e.chain[1], e.chain[0]
+		}
+	}
+}
+
+// assignLayers assigns each node to a layer using longest path layering, and
+// returns the nodes of each layer.
+func (l *Layout) assignLayers() [][]*Node {
+	preds := make(map[*Node][]*Node)
+	succs := make(map[*Node][]*Node)
+	for _, e := range l.Edges {
+		if e.self {
+			continue
+		}
+		from, to := e.chain[0], e.chain[1]
+		succs[from] = append(succs[from], to)
+		preds[to] = append(preds[to], from)
+	}
+	// Topological traversal (Kahn's algorithm), in order of node ID.
+	npreds := make(map[*Node]int)
+	var queue []*Node
+	for _, n := range l.Nodes {
+		npreds[n] = len(preds[n])
+		if npreds[n] == 0 {
+			queue = append(queue, n)
+		}
+	}
+	var layers [][]*Node
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, pred := range preds[n] {
+			if pred.layer+1 > n.layer {
+				n.layer = pred.layer + 1
+			}
+		}
+		for len(layers) <= n.layer {
+			layers = append(layers, nil)
+		}
+		layers[n.layer] = append(layers[n.layer], n)
+		for _, succ := range succs[n] {
+			npreds[succ]--
+			if npreds[succ] == 0 {
+				queue = append(queue, succ)
+			}
+		}
+	}
+	return layers
+}
+
+// insertDummies splits edges spanning more than one layer by inserting dummy
+// nodes in each intermediate layer.
+func (l *Layout) insertDummies(layers [][]*Node) [][]*Node {
+	for _, e := range l.Edges {
+		if e.self {
+			continue
+		}
+		from, to := e.chain[0], e.chain[1]
+		chain := []*Node{from}
+		for layer := from.layer + 1; layer < to.layer; layer++ {
+			dummy := &Node{
+				layer: layer,
+				dummy: true,
+				W:     1,
+			}
+			layers[layer] = append(layers[layer], dummy)
+			chain = append(chain, dummy)
+		}
+		e.chain = append(chain, to)
+	}
+	return layers
+}
+
+// orderLayers orders the nodes within each layer to reduce edge crossings,
+// using the barycenter heuristic with alternating downward and upward sweeps.
+func (l *Layout) orderLayers(layers [][]*Node) {
+	up := make(map[*Node][]*Node)
+	down := make(map[*Node][]*Node)
+	for _, e := range l.Edges {
+		for i := 1; i < len(e.chain); i++ {
+			from, to := e.chain[i-1], e.chain[i]
+			down[from] = append(down[from], to)
+			up[to] = append(up[to], from)
+		}
+	}
+	setPos(layers)
+	best := copyLayers(layers)
+	bestCrossings := crossings(layers, down)
+	for iter := 0; iter < orderIters && bestCrossings > 0; iter++ {
+		if iter%2 == 0 {
+			for i := 1; i < len(layers); i++ {
+				sortByBarycenter(layers[i], up)
+			}
+		} else {
+			for i := len(layers) - 2; i >= 0; i-- {
+				sortByBarycenter(layers[i], down)
+			}
+		}
+		if c := crossings(layers, down); c < bestCrossings {
+			best = copyLayers(layers)
+			bestCrossings = c
+		}
+	}
+	for i := range layers {
+		copy(layers[i], best[i])
+	}
+	setPos(layers)
+}
+
+// sortByBarycenter sorts the nodes of the given layer by the average position
+// of their neighbours in the adjacent layer. Nodes without neighbours keep
+// their position.
+func sortByBarycenter(layer []*Node, neighbours map[*Node][]*Node) {
+	bary := make(map[*Node]float64)
+	for _, n := range layer {
+		ns := neighbours[n]
+		if len(ns) == 0 {
+			bary[n] = float64(n.pos)
+			continue
+		}
+		sum := 0.0
+		for _, m := range ns {
+			sum += float64(m.pos)
+		}
+		bary[n] = sum / float64(len(ns))
+	}
+	sort.SliceStable(layer, func(i, j int) bool {
+		return bary[layer[i]] < bary[layer[j]]
+	})
+	for pos, n := range layer {
+		n.pos = pos
+	}
+}
+
+// crossings returns the number of edge crossings between adjacent layers.
+func crossings(layers [][]*Node, down map[*Node][]*Node) int {
+	total := 0
+	for _, layer := range layers {
+		type segment struct{ from, to int }
+		var segs []segment
+		for _, n := range layer {
+			for _, m := range down[n] {
+				segs = append(segs, segment{from: n.pos, to: m.pos})
+			}
+		}
+		for i := range segs {
+			for j := i + 1; j < len(segs); j++ {
+				a, b := segs[i], segs[j]
+				if (a.from < b.from && a.to > b.to) || (a.from > b.from && a.to < b.to) {
+					total++
+				}
+			}
+		}
+	}
+	return total
+}
+
+// assignCoords assigns coordinates to the nodes of each layer, placing nodes
+// close to the average position of their neighbours without overlap.
+func (l *Layout) assignCoords(layers [][]*Node) {
+	neighbours := make(map[*Node][]*Node)
+	for _, e := range l.Edges {
+		for i := 1; i < len(e.chain); i++ {
+			from, to := e.chain[i-1], e.chain[i]
+			neighbours[from] = append(neighbours[from], to)
+			neighbours[to] = append(neighbours[to], from)
+		}
+	}
+	// Initial placement; left-aligned layers.
+	y := float64(margin)
+	for _, layer := range layers {
+		height := 0.0
+		for _, n := range layer {
+			if n.H > height {
+				height = n.H
+			}
+		}
+		x := float64(margin)
+		for _, n := range layer {
+			n.X = x + n.W/2
+			n.Y = y + height/2
+			x += n.W + nodeSep
+		}
+		y += height + rankSep
+	}
+	// Move nodes towards the average position of their neighbours.
+	for iter := 0; iter < coordIters; iter++ {
+		for _, layer := range layers {
+			for _, n := range layer {
+				ns := neighbours[n]
+				if len(ns) == 0 {
+					continue
+				}
+				sum := 0.0
+				for _, m := range ns {
+					sum += m.X
+				}
+				n.X = sum / float64(len(ns))
+			}
+			separate(layer)
+		}
+	}
+	// Translate the drawing to the margin. The drawing is at least as large as
+	// its margins, so that empty graphs are drawn as blank images.
+	l.Width, l.Height = 2*margin, 2*margin
+	minX := 0.0
+	for i, n := range l.allNodes(layers) {
+		if left := n.X - n.W/2; i == 0 || left < minX {
+			minX = left
+		}
+	}
+	for _, n := range l.allNodes(layers) {
+		n.X += margin - minX
+		if right := n.X + n.W/2 + margin; right > l.Width {
+			l.Width = right
+		}
+		if bottom := n.Y + n.H/2 + margin; bottom > l.Height {
+			l.Height = bottom
+		}
+	}
+}
+
+// separate resolves overlaps between adjacent nodes of the given layer, while
+// keeping the average displacement small.
+func separate(layer []*Node) {
+	if len(layer) == 0 {
+		return
+	}
+	want := make([]float64, len(layer))
+	for i, n := range layer {
+		want[i] = n.X
+	}
+	// Push nodes to the right of their left neighbour.
+	for i := 1; i < len(layer); i++ {
+		prev, n := layer[i-1], layer[i]
+		if min := prev.X + prev.W/2 + nodeSep + n.W/2; n.X < min {
+			n.X = min
+		}
+	}
+	// Shift the layer back to its desired center of mass.
+	shift := 0.0
+	for i, n := range layer {
+		shift += want[i] - n.X
+	}
+	shift /= float64(len(layer))
+	for _, n := range layer {
+		n.X += shift
+	}
+}
+
+// routeEdges computes the polyline points of each edge.
+func (l *Layout) routeEdges() {
+	for _, e := range l.Edges {
+		if e.self {
+			n := e.chain[0]
+			right := n.X + n.W/2
+			e.Points = []Point{
+				{X: right - 4, Y: n.Y - n.H/2 + 4},
+				{X: right + 18, Y: n.Y - n.H/2 - 6},
+				{X: right + 18, Y: n.Y + n.H/2 + 6},
+				{X: right - 4, Y: n.Y + n.H/2 - 4},
+			}
+			if r := right + 18 + margin; r > l.Width {
+				l.Width = r
+			}
+			continue
+		}
+		var points []Point
+		for i, n := range e.chain {
+			switch {
+			case i == 0:
+				points = append(points, Point{X: n.X, Y: n.Y + n.H/2})
+			case i == len(e.chain)-1:
+				points = append(points, Point{X: n.X, Y: n.Y - n.H/2})
+			default:
+				points = append(points, Point{X: n.X, Y: n.Y})
+			}
+		}
+		if e.reversed {
+			for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+				points[i], points[j] = points[j], points[i]
+			}
+		}
+		e.Points = points
+	}
+}
+
+// allNodes returns the nodes of all layers, including dummy nodes.
+func (l *Layout) allNodes(layers [][]*Node) []*Node {
+	var nodes []*Node
+	for _, layer := range layers {
+		nodes = append(nodes, layer...)
+	}
+	return nodes
+}
+
+// LabelPos returns the position of the label of the edge.
+func (e *Edge) LabelPos() Point {
+	// Place label next to the midpoint of the middle segment.
+	i := (len(e.Points) - 1) / 2
+	a, b := e.Points[i], e.Points[i+1]
+	return Point{X: (a.X+b.X)/2 + 4, Y: (a.Y + b.Y) / 2}
+}
+
+// setPos updates the position of each node within its layer.
+func setPos(layers [][]*Node) {
+	for _, layer := range layers {
+		for pos, n := range layer {
+			n.pos = pos
+		}
+	}
+}
+
+// copyLayers returns a copy of the given layers.
+func copyLayers(layers [][]*Node) [][]*Node {
+	cp := make([][]*Node, len(layers))
+	for i, layer := range layers {
+		cp[i] = append([]*Node(nil), layer...)
+	}
+	return cp
+}
+
+// attributes returns the DOT attributes of the given node or edge.
+func attributes(v interface{}) map[string]string {
+	attrs := make(map[string]string)
+	if a, ok := v.(encoding.Attributer); ok {
+		for _, attr := range a.Attributes() {
+			attrs[attr.Key] = attr.Value
+		}
+	}
+	return attrs
+}
+
+// nodeName returns the DOT node ID of the given node, or its numeric ID if not
+// present.
+func nodeName(n graph.Node) string {
+	if n, ok := n.(dot.Node); ok {
+		if id := n.DOTID(); len(id) > 0 {
+			return id
+		}
+	}
+	return fmt.Sprintf("%d", n.ID())
+}
+
+// unquote returns an unquoted version of s, if quoted.
+func unquote(s string) string {
+	if strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+	}
+	return s
+}
+
+// textWidth returns the width in pixels of the given label.
+func textWidth(s string) int {
+	max := 0
+	for _, line := range strings.Split(s, "\n") {
+		if n := len([]rune(line)); n > max {
+			max = n
+		}
+	}
+	return max * charWidth
+}
+
+// textHeight returns the height in pixels of the given label.
+func textHeight(s string) int {
+	return (strings.Count(s, "\n") + 1) * charHeight
+}
@@ -0,0 +1,189 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"gonum.org/v1/gonum/graph"
+)
+
+// PNG writes a PNG image of the given directed graph to w.
+func PNG(w io.Writer, g graph.Directed) error {
+	return NewLayout(g).WritePNG(w)
+}
+
+// WritePNG writes a PNG image of the layout to w.
+func (l *Layout) WritePNG(w io.Writer) error {
+	if err := png.Encode(w, l.Image()); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Image returns a raster image of the layout.
+func (l *Layout) Image() *image.RGBA {
+	bounds := image.Rect(0, 0, int(math.Ceil(l.Width)), int(math.Ceil(l.Height)))
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, image.White, image.Point{}, draw.Src)
+	for _, e := range l.Edges {
+		drawEdge(dst, e)
+	}
+	for _, n := range l.Nodes {
+		drawNode(dst, n)
+	}
+	return dst
+}
+
+// drawNode draws the node onto dst.
+func drawNode(dst *image.RGBA, n *Node) {
+	stroke := colorOf(n.Attrs["color"], color.Black)
+	dashed := hasStyle(n.Attrs, "dashed")
+	if hasStyle(n.Attrs, "filled") {
+		fill := colorOf(n.Attrs["fillcolor"], color.RGBA{R: 0xD3, G: 0xD3, B: 0xD3, A: 0xFF})
+		if isBox(n.Attrs) {
+			fillRect(dst, n.X-n.W/2, n.Y-n.H/2, n.X+n.W/2, n.Y+n.H/2, fill)
+		} else {
+			fillEllipse(dst, n.X, n.Y, n.W/2, n.H/2, fill)
+		}
+	}
+	peripheries := 1
+	if n.Attrs["peripheries"] == "2" {
+		peripheries = 2
+	}
+	for i := 0; i < peripheries; i++ {
+		grow := float64(4 * i)
+		if isBox(n.Attrs) {
+			x0, y0 := n.X-n.W/2-grow, n.Y-n.H/2-grow
+			x1, y1 := n.X+n.W/2+grow, n.Y+n.H/2+grow
+			drawPolyline(dst, []Point{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}, {x0, y0}}, stroke, dashed)
+		} else {
+			strokeEllipse(dst, n.X, n.Y, n.W/2+grow, n.H/2+grow, stroke, dashed)
+		}
+	}
+	drawText(dst, n.Label, n.X, n.Y, true)
+}
+
+// drawEdge draws the edge onto dst.
+func drawEdge(dst *image.RGBA, e *Edge) {
+	stroke := colorOf(e.Attrs["color"], color.Black)
+	drawPolyline(dst, e.Points, stroke, hasStyle(e.Attrs, "dashed"))
+	tip, base := arrowhead(e.Points)
+	fillTriangle(dst, tip, base[0], base[1], stroke)
+	if len(e.Label) > 0 {
+		p := e.LabelPos()
+		drawText(dst, e.Label, p.X, p.Y, false)
+	}
+}
+
+// drawText draws the given text, vertically centered at y, onto dst. If center
+// is set, the text is also horizontally centered at x.
+func drawText(dst *image.RGBA, s string, x, y float64, center bool) {
+	lines := strings.Split(s, "\n")
+	top := y - float64(len(lines)*charHeight)/2
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.Black,
+		Face: basicfont.Face7x13,
+	}
+	for i, line := range lines {
+		left := x
+		if center {
+			left -= float64(len([]rune(line))*charWidth) / 2
+		}
+		baseline := top + float64((i+1)*charHeight) - 3
+		d.Dot = fixed.P(int(math.Round(left)), int(math.Round(baseline)))
+		d.DrawString(line)
+	}
+}
+
+// drawPolyline draws the line segments of the given polyline onto dst.
+func drawPolyline(dst *image.RGBA, points []Point, c color.Color, dashed bool) {
+	// dist tracks the distance travelled along the polyline, for dashes.
+	dist := 0.0
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		length := math.Hypot(b.X-a.X, b.Y-a.Y)
+		steps := int(math.Ceil(length * 2))
+		for step := 0; step <= steps; step++ {
+			t := 0.0
+			if steps > 0 {
+				t = float64(step) / float64(steps)
+			}
+			if dashed && math.Mod(dist+t*length, 8) >= 5 {
+				continue
+			}
+			x := a.X + t*(b.X-a.X)
+			y := a.Y + t*(b.Y-a.Y)
+			dst.Set(int(math.Round(x)), int(math.Round(y)), c)
+		}
+		dist += length
+	}
+}
+
+// strokeEllipse draws the outline of the given ellipse onto dst.
+func strokeEllipse(dst *image.RGBA, cx, cy, rx, ry float64, c color.Color, dashed bool) {
+	steps := int(math.Ceil(2 * math.Pi * math.Max(rx, ry) * 2))
+	var points []Point
+	for step := 0; step <= steps; step++ {
+		theta := 2 * math.Pi * float64(step) / float64(steps)
+		points = append(points, Point{X: cx + rx*math.Cos(theta), Y: cy + ry*math.Sin(theta)})
+	}
+	drawPolyline(dst, points, c, dashed)
+}
+
+// fillEllipse fills the given ellipse onto dst.
+func fillEllipse(dst *image.RGBA, cx, cy, rx, ry float64, c color.Color) {
+	for y := int(cy - ry); y <= int(cy+ry); y++ {
+		for x := int(cx - rx); x <= int(cx+rx); x++ {
+			dx, dy := (float64(x)-cx)/rx, (float64(y)-cy)/ry
+			if dx*dx+dy*dy <= 1 {
+				dst.Set(x, y, c)
+			}
+		}
+	}
+}
+
+// fillRect fills the given rectangle onto dst.
+func fillRect(dst *image.RGBA, x0, y0, x1, y1 float64, c color.Color) {
+	r := image.Rect(int(x0), int(y0), int(x1), int(y1))
+	draw.Draw(dst, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// fillTriangle fills the given triangle onto dst.
+func fillTriangle(dst *image.RGBA, a, b, c Point, col color.Color) {
+	minX := math.Floor(math.Min(a.X, math.Min(b.X, c.X)))
+	maxX := math.Ceil(math.Max(a.X, math.Max(b.X, c.X)))
+	minY := math.Floor(math.Min(a.Y, math.Min(b.Y, c.Y)))
+	maxY := math.Ceil(math.Max(a.Y, math.Max(b.Y, c.Y)))
+	// edge returns the signed area of the parallelogram spanned by p-q and r-q.
+	edge := func(p, q, r Point) float64 {
+		return (p.X-q.X)*(r.Y-q.Y) - (p.Y-q.Y)*(r.X-q.X)
+	}
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			p := Point{X: x, Y: y}
+			w0, w1, w2 := edge(p, a, b), edge(p, b, c), edge(p, c, a)
+			if (w0 >= 0 && w1 >= 0 && w2 >= 0) || (w0 <= 0 && w1 <= 0 && w2 <= 0) {
+				dst.Set(int(x), int(y), col)
+			}
+		}
+	}
+}
+
+// colorOf returns the color of the given DOT color attribute, or def if not
+// present or unknown.
+func colorOf(s string, def color.Color) color.Color {
+	if c, ok := parseColor(unquote(s)); ok {
+		return c
+	}
+	return def
+}
@@ -0,0 +1,213 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/decomp/decomp/graph/cfg"
+	"github.com/llir/llvm/asm"
+	"gonum.org/v1/gonum/graph"
+	"gonum.org/v1/gonum/graph/simple"
+)
+
+func TestLayers(t *testing.T) {
+	golden := []struct {
+		name  string
+		edges [][2]int64
+		// Layer of each node, in order of node ID.
+		layers []int
+		// Number of dummy nodes of each edge, in order of source and destination
+		// node ID.
+		dummies []int
+	}{
+		{
+			name:    "chain",
+			edges:   [][2]int64{{0, 1}, {1, 2}},
+			layers:  []int{0, 1, 2},
+			dummies: []int{0, 0},
+		},
+		// Edges spanning several layers are split by dummy nodes.
+		{
+			name:    "long_edge",
+			edges:   [][2]int64{{0, 1}, {0, 3}, {1, 2}, {2, 3}},
+			layers:  []int{0, 1, 2, 3},
+			dummies: []int{0, 2, 0, 0},
+		},
+		// Back edges are reversed.
+		{
+			name:    "loop",
+			edges:   [][2]int64{{0, 1}, {1, 2}, {2, 1}, {2, 3}},
+			layers:  []int{0, 1, 2, 3},
+			dummies: []int{0, 0, 0, 0},
+		},
+	}
+	for _, g := range golden {
+		l := NewLayout(newGraph(g.edges))
+		for i, n := range l.Nodes {
+			if n.layer != g.layers[i] {
+				t.Errorf("%s: layer mismatch of node %d; expected %d, got %d", g.name, n.ID(), g.layers[i], n.layer)
+			}
+		}
+		for i, e := range l.Edges {
+			dummies := 0
+			if !e.self {
+				dummies = len(e.chain) - 2
+			}
+			if dummies != g.dummies[i] {
+				t.Errorf("%s: dummy node mismatch of edge %d->%d; expected %d, got %d", g.name, e.From().ID(), e.To().ID(), g.dummies[i], dummies)
+			}
+			// Edges flow from top to bottom, except for reversed back edges.
+			from, to := e.Points[0], e.Points[len(e.Points)-1]
+			if !e.self && (from.Y < to.Y) == e.reversed {
+				t.Errorf("%s: direction mismatch of edge %d->%d; from %v, to %v, reversed %v", g.name, e.From().ID(), e.To().ID(), from, to, e.reversed)
+			}
+		}
+	}
+}
+
+func TestCrossings(t *testing.T) {
+	golden := []struct {
+		name  string
+		edges [][2]int64
+	}{
+		// The dummy node of edge 0->4 is initially placed to the right of node 2,
+		// and edge 2->3 crosses the edge from the dummy node to node 4.
+		{
+			name:  "dummy",
+			edges: [][2]int64{{0, 1}, {0, 2}, {0, 4}, {1, 4}, {2, 3}},
+		},
+		{
+			name:  "dummies",
+			edges: [][2]int64{{0, 1}, {0, 2}, {0, 5}, {1, 3}, {2, 4}, {3, 5}, {4, 6}},
+		},
+	}
+	for _, g := range golden {
+		l := NewLayout(newGraph(g.edges))
+		// Collect the layers of the layout, including dummy nodes.
+		var layers [][]*Node
+		down := make(map[*Node][]*Node)
+		seen := make(map[*Node]bool)
+		for _, e := range l.Edges {
+			for i, n := range e.chain {
+				if !seen[n] {
+					seen[n] = true
+					for len(layers) <= n.layer {
+						layers = append(layers, nil)
+					}
+					layers[n.layer] = append(layers[n.layer], n)
+				}
+				if i > 0 {
+					down[e.chain[i-1]] = append(down[e.chain[i-1]], n)
+				}
+			}
+		}
+		if c := crossings(layers, down); c != 0 {
+			t.Errorf("%s: crossings mismatch; expected 0, got %d", g.name, c)
+		}
+		// Nodes of a layer are ordered from left to right without overlap.
+		for _, layer := range layers {
+			for _, a := range layer {
+				for _, b := range layer {
+					if a.pos < b.pos && a.X+a.W/2 > b.X-b.W/2 {
+						t.Errorf("%s: overlapping nodes in layer %d", g.name, a.layer)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	const src = `
+define i32 @f(i32 %x) {
+entry:
+	%c = icmp slt i32 %x, 0
+	br i1 %c, label %neg, label %loop
+
+neg:
+	ret i32 0
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %j, %loop ]
+	%j = add i32 %i, 1
+	%d = icmp slt i32 %j, %x
+	br i1 %d, label %loop, label %exit
+
+exit:
+	ret i32 %j
+}
+`
+	m, err := asm.ParseString("test.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %v", err)
+	}
+	golden := []struct {
+		name   string
+		g      graph.Directed
+		labels []string
+	}{
+		{name: "cfg", g: cfg.New(m.Funcs[0]), labels: []string{"entry", "neg", "loop", "exit", "true", "false"}},
+		{name: "empty", g: simple.NewDirectedGraph()},
+	}
+	for _, g := range golden {
+		l := NewLayout(g.g)
+		if l.Width < 2*margin || l.Height < 2*margin {
+			t.Errorf("%s: invalid layout size %vx%v", g.name, l.Width, l.Height)
+		}
+		// SVG output.
+		buf := &bytes.Buffer{}
+		if err := l.WriteSVG(buf); err != nil {
+			t.Errorf("%s: unable to write SVG image; %+v", g.name, err)
+			continue
+		}
+		svg := buf.String()
+		dec := xml.NewDecoder(strings.NewReader(svg))
+		for {
+			if _, err := dec.Token(); err != nil {
+				if err != io.EOF {
+					t.Errorf("%s: invalid SVG image; %v", g.name, err)
+				}
+				break
+			}
+		}
+		for _, label := range g.labels {
+			if !strings.Contains(svg, ">"+label+"<") {
+				t.Errorf("%s: label %q missing from SVG image", g.name, label)
+			}
+		}
+		// PNG output.
+		buf.Reset()
+		if err := l.WritePNG(buf); err != nil {
+			t.Errorf("%s: unable to write PNG image; %+v", g.name, err)
+			continue
+		}
+		img, err := png.Decode(buf)
+		if err != nil {
+			t.Errorf("%s: unable to decode PNG image; %v", g.name, err)
+			continue
+		}
+		width, height := int(math.Ceil(l.Width)), int(math.Ceil(l.Height))
+		if b := img.Bounds(); b.Dx() != width || b.Dy() != height {
+			t.Errorf("%s: PNG image size mismatch; expected %dx%d, got %dx%d", g.name, width, height, b.Dx(), b.Dy())
+		}
+	}
+}
+
+// newGraph returns a directed graph with the given edges.
+func newGraph(edges [][2]int64) *simple.DirectedGraph {
+	g := simple.NewDirectedGraph()
+	for _, e := range edges {
+		for _, id := range e {
+			if g.Node(id) == nil {
+				g.AddNode(simple.Node(id))
+			}
+		}
+		g.SetEdge(g.NewEdge(g.Node(e[0]), g.Node(e[1])))
+	}
+	return g
+}
@@ -0,0 +1,152 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/graph"
+)
+
+// SVG writes an SVG image of the given directed graph to w.
+func SVG(w io.Writer, g graph.Directed) error {
+	return NewLayout(g).WriteSVG(w)
+}
+
+// WriteSVG writes an SVG image of the layout to w.
+func (l *Layout) WriteSVG(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<svg xmlns=%q width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\">\n", "http://www.w3.org/2000/svg", l.Width, l.Height, l.Width, l.Height)
+	if len(l.Name) > 0 {
+		fmt.Fprintf(bw, "\t<title>%s</title>\n", html.EscapeString(l.Name))
+	}
+	fmt.Fprintf(bw, "\t<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n")
+	fmt.Fprintf(bw, "\t<g font-family=\"monospace\" font-size=\"%d\">\n", charHeight-1)
+	for _, e := range l.Edges {
+		writeSVGEdge(bw, e)
+	}
+	for _, n := range l.Nodes {
+		writeSVGNode(bw, n)
+	}
+	fmt.Fprintf(bw, "\t</g>\n")
+	fmt.Fprintf(bw, "</svg>\n")
+	if err := bw.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// writeSVGNode writes an SVG representation of the node to w.
+func writeSVGNode(w io.Writer, n *Node) {
+	stroke := svgColor(n.Attrs["color"], "black")
+	fill := "white"
+	if hasStyle(n.Attrs, "filled") {
+		fill = svgColor(n.Attrs["fillcolor"], "lightgray")
+	}
+	dash := ""
+	if hasStyle(n.Attrs, "dashed") {
+		dash = ` stroke-dasharray="5,3"`
+	}
+	peripheries := 1
+	if n.Attrs["peripheries"] == "2" {
+		peripheries = 2
+	}
+	for i := 0; i < peripheries; i++ {
+		grow := float64(4 * i)
+		f := fill
+		if i > 0 {
+			f = "none"
+		}
+		if isBox(n.Attrs) {
+			fmt.Fprintf(w, "\t\t<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=%q stroke=%q%s/>\n", n.X-n.W/2-grow, n.Y-n.H/2-grow, n.W+2*grow, n.H+2*grow, f, stroke, dash)
+		} else {
+			fmt.Fprintf(w, "\t\t<ellipse cx=\"%.1f\" cy=\"%.1f\" rx=\"%.1f\" ry=\"%.1f\" fill=%q stroke=%q%s/>\n", n.X, n.Y, n.W/2+grow, n.H/2+grow, f, stroke, dash)
+		}
+	}
+	writeSVGText(w, n.Label, n.X, n.Y, "middle")
+}
+
+// writeSVGEdge writes an SVG representation of the edge to w.
+func writeSVGEdge(w io.Writer, e *Edge) {
+	stroke := svgColor(e.Attrs["color"], "black")
+	dash := ""
+	if hasStyle(e.Attrs, "dashed") {
+		dash = ` stroke-dasharray="5,3"`
+	}
+	var points []string
+	for _, p := range e.Points {
+		points = append(points, fmt.Sprintf("%.1f,%.1f", p.X, p.Y))
+	}
+	fmt.Fprintf(w, "\t\t<polyline points=%q fill=\"none\" stroke=%q%s/>\n", strings.Join(points, " "), stroke, dash)
+	// Arrowhead.
+	tip, base := arrowhead(e.Points)
+	fmt.Fprintf(w, "\t\t<polygon points=\"%.1f,%.1f %.1f,%.1f %.1f,%.1f\" fill=%q stroke=%q/>\n", tip.X, tip.Y, base[0].X, base[0].Y, base[1].X, base[1].Y, stroke, stroke)
+	if len(e.Label) > 0 {
+		p := e.LabelPos()
+		writeSVGText(w, e.Label, p.X, p.Y, "start")
+	}
+}
+
+// writeSVGText writes an SVG text element, vertically centered at y, to w.
+func writeSVGText(w io.Writer, s string, x, y float64, anchor string) {
+	lines := strings.Split(s, "\n")
+	top := y - float64(len(lines)*charHeight)/2
+	for i, line := range lines {
+		baseline := top + float64((i+1)*charHeight) - 3
+		fmt.Fprintf(w, "\t\t<text x=\"%.1f\" y=\"%.1f\" text-anchor=%q>%s</text>\n", x, baseline, anchor, html.EscapeString(line))
+	}
+}
+
+// arrowhead returns the tip and the two base corners of the arrowhead at the
+// end of the given polyline.
+func arrowhead(points []Point) (tip Point, base [2]Point) {
+	const length, width = 9, 4
+	tip = points[len(points)-1]
+	prev := points[len(points)-2]
+	dx, dy := tip.X-prev.X, tip.Y-prev.Y
+	d := math.Hypot(dx, dy)
+	if d == 0 {
+		dx, dy, d = 0, 1, 1
+	}
+	ux, uy := dx/d, dy/d
+	bx, by := tip.X-ux*length, tip.Y-uy*length
+	base[0] = Point{X: bx - uy*width, Y: by + ux*width}
+	base[1] = Point{X: bx + uy*width, Y: by - ux*width}
+	return tip, base
+}
+
+// hasStyle reports whether the "style" attribute contains the given style.
+func hasStyle(attrs map[string]string, style string) bool {
+	for _, s := range strings.Split(unquote(attrs["style"]), ",") {
+		if strings.TrimSpace(s) == style {
+			return true
+		}
+	}
+	return false
+}
+
+// isBox reports whether the node shape is rectangular.
+func isBox(attrs map[string]string) bool {
+	switch unquote(attrs["shape"]) {
+	case "box", "rect", "rectangle", "square", "record":
+		return true
+	}
+	return false
+}
+
+// svgColor returns the SVG color of the given DOT color attribute, or def if
+// not present.
+func svgColor(color, def string) string {
+	color = unquote(color)
+	if len(color) == 0 {
+		return def
+	}
+	if _, ok := parseColor(color); !ok {
+		return def
+	}
+	return color
+}
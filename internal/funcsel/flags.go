@@ -0,0 +1,70 @@
+package funcsel
+
+import (
+	"flag"
+	"log"
+)
+
+// Flags holds the values of the function selection command line flags shared
+// by the decompiler tools.
+type Flags struct {
+	// addrs represents a comma-separated list of address ranges of functions
+	// to parse.
+	addrs string
+	// exclude represents a comma-separated list of functions to skip.
+	exclude string
+	// excludeRegex represents a regular expression of functions to skip.
+	excludeRegex string
+	// funcs represents a comma-separated list of functions to parse.
+	funcs string
+	// funcsFile represents a file containing the names of functions to parse,
+	// one per line.
+	funcsFile string
+	// funcsRegex represents a regular expression of functions to parse.
+	funcsRegex string
+	// reachable represents a comma-separated list of functions from which
+	// reachable functions are parsed.
+	reachable string
+}
+
+// RegisterFlags registers the function selection flags with the given flag
+// set.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.addrs, "addrs", "", `comma-separated list of address ranges of functions to parse (e.g. "0x401000-0x402000")`)
+	fs.StringVar(&f.exclude, "exclude", "", "comma-separated list of functions to skip")
+	fs.StringVar(&f.excludeRegex, "exclude-regex", "", "regular expression of functions to skip")
+	fs.StringVar(&f.funcs, "funcs", "", "comma-separated list of functions to parse")
+	fs.StringVar(&f.funcsFile, "funcs-file", "", "file containing functions to parse, one per line")
+	fs.StringVar(&f.funcsRegex, "funcs-regex", "", "regular expression of functions to parse")
+	fs.StringVar(&f.reachable, "reachable", "", `comma-separated list of functions from which reachable functions in the call graph are parsed (e.g. "main")`)
+	return f
+}
+
+// Selector returns a selector of the functions specified by the parsed flags,
+// which logs warnings to the given logger.
+func (f *Flags) Selector(logger *log.Logger) (*Selector, error) {
+	sel := &Selector{Logger: logger}
+	sel.AddNames(f.funcs)
+	if len(f.funcsFile) > 0 {
+		if err := sel.AddNamesFile(f.funcsFile); err != nil {
+			return nil, err
+		}
+	}
+	if len(f.funcsRegex) > 0 {
+		if err := sel.AddPattern(f.funcsRegex); err != nil {
+			return nil, err
+		}
+	}
+	if err := sel.AddRanges(f.addrs); err != nil {
+		return nil, err
+	}
+	sel.AddRoots(f.reachable)
+	sel.Exclude(f.exclude)
+	if len(f.excludeRegex) > 0 {
+		if err := sel.ExcludePattern(f.excludeRegex); err != nil {
+			return nil, err
+		}
+	}
+	return sel, nil
+}
@@ -0,0 +1,312 @@
+// Package funcsel implements the selection of functions from LLVM IR modules,
+// by name, regular expression, address range and call graph reachability.
+package funcsel
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/decomp/decomp/graph/callgraph"
+	"github.com/llir/llvm/ir"
+	"github.com/pkg/errors"
+)
+
+// A Selector selects functions of LLVM IR modules.
+//
+// A function is selected if it matches any of the inclusion criteria (names,
+// patterns, address ranges and functions reachable from roots) and none of the
+// exclusion criteria. If no inclusion criteria have been specified, every
+// function is included. The zero value of Selector is ready to use and selects
+// every function.
+type Selector struct {
+	// Names of functions to include.
+	names map[string]bool
+	// Patterns of function names to include.
+	patterns []*regexp.Regexp
+	// Address ranges of functions to include.
+	ranges []Range
+	// Names of functions from which reachable functions are included.
+	roots []string
+	// Names of functions to exclude.
+	excludeNames map[string]bool
+	// Patterns of function names to exclude.
+	excludePatterns []*regexp.Regexp
+
+	// Logger of warnings (e.g. modules without root functions); or no logging
+	// if nil.
+	Logger *log.Logger
+}
+
+// AddNames includes the functions of the given comma-separated list of names.
+func (s *Selector) AddNames(list string) {
+	for _, name := range splitList(list) {
+		s.addName(name)
+	}
+}
+
+// addName includes the named function.
+func (s *Selector) addName(name string) {
+	if s.names == nil {
+		s.names = make(map[string]bool)
+	}
+	s.names[name] = true
+}
+
+// AddNamesFile includes the functions named in the given file, which contains
+// one function name per line; names may contain commas (e.g. C++ template
+// functions). Empty lines and lines starting with '#' are ignored.
+func (s *Selector) AddNamesFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s.addName(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// AddPattern includes the functions with names matching the given regular
+// expression.
+func (s *Selector) AddPattern(expr string) error {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	s.patterns = append(s.patterns, re)
+	return nil
+}
+
+// AddRanges includes the functions with addresses in the given comma-separated
+// list of address ranges (e.g. "0x401000-0x402000,0x405000-0x406000"). The end
+// address of each range is exclusive.
+//
+// The address of a function is derived from its name, as used by lifted
+// modules (e.g. "sub_401000", "fcn.00401000").
+func (s *Selector) AddRanges(list string) error {
+	for _, r := range splitList(list) {
+		rng, err := ParseRange(r)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		s.ranges = append(s.ranges, rng)
+	}
+	return nil
+}
+
+// AddRoots includes the functions reachable in the call graph from the
+// functions of the given comma-separated list of names, including the named
+// functions themselves.
+func (s *Selector) AddRoots(list string) {
+	s.roots = append(s.roots, splitList(list)...)
+}
+
+// Exclude excludes the functions of the given comma-separated list of names.
+func (s *Selector) Exclude(list string) {
+	for _, name := range splitList(list) {
+		if s.excludeNames == nil {
+			s.excludeNames = make(map[string]bool)
+		}
+		s.excludeNames[name] = true
+	}
+}
+
+// ExcludePattern excludes the functions with names matching the given regular
+// expression.
+func (s *Selector) ExcludePattern(expr string) error {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	s.excludePatterns = append(s.excludePatterns, re)
+	return nil
+}
+
+// Funcs returns the selected functions of the given module, in module order.
+func (s *Selector) Funcs(m *ir.Module) []*ir.Func {
+	reachable := s.reachable(m)
+	var funcs []*ir.Func
+	for _, f := range m.Funcs {
+		if !s.included(f.Name(), reachable) || s.excluded(f.Name()) {
+			continue
+		}
+		funcs = append(funcs, f)
+	}
+	return funcs
+}
+
+// included reports whether the given function is included by the inclusion
+// criteria of s, given the set of functions reachable from the roots of s.
+func (s *Selector) included(name string, reachable map[string]bool) bool {
+	if len(s.names) == 0 && len(s.patterns) == 0 && len(s.ranges) == 0 && len(s.roots) == 0 {
+		return true
+	}
+	if s.names[name] || reachable[name] {
+		return true
+	}
+	for _, re := range s.patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	if addr, ok := Addr(name); ok {
+		for _, r := range s.ranges {
+			if r.Contains(addr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// excluded reports whether the given function is excluded by the exclusion
+// criteria of s.
+func (s *Selector) excluded(name string) bool {
+	if s.excludeNames[name] {
+		return true
+	}
+	for _, re := range s.excludePatterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// reachable returns the set of functions reachable in the call graph of the
+// given module from the roots of s. Potential callees of indirect calls are
+// considered reachable. Roots not present in the module are skipped, as a root
+// function is typically defined in one of several modules.
+func (s *Selector) reachable(m *ir.Module) map[string]bool {
+	if len(s.roots) == 0 {
+		return nil
+	}
+	g := callgraph.New(m)
+	reachable := make(map[string]bool)
+	var queue []*callgraph.Node
+	for _, root := range s.roots {
+		n, ok := g.NodeByName(root)
+		if !ok {
+			s.logf("WARNING: unable to locate root function %q in module %q", root, m.SourceFilename)
+			continue
+		}
+		if !reachable[n.Name] {
+			reachable[n.Name] = true
+			queue = append(queue, n)
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, callee := range g.Callees(n) {
+			if !reachable[callee.Name] {
+				reachable[callee.Name] = true
+				queue = append(queue, callee)
+			}
+		}
+	}
+	return reachable
+}
+
+// logf logs the given message, if a logger is present.
+func (s *Selector) logf(format string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, args...)
+	}
+}
+
+// A Range is a half-open address range [Start, End).
+type Range struct {
+	// Start address (inclusive).
+	Start uint64
+	// End address (exclusive).
+	End uint64
+}
+
+// ParseRange parses the given address range of the form "START-END", where
+// START and END are hexadecimal addresses with an optional "0x" prefix.
+func ParseRange(s string) (Range, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return Range{}, errors.Errorf("invalid address range %q; expected START-END", s)
+	}
+	start, err := parseAddr(parts[0])
+	if err != nil {
+		return Range{}, errors.WithStack(err)
+	}
+	end, err := parseAddr(parts[1])
+	if err != nil {
+		return Range{}, errors.WithStack(err)
+	}
+	if start > end {
+		return Range{}, errors.Errorf("invalid address range %q; start address larger than end address", s)
+	}
+	return Range{Start: start, End: end}, nil
+}
+
+// Contains reports whether the given address is within the address range.
+func (r Range) Contains(addr uint64) bool {
+	return r.Start <= addr && addr < r.End
+}
+
+// parseAddr parses the given hexadecimal address, with an optional "0x"
+// prefix.
+func parseAddr(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	addr, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return addr, nil
+}
+
+// addrSuffix matches the hexadecimal address suffix of function names of
+// lifted modules; either following one of the address prefixes of lifters (e.g.
+// "sub_401000", "FUN_00401000", "fcn.00401000") or a "_0x" separator (e.g.
+// "foo_0x401000").
+var addrSuffix = regexp.MustCompile(`^(?:sub_|FUN_|fcn\.|func_|loc_)(?:0x)?([0-9A-Fa-f]{1,16})$|_0x([0-9A-Fa-f]{1,16})$`)
+
+// Addr returns the address of the given function, as derived from the
+// hexadecimal suffix of its name. The boolean return value indicates success.
+func Addr(name string) (uint64, bool) {
+	m := addrSuffix.FindStringSubmatch(name)
+	if m == nil {
+		return 0, false
+	}
+	hex := m[1]
+	if len(hex) == 0 {
+		hex = m[2]
+	}
+	addr, err := strconv.ParseUint(hex, 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return addr, true
+}
+
+// splitList splits the given comma-separated list, omitting empty elements.
+func splitList(list string) []string {
+	var elems []string
+	for _, elem := range strings.Split(list, ",") {
+		elem = strings.TrimSpace(elem)
+		if len(elem) == 0 {
+			continue
+		}
+		elems = append(elems, elem)
+	}
+	return elems
+}
@@ -0,0 +1,221 @@
+package funcsel
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+)
+
+func TestSelector(t *testing.T) {
+	const src = `
+define void @main() {
+	call void @sub_401000()
+	ret void
+}
+
+define void @sub_401000() {
+	call void @sub_401100()
+	ret void
+}
+
+define void @sub_401100() {
+	ret void
+}
+
+define void @sub_402000() {
+	ret void
+}
+
+define void @helper() {
+	ret void
+}
+`
+	m, err := asm.ParseString("test.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %v", err)
+	}
+	golden := []struct {
+		setup func(s *Selector) error
+		want  []string
+	}{
+		{
+			setup: func(s *Selector) error { return nil },
+			want:  []string{"main", "sub_401000", "sub_401100", "sub_402000", "helper"},
+		},
+		{
+			setup: func(s *Selector) error {
+				s.AddNames("helper,main")
+				return nil
+			},
+			want: []string{"main", "helper"},
+		},
+		{
+			setup: func(s *Selector) error {
+				s.Exclude("sub_401100")
+				return s.AddPattern(`^sub_`)
+			},
+			want: []string{"sub_401000", "sub_402000"},
+		},
+		{
+			setup: func(s *Selector) error {
+				return s.AddRanges("0x401000-0x402000")
+			},
+			want: []string{"sub_401000", "sub_401100"},
+		},
+		{
+			setup: func(s *Selector) error {
+				s.AddRoots("main")
+				return s.ExcludePattern(`^main$`)
+			},
+			want: []string{"sub_401000", "sub_401100"},
+		},
+		// Roots not present in the module are skipped.
+		{
+			setup: func(s *Selector) error {
+				s.AddRoots("sub_401000,WinMain")
+				return nil
+			},
+			want: []string{"sub_401000", "sub_401100"},
+		},
+		{
+			setup: func(s *Selector) error {
+				s.AddRoots("WinMain")
+				return nil
+			},
+			want: nil,
+		},
+	}
+	for i, g := range golden {
+		s := &Selector{}
+		if err := g.setup(s); err != nil {
+			t.Errorf("i=%d: unable to set up selector; %v", i, err)
+			continue
+		}
+		funcs := s.Funcs(m)
+		if got := names(funcs); !reflect.DeepEqual(got, g.want) {
+			t.Errorf("i=%d: functions mismatch; expected %v, got %v", i, g.want, got)
+		}
+	}
+}
+
+func TestAddr(t *testing.T) {
+	golden := []struct {
+		name string
+		want uint64
+		ok   bool
+	}{
+		{name: "sub_401000", want: 0x401000, ok: true},
+		{name: "FUN_00401000", want: 0x401000, ok: true},
+		{name: "fcn.0x00401000", want: 0x401000, ok: true},
+		{name: "main", ok: false},
+		{name: "foo_0x401000", want: 0x401000, ok: true},
+		{name: "do_add", ok: false},
+		{name: "add_1000", ok: false},
+		{name: "buf_4096", ok: false},
+		{name: "decode.cafe", ok: false},
+	}
+	for _, g := range golden {
+		got, ok := Addr(g.name)
+		if got != g.want || ok != g.ok {
+			t.Errorf("%q: address mismatch; expected (0x%X, %v), got (0x%X, %v)", g.name, g.want, g.ok, got, ok)
+		}
+	}
+}
+
+func names(funcs []*ir.Func) []string {
+	var names []string
+	for _, f := range funcs {
+		names = append(names, f.Name())
+	}
+	return names
+}
+
+func TestAddNamesFile(t *testing.T) {
+	const src = `
+define void @"f<int, int>"() {
+	ret void
+}
+
+define void @f() {
+	ret void
+}
+
+define void @g() {
+	ret void
+}
+`
+	m, err := asm.ParseString("test.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %v", err)
+	}
+	const namesFile = `
+# Function names containing commas.
+f<int, int>
+  g
+`
+	path := filepath.Join(t.TempDir(), "funcs.txt")
+	if err := ioutil.WriteFile(path, []byte(namesFile), 0644); err != nil {
+		t.Fatalf("unable to create names file; %v", err)
+	}
+	s := &Selector{}
+	if err := s.AddNamesFile(path); err != nil {
+		t.Fatalf("unable to parse names file; %v", err)
+	}
+	funcs := s.Funcs(m)
+	want := []string{"f<int, int>", "g"}
+	if got := names(funcs); !reflect.DeepEqual(got, want) {
+		t.Errorf("functions mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestFlags(t *testing.T) {
+	const src = `
+define void @main() {
+	call void @sub_401000()
+	ret void
+}
+
+define void @sub_401000() {
+	ret void
+}
+
+define void @sub_402000() {
+	ret void
+}
+
+define void @helper() {
+	ret void
+}
+`
+	m, err := asm.ParseString("test.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %v", err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := RegisterFlags(fs)
+	args := []string{"-reachable", "main", "-funcs-regex", "^help", "-exclude", "sub_401000"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("unable to parse flags; %v", err)
+	}
+	s, err := f.Selector(nil)
+	if err != nil {
+		t.Fatalf("unable to create selector; %v", err)
+	}
+	want := []string{"main", "helper"}
+	if got := names(s.Funcs(m)); !reflect.DeepEqual(got, want) {
+		t.Errorf("functions mismatch; expected %v, got %v", want, got)
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	f = RegisterFlags(fs)
+	if err := fs.Parse([]string{"-exclude-regex", "["}); err != nil {
+		t.Fatalf("unable to parse flags; %v", err)
+	}
+	if _, err := f.Selector(nil); err == nil {
+		t.Errorf("expected error for invalid -exclude-regex")
+	}
+}
@@ -0,0 +1,27 @@
+package lower
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+// TrueTarget returns the name of the basic block to which the given
+// conditional terminator transfers control when its condition holds; i.e. the
+// true branch of a conditional branch terminator, or the target of the case of
+// a switch terminator with a single case. The boolean return value indicates
+// success.
+//
+// The condition of a control flow primitive is negated if its body is not the
+// true target of the terminator.
+func TrueTarget(term ir.Terminator) (string, bool) {
+	switch term := term.(type) {
+	case *ir.TermCondBr:
+		return term.TargetTrue.(value.Named).Name(), true
+	case *ir.TermSwitch:
+		if len(term.Cases) != 1 {
+			return "", false
+		}
+		return term.Cases[0].Target.(value.Named).Name(), true
+	}
+	return "", false
+}
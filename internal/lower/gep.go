@@ -0,0 +1,82 @@
+package lower
+
+import (
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// A Step is a step of the access path of a getelementptr instruction, which
+// selects a struct field or an array or vector element.
+type Step struct {
+	// Struct type of the selected field; or nil if an array or vector element
+	// is selected.
+	Struct *types.StructType
+	// Index of the selected struct field.
+	Field int
+	// Index of the selected array or vector element.
+	Index value.Value
+	// Type of the selected field or element.
+	Type types.Type
+}
+
+// Path returns the access path of the given getelementptr indices into the
+// element type elemType, excluding the first index, which offsets the source
+// address in units of the element type.
+//
+//    getelementptr %T, %T* %p, i64 0, i32 1, i64 %i   ; field_1[i]
+func Path(elemType types.Type, indices []value.Value) ([]Step, error) {
+	var path []Step
+	t := elemType
+	for _, index := range indices {
+		switch tt := t.(type) {
+		case *types.StructType:
+			i, ok := ConstIndex(index)
+			if !ok {
+				return nil, errors.Errorf("invalid struct index %v; expected constant integer", index)
+			}
+			if i < 0 || i >= int64(len(tt.Fields)) {
+				return nil, errors.Errorf("struct index %d out of bounds of %v", i, tt)
+			}
+			t = tt.Fields[i]
+			path = append(path, Step{Struct: tt, Field: int(i), Type: t})
+		case *types.ArrayType:
+			t = tt.ElemType
+			path = append(path, Step{Index: Index(index), Type: t})
+		case *types.VectorType:
+			t = tt.ElemType
+			path = append(path, Step{Index: Index(index), Type: t})
+		default:
+			return nil, errors.Errorf("invalid getelementptr element type; expected *types.StructType, *types.ArrayType or *types.VectorType, got %T", t)
+		}
+	}
+	return path, nil
+}
+
+// Index returns the value of the given getelementptr index, unwrapping the
+// index of constant expressions.
+func Index(index value.Value) value.Value {
+	if c, ok := index.(*constant.Index); ok {
+		return c.Constant
+	}
+	return index
+}
+
+// ConstIndex returns the value of the given getelementptr index, if constant.
+// The boolean return value indicates success.
+func ConstIndex(index value.Value) (int64, bool) {
+	switch c := Index(index).(type) {
+	case *constant.Int:
+		return c.X.Int64(), true
+	case *constant.ZeroInitializer:
+		return 0, true
+	}
+	return 0, false
+}
+
+// IsZero reports whether the given getelementptr index is the constant zero.
+func IsZero(index value.Value) bool {
+	i, ok := ConstIndex(index)
+	return ok && i == 0
+}
@@ -0,0 +1,43 @@
+// Package lower implements the parts of the lowering of LLVM IR to source code
+// which are independent of the target language, as shared by the Go and C
+// back-ends.
+//
+// This includes the access paths of getelementptr instructions, the recovery
+// of C string constants, the branch polarity of control flow primitives, the
+// operands of instructions, and the lookup of LLVM intrinsic functions and C
+// standard library functions by name.
+package lower
+
+import (
+	"strings"
+
+	"github.com/llir/llvm/ir"
+)
+
+// Intrinsic returns the name of the given LLVM intrinsic function for which
+// known reports true, stripping overloaded type suffixes until a match is found
+// (e.g. "llvm.memcpy" of "llvm.memcpy.p0i8.p0i8.i64"). The boolean return value
+// indicates success.
+func Intrinsic(name string, known func(name string) bool) (string, bool) {
+	if !strings.HasPrefix(name, "llvm.") {
+		return "", false
+	}
+	for {
+		if known(name) {
+			return name, true
+		}
+		pos := strings.LastIndex(name, ".")
+		if pos == -1 {
+			return "", false
+		}
+		name = name[:pos]
+	}
+}
+
+// Libc reports whether calls to the given function are lowered as calls to a C
+// standard library function, for which known reports true. Functions defined
+// within the module are not lowered, even if their names coincide with C
+// standard library functions.
+func Libc(f *ir.Func, known func(name string) bool) bool {
+	return len(f.Blocks) == 0 && known(f.Name())
+}
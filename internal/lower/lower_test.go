@@ -0,0 +1,181 @@
+package lower
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestIntrinsic(t *testing.T) {
+	known := map[string]bool{"llvm.memcpy": true, "llvm.ctpop": true}
+	golden := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{name: "llvm.memcpy.p0i8.p0i8.i64", want: "llvm.memcpy", ok: true},
+		{name: "llvm.ctpop.i32", want: "llvm.ctpop", ok: true},
+		{name: "llvm.ctpop", want: "llvm.ctpop", ok: true},
+		{name: "llvm.cttz.i32"},
+		// Only LLVM intrinsic functions are recognized.
+		{name: "memcpy"},
+	}
+	for _, g := range golden {
+		got, ok := Intrinsic(g.name, func(name string) bool { return known[name] })
+		if got != g.want || ok != g.ok {
+			t.Errorf("%q: intrinsic mismatch; expected %q (%v), got %q (%v)", g.name, g.want, g.ok, got, ok)
+		}
+	}
+}
+
+func TestLibc(t *testing.T) {
+	const src = `
+declare i32 @puts(i8*)
+
+declare i32 @foo(i8*)
+
+define i64 @strlen(i8* %s) {
+	ret i64 0
+}
+`
+	m := parse(t, src)
+	known := map[string]bool{"puts": true, "strlen": true}
+	// Functions defined within the module are not lowered.
+	want := map[string]bool{"puts": true, "foo": false, "strlen": false}
+	for _, f := range m.Funcs {
+		if got := Libc(f, func(name string) bool { return known[name] }); got != want[f.Name()] {
+			t.Errorf("%q: libc mismatch; expected %v, got %v", f.Name(), want[f.Name()], got)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	const src = `
+%T = type { i32, [4 x { i8, [2 x i16] }] }
+
+define void @f(%T* %p, i64 %i) {
+	%1 = getelementptr %T, %T* %p, i64 %i, i32 1, i64 %i, i32 1, i32 0
+	%2 = getelementptr %T, %T* %p, i64 0, i32 0
+	ret void
+}
+`
+	m := parse(t, src)
+	f := m.Funcs[0]
+	typ := m.TypeDefs[0]
+	golden := []struct {
+		elemType types.Type
+		indices  []value.Value
+		// String representation of the access path; or the error.
+		want string
+	}{
+		{want: "field_1 [%i] field_1 [0] -> i16"},
+		{want: "field_0 -> i32"},
+		{
+			elemType: typ,
+			indices:  []value.Value{constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 0)},
+			want:     "invalid getelementptr element type; expected *types.StructType, *types.ArrayType or *types.VectorType, got *types.IntType",
+		},
+		{
+			elemType: typ,
+			indices:  []value.Value{constant.NewInt(types.I32, 2)},
+			want:     "struct index 2 out of bounds of %T",
+		},
+		{
+			elemType: typ,
+			indices:  []value.Value{f.Params[1]},
+			want:     "invalid struct index i64 %i; expected constant integer",
+		},
+	}
+	for i, g := range golden {
+		if g.elemType == nil {
+			inst := f.Blocks[0].Insts[i].(*ir.InstGetElementPtr)
+			g.elemType, g.indices = inst.ElemType, inst.Indices[1:]
+		}
+		path, err := Path(g.elemType, g.indices)
+		got := pathString(path)
+		if err != nil {
+			got = err.Error()
+		}
+		if got != g.want {
+			t.Errorf("%d: access path mismatch; expected %q, got %q", i, g.want, got)
+		}
+	}
+}
+
+func TestCString(t *testing.T) {
+	const src = `
+@s = private unnamed_addr constant [4 x i8] c"foo\00"
+@t = constant [8 x i8] c"foo\00bar\00"
+@u = global [4 x i8] c"foo\00"
+@v = constant [3 x i8] c"foo"
+
+define void @f() {
+	%1 = getelementptr [4 x i8], [4 x i8]* @s, i64 0, i64 0
+	ret void
+}
+`
+	m := parse(t, src)
+	golden := []struct {
+		name string
+		// C string; or not a C string if empty.
+		str string
+		// Content of string global; or not a string global if empty.
+		global string
+	}{
+		{name: "s", str: "foo", global: "foo"},
+		// Character arrays holding several C strings are not string globals.
+		{name: "t", str: "foo"},
+		// Global variables are not string globals.
+		{name: "u"},
+		{name: "v"},
+	}
+	for i, g := range golden {
+		v := m.Globals[i]
+		s, ok := CString(v)
+		if g.str != s || ok != (len(g.str) > 0) {
+			t.Errorf("%s: C string mismatch; expected %q, got %q (%v)", g.name, g.str, s, ok)
+		}
+		_, s, ok = StringGlobal(v)
+		if g.global != s || ok != (len(g.global) > 0) {
+			t.Errorf("%s: string global mismatch; expected %q, got %q (%v)", g.name, g.global, s, ok)
+		}
+	}
+	gep := m.Funcs[0].Blocks[0].Insts[0].(*ir.InstGetElementPtr)
+	index, ok := CharIndex(gep.Indices)
+	if !ok || !IsZero(index) {
+		t.Errorf("character index mismatch; expected 0, got %v (%v)", index, ok)
+	}
+	if _, ok := CharIndex(gep.Indices[:1]); ok {
+		t.Errorf("character index of single index; expected none")
+	}
+}
+
+// parse parses the given LLVM IR assembly.
+func parse(t *testing.T, src string) *ir.Module {
+	m, err := asm.ParseString("foo.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse LLVM IR; %v", err)
+	}
+	return m
+}
+
+// pathString returns a string representation of the given access path.
+func pathString(path []Step) string {
+	s := ""
+	for _, step := range path {
+		if step.Struct != nil {
+			s += fmt.Sprintf("field_%d ", step.Field)
+		} else {
+			s += fmt.Sprintf("[%v] ", step.Index.Ident())
+		}
+	}
+	if len(path) > 0 {
+		s += fmt.Sprintf("-> %v", path[len(path)-1].Type)
+	}
+	return s
+}
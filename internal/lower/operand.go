@@ -0,0 +1,163 @@
+package lower
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+// InstOperands returns the operands of the given non-PHI instruction.
+func InstOperands(inst ir.Instruction) []value.Value {
+	switch inst := inst.(type) {
+	// Unary instructions
+	case *ir.InstFNeg:
+		return []value.Value{inst.X}
+	// Binary instructions
+	case *ir.InstAdd:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstFAdd:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstSub:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstFSub:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstMul:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstFMul:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstUDiv:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstSDiv:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstFDiv:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstURem:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstSRem:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstFRem:
+		return []value.Value{inst.X, inst.Y}
+	// Bitwise instructions
+	case *ir.InstShl:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstLShr:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstAShr:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstAnd:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstOr:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstXor:
+		return []value.Value{inst.X, inst.Y}
+	// Vector instructions
+	case *ir.InstExtractElement:
+		return []value.Value{inst.X, inst.Index}
+	case *ir.InstInsertElement:
+		return []value.Value{inst.X, inst.Elem, inst.Index}
+	case *ir.InstShuffleVector:
+		return []value.Value{inst.X, inst.Y, inst.Mask}
+	// Aggregate instructions
+	case *ir.InstExtractValue:
+		return []value.Value{inst.X}
+	case *ir.InstInsertValue:
+		return []value.Value{inst.X, inst.Elem}
+	// Memory instructions
+	case *ir.InstAlloca:
+		if inst.NElems != nil {
+			return []value.Value{inst.NElems}
+		}
+	case *ir.InstLoad:
+		return []value.Value{inst.Src}
+	case *ir.InstStore:
+		return []value.Value{inst.Src, inst.Dst}
+	case *ir.InstCmpXchg:
+		return []value.Value{inst.Ptr, inst.Cmp, inst.New}
+	case *ir.InstAtomicRMW:
+		return []value.Value{inst.Dst, inst.X}
+	case *ir.InstGetElementPtr:
+		return append([]value.Value{inst.Src}, inst.Indices...)
+	// Conversion instructions
+	case *ir.InstTrunc:
+		return []value.Value{inst.From}
+	case *ir.InstZExt:
+		return []value.Value{inst.From}
+	case *ir.InstSExt:
+		return []value.Value{inst.From}
+	case *ir.InstFPTrunc:
+		return []value.Value{inst.From}
+	case *ir.InstFPExt:
+		return []value.Value{inst.From}
+	case *ir.InstFPToUI:
+		return []value.Value{inst.From}
+	case *ir.InstFPToSI:
+		return []value.Value{inst.From}
+	case *ir.InstUIToFP:
+		return []value.Value{inst.From}
+	case *ir.InstSIToFP:
+		return []value.Value{inst.From}
+	case *ir.InstPtrToInt:
+		return []value.Value{inst.From}
+	case *ir.InstIntToPtr:
+		return []value.Value{inst.From}
+	case *ir.InstBitCast:
+		return []value.Value{inst.From}
+	case *ir.InstAddrSpaceCast:
+		return []value.Value{inst.From}
+	// Other instructions
+	case *ir.InstICmp:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstFCmp:
+		return []value.Value{inst.X, inst.Y}
+	case *ir.InstSelect:
+		return []value.Value{inst.Cond, inst.ValueTrue, inst.ValueFalse}
+	case *ir.InstFreeze:
+		return []value.Value{inst.X}
+	case *ir.InstCall:
+		return append([]value.Value{inst.Callee}, inst.Args...)
+	case *ir.InstVAArg:
+		return []value.Value{inst.ArgList}
+	case *ir.InstLandingPad:
+		var ops []value.Value
+		for _, clause := range inst.Clauses {
+			ops = append(ops, clause.X)
+		}
+		return ops
+	case *ir.InstCatchPad:
+		return append([]value.Value{inst.CatchSwitch}, inst.Args...)
+	case *ir.InstCleanupPad:
+		return append([]value.Value{inst.ParentPad}, inst.Args...)
+	}
+	// Instructions without operands (e.g. fence).
+	return nil
+}
+
+// TermOperands returns the operands of the given terminator, excluding target
+// basic blocks.
+func TermOperands(term ir.Terminator) []value.Value {
+	switch term := term.(type) {
+	case *ir.TermRet:
+		if term.X != nil {
+			return []value.Value{term.X}
+		}
+	case *ir.TermCondBr:
+		return []value.Value{term.Cond}
+	case *ir.TermSwitch:
+		return []value.Value{term.X}
+	case *ir.TermIndirectBr:
+		return []value.Value{term.Addr}
+	case *ir.TermInvoke:
+		return append([]value.Value{term.Invokee}, term.Args...)
+	case *ir.TermCallBr:
+		return append([]value.Value{term.Callee}, term.Args...)
+	case *ir.TermResume:
+		return []value.Value{term.X}
+	case *ir.TermCatchSwitch:
+		return []value.Value{term.ParentPad}
+	case *ir.TermCatchRet:
+		return []value.Value{term.CatchPad}
+	case *ir.TermCleanupRet:
+		return []value.Value{term.CleanupPad}
+	}
+	// Terminators without operands (e.g. br and unreachable).
+	return nil
+}
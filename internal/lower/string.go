@@ -0,0 +1,71 @@
+package lower
+
+import (
+	"bytes"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/value"
+)
+
+// StringGlobal returns the contents of the given LLVM IR value, if it is a
+// global constant of character array type holding a C string; i.e. exactly one
+// NUL character, which terminates the array (as emitted by clang for C string
+// literals). The boolean return value indicates success.
+//
+//    @.str = private unnamed_addr constant [4 x i8] c"foo\00"
+func StringGlobal(v value.Value) (*ir.Global, string, bool) {
+	g, ok := v.(*ir.Global)
+	if !ok || !g.Immutable {
+		return nil, "", false
+	}
+	arr, ok := g.Init.(*constant.CharArray)
+	if !ok {
+		return nil, "", false
+	}
+	if pos := bytes.IndexByte(arr.X, 0); pos == -1 || pos != len(arr.X)-1 {
+		return nil, "", false
+	}
+	return g, string(arr.X[:len(arr.X)-1]), true
+}
+
+// CString returns the contents of the given LLVM IR value, if it is a pointer
+// to the first character of a constant NUL-terminated character array; as used
+// for C string literals. The boolean return value indicates success.
+//
+//    getelementptr ([4 x i8], [4 x i8]* @.str, i64 0, i64 0)
+func CString(v value.Value) (string, bool) {
+	if expr, ok := v.(*constant.ExprGetElementPtr); ok {
+		for _, index := range expr.Indices {
+			if !IsZero(index) {
+				return "", false
+			}
+		}
+		v = expr.Src
+	}
+	g, ok := v.(*ir.Global)
+	if !ok || !g.Immutable {
+		return "", false
+	}
+	arr, ok := g.Init.(*constant.CharArray)
+	if !ok {
+		return "", false
+	}
+	pos := bytes.IndexByte(arr.X, 0)
+	if pos == -1 {
+		return "", false
+	}
+	return string(arr.X[:pos]), true
+}
+
+// CharIndex returns the index of the character located by the given
+// getelementptr indices into a character array; i.e. two indices, the first of
+// which is zero. The boolean return value indicates success.
+//
+//    getelementptr ([4 x i8], [4 x i8]* @.str, i64 0, i64 %i)
+func CharIndex(indices []value.Value) (value.Value, bool) {
+	if len(indices) != 2 || !IsZero(indices[0]) {
+		return nil, false
+	}
+	return Index(indices[1]), true
+}
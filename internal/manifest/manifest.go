@@ -0,0 +1,145 @@
+// Package manifest implements content hash manifests of graph directories,
+// which track the LLVM IR of the functions from which the files of a graph
+// directory (e.g. "foo_graphs/") were generated.
+//
+// The manifest enables incremental regeneration of control flow graphs, and
+// detection of stale control flow primitive files.
+package manifest
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	goerrors "errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/llir/llvm/ir"
+	"github.com/pkg/errors"
+)
+
+// Name is the file name of manifests within graph directories.
+const Name = "manifest.json"
+
+// A Manifest tracks the content hashes of the functions of a graph directory.
+type Manifest struct {
+	// Funcs maps from function name to the content hash of the LLVM IR of the
+	// function.
+	Funcs map[string]string `json:"funcs"`
+}
+
+// New returns a new empty manifest.
+func New() *Manifest {
+	return &Manifest{
+		Funcs: make(map[string]string),
+	}
+}
+
+// Load loads the manifest of the given graph directory. An empty manifest is
+// returned if the graph directory contains no manifest.
+func Load(dir string) (*Manifest, error) {
+	path := filepath.Join(dir, Name)
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return New(), nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	m := New()
+	if err := json.Unmarshal(buf, m); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse manifest %q", path)
+	}
+	if m.Funcs == nil {
+		m.Funcs = make(map[string]string)
+	}
+	return m, nil
+}
+
+// Store stores the manifest in the given graph directory.
+func (m *Manifest) Store(dir string) error {
+	buf, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	buf = append(buf, '\n')
+	path := filepath.Join(dir, Name)
+	if err := ioutil.WriteFile(path, buf, 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// UpToDate reports whether the manifest records the given content hash for the
+// named function.
+func (m *Manifest) UpToDate(funcName, hash string) bool {
+	h, ok := m.Funcs[funcName]
+	return ok && h == hash
+}
+
+// Hash returns the content hash of the LLVM IR of the given function.
+func Hash(f *ir.Func) string {
+	sum := sha256.Sum256([]byte(f.LLString()))
+	return hex.EncodeToString(sum[:])
+}
+
+// ErrStale signals a stale control flow primitive file.
+var ErrStale = goerrors.New("stale control flow primitives")
+
+// Prims parses the JSON file containing a mapping of control flow primitives for
+// the given function from the graph directory of the manifest. No primitives
+// are returned if the JSON file is not present on the file system, and an error
+// with cause ErrStale if it is stale with regards to the manifest.
+func (m *Manifest) Prims(dir string, f *ir.Func) ([]*primitive.Primitive, error) {
+	jsonPath := filepath.Join(dir, f.Name()+".json")
+	fr, err := os.Open(jsonPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	defer fr.Close()
+	stale, err := m.isStale(dir, f)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if stale {
+		return nil, errors.Wrapf(ErrStale, "%q of %q", jsonPath, f.Ident())
+	}
+	// Parse primitives from file system.
+	var prims []*primitive.Primitive
+	r := bufio.NewReader(fr)
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&prims); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return prims, nil
+}
+
+// isStale reports whether the JSON file containing the control flow primitives
+// of the given function is stale; i.e. if the LLVM IR of the function has
+// changed since the control flow graph was generated, or if the control flow
+// graph has been regenerated since the primitives were recovered. Functions not
+// tracked by the manifest (e.g. graph directories generated by earlier versions
+// of ll2dot) are only checked against the control flow graph.
+func (m *Manifest) isStale(dir string, f *ir.Func) (bool, error) {
+	if _, ok := m.Funcs[f.Name()]; ok && !m.UpToDate(f.Name(), Hash(f)) {
+		return true, nil
+	}
+	jsonInfo, err := os.Stat(filepath.Join(dir, f.Name()+".json"))
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	dotInfo, err := os.Stat(filepath.Join(dir, f.Name()+".dot"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.WithStack(err)
+	}
+	return jsonInfo.ModTime().Before(dotInfo.ModTime()), nil
+}
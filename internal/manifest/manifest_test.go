@@ -0,0 +1,132 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/decomp/decomp/cfa/primitive"
+	"github.com/llir/llvm/asm"
+	"github.com/pkg/errors"
+)
+
+func TestLoadStore(t *testing.T) {
+	dir := t.TempDir()
+	// An empty manifest is returned if the graph directory contains no
+	// manifest.
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unable to load manifest; %v", err)
+	}
+	if len(m.Funcs) != 0 {
+		t.Errorf("functions mismatch of empty manifest; expected none, got %v", m.Funcs)
+	}
+	m.Funcs["f"] = "1234"
+	if err := m.Store(dir); err != nil {
+		t.Fatalf("unable to store manifest; %v", err)
+	}
+	got, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unable to load manifest; %v", err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("manifest mismatch; expected %v, got %v", m, got)
+	}
+	if !got.UpToDate("f", "1234") {
+		t.Errorf("function %q not up to date", "f")
+	}
+	if got.UpToDate("f", "5678") {
+		t.Errorf("function %q up to date with hash mismatch", "f")
+	}
+	if got.UpToDate("g", "1234") {
+		t.Errorf("function %q not tracked by manifest up to date", "g")
+	}
+	// Invalid manifest.
+	if err := ioutil.WriteFile(filepath.Join(dir, Name), []byte("{"), 0644); err != nil {
+		t.Fatalf("unable to create manifest; %v", err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Errorf("expected error for invalid manifest, got nil")
+	}
+}
+
+func TestPrims(t *testing.T) {
+	const src = `
+define void @f() {
+	ret void
+}
+`
+	module, err := asm.ParseString("foo.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse LLVM IR; %v", err)
+	}
+	f := module.Funcs[0]
+	prims := []*primitive.Primitive{
+		{Prim: "if", Nodes: map[string]string{"cond": "0", "body": "1", "exit": "2"}, Entry: "0", Exit: "2"},
+	}
+	const primsJSON = `[{"prim": "if", "nodes": {"cond": "0", "body": "1", "exit": "2"}, "entry": "0", "exit": "2"}]`
+	now := time.Now()
+	golden := []struct {
+		name string
+		// Content hash of f recorded by the manifest; or untracked if empty.
+		hash string
+		// JSON file present.
+		json bool
+		// Modification time of the DOT file relative to the JSON file; or no DOT
+		// file if zero.
+		dotAge time.Duration
+		want   []*primitive.Primitive
+		stale  bool
+	}{
+		{name: "missing_json", hash: Hash(f)},
+		{name: "up_to_date", hash: Hash(f), json: true, dotAge: -time.Minute, want: prims},
+		{name: "hash_mismatch", hash: "1234", json: true, dotAge: -time.Minute, stale: true},
+		// Functions not tracked by the manifest are only checked against the DOT
+		// file.
+		{name: "missing_entry", json: true, dotAge: -time.Minute, want: prims},
+		{name: "missing_entry_no_dot", json: true, want: prims},
+		{name: "newer_dot", hash: Hash(f), json: true, dotAge: time.Minute, stale: true},
+		{name: "missing_entry_newer_dot", json: true, dotAge: time.Minute, stale: true},
+	}
+	for _, g := range golden {
+		dir := t.TempDir()
+		m := New()
+		if len(g.hash) > 0 {
+			m.Funcs[f.Name()] = g.hash
+		}
+		jsonPath := filepath.Join(dir, f.Name()+".json")
+		if g.json {
+			if err := ioutil.WriteFile(jsonPath, []byte(primsJSON), 0644); err != nil {
+				t.Fatalf("%s: unable to create JSON file; %v", g.name, err)
+			}
+			if err := os.Chtimes(jsonPath, now, now); err != nil {
+				t.Fatalf("%s: unable to set modification time; %v", g.name, err)
+			}
+		}
+		if g.dotAge != 0 {
+			dotPath := filepath.Join(dir, f.Name()+".dot")
+			if err := ioutil.WriteFile(dotPath, []byte("digraph f {}\n"), 0644); err != nil {
+				t.Fatalf("%s: unable to create DOT file; %v", g.name, err)
+			}
+			mtime := now.Add(g.dotAge)
+			if err := os.Chtimes(dotPath, mtime, mtime); err != nil {
+				t.Fatalf("%s: unable to set modification time; %v", g.name, err)
+			}
+		}
+		got, err := m.Prims(dir, f)
+		if stale := errors.Cause(err) == ErrStale; stale != g.stale {
+			t.Errorf("%s: staleness mismatch; expected %v, got %v (%v)", g.name, g.stale, stale, err)
+			continue
+		}
+		if err != nil && !g.stale {
+			t.Errorf("%s: unable to parse primitives; %v", g.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, g.want) {
+			t.Errorf("%s: primitives mismatch; expected %v, got %v", g.name, g.want, got)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Package outssa implements the translation of LLVM IR functions out of SSA
+// form, which replaces PHI instructions by copies between variables.
+//
+// The incoming values of the PHI instructions of a basic block are assigned by
+// a parallel copy at the end of each predecessor basic block; which is
+// sequentialized, using temporary variables to break cycles (e.g. when the
+// values of two PHI instructions are swapped in a loop).
+//
+// The copies at the end of a basic block with several successors are executed
+// on each outgoing edge. Thus, a PHI instruction whose value is live on another
+// outgoing edge (e.g. when used after the loop which updates it) is assigned
+// through its temporary variable instead, which is copied to the variable of the
+// PHI instruction at the beginning of its basic block. This has the effect of
+// splitting critical edges, without altering the control flow graph of the
+// function.
+//
+// The result of an invoke or callbr terminator is only defined on the edge to
+// its normal destination, after the call. Thus, the incoming values of the PHI
+// instructions of the normal destination are assigned by a separate parallel
+// copy, following the call.
+//
+//    copies := outssa.Eliminate(f)
+package outssa
+
+import (
+	"github.com/decomp/decomp/internal/lower"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+// A Copy is an assignment of a value to the variable of a PHI instruction, or
+// to its temporary variable.
+type Copy struct {
+	// Destination PHI instruction.
+	Dst *ir.InstPhi
+	// DstTemp specifies whether the destination is the temporary variable of
+	// Dst, rather than the variable of Dst.
+	DstTemp bool
+	// Source value.
+	Src value.Value
+	// SrcTemp specifies whether the source is the temporary variable of Src,
+	// which is a PHI instruction, rather than the value of Src.
+	SrcTemp bool
+}
+
+// Copies are the copies replacing the PHI instructions of a function.
+type Copies struct {
+	// Map from basic block to the copies at its beginning; which assign the
+	// temporary variables of its PHI instructions to their variables.
+	In map[*ir.Block][]*Copy
+	// Map from basic block to the copies at its end, preceding its terminator;
+	// which assign the incoming values of the PHI instructions of its
+	// successors.
+	Out map[*ir.Block][]*Copy
+	// Map from basic block with an invoke or callbr terminator to the copies on
+	// the edge to its normal destination, following the call; which assign the
+	// incoming values of the PHI instructions of the normal destination.
+	Normal map[*ir.Block][]*Copy
+}
+
+// Eliminate returns the copies replacing the PHI instructions of the given
+// function. Copies are to be executed in order.
+func Eliminate(f *ir.Func) *Copies {
+	copies := &Copies{
+		In:     make(map[*ir.Block][]*Copy),
+		Out:    make(map[*ir.Block][]*Copy),
+		Normal: make(map[*ir.Block][]*Copy),
+	}
+	preds := predecessors(f)
+	uses := phiUses(f)
+	// Identify PHI instructions assigned through their temporary variables.
+	temp := make(map[*ir.InstPhi]bool)
+	for _, block := range f.Blocks {
+		for _, phi := range phis(block) {
+			if needsTemp(phi, block, preds, uses) {
+				temp[phi] = true
+				c := &Copy{Dst: phi, Src: phi, SrcTemp: true}
+				copies.In[block] = append(copies.In[block], c)
+			}
+		}
+	}
+	// Assign the incoming values of PHI instructions by parallel copies at the
+	// end of their predecessors, and on the edge to the normal destination of
+	// invoke and callbr terminators.
+	for _, pred := range f.Blocks {
+		var pcopy, normal []*Copy
+		done := make(map[*ir.InstPhi]bool)
+		for _, succ := range pred.Term.Succs() {
+			for _, phi := range phis(succ) {
+				if done[phi] {
+					continue
+				}
+				done[phi] = true
+				if x, ok := incoming(phi, pred); ok {
+					c := &Copy{Dst: phi, DstTemp: temp[phi], Src: x}
+					if succ == normalDest(pred.Term) {
+						normal = append(normal, c)
+					} else {
+						pcopy = append(pcopy, c)
+					}
+				}
+			}
+		}
+		if seq := sequentialize(pcopy); len(seq) > 0 {
+			copies.Out[pred] = seq
+		}
+		if seq := sequentialize(normal); len(seq) > 0 {
+			copies.Normal[pred] = seq
+		}
+	}
+	return copies
+}
+
+// needsTemp reports whether the given PHI instruction of the specified basic
+// block is to be assigned through its temporary variable; i.e. if an incoming
+// value would otherwise be assigned at the end of a predecessor with another
+// successor at which the value of the PHI instruction is live, or before a
+// terminator using the PHI instruction. Incoming values assigned on the edge to
+// the normal destination of invoke and callbr terminators need no temporary
+// variable.
+func needsTemp(phi *ir.InstPhi, block *ir.Block, preds map[*ir.Block][]*ir.Block, uses *uses) bool {
+	var live map[*ir.Block]bool
+	for _, inc := range phi.Incs {
+		pred := inc.Pred.(*ir.Block)
+		if inc.X == value.Value(phi) {
+			// Copies of a PHI instruction to itself are omitted.
+			continue
+		}
+		if normalDest(pred.Term) == block {
+			continue
+		}
+		if uses.terms[phi][pred] {
+			return true
+		}
+		for _, succ := range pred.Term.Succs() {
+			if succ == block {
+				continue
+			}
+			if live == nil {
+				live = liveIn(phi, block, preds, uses)
+			}
+			if live[succ] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// liveIn returns the set of basic blocks at the beginning of which the value of
+// the given PHI instruction of the specified basic block is live.
+func liveIn(phi *ir.InstPhi, block *ir.Block, preds map[*ir.Block][]*ir.Block, uses *uses) map[*ir.Block]bool {
+	live := make(map[*ir.Block]bool)
+	// The value of the PHI instruction is live at the beginning of basic blocks
+	// using it, and of basic blocks at the end of which it is used by PHI
+	// instructions of their successors; and propagated backwards to their
+	// predecessors until reaching the basic block of the PHI instruction.
+	var queue []*ir.Block
+	for b := range uses.blocks[phi] {
+		queue = append(queue, b)
+	}
+	for len(queue) > 0 {
+		b := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if b == block || live[b] {
+			continue
+		}
+		live[b] = true
+		queue = append(queue, preds[b]...)
+	}
+	return live
+}
+
+// uses records the uses of the PHI instructions of a function.
+type uses struct {
+	// Map from PHI instruction to the basic blocks using it; including the
+	// predecessors of basic blocks of PHI instructions using it as incoming
+	// value.
+	blocks map[*ir.InstPhi]map[*ir.Block]bool
+	// Map from PHI instruction to the basic blocks with terminators using it.
+	terms map[*ir.InstPhi]map[*ir.Block]bool
+}
+
+// phiUses returns the uses of the PHI instructions of the given function.
+func phiUses(f *ir.Func) *uses {
+	u := &uses{
+		blocks: make(map[*ir.InstPhi]map[*ir.Block]bool),
+		terms:  make(map[*ir.InstPhi]map[*ir.Block]bool),
+	}
+	use := func(m map[*ir.InstPhi]map[*ir.Block]bool, v value.Value, block *ir.Block) {
+		phi, ok := v.(*ir.InstPhi)
+		if !ok {
+			return
+		}
+		if m[phi] == nil {
+			m[phi] = make(map[*ir.Block]bool)
+		}
+		m[phi][block] = true
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if phi, ok := inst.(*ir.InstPhi); ok {
+				// Incoming values are used at the end of predecessors.
+				for _, inc := range phi.Incs {
+					use(u.blocks, inc.X, inc.Pred.(*ir.Block))
+				}
+				continue
+			}
+			for _, v := range lower.InstOperands(inst) {
+				use(u.blocks, v, block)
+			}
+		}
+		for _, v := range lower.TermOperands(block.Term) {
+			use(u.blocks, v, block)
+			use(u.terms, v, block)
+		}
+	}
+	return u
+}
+
+// sequentialize returns a sequence of copies equivalent to the given parallel
+// copy, using temporary variables to break cycles.
+//
+//    (x, y) = (y, x)
+//
+//    x_phi = x
+//    x = y
+//    y = x_phi
+func sequentialize(pcopy []*Copy) []*Copy {
+	var pending []*Copy
+	for _, c := range pcopy {
+		// Omit copies of a PHI instruction to itself.
+		if !c.DstTemp && c.Src == value.Value(c.Dst) {
+			continue
+		}
+		pending = append(pending, c)
+	}
+	// read reports whether the variable of the given PHI instruction is read by
+	// a pending copy.
+	read := func(phi *ir.InstPhi) bool {
+		for _, c := range pending {
+			if !c.SrcTemp && c.Src == value.Value(phi) {
+				return true
+			}
+		}
+		return false
+	}
+	var seq []*Copy
+	for len(pending) > 0 {
+		// Emit copies to variables which are not read by pending copies, in
+		// order.
+		progress := false
+		for i := 0; i < len(pending); i++ {
+			c := pending[i]
+			if !c.DstTemp && read(c.Dst) {
+				continue
+			}
+			seq = append(seq, c)
+			pending = append(pending[:i], pending[i+1:]...)
+			i--
+			progress = true
+		}
+		if progress {
+			continue
+		}
+		// The remaining copies form cycles; break a cycle by saving the value of
+		// a destination variable to its temporary variable.
+		dst := pending[0].Dst
+		seq = append(seq, &Copy{Dst: dst, DstTemp: true, Src: dst})
+		for _, c := range pending {
+			if !c.SrcTemp && c.Src == value.Value(dst) {
+				c.SrcTemp = true
+			}
+		}
+	}
+	return seq
+}
+
+// normalDest returns the normal destination of the given invoke or callbr
+// terminator, and nil for other terminators.
+func normalDest(term ir.Terminator) *ir.Block {
+	switch term := term.(type) {
+	case *ir.TermInvoke:
+		return term.NormalRetTarget.(*ir.Block)
+	case *ir.TermCallBr:
+		return term.NormalRetTarget.(*ir.Block)
+	}
+	return nil
+}
+
+// phis returns the PHI instructions of the given basic block.
+func phis(block *ir.Block) []*ir.InstPhi {
+	var phis []*ir.InstPhi
+	for _, inst := range block.Insts {
+		if phi, ok := inst.(*ir.InstPhi); ok {
+			phis = append(phis, phi)
+		}
+	}
+	return phis
+}
+
+// incoming returns the incoming value of the given PHI instruction from the
+// specified predecessor basic block. The boolean return value indicates
+// success.
+func incoming(phi *ir.InstPhi, pred *ir.Block) (value.Value, bool) {
+	for _, inc := range phi.Incs {
+		if inc.Pred == value.Value(pred) {
+			return inc.X, true
+		}
+	}
+	return nil, false
+}
+
+// predecessors returns a map from basic block to predecessor basic blocks of the
+// given function.
+func predecessors(f *ir.Func) map[*ir.Block][]*ir.Block {
+	preds := make(map[*ir.Block][]*ir.Block)
+	for _, block := range f.Blocks {
+		for _, succ := range block.Term.Succs() {
+			preds[succ] = append(preds[succ], block)
+		}
+	}
+	return preds
+}
@@ -0,0 +1,218 @@
+package outssa
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+)
+
+func TestEliminate(t *testing.T) {
+	golden := []struct {
+		name string
+		src  string
+		// Map from basic block name to the copies at its beginning.
+		in map[string][]string
+		// Map from basic block name to the copies at its end.
+		out map[string][]string
+		// Map from basic block name to the copies on the edge to the normal
+		// destination of its terminator.
+		normal map[string][]string
+	}{
+		// Rotation of variables in a pre-test loop.
+		{
+			name: "fib",
+			src: `
+define i32 @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%a = phi i32 [ 0, %entry ], [ %b, %body ]
+	%b = phi i32 [ 1, %entry ], [ %sum, %body ]
+	%i = phi i32 [ 0, %entry ], [ %i1, %body ]
+	%c = icmp slt i32 %i, %n
+	br i1 %c, label %body, label %exit
+
+body:
+	%sum = add i32 %a, %b
+	%i1 = add i32 %i, 1
+	br label %loop
+
+exit:
+	ret i32 %a
+}
+`,
+			in: map[string][]string{},
+			out: map[string][]string{
+				"entry": {"%a = 0", "%b = 1", "%i = 0"},
+				"body":  {"%a = %b", "%b = %sum", "%i = %i1"},
+			},
+			normal: map[string][]string{},
+		},
+		// Swap of variables; the swap problem.
+		{
+			name: "swap",
+			src: `
+define i32 @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%x = phi i32 [ 1, %entry ], [ %y, %loop ]
+	%y = phi i32 [ 2, %entry ], [ %x, %loop ]
+	%i = phi i32 [ 0, %entry ], [ %i1, %loop ]
+	%i1 = add i32 %i, 1
+	%c = icmp slt i32 %i1, %n
+	br i1 %c, label %loop, label %exit
+
+exit:
+	ret i32 %i1
+}
+`,
+			in: map[string][]string{},
+			out: map[string][]string{
+				"entry": {"%x = 1", "%y = 2", "%i = 0"},
+				"loop":  {"%i = %i1", "%x_phi = %x", "%x = %y", "%y = %x_phi"},
+			},
+			normal: map[string][]string{},
+		},
+		// Swap of variables used after the loop, on a critical edge; the lost
+		// copy problem.
+		{
+			name: "swap_live",
+			src: `
+define i32 @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%x = phi i32 [ 1, %entry ], [ %y, %body ]
+	%y = phi i32 [ 2, %entry ], [ %x, %body ]
+	%i = phi i32 [ 0, %entry ], [ %i1, %body ]
+	br label %body
+
+body:
+	%i1 = add i32 %i, 1
+	%c = icmp slt i32 %i1, %n
+	br i1 %c, label %loop, label %exit
+
+exit:
+	%r = mul i32 %x, 10
+	%s = add i32 %r, %i
+	ret i32 %s
+}
+`,
+			in: map[string][]string{
+				"loop": {"%x = %x_phi", "%i = %i_phi"},
+			},
+			out: map[string][]string{
+				"entry": {"%x_phi = 1", "%y = 2", "%i_phi = 0"},
+				"body":  {"%x_phi = %y", "%y = %x", "%i_phi = %i1"},
+			},
+			normal: map[string][]string{},
+		},
+		// Incoming value used by the terminator of the predecessor.
+		{
+			name: "term",
+			src: `
+define i32 @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %i1, %loop ]
+	%i1 = add i32 %i, 1
+	%c = icmp slt i32 %i1, %n
+	br i1 %c, label %loop, label %exit
+
+exit:
+	ret i32 %i
+}
+`,
+			in: map[string][]string{
+				"loop": {"%i = %i_phi"},
+			},
+			out: map[string][]string{
+				"entry": {"%i_phi = 0"},
+				"loop":  {"%i_phi = %i1"},
+			},
+			normal: map[string][]string{},
+		},
+		// Result of invoke terminator used as incoming value; assigned on the
+		// edge to the normal destination, after the call.
+		{
+			name: "invoke",
+			src: `
+declare i32 @g(i32 %x)
+
+declare i32 @__gxx_personality_v0(...)
+
+define i32 @f(i32 %n) personality i32 (...)* @__gxx_personality_v0 {
+entry:
+	%r = invoke i32 @g(i32 %n) to label %cont unwind label %lpad
+
+cont:
+	%p = phi i32 [ %r, %entry ]
+	ret i32 %p
+
+lpad:
+	%q = phi i32 [ %n, %entry ]
+	%lp = landingpad { i8*, i32 } cleanup
+	ret i32 %q
+}
+`,
+			in: map[string][]string{},
+			out: map[string][]string{
+				"entry": {"%q = %n"},
+			},
+			normal: map[string][]string{
+				"entry": {"%p = %r"},
+			},
+		},
+	}
+	for _, g := range golden {
+		module, err := asm.ParseString(g.name+".ll", g.src)
+		if err != nil {
+			t.Errorf("%s: unable to parse LLVM IR; %v", g.name, err)
+			continue
+		}
+		f := module.Funcs[len(module.Funcs)-1]
+		copies := Eliminate(f)
+		if got := blockCopies(copies.In); !reflect.DeepEqual(got, g.in) {
+			t.Errorf("%s: copies at beginning of basic blocks mismatch; expected %q, got %q", g.name, g.in, got)
+		}
+		if got := blockCopies(copies.Out); !reflect.DeepEqual(got, g.out) {
+			t.Errorf("%s: copies at end of basic blocks mismatch; expected %q, got %q", g.name, g.out, got)
+		}
+		if got := blockCopies(copies.Normal); !reflect.DeepEqual(got, g.normal) {
+			t.Errorf("%s: copies on normal edge of basic blocks mismatch; expected %q, got %q", g.name, g.normal, got)
+		}
+	}
+}
+
+// blockCopies returns a map from basic block name to the string representation
+// of the given copies.
+func blockCopies(m map[*ir.Block][]*Copy) map[string][]string {
+	s := make(map[string][]string)
+	for block, copies := range m {
+		for _, c := range copies {
+			s[block.Name()] = append(s[block.Name()], copyString(c))
+		}
+	}
+	return s
+}
+
+// copyString returns the string representation of the given copy.
+func copyString(c *Copy) string {
+	dst := c.Dst.Ident()
+	if c.DstTemp {
+		dst += "_phi"
+	}
+	src := c.Src.Ident()
+	if c.SrcTemp {
+		src += "_phi"
+	}
+	return dst + " = " + src
+}
@@ -0,0 +1,98 @@
+// Package par implements a worker pool for processing independent work items
+// concurrently.
+package par
+
+import (
+	"runtime"
+	"sync"
+)
+
+// A Pool bounds the number of work items processed concurrently, including work
+// items of nested invocations of Do (e.g. the functions of each file, when
+// processing files concurrently).
+//
+//    pool := par.New(jobs)
+//    err := pool.Do(len(files), func(i int) error {
+//       return pool.Do(len(files[i].funcs), ...)
+//    })
+type Pool struct {
+	// sem holds a token for each worker goroutine in addition to the calling
+	// goroutine.
+	sem chan struct{}
+}
+
+// New returns a new pool of the given number of concurrent workers. If workers
+// is less than 1, the number of logical CPUs is used.
+func New(workers int) *Pool {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	return &Pool{sem: make(chan struct{}, workers-1)}
+}
+
+// Do invokes fn for each index in [0, n) using the given number of concurrent
+// workers. If workers is less than 1, the number of logical CPUs is used.
+//
+// Callers keep output deterministic by storing the result of each work item at
+// its index. Do returns the error of the work item with the lowest index, if
+// any; once an error has been encountered, work items with higher indices are
+// skipped.
+func Do(n, workers int, fn func(i int) error) error {
+	return New(workers).Do(n, fn)
+}
+
+// Do invokes fn for each index in [0, n) using the workers of the pool. Work
+// items are processed by the calling goroutine while all workers are busy, so
+// that nested invocations of Do make progress without exceeding the bound of
+// the pool.
+//
+// Callers keep output deterministic by storing the result of each work item at
+// its index. Do returns the error of the work item with the lowest index, if
+// any; once an error has been encountered, work items with higher indices are
+// skipped.
+func (p *Pool) Do(n int, fn func(i int) error) error {
+	var (
+		mu sync.Mutex
+		// errs records errors by work item index.
+		errs = make(map[int]error)
+		// failed records the lowest index of a failed work item.
+		failed = n
+	)
+	run := func(i int) {
+		if err := fn(i); err != nil {
+			mu.Lock()
+			errs[i] = err
+			if i < failed {
+				failed = i
+			}
+			mu.Unlock()
+		}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		mu.Lock()
+		stop := i > failed
+		mu.Unlock()
+		if stop {
+			break
+		}
+		select {
+		case p.sem <- struct{}{}:
+			wg.Add(1)
+			go func(i int) {
+				defer func() {
+					<-p.sem
+					wg.Done()
+				}()
+				run(i)
+			}(i)
+		default:
+			run(i)
+		}
+	}
+	wg.Wait()
+	if failed < n {
+		return errs[failed]
+	}
+	return nil
+}
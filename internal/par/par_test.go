@@ -0,0 +1,100 @@
+package par
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	for _, workers := range []int{1, 2, 8} {
+		const n = 100
+		out := make([]int, n)
+		err := Do(n, workers, func(i int) error {
+			out[i] = i * i
+			return nil
+		})
+		if err != nil {
+			t.Errorf("workers %d: unexpected error; %v", workers, err)
+			continue
+		}
+		for i, v := range out {
+			if v != i*i {
+				t.Errorf("workers %d: result mismatch of work item %d; expected %d, got %d", workers, i, i*i, v)
+			}
+		}
+	}
+}
+
+func TestDoError(t *testing.T) {
+	for _, workers := range []int{1, 2, 8} {
+		const n = 100
+		var mu sync.Mutex
+		done := make(map[int]bool)
+		err := Do(n, workers, func(i int) error {
+			mu.Lock()
+			done[i] = true
+			mu.Unlock()
+			if i == 10 || i == 20 {
+				return fmt.Errorf("work item %d", i)
+			}
+			return nil
+		})
+		// The error of the work item with the lowest index is returned.
+		if want := "work item 10"; err == nil || err.Error() != want {
+			t.Errorf("workers %d: error mismatch; expected %q, got %v", workers, want, err)
+		}
+		for i := 0; i <= 10; i++ {
+			if !done[i] {
+				t.Errorf("workers %d: work item %d not processed", workers, i)
+			}
+		}
+		// Work items are skipped once an error has been encountered.
+		if workers == 1 && len(done) != 11 {
+			t.Errorf("workers %d: work items processed after error; expected 11, got %d", workers, len(done))
+		}
+	}
+}
+
+func TestPoolLimit(t *testing.T) {
+	for _, workers := range []int{1, 2, 4} {
+		var cur, max int32
+		work := func() {
+			c := atomic.AddInt32(&cur, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if c <= m || atomic.CompareAndSwapInt32(&max, m, c) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&cur, -1)
+		}
+		// Nested invocations share the bound of the pool.
+		pool := New(workers)
+		var count int32
+		err := pool.Do(8, func(i int) error {
+			work()
+			return pool.Do(8, func(j int) error {
+				work()
+				atomic.AddInt32(&count, 1)
+				return nil
+			})
+		})
+		if err != nil {
+			t.Errorf("workers %d: unexpected error; %v", workers, err)
+			continue
+		}
+		if count != 8*8 {
+			t.Errorf("workers %d: work item count mismatch; expected %d, got %d", workers, 8*8, count)
+		}
+		if max > int32(workers) {
+			t.Errorf("workers %d: worker limit exceeded; got %d concurrent work items", workers, max)
+		}
+		if workers > 1 && max < 2 {
+			t.Errorf("workers %d: work items not processed concurrently", workers)
+		}
+	}
+}
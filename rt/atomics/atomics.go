@@ -0,0 +1,169 @@
+// Package atomics provides runtime support for atomic operations in Go source
+// code decompiled from LLVM IR.
+//
+// Atomic operations on 32- and 64-bit values (integers, floating-point values
+// and pointers) are implemented using the sequentially consistent operations of
+// sync/atomic, which are at least as strong as any LLVM IR memory ordering.
+// Atomic operations on values of other widths (e.g. i8, i16, x86_fp80) fall
+// back to being performed while holding a global lock, using Do.
+//
+// Each width is thus routed through exactly one of the two mechanisms. As the
+// global lock does not synchronize with the operations of sync/atomic, a memory
+// location must not be accessed by atomic operations of different widths; as
+// is the case in C11, where each atomic object has a single type.
+package atomics
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// mu is the global lock guarding atomic operations not supported by
+// sync/atomic.
+var mu sync.Mutex
+
+// Do calls f while holding the global lock guarding atomic operations not
+// supported by sync/atomic. Do must only be used for atomic operations on
+// values of widths other than 32 and 64 bits.
+func Do(f func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	f()
+}
+
+// fence is the memory location of the atomic operations used to implement
+// memory barriers.
+var fence uint32
+
+// Fence is a memory barrier. Memory operations before and after the fence may
+// not be reordered across the fence, as observed by other goroutines calling
+// Fence.
+func Fence() {
+	atomic.AddUint32(&fence, 0)
+}
+
+// CompareAndSwapInt32 atomically stores new at p if the value at p equals cmp.
+// It returns the old value at p, and reports whether new was stored.
+func CompareAndSwapInt32(p *int32, cmp, new int32) (old int32, ok bool) {
+	for {
+		if atomic.CompareAndSwapInt32(p, cmp, new) {
+			return cmp, true
+		}
+		if old := atomic.LoadInt32(p); old != cmp {
+			return old, false
+		}
+	}
+}
+
+// CompareAndSwapInt64 atomically stores new at p if the value at p equals cmp.
+// It returns the old value at p, and reports whether new was stored.
+func CompareAndSwapInt64(p *int64, cmp, new int64) (old int64, ok bool) {
+	for {
+		if atomic.CompareAndSwapInt64(p, cmp, new) {
+			return cmp, true
+		}
+		if old := atomic.LoadInt64(p); old != cmp {
+			return old, false
+		}
+	}
+}
+
+// UpdateInt32 atomically replaces the value at p with f of the value, and
+// returns the old value at p. f may be called more than once.
+func UpdateInt32(p *int32, f func(old int32) int32) int32 {
+	for {
+		old := atomic.LoadInt32(p)
+		if atomic.CompareAndSwapInt32(p, old, f(old)) {
+			return old
+		}
+	}
+}
+
+// UpdateInt64 atomically replaces the value at p with f of the value, and
+// returns the old value at p. f may be called more than once.
+func UpdateInt64(p *int64, f func(old int64) int64) int64 {
+	for {
+		old := atomic.LoadInt64(p)
+		if atomic.CompareAndSwapInt64(p, old, f(old)) {
+			return old
+		}
+	}
+}
+
+// LoadFloat32 atomically loads the value at p.
+func LoadFloat32(p *float32) float32 {
+	return math.Float32frombits(atomic.LoadUint32((*uint32)(unsafe.Pointer(p))))
+}
+
+// LoadFloat64 atomically loads the value at p.
+func LoadFloat64(p *float64) float64 {
+	return math.Float64frombits(atomic.LoadUint64((*uint64)(unsafe.Pointer(p))))
+}
+
+// StoreFloat32 atomically stores v at p.
+func StoreFloat32(p *float32, v float32) {
+	atomic.StoreUint32((*uint32)(unsafe.Pointer(p)), math.Float32bits(v))
+}
+
+// StoreFloat64 atomically stores v at p.
+func StoreFloat64(p *float64, v float64) {
+	atomic.StoreUint64((*uint64)(unsafe.Pointer(p)), math.Float64bits(v))
+}
+
+// UpdateFloat32 atomically replaces the value at p with f of the value, and
+// returns the old value at p. f may be called more than once.
+func UpdateFloat32(p *float32, f func(old float32) float32) float32 {
+	q := (*uint32)(unsafe.Pointer(p))
+	for {
+		old := atomic.LoadUint32(q)
+		if atomic.CompareAndSwapUint32(q, old, math.Float32bits(f(math.Float32frombits(old)))) {
+			return math.Float32frombits(old)
+		}
+	}
+}
+
+// UpdateFloat64 atomically replaces the value at p with f of the value, and
+// returns the old value at p. f may be called more than once.
+func UpdateFloat64(p *float64, f func(old float64) float64) float64 {
+	q := (*uint64)(unsafe.Pointer(p))
+	for {
+		old := atomic.LoadUint64(q)
+		if atomic.CompareAndSwapUint64(q, old, math.Float64bits(f(math.Float64frombits(old)))) {
+			return math.Float64frombits(old)
+		}
+	}
+}
+
+// LoadPointer atomically loads the pointer at p.
+func LoadPointer(p unsafe.Pointer) unsafe.Pointer {
+	return atomic.LoadPointer((*unsafe.Pointer)(p))
+}
+
+// StorePointer atomically stores the pointer v at p.
+func StorePointer(p, v unsafe.Pointer) {
+	atomic.StorePointer((*unsafe.Pointer)(p), v)
+}
+
+// SwapPointer atomically stores the pointer new at p, and returns the old
+// pointer at p.
+func SwapPointer(p, new unsafe.Pointer) unsafe.Pointer {
+	return atomic.SwapPointer((*unsafe.Pointer)(p), new)
+}
+
+// CompareAndSwapPointer atomically stores the pointer new at p if the pointer
+// at p equals cmp. It stores the old pointer at p to old, and reports whether
+// new was stored.
+func CompareAndSwapPointer(p, cmp, new, old unsafe.Pointer) bool {
+	for {
+		if atomic.CompareAndSwapPointer((*unsafe.Pointer)(p), cmp, new) {
+			*(*unsafe.Pointer)(old) = cmp
+			return true
+		}
+		if v := atomic.LoadPointer((*unsafe.Pointer)(p)); v != cmp {
+			*(*unsafe.Pointer)(old) = v
+			return false
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package atomics
+
+import (
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestUpdateInt32(t *testing.T) {
+	var x int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				UpdateInt32(&x, func(old int32) int32 { return old ^ 1<<uint(j%31) })
+			}
+		}()
+	}
+	wg.Wait()
+	// Each bit is toggled an even number of times in total.
+	if x != 0 {
+		t.Errorf("expected 0, got 0x%X", x)
+	}
+}
+
+func TestCompareAndSwapInt64(t *testing.T) {
+	x := int64(42)
+	if old, ok := CompareAndSwapInt64(&x, 1, 2); ok || old != 42 {
+		t.Errorf("failed swap: expected (42, false), got (%d, %v)", old, ok)
+	}
+	if old, ok := CompareAndSwapInt64(&x, 42, 2); !ok || old != 42 || x != 2 {
+		t.Errorf("successful swap: expected (42, true) and 2, got (%d, %v) and %d", old, ok, x)
+	}
+}
+
+func TestUpdateFloat64(t *testing.T) {
+	var x float64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				UpdateFloat64(&x, func(old float64) float64 { return old + 1 })
+			}
+		}()
+	}
+	wg.Wait()
+	if got := LoadFloat64(&x); got != 8000 {
+		t.Errorf("expected 8000, got %v", got)
+	}
+}
+
+func TestCompareAndSwapPointer(t *testing.T) {
+	a, b := new(int8), new(int8)
+	p := a
+	var old *int8
+	if ok := CompareAndSwapPointer(unsafe.Pointer(&p), unsafe.Pointer(b), unsafe.Pointer(b), unsafe.Pointer(&old)); ok || old != a {
+		t.Errorf("failed swap: expected (%p, false), got (%p, %v)", a, old, ok)
+	}
+	if ok := CompareAndSwapPointer(unsafe.Pointer(&p), unsafe.Pointer(a), unsafe.Pointer(b), unsafe.Pointer(&old)); !ok || old != a || p != b {
+		t.Errorf("successful swap: expected (%p, true) and %p, got (%p, %v) and %p", a, b, old, ok, p)
+	}
+}
@@ -0,0 +1,122 @@
+// Package eh provides runtime support for exception handling and indirect
+// branches in Go source code decompiled from LLVM IR.
+//
+// Exceptions are raised by panics. C++ exceptions thrown by __cxa_throw are
+// raised by Throw. The invoke terminator is implemented by Invoke, which
+// recovers the exception raised by the callee, and the resume terminator is
+// implemented by panicking with the value returned by Resume, which raises it
+// again.
+//
+// Catch clauses of landing pads are matched against the type information of
+// exceptions by identity; class hierarchies of C++ exceptions are not taken
+// into account.
+package eh
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Exception is an exception in flight.
+type Exception struct {
+	// Pointer to the exception object (e.g. as thrown by __cxa_throw); or nil
+	// if the exception was raised by a Go panic.
+	Ptr *int8
+	// Type information of the exception object; or nil if unknown.
+	Type *int8
+	// Value of the Go panic which raised the exception; or nil if raised by
+	// Throw.
+	Value interface{}
+}
+
+// Error returns a string representation of the exception.
+func (e *Exception) Error() string {
+	if e.Value != nil {
+		return fmt.Sprintf("eh: exception raised by panic: %v", e.Value)
+	}
+	return fmt.Sprintf("eh: uncaught exception %p of type %p", e.Ptr, e.Type)
+}
+
+// Throw raises an exception of the given exception object and type
+// information; corresponding to __cxa_throw.
+func Throw(ptr, typ *int8) {
+	panic(&Exception{Ptr: ptr, Type: typ})
+}
+
+// Invoke calls f, and returns the exception raised by f; or nil if f returns
+// normally.
+func Invoke(f func()) (exc *Exception) {
+	defer func() {
+		if e := recover(); e != nil {
+			if ee, ok := e.(*Exception); ok {
+				exc = ee
+			} else {
+				exc = &Exception{Value: e}
+			}
+		}
+	}()
+	f()
+	return nil
+}
+
+// Resume returns the panic value which raises the given exception again, after
+// its landing pad has run cleanup code or failed to catch it. Exceptions raised
+// by Go panics are resumed by panicking with the original value.
+//
+//    panic(eh.Resume(exc))
+func Resume(exc *Exception) interface{} {
+	if exc.Value != nil {
+		return exc.Value
+	}
+	return exc
+}
+
+var (
+	// mu guards typeIDs and blockAddrs.
+	mu sync.Mutex
+	// typeIDs maps from type information to type ID.
+	typeIDs = make(map[*int8]int32)
+	// blockAddrs maps from function and basic block name to block address.
+	blockAddrs = make(map[[2]string]*int8)
+)
+
+// TypeID returns the type ID of the given type information, as used for
+// landing pad selector values; corresponding to llvm.eh.typeid.for.
+func TypeID(typ *int8) int32 {
+	mu.Lock()
+	defer mu.Unlock()
+	id, ok := typeIDs[typ]
+	if !ok {
+		id = int32(len(typeIDs) + 1)
+		typeIDs[typ] = id
+	}
+	return id
+}
+
+// Selector returns the selector value of the landing pad with the given catch
+// clauses for the specified exception; i.e. the type ID of the type information
+// of the first matching catch clause, or 0 if no catch clause matches (e.g.
+// cleanup landing pads). A catch clause of nil type information matches any
+// exception.
+func Selector(exc *Exception, catches ...*int8) int32 {
+	for _, typ := range catches {
+		if typ == nil || typ == exc.Type {
+			return TypeID(typ)
+		}
+	}
+	return 0
+}
+
+// BlockAddress returns the address of the given basic block of the specified
+// function, as used by indirect branches. Addresses are unique per basic block.
+func BlockAddress(funcName, blockName string) *int8 {
+	mu.Lock()
+	defer mu.Unlock()
+	key := [2]string{funcName, blockName}
+	addr, ok := blockAddrs[key]
+	if !ok {
+		addr = new(int8)
+		blockAddrs[key] = addr
+	}
+	return addr
+}
@@ -0,0 +1,33 @@
+package eh
+
+import "testing"
+
+func TestInvoke(t *testing.T) {
+	ptr, typ, other := new(int8), new(int8), new(int8)
+	if exc := Invoke(func() {}); exc != nil {
+		t.Fatalf("normal return: expected nil exception, got %v", exc)
+	}
+	exc := Invoke(func() { Throw(ptr, typ) })
+	if exc == nil || exc.Ptr != ptr {
+		t.Fatalf("thrown exception: expected exception object %p, got %v", ptr, exc)
+	}
+	if got, want := Selector(exc, other, typ), TypeID(typ); got != want {
+		t.Errorf("catch clause: expected selector %d, got %d", want, got)
+	}
+	if got := Selector(exc, other); got != 0 {
+		t.Errorf("cleanup: expected selector 0, got %d", got)
+	}
+	// Thrown exceptions are resumed as is, to be caught again by enclosing
+	// landing pads.
+	if got := Invoke(func() { panic(Resume(exc)) }); got != exc {
+		t.Errorf("resume: expected exception %v, got %v", exc, got)
+	}
+	// Exceptions raised by Go panics are resumed with the original value.
+	exc = Invoke(func() { panic("foo") })
+	defer func() {
+		if e := recover(); e != "foo" {
+			t.Errorf("resume: expected panic value %q, got %v", "foo", e)
+		}
+	}()
+	panic(Resume(exc))
+}
@@ -0,0 +1,277 @@
+// Package floatn provides runtime support for floating-point types without a
+// direct equivalent in Go (half, x86_fp80, fp128 and ppc_fp128), in Go source
+// code decompiled from LLVM IR.
+//
+// Values are represented by Float, which rounds the results of arithmetic
+// operations to the precision of the LLVM IR type (e.g. 64 bits for x86_fp80
+// and 113 bits for fp128). The exponent range is not limited, thus overflow
+// and subnormal results of the LLVM IR type are not reproduced.
+package floatn
+
+import (
+	"math"
+	"math/big"
+)
+
+// Precisions of LLVM IR floating-point types, in bits of the significand.
+const (
+	PrecHalf      = 11
+	PrecX86_FP80  = 64
+	PrecFP128     = 113
+	PrecPPC_FP128 = 106
+)
+
+// Float is a floating-point number of a fixed precision, with IEEE 754
+// semantics for NaN, infinities and signed zeros.
+//
+// Values of type Float are immutable, and the zero value is positive zero. The
+// precision of the result of binary operations is the larger of the precisions
+// of the operands.
+type Float struct {
+	// Underlying value; nil if zero value.
+	x *big.Float
+	// NaN.
+	nan bool
+}
+
+// New returns the floating-point number of the given precision represented by
+// s, which is a decimal or hexadecimal floating-point literal (e.g. "1.5",
+// "0x.8p+2"), "±Inf" or "NaN". New panics if s is invalid.
+func New(prec uint, s string) Float {
+	if s == "NaN" {
+		return NaN(prec)
+	}
+	x, _, err := big.ParseFloat(s, 0, prec, big.ToNearestEven)
+	if err != nil {
+		panic(err)
+	}
+	return Float{x: x}
+}
+
+// NaN returns a NaN of the given precision.
+func NaN(prec uint) Float {
+	return Float{nan: true}
+}
+
+// Inf returns positive infinity if sign >= 0, and negative infinity otherwise.
+func Inf(prec uint, sign int) Float {
+	return Float{x: new(big.Float).SetPrec(prec).SetInf(sign < 0)}
+}
+
+// FromFloat64 returns the floating-point number of the given precision closest
+// to f.
+func FromFloat64(prec uint, f float64) Float {
+	if math.IsNaN(f) {
+		return NaN(prec)
+	}
+	return Float{x: new(big.Float).SetPrec(prec).SetFloat64(f)}
+}
+
+// FromInt64 returns the floating-point number of the given precision closest
+// to v.
+func FromInt64(prec uint, v int64) Float {
+	return Float{x: new(big.Float).SetPrec(prec).SetInt64(v)}
+}
+
+// FromUint64 returns the floating-point number of the given precision closest
+// to v.
+func FromUint64(prec uint, v uint64) Float {
+	return Float{x: new(big.Float).SetPrec(prec).SetUint64(v)}
+}
+
+// IsNaN reports whether x is NaN.
+func (x Float) IsNaN() bool {
+	return x.nan
+}
+
+// big returns the underlying value of x; which must not be NaN.
+func (x Float) big() *big.Float {
+	if x.x == nil {
+		return new(big.Float)
+	}
+	return x.x
+}
+
+// Convert returns x rounded to the given precision; corresponding to fpext and
+// fptrunc.
+func (x Float) Convert(prec uint) Float {
+	if x.IsNaN() {
+		return x
+	}
+	return Float{x: new(big.Float).SetPrec(prec).Set(x.big())}
+}
+
+// Float64 returns the float64 value closest to x.
+func (x Float) Float64() float64 {
+	if x.IsNaN() {
+		return math.NaN()
+	}
+	f, _ := x.big().Float64()
+	return f
+}
+
+// Float32 returns the float32 value closest to x.
+func (x Float) Float32() float32 {
+	if x.IsNaN() {
+		return float32(math.NaN())
+	}
+	f, _ := x.big().Float32()
+	return f
+}
+
+// Int64 returns the integer part of x, truncated towards zero and saturated to
+// the range of int64. NaN is converted to 0.
+func (x Float) Int64() int64 {
+	if x.IsNaN() {
+		return 0
+	}
+	i, _ := x.big().Int64()
+	return i
+}
+
+// Uint64 returns the integer part of x, truncated towards zero and saturated
+// to the range of uint64. NaN is converted to 0.
+func (x Float) Uint64() uint64 {
+	if x.IsNaN() {
+		return 0
+	}
+	i, _ := x.big().Uint64()
+	return i
+}
+
+// String returns the decimal representation of x.
+func (x Float) String() string {
+	if x.IsNaN() {
+		return "NaN"
+	}
+	return x.big().Text('g', -1)
+}
+
+// prec returns the precision of the result of binary operations on x and y.
+func prec(x, y Float) uint {
+	if x.big().Prec() > y.big().Prec() {
+		return x.big().Prec()
+	}
+	return y.big().Prec()
+}
+
+// op returns the result of the given binary operation on x and y, or NaN if
+// either operand is NaN or the operation is invalid (e.g. Inf - Inf).
+func op(x, y Float, f func(z, x, y *big.Float) *big.Float) (z Float) {
+	if x.IsNaN() || y.IsNaN() {
+		return NaN(0)
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(big.ErrNaN); !ok {
+				panic(e)
+			}
+			z = NaN(0)
+		}
+	}()
+	return Float{x: f(new(big.Float).SetPrec(prec(x, y)), x.big(), y.big())}
+}
+
+// Add returns x + y; corresponding to fadd.
+func (x Float) Add(y Float) Float {
+	return op(x, y, (*big.Float).Add)
+}
+
+// Sub returns x - y; corresponding to fsub.
+func (x Float) Sub(y Float) Float {
+	return op(x, y, (*big.Float).Sub)
+}
+
+// Mul returns x * y; corresponding to fmul.
+func (x Float) Mul(y Float) Float {
+	return op(x, y, (*big.Float).Mul)
+}
+
+// Quo returns x / y; corresponding to fdiv.
+func (x Float) Quo(y Float) Float {
+	return op(x, y, (*big.Float).Quo)
+}
+
+// Rem returns the remainder of x / y, with the sign of x; corresponding to
+// frem. The result is exact, as computed by fmod in C.
+func (x Float) Rem(y Float) Float {
+	switch {
+	case x.IsNaN() || y.IsNaN() || x.big().IsInf() || y.big().Sign() == 0:
+		return NaN(0)
+	case y.big().IsInf() || x.big().Sign() == 0:
+		return x
+	}
+	// Scale x and y to integers by a common power of two; the remainder of the
+	// integers scaled back is exact.
+	p := prec(x, y)
+	ex := x.big().MantExp(nil)
+	ey := y.big().MantExp(nil)
+	e := ex
+	if ey < e {
+		e = ey
+	}
+	shift := int(p) - e
+	xi, _ := new(big.Float).SetMantExp(x.big(), shift).Int(nil)
+	yi, _ := new(big.Float).SetMantExp(y.big(), shift).Int(nil)
+	ri := new(big.Int).Rem(xi, yi)
+	r := new(big.Float).SetPrec(p).SetInt(ri)
+	r.SetMantExp(r, -shift)
+	if r.Sign() == 0 && x.big().Signbit() {
+		r.Neg(r)
+	}
+	return Float{x: r}
+}
+
+// Neg returns -x; corresponding to fneg.
+func (x Float) Neg() Float {
+	if x.IsNaN() {
+		return x
+	}
+	return Float{x: new(big.Float).Neg(x.big())}
+}
+
+// cmp returns the comparison of x and y, and reports whether x and y are
+// ordered (i.e. neither is NaN).
+func cmp(x, y Float) (int, bool) {
+	if x.IsNaN() || y.IsNaN() {
+		return 0, false
+	}
+	return x.big().Cmp(y.big()), true
+}
+
+// Eq reports whether x == y. Eq is false if either operand is NaN, as for the
+// == operator on Go floating-point numbers.
+func (x Float) Eq(y Float) bool {
+	c, ok := cmp(x, y)
+	return ok && c == 0
+}
+
+// Ne reports whether x != y. Ne is true if either operand is NaN, as for the
+// != operator on Go floating-point numbers.
+func (x Float) Ne(y Float) bool {
+	return !x.Eq(y)
+}
+
+// Lt reports whether x < y. Lt is false if either operand is NaN.
+func (x Float) Lt(y Float) bool {
+	c, ok := cmp(x, y)
+	return ok && c < 0
+}
+
+// Le reports whether x <= y. Le is false if either operand is NaN.
+func (x Float) Le(y Float) bool {
+	c, ok := cmp(x, y)
+	return ok && c <= 0
+}
+
+// Gt reports whether x > y. Gt is false if either operand is NaN.
+func (x Float) Gt(y Float) bool {
+	c, ok := cmp(x, y)
+	return ok && c > 0
+}
+
+// Ge reports whether x >= y. Ge is false if either operand is NaN.
+func (x Float) Ge(y Float) bool {
+	c, ok := cmp(x, y)
+	return ok && c >= 0
+}
@@ -0,0 +1,96 @@
+package floatn
+
+import (
+	"math"
+	"testing"
+)
+
+func TestArith(t *testing.T) {
+	one := FromInt64(PrecX86_FP80, 1)
+	three := FromInt64(PrecX86_FP80, 3)
+	tiny := New(PrecX86_FP80, "0x1p-63")
+	golden := []struct {
+		name string
+		got  Float
+		want string
+	}{
+		{name: "1+2^-63", got: one.Add(tiny), want: "1.0000000000000000001"},
+		{name: "1/3", got: one.Quo(three), want: "0.33333333333333333334"},
+		{name: "(1+2^-63)-1", got: one.Add(tiny).Sub(one), want: "1.084202172485504434e-19"},
+		{name: "fp128 1+2^-112", got: FromInt64(PrecFP128, 1).Add(New(PrecFP128, "0x1p-112")).Sub(FromInt64(PrecFP128, 1)), want: "1.9259299443872358530559779425849273e-34"},
+		{name: "half 1+2^-11", got: FromInt64(PrecHalf, 1).Add(New(PrecHalf, "0x1p-11")), want: "1"},
+		{name: "7 rem 3", got: FromInt64(PrecFP128, 7).Rem(FromInt64(PrecFP128, 3)), want: "1"},
+		{name: "-7 rem 3", got: FromInt64(PrecFP128, -7).Rem(FromInt64(PrecFP128, 3)), want: "-1"},
+		{name: "5.5 rem 0.25", got: New(PrecFP128, "5.5").Rem(New(PrecFP128, "0.25")), want: "0"},
+		{name: "1e30 rem 0.1", got: New(PrecX86_FP80, "1e30").Rem(FromFloat64(PrecX86_FP80, 0.1)), want: "0.076058935420785206416"},
+		{name: "1 rem 0", got: one.Rem(FromInt64(PrecX86_FP80, 0)), want: "NaN"},
+		{name: "inf-inf", got: Inf(PrecFP128, 1).Sub(Inf(PrecFP128, 1)), want: "NaN"},
+		{name: "0/0", got: FromInt64(PrecFP128, 0).Quo(FromInt64(PrecFP128, 0)), want: "NaN"},
+		{name: "1/0", got: one.Quo(FromInt64(PrecX86_FP80, 0)), want: "+Inf"},
+		{name: "-nan", got: NaN(PrecFP128).Neg(), want: "NaN"},
+	}
+	for _, g := range golden {
+		if got := g.got.String(); got != g.want {
+			t.Errorf("%s: expected %v, got %v", g.name, g.want, got)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	one := FromInt64(PrecFP128, 1)
+	two := FromInt64(PrecFP128, 2)
+	nan := NaN(PrecFP128)
+	golden := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{name: "1 == 1", got: one.Eq(one), want: true},
+		{name: "1 < 2", got: one.Lt(two), want: true},
+		{name: "2 <= 1", got: two.Le(one), want: false},
+		{name: "2 > 1", got: two.Gt(one), want: true},
+		{name: "1 >= 1", got: one.Ge(one), want: true},
+		{name: "nan == nan", got: nan.Eq(nan), want: false},
+		{name: "nan != nan", got: nan.Ne(nan), want: true},
+		{name: "nan < 1", got: nan.Lt(one), want: false},
+		{name: "1 >= nan", got: one.Ge(nan), want: false},
+		{name: "-0 == 0", got: FromFloat64(PrecFP128, math.Copysign(0, -1)).Eq(FromInt64(PrecFP128, 0)), want: true},
+	}
+	for _, g := range golden {
+		if g.got != g.want {
+			t.Errorf("%s: expected %v, got %v", g.name, g.want, g.got)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	x := New(PrecFP128, "0x1.0000000000000000000000000001p+0")
+	if got := x.Convert(PrecX86_FP80).Sub(FromInt64(PrecX86_FP80, 1)).String(); got != "0" {
+		t.Errorf("fptrunc: expected 0, got %v", got)
+	}
+	if got := New(PrecX86_FP80, "-2.75").Int64(); got != -2 {
+		t.Errorf("fptosi: expected -2, got %v", got)
+	}
+	if got := FromUint64(PrecX86_FP80, math.MaxUint64).Uint64(); got != math.MaxUint64 {
+		t.Errorf("uitofp/fptoui: expected %v, got %v", uint64(math.MaxUint64), got)
+	}
+	if got := NaN(PrecX86_FP80).Float64(); !math.IsNaN(got) {
+		t.Errorf("fptrunc NaN: expected NaN, got %v", got)
+	}
+	if got := New(PrecHalf, "0.1").Float32(); got != 0.099975586 {
+		t.Errorf("half 0.1: expected 0.099975586, got %v", got)
+	}
+}
+
+func TestZero(t *testing.T) {
+	var zero Float
+	if zero.IsNaN() || !zero.Eq(FromInt64(PrecFP128, 0)) {
+		t.Errorf("zero value: expected 0, got %v", zero)
+	}
+	if got := zero.Add(New(PrecFP128, "1.5")).String(); got != "1.5" {
+		t.Errorf("0+1.5: expected 1.5, got %v", got)
+	}
+	if got := zero.Neg().Float64(); !math.Signbit(got) {
+		t.Errorf("-0: expected negative zero, got %v", got)
+	}
+}
@@ -0,0 +1,269 @@
+// Package intn provides runtime support for arbitrary-width integers in Go
+// source code decompiled from LLVM IR.
+//
+// LLVM IR integer types of at most 64 bits (e.g. i24, i48) are represented by
+// Go integers of the next larger width, kept in sign-extended form using
+// SignExtend and ZeroExtend. Integer types wider than 64 bits (e.g. i128,
+// i256) are represented by Int, which implements two's complement arithmetic of
+// the given bit width.
+package intn
+
+import (
+	"math"
+	"math/big"
+)
+
+// SignExtend returns the n least significant bits of x, sign-extended to 64
+// bits.
+func SignExtend(x int64, n uint) int64 {
+	if n == 0 || n >= 64 {
+		return x
+	}
+	shift := 64 - n
+	return x << shift >> shift
+}
+
+// ZeroExtend returns the n least significant bits of x, zero-extended to 64
+// bits.
+func ZeroExtend(x uint64, n uint) uint64 {
+	if n >= 64 {
+		return x
+	}
+	return x & (1<<n - 1)
+}
+
+// Bool returns 1 if b is true, and 0 otherwise; corresponding to the
+// zero-extension of i1 values, which are represented by the Go bool type.
+func Bool(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Int is an integer of a fixed bit width, with two's complement semantics.
+//
+// Values of type Int are immutable, and the zero value represents 0 of
+// unspecified width. The bit width of the result of binary operations is the
+// larger of the bit widths of the operands.
+type Int struct {
+	// Bit width.
+	bits uint
+	// Value in the range [0, 2^bits); or nil to represent 0.
+	x *big.Int
+}
+
+// New returns the integer of the given bit width represented by the decimal
+// string s. The value wraps around if out of range. New panics if s is not a
+// valid decimal integer.
+func New(bits uint, s string) Int {
+	x, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("intn: invalid integer " + s)
+	}
+	return newInt(bits, x)
+}
+
+// FromInt64 returns the integer of the given bit width with the value of v,
+// sign-extended.
+func FromInt64(bits uint, v int64) Int {
+	return newInt(bits, big.NewInt(v))
+}
+
+// FromUint64 returns the integer of the given bit width with the value of v,
+// zero-extended.
+func FromUint64(bits uint, v uint64) Int {
+	return newInt(bits, new(big.Int).SetUint64(v))
+}
+
+// FromFloat64 returns the integer of the given bit width with the value of f,
+// truncated towards zero.
+func FromFloat64(bits uint, f float64) Int {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Int{bits: bits}
+	}
+	x, _ := big.NewFloat(f).Int(nil)
+	return newInt(bits, x)
+}
+
+// newInt returns the integer of the given bit width with the value of x,
+// wrapped around if out of range.
+func newInt(bits uint, x *big.Int) Int {
+	return Int{bits: bits, x: new(big.Int).Mod(x, limit(bits))}
+}
+
+// limit returns 2^bits.
+func limit(bits uint) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), bits)
+}
+
+// Bits returns the bit width of x.
+func (x Int) Bits() uint {
+	return x.bits
+}
+
+// unsigned returns the unsigned value of x.
+func (x Int) unsigned() *big.Int {
+	if x.x == nil {
+		return new(big.Int)
+	}
+	return x.x
+}
+
+// signed returns the signed value of x.
+func (x Int) signed() *big.Int {
+	v := new(big.Int).Set(x.unsigned())
+	if x.bits > 0 && v.Bit(int(x.bits-1)) == 1 {
+		v.Sub(v, limit(x.bits))
+	}
+	return v
+}
+
+// Int64 returns the 64 least significant bits of x, as a signed integer.
+func (x Int) Int64() int64 {
+	return int64(x.Uint64())
+}
+
+// Uint64 returns the 64 least significant bits of x, as an unsigned integer.
+func (x Int) Uint64() uint64 {
+	return new(big.Int).And(x.unsigned(), new(big.Int).SetUint64(math.MaxUint64)).Uint64()
+}
+
+// Float64 returns the floating-point value nearest to the signed value of x.
+func (x Int) Float64() float64 {
+	f, _ := new(big.Float).SetInt(x.signed()).Float64()
+	return f
+}
+
+// UFloat64 returns the floating-point value nearest to the unsigned value of x.
+func (x Int) UFloat64() float64 {
+	f, _ := new(big.Float).SetInt(x.unsigned()).Float64()
+	return f
+}
+
+// String returns the signed decimal representation of x.
+func (x Int) String() string {
+	return x.signed().String()
+}
+
+// Trunc returns x truncated to the given bit width.
+func (x Int) Trunc(bits uint) Int {
+	return newInt(bits, x.unsigned())
+}
+
+// ZExt returns x zero-extended to the given bit width.
+func (x Int) ZExt(bits uint) Int {
+	return newInt(bits, x.unsigned())
+}
+
+// SExt returns x sign-extended to the given bit width.
+func (x Int) SExt(bits uint) Int {
+	return newInt(bits, x.signed())
+}
+
+// width returns the bit width of the result of a binary operation on x and y.
+func width(x, y Int) uint {
+	if x.bits > y.bits {
+		return x.bits
+	}
+	return y.bits
+}
+
+// Add returns x + y.
+func (x Int) Add(y Int) Int {
+	return newInt(width(x, y), new(big.Int).Add(x.unsigned(), y.unsigned()))
+}
+
+// Sub returns x - y.
+func (x Int) Sub(y Int) Int {
+	return newInt(width(x, y), new(big.Int).Sub(x.unsigned(), y.unsigned()))
+}
+
+// Mul returns x * y.
+func (x Int) Mul(y Int) Int {
+	return newInt(width(x, y), new(big.Int).Mul(x.unsigned(), y.unsigned()))
+}
+
+// UDiv returns the unsigned quotient x / y. UDiv panics if y is zero.
+func (x Int) UDiv(y Int) Int {
+	return newInt(width(x, y), new(big.Int).Quo(x.unsigned(), nonZero(y.unsigned())))
+}
+
+// SDiv returns the signed quotient x / y, truncated towards zero. SDiv panics if
+// y is zero.
+func (x Int) SDiv(y Int) Int {
+	return newInt(width(x, y), new(big.Int).Quo(x.signed(), nonZero(y.signed())))
+}
+
+// URem returns the unsigned remainder x % y. URem panics if y is zero.
+func (x Int) URem(y Int) Int {
+	return newInt(width(x, y), new(big.Int).Rem(x.unsigned(), nonZero(y.unsigned())))
+}
+
+// SRem returns the signed remainder x % y, with the sign of x. SRem panics if y
+// is zero.
+func (x Int) SRem(y Int) Int {
+	return newInt(width(x, y), new(big.Int).Rem(x.signed(), nonZero(y.signed())))
+}
+
+// nonZero panics with a division by zero error if y is zero.
+func nonZero(y *big.Int) *big.Int {
+	if y.Sign() == 0 {
+		panic("intn: integer divide by zero")
+	}
+	return y
+}
+
+// And returns x & y.
+func (x Int) And(y Int) Int {
+	return newInt(width(x, y), new(big.Int).And(x.unsigned(), y.unsigned()))
+}
+
+// Or returns x | y.
+func (x Int) Or(y Int) Int {
+	return newInt(width(x, y), new(big.Int).Or(x.unsigned(), y.unsigned()))
+}
+
+// Xor returns x ^ y.
+func (x Int) Xor(y Int) Int {
+	return newInt(width(x, y), new(big.Int).Xor(x.unsigned(), y.unsigned()))
+}
+
+// Shl returns x << y.
+func (x Int) Shl(y Int) Int {
+	bits := width(x, y)
+	return newInt(bits, new(big.Int).Lsh(x.unsigned(), shift(y, bits)))
+}
+
+// LShr returns the logical right shift x >> y.
+func (x Int) LShr(y Int) Int {
+	bits := width(x, y)
+	return newInt(bits, new(big.Int).Rsh(x.unsigned(), shift(y, bits)))
+}
+
+// AShr returns the arithmetic right shift x >> y.
+func (x Int) AShr(y Int) Int {
+	bits := width(x, y)
+	return newInt(bits, new(big.Int).Rsh(x.signed(), shift(y, bits)))
+}
+
+// shift returns the shift amount y, clamped to the given bit width.
+func shift(y Int, bits uint) uint {
+	n := y.unsigned()
+	if !n.IsUint64() || n.Uint64() > uint64(bits) {
+		return bits
+	}
+	return uint(n.Uint64())
+}
+
+// Cmp compares the signed values of x and y and returns -1 if x < y, 0 if x ==
+// y, and +1 if x > y.
+func (x Int) Cmp(y Int) int {
+	return x.signed().Cmp(y.signed())
+}
+
+// UCmp compares the unsigned values of x and y and returns -1 if x < y, 0 if x
+// == y, and +1 if x > y.
+func (x Int) UCmp(y Int) int {
+	return x.unsigned().Cmp(y.unsigned())
+}
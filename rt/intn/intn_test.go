@@ -0,0 +1,66 @@
+package intn
+
+import "testing"
+
+func TestSignExtend(t *testing.T) {
+	golden := []struct {
+		x    int64
+		n    uint
+		want int64
+	}{
+		{x: 0x7FFFFF, n: 24, want: 0x7FFFFF},
+		{x: 0x800000, n: 24, want: -0x800000},
+		{x: 0xFFFFFF + 2, n: 24, want: 1},
+		{x: -1, n: 48, want: -1},
+		{x: 1 << 47, n: 48, want: -1 << 47},
+	}
+	for _, g := range golden {
+		if got := SignExtend(g.x, g.n); got != g.want {
+			t.Errorf("SignExtend(0x%X, %d): expected %d, got %d", g.x, g.n, g.want, got)
+		}
+	}
+	if got, want := ZeroExtend(0xFFFFFFFF, 24), uint64(0xFFFFFF); got != want {
+		t.Errorf("ZeroExtend: expected 0x%X, got 0x%X", want, got)
+	}
+	if Bool(true) != 1 || Bool(false) != 0 {
+		t.Errorf("Bool: expected 1 and 0, got %d and %d", Bool(true), Bool(false))
+	}
+}
+
+func TestInt(t *testing.T) {
+	max := New(128, "170141183460469231731687303715884105727") // 2^127-1
+	one := FromInt64(128, 1)
+	minusOne := FromInt64(128, -1)
+	golden := []struct {
+		got  Int
+		want string
+	}{
+		// Signed overflow wraps around.
+		{got: max.Add(one), want: "-170141183460469231731687303715884105728"},
+		{got: minusOne.Mul(minusOne), want: "1"},
+		{got: FromInt64(128, -7).SDiv(FromInt64(128, 2)), want: "-3"},
+		{got: FromInt64(128, -7).SRem(FromInt64(128, 2)), want: "-1"},
+		// 2^128-1 / 2 = 2^127-1
+		{got: minusOne.UDiv(FromInt64(128, 2)), want: "170141183460469231731687303715884105727"},
+		{got: minusOne.LShr(FromInt64(128, 127)), want: "1"},
+		{got: minusOne.AShr(FromInt64(128, 127)), want: "-1"},
+		{got: one.Shl(FromInt64(128, 127)), want: "-170141183460469231731687303715884105728"},
+		{got: FromUint64(128, 1<<63).Add(FromUint64(128, 1<<63)), want: "18446744073709551616"},
+		{got: minusOne.Trunc(96).ZExt(128), want: "79228162514264337593543950335"},
+		{got: minusOne.Trunc(96).SExt(256), want: "-1"},
+	}
+	for i, g := range golden {
+		if got := g.got.String(); got != g.want {
+			t.Errorf("i=%d: expected %s, got %s", i, g.want, got)
+		}
+	}
+	if minusOne.Cmp(one) >= 0 {
+		t.Errorf("expected -1 < 1 (signed)")
+	}
+	if minusOne.UCmp(one) <= 0 {
+		t.Errorf("expected 2^128-1 > 1 (unsigned)")
+	}
+	if got, want := minusOne.Uint64(), uint64(1<<64-1); got != want {
+		t.Errorf("Uint64: expected %d, got %d", want, got)
+	}
+}
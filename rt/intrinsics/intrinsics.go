@@ -0,0 +1,200 @@
+// Package intrinsics provides runtime support for LLVM intrinsic functions
+// without a direct equivalent in Go, as used by Go source code decompiled from
+// LLVM IR.
+package intrinsics
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+	"reflect"
+	"unsafe"
+)
+
+// Slice returns a slice of the n bytes of memory starting at p.
+func Slice(p *int8, n int64) []int8 {
+	var s []int8
+	if n == 0 {
+		return s
+	}
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&s))
+	hdr.Data = uintptr(unsafe.Pointer(p))
+	hdr.Len = int(n)
+	hdr.Cap = int(n)
+	return s
+}
+
+// Set sets the n bytes of memory starting at p to c; corresponding to
+// llvm.memset.
+func Set(p *int8, c int8, n int64) {
+	s := Slice(p, n)
+	for i := range s {
+		s[i] = c
+	}
+}
+
+// MinNum returns the lesser of x and y; or the other operand if either x or y
+// is NaN. MinNum corresponds to llvm.minnum.
+func MinNum(x, y float64) float64 {
+	switch {
+	case math.IsNaN(x):
+		return y
+	case math.IsNaN(y):
+		return x
+	}
+	return math.Min(x, y)
+}
+
+// MaxNum returns the greater of x and y; or the other operand if either x or y
+// is NaN. MaxNum corresponds to llvm.maxnum.
+func MaxNum(x, y float64) float64 {
+	switch {
+	case math.IsNaN(x):
+		return y
+	case math.IsNaN(y):
+		return x
+	}
+	return math.Max(x, y)
+}
+
+// SMin returns the lesser of the signed integers x and y; corresponding to
+// llvm.smin.
+func SMin(x, y int64) int64 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// SMax returns the greater of the signed integers x and y; corresponding to
+// llvm.smax.
+func SMax(x, y int64) int64 {
+	if x > y {
+		return x
+	}
+	return y
+}
+
+// UMin returns the lesser of the unsigned integers x and y; corresponding to
+// llvm.umin.
+func UMin(x, y uint64) uint64 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// UMax returns the greater of the unsigned integers x and y; corresponding to
+// llvm.umax.
+func UMax(x, y uint64) uint64 {
+	if x > y {
+		return x
+	}
+	return y
+}
+
+// Abs returns the absolute value of x; corresponding to llvm.abs. The absolute
+// value of the minimum n-bit integer wraps around to itself when truncated to n
+// bits.
+func Abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// FShl returns the n most significant bits of the concatenation of the n-bit
+// integers x and y, shifted left by s modulo n; corresponding to llvm.fshl.
+func FShl(x, y, s uint64, n uint) uint64 {
+	s %= uint64(n)
+	if s == 0 {
+		return x
+	}
+	return (x<<s | y>>(uint64(n)-s)) & mask(n)
+}
+
+// FShr returns the n least significant bits of the concatenation of the n-bit
+// integers x and y, shifted right by s modulo n; corresponding to llvm.fshr.
+func FShr(x, y, s uint64, n uint) uint64 {
+	s %= uint64(n)
+	if s == 0 {
+		return y
+	}
+	return (x<<(uint64(n)-s) | y>>s) & mask(n)
+}
+
+// mask returns a bit mask of the n least significant bits.
+func mask(n uint) uint64 {
+	if n >= 64 {
+		return math.MaxUint64
+	}
+	return 1<<n - 1
+}
+
+// signExtend returns the n least significant bits of x, sign-extended to 64
+// bits.
+func signExtend(x int64, n uint) int64 {
+	shift := 64 - n
+	return x << shift >> shift
+}
+
+// SAddOverflow reports whether the signed addition x + y of n-bit integers
+// overflows; corresponding to the overflow bit of llvm.sadd.with.overflow.
+func SAddOverflow(x, y int64, n uint) bool {
+	r := x + y
+	if n < 64 {
+		return r != signExtend(r, n)
+	}
+	return (x >= 0) == (y >= 0) && (r >= 0) != (x >= 0)
+}
+
+// UAddOverflow reports whether the unsigned addition x + y of n-bit integers
+// overflows; corresponding to the overflow bit of llvm.uadd.with.overflow.
+func UAddOverflow(x, y uint64, n uint) bool {
+	r := x + y
+	if n < 64 {
+		return r>>n != 0
+	}
+	return r < x
+}
+
+// SSubOverflow reports whether the signed subtraction x - y of n-bit integers
+// overflows; corresponding to the overflow bit of llvm.ssub.with.overflow.
+func SSubOverflow(x, y int64, n uint) bool {
+	r := x - y
+	if n < 64 {
+		return r != signExtend(r, n)
+	}
+	return (x >= 0) != (y >= 0) && (r >= 0) != (x >= 0)
+}
+
+// USubOverflow reports whether the unsigned subtraction x - y of n-bit integers
+// overflows; corresponding to the overflow bit of llvm.usub.with.overflow.
+func USubOverflow(x, y uint64, n uint) bool {
+	return x < y
+}
+
+// SMulOverflow reports whether the signed multiplication x * y of n-bit
+// integers overflows; corresponding to the overflow bit of
+// llvm.smul.with.overflow.
+func SMulOverflow(x, y int64, n uint) bool {
+	if n <= 32 {
+		r := x * y
+		return r != signExtend(r, n)
+	}
+	r := new(big.Int).Mul(big.NewInt(x), big.NewInt(y))
+	min := new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), n-1))
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), n-1), big.NewInt(1))
+	return r.Cmp(min) < 0 || r.Cmp(max) > 0
+}
+
+// UMulOverflow reports whether the unsigned multiplication x * y of n-bit
+// integers overflows; corresponding to the overflow bit of
+// llvm.umul.with.overflow.
+func UMulOverflow(x, y uint64, n uint) bool {
+	hi, lo := bits.Mul64(x, y)
+	if n < 64 {
+		return hi != 0 || lo>>n != 0
+	}
+	return hi != 0
+}
@@ -0,0 +1,72 @@
+package intrinsics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOverflow(t *testing.T) {
+	golden := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{name: "sadd i8 127+1", got: SAddOverflow(127, 1, 8), want: true},
+		{name: "sadd i8 -128+127", got: SAddOverflow(-128, 127, 8), want: false},
+		{name: "sadd i64 max+1", got: SAddOverflow(math.MaxInt64, 1, 64), want: true},
+		{name: "uadd i32 max+1", got: UAddOverflow(math.MaxUint32, 1, 32), want: true},
+		{name: "uadd i64 max+0", got: UAddOverflow(math.MaxUint64, 0, 64), want: false},
+		{name: "ssub i16 -32768-1", got: SSubOverflow(-32768, 1, 16), want: true},
+		{name: "ssub i64 min-1", got: SSubOverflow(math.MinInt64, 1, 64), want: true},
+		{name: "usub i32 0-1", got: USubOverflow(0, 1, 32), want: true},
+		{name: "smul i32 65536*32768", got: SMulOverflow(65536, 32768, 32), want: true},
+		{name: "smul i64 -1*min", got: SMulOverflow(-1, math.MinInt64, 64), want: true},
+		{name: "smul i64 2^31*2^31", got: SMulOverflow(1<<31, 1<<31, 64), want: false},
+		{name: "umul i16 256*256", got: UMulOverflow(256, 256, 16), want: true},
+		{name: "umul i64 2^32*2^32", got: UMulOverflow(1<<32, 1<<32, 64), want: true},
+	}
+	for _, g := range golden {
+		if g.got != g.want {
+			t.Errorf("%s: expected %v, got %v", g.name, g.want, g.got)
+		}
+	}
+}
+
+func TestIntOps(t *testing.T) {
+	golden := []struct {
+		name string
+		got  uint64
+		want uint64
+	}{
+		{name: "smin -1,1", got: uint64(SMin(-1, 1)), want: math.MaxUint64},
+		{name: "smax -1,1", got: uint64(SMax(-1, 1)), want: 1},
+		{name: "umin 1,max", got: UMin(1, math.MaxUint64), want: 1},
+		{name: "umax 1,max", got: UMax(1, math.MaxUint64), want: math.MaxUint64},
+		{name: "abs -5", got: uint64(Abs(-5)), want: 5},
+		{name: "abs i32 min", got: uint64(int32(Abs(math.MinInt32))), want: 1<<64 - 1<<31},
+		{name: "fshl i8 0x12,0x34,4", got: FShl(0x12, 0x34, 4, 8), want: 0x23},
+		{name: "fshl i8 0x12,0x34,12", got: FShl(0x12, 0x34, 12, 8), want: 0x23},
+		{name: "fshl i64 0", got: FShl(1, 2, 0, 64), want: 1},
+		{name: "fshl i64 1", got: FShl(1<<63|1, 1<<63, 1, 64), want: 1<<1 | 1},
+		{name: "fshr i8 0x12,0x34,4", got: FShr(0x12, 0x34, 4, 8), want: 0x23},
+		{name: "fshr i32 0", got: FShr(1, 2, 32, 32), want: 2},
+		{name: "fshr i16 1", got: FShr(0x0001, 0x0000, 1, 16), want: 0x8000},
+	}
+	for _, g := range golden {
+		if g.got != g.want {
+			t.Errorf("%s: expected %#x, got %#x", g.name, g.want, g.got)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	buf := make([]int8, 4)
+	Set(&buf[1], 7, 2)
+	copy(Slice(&buf[0], 1), Slice(&buf[2], 1))
+	want := []int8{7, 7, 7, 0}
+	for i := range want {
+		if buf[i] != want[i] {
+			t.Errorf("i=%d: expected %d, got %d", i, want[i], buf[i])
+		}
+	}
+}
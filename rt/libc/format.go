@@ -0,0 +1,110 @@
+package libc
+
+import (
+	"strings"
+)
+
+// Format translates the given C format string (as used by printf) to a
+// corresponding Go format string (as used by fmt.Printf). Length modifiers are
+// removed, and conversion specifiers without a direct equivalent in Go are
+// mapped to Go verbs (e.g. "%lu" to "%d").
+//
+// The C conversion specifier of each argument consumed by the format string is
+// returned in order, with '*' denoting field width and precision arguments. The
+// arguments are converted to the representation expected by the Go verbs using
+// Arg.
+func Format(format string) (string, []byte) {
+	var (
+		buf   strings.Builder
+		convs []byte
+	)
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		buf.WriteByte(c)
+		if c != '%' {
+			continue
+		}
+		// Flags, field width and precision are identical in C and Go.
+		j := i + 1
+		for ; j < len(format); j++ {
+			c := format[j]
+			if strings.IndexByte("-+ #0123456789.", c) != -1 {
+				buf.WriteByte(c)
+				continue
+			}
+			if c == '*' {
+				buf.WriteByte(c)
+				convs = append(convs, '*')
+				continue
+			}
+			break
+		}
+		// Skip length modifiers.
+		for ; j < len(format) && strings.IndexByte("hlLqjzt", format[j]) != -1; j++ {
+		}
+		if j >= len(format) {
+			// Incomplete conversion specification.
+			buf.WriteString(format[i+1 : j])
+			break
+		}
+		i = j
+		conv := format[j]
+		switch conv {
+		case '%':
+			buf.WriteByte('%')
+			continue
+		case 'i', 'u':
+			buf.WriteByte('d')
+		case 'a':
+			buf.WriteByte('x')
+		case 'A':
+			buf.WriteByte('X')
+		case 'n':
+			// The number of characters written so far is not stored. The
+			// argument is consumed by an empty string.
+			buf.WriteString(".0s")
+		default:
+			buf.WriteByte(conv)
+		}
+		convs = append(convs, conv)
+	}
+	return buf.String(), convs
+}
+
+// Arg converts the given argument of a C formatting function to the
+// representation expected by the Go verb corresponding to the specified C
+// conversion specifier. C strings are converted to Go strings, characters to
+// runes, and integers of unsigned conversions to unsigned integers.
+func Arg(conv byte, arg interface{}) interface{} {
+	switch conv {
+	case 's':
+		if s, ok := arg.(*int8); ok {
+			return GoString(s)
+		}
+	case 'c':
+		switch c := arg.(type) {
+		case int8:
+			return rune(uint8(c))
+		case int32:
+			return rune(c)
+		}
+	case 'u', 'o', 'x', 'X':
+		switch x := arg.(type) {
+		case int8:
+			return uint8(x)
+		case int16:
+			return uint16(x)
+		case int32:
+			return uint32(x)
+		case int64:
+			return uint64(x)
+		}
+	case '*':
+		if x, ok := arg.(int32); ok {
+			return int(x)
+		}
+	case 'n':
+		return ""
+	}
+	return arg
+}
@@ -0,0 +1,199 @@
+// Package libc provides Go implementations of C standard library functions, as
+// used by Go source code decompiled from LLVM IR.
+//
+// C strings are represented by pointers to their first character (i.e. *int8)
+// and are terminated by a NUL character.
+package libc
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// at returns a pointer to the i:th byte of memory starting at p.
+func at(p *int8, i int64) *int8 {
+	return (*int8)(unsafe.Pointer(uintptr(unsafe.Pointer(p)) + uintptr(i)))
+}
+
+// GoString returns the Go string of the given NUL-terminated C string.
+func GoString(s *int8) string {
+	if s == nil {
+		return ""
+	}
+	n := Strlen(s)
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = byte(*at(s, int64(i)))
+	}
+	return string(buf)
+}
+
+// CString returns a NUL-terminated C string of the given Go string.
+func CString(s string) *int8 {
+	buf := make([]int8, len(s)+1)
+	for i := 0; i < len(s); i++ {
+		buf[i] = int8(s[i])
+	}
+	return &buf[0]
+}
+
+// Strlen returns the length of the given C string; corresponding to strlen.
+func Strlen(s *int8) int64 {
+	n := int64(0)
+	for *at(s, n) != 0 {
+		n++
+	}
+	return n
+}
+
+// Strcmp compares the C strings a and b; corresponding to strcmp.
+func Strcmp(a, b *int8) int32 {
+	return Strncmp(a, b, -1)
+}
+
+// Strncmp compares at most n characters of the C strings a and b; corresponding
+// to strncmp. A negative n compares the entire strings.
+func Strncmp(a, b *int8, n int64) int32 {
+	for i := int64(0); n < 0 || i < n; i++ {
+		x, y := uint8(*at(a, i)), uint8(*at(b, i))
+		if x != y {
+			return int32(x) - int32(y)
+		}
+		if x == 0 {
+			break
+		}
+	}
+	return 0
+}
+
+// Strcpy copies the C string src, including the terminating NUL character, to
+// dst and returns dst; corresponding to strcpy.
+func Strcpy(dst, src *int8) *int8 {
+	for i := int64(0); ; i++ {
+		c := *at(src, i)
+		*at(dst, i) = c
+		if c == 0 {
+			return dst
+		}
+	}
+}
+
+// Strcat appends the C string src to dst and returns dst; corresponding to
+// strcat.
+func Strcat(dst, src *int8) *int8 {
+	Strcpy(at(dst, Strlen(dst)), src)
+	return dst
+}
+
+// Strchr returns a pointer to the first occurrence of c in the C string s, or
+// nil if not present; corresponding to strchr.
+func Strchr(s *int8, c int32) *int8 {
+	for i := int64(0); ; i++ {
+		p := at(s, i)
+		if *p == int8(c) {
+			return p
+		}
+		if *p == 0 {
+			return nil
+		}
+	}
+}
+
+// Memcmp compares the first n bytes of memory starting at a and b;
+// corresponding to memcmp.
+func Memcmp(a, b *int8, n int64) int32 {
+	for i := int64(0); i < n; i++ {
+		x, y := uint8(*at(a, i)), uint8(*at(b, i))
+		if x != y {
+			return int32(x) - int32(y)
+		}
+	}
+	return 0
+}
+
+// Atoi returns the integer value of the given C string; corresponding to atoi.
+// Leading white space is skipped and parsing stops at the first non-digit
+// character.
+func Atoi(s *int8) int32 {
+	str := strings.TrimLeft(GoString(s), " \t\n\v\f\r")
+	end := 0
+	if end < len(str) && (str[end] == '+' || str[end] == '-') {
+		end++
+	}
+	for end < len(str) && '0' <= str[end] && str[end] <= '9' {
+		end++
+	}
+	x, _ := strconv.ParseInt(str[:end], 10, 32)
+	return int32(x)
+}
+
+// stdin is a buffered reader of standard input.
+var stdin = bufio.NewReader(os.Stdin)
+
+// EOF is the value returned by character input functions at end-of-file.
+const EOF = -1
+
+// Getchar reads a character from standard input; corresponding to getchar. EOF
+// is returned at end-of-file or on error.
+func Getchar() int32 {
+	c, err := stdin.ReadByte()
+	if err != nil {
+		return EOF
+	}
+	return int32(c)
+}
+
+// Putchar writes the character c to standard output and returns c;
+// corresponding to putchar.
+func Putchar(c int32) int32 {
+	if _, err := os.Stdout.Write([]byte{byte(c)}); err != nil {
+		return EOF
+	}
+	return c
+}
+
+// Puts writes the C string s followed by a newline to standard output;
+// corresponding to puts.
+func Puts(s *int8) int32 {
+	if _, err := fmt.Println(GoString(s)); err != nil {
+		return EOF
+	}
+	return 0
+}
+
+// Printf writes output to standard output according to the given C format
+// string; corresponding to printf.
+func Printf(format *int8, args ...interface{}) int32 {
+	goFormat, verbs := Format(GoString(format))
+	for i := range args {
+		if i < len(verbs) {
+			args[i] = Arg(verbs[i], args[i])
+		}
+	}
+	return Count(fmt.Printf(goFormat, args...))
+}
+
+// Count returns the number of bytes written by a Go output function as a C int,
+// or -1 on error.
+//
+//    _1 = libc.Count(fmt.Printf("%d\n", x))
+func Count(n int, err error) int32 {
+	if err != nil {
+		return -1
+	}
+	return int32(n)
+}
+
+// Exit terminates the program with the given status; corresponding to exit.
+func Exit(status int32) {
+	os.Exit(int(status))
+}
+
+// Abort terminates the program abnormally; corresponding to abort.
+func Abort() {
+	panic("abort")
+}
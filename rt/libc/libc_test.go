@@ -0,0 +1,68 @@
+package libc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	golden := []struct {
+		in    string
+		want  string
+		convs string
+	}{
+		{in: "hello\n", want: "hello\n", convs: ""},
+		{in: "%d %i %u", want: "%d %d %d", convs: "diu"},
+		{in: "%ld %llu %zu %hhx", want: "%d %d %d %x", convs: "duux"},
+		{in: "%-8s|%05.2f%%", want: "%-8s|%05.2f%%", convs: "sf"},
+		{in: "%*d %.*s", want: "%*d %.*s", convs: "*d*s"},
+		{in: "%c%p%n%a", want: "%c%p%.0s%x", convs: "cpna"},
+		{in: "100%", want: "100%", convs: ""},
+	}
+	for _, g := range golden {
+		got, convs := Format(g.in)
+		if got != g.want {
+			t.Errorf("%q: format mismatch; expected %q, got %q", g.in, g.want, got)
+		}
+		if string(convs) != g.convs {
+			t.Errorf("%q: conversion specifiers mismatch; expected %q, got %q", g.in, g.convs, string(convs))
+		}
+	}
+}
+
+func TestArg(t *testing.T) {
+	format, convs := Format("%s=%c %lu %x %*d")
+	args := []interface{}{CString("x"), int8('y'), int32(-1), int8(-1), int32(3), int32(7)}
+	for i := range args {
+		args[i] = Arg(convs[i], args[i])
+	}
+	got := fmt.Sprintf(format, args...)
+	want := "x=y 4294967295 ff   7"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestStrings(t *testing.T) {
+	buf := make([]int8, 16)
+	Strcpy(&buf[0], CString("foo"))
+	Strcat(&buf[0], CString("bar"))
+	if got, want := GoString(&buf[0]), "foobar"; got != want {
+		t.Errorf("Strcat mismatch; expected %q, got %q", want, got)
+	}
+	if got := Strlen(&buf[0]); got != 6 {
+		t.Errorf("Strlen mismatch; expected 6, got %d", got)
+	}
+	if got := Strcmp(CString("abc"), CString("abd")); got >= 0 {
+		t.Errorf("Strcmp mismatch; expected negative result, got %d", got)
+	}
+	if got := Strncmp(CString("abc"), CString("abd"), 2); got != 0 {
+		t.Errorf("Strncmp mismatch; expected 0, got %d", got)
+	}
+	if got := GoString(Strchr(&buf[0], 'b')); got != "bar" {
+		t.Errorf("Strchr mismatch; expected %q, got %q", "bar", got)
+	}
+	if got := Atoi(CString("  -42abc")); got != -42 {
+		t.Errorf("Atoi mismatch; expected -42, got %d", got)
+	}
+}
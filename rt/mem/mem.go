@@ -0,0 +1,15 @@
+// Package mem provides runtime support for pointer arithmetic in Go source code
+// decompiled from LLVM IR.
+package mem
+
+import (
+	"unsafe"
+)
+
+// Offset returns a pointer to the i:th element of the given size in memory,
+// relative to p; corresponding to the first index of getelementptr.
+//
+//    (*T)(mem.Offset(unsafe.Pointer(p), i, unsafe.Sizeof(*p)))
+func Offset(p unsafe.Pointer, i int64, size uintptr) unsafe.Pointer {
+	return unsafe.Pointer(uintptr(p) + uintptr(i)*size)
+}
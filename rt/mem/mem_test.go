@@ -0,0 +1,29 @@
+package mem
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestOffset(t *testing.T) {
+	type pair struct {
+		a int8
+		b int64
+	}
+	ps := []pair{{a: 1}, {a: 2}, {a: 3}}
+	p := &ps[1]
+	golden := []struct {
+		i    int64
+		want int8
+	}{
+		{i: -1, want: 1},
+		{i: 0, want: 2},
+		{i: 1, want: 3},
+	}
+	for _, g := range golden {
+		got := (*pair)(Offset(unsafe.Pointer(p), g.i, unsafe.Sizeof(*p))).a
+		if got != g.want {
+			t.Errorf("offset %d: expected %d, got %d", g.i, g.want, got)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// Package rt embeds the Go source code of the runtime support packages of Go
+// source code decompiled from LLVM IR (e.g. rt/intn, rt/eh); which enables
+// type-checking decompiled Go source code independent of the working
+// directory.
+package rt
+
+import "embed"
+
+// Path is the import path of package rt; the import path prefix of the runtime
+// support packages.
+const Path = "github.com/decomp/decomp/rt"
+
+// FS contains the Go source files of the runtime support packages, by package
+// directory (e.g. "intn/intn.go"); including test files.
+//
+//go:embed */*.go
+var FS embed.FS
@@ -0,0 +1,112 @@
+// Package va provides runtime support for variadic arguments in Go source code
+// decompiled from LLVM IR.
+//
+// An LLVM IR va_list is identified by the address of its memory (e.g. the
+// operand of llvm.va_start), which is associated with the list of variadic
+// arguments by Start until End is called.
+package va
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// List is a list of variadic arguments, corresponding to va_list in C.
+type List struct {
+	// Remaining arguments.
+	args []interface{}
+}
+
+var (
+	// mu guards lists.
+	mu sync.Mutex
+	// lists maps from va_list addresses to variadic argument lists.
+	lists = make(map[interface{}]*List)
+)
+
+// Start associates the va_list at address ap with the given variadic
+// arguments.
+func Start(ap interface{}, args []interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	lists[key(ap)] = &List{args: args}
+}
+
+// Copy associates the va_list at address dst with a copy of the remaining
+// arguments of the va_list at address src.
+func Copy(dst, src interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	l := lookup(src)
+	lists[key(dst)] = &List{args: l.args}
+}
+
+// End releases the va_list at address ap.
+func End(ap interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(lists, key(ap))
+}
+
+// Arg returns the next argument of the va_list at address ap, converted to the
+// type of typ (e.g. a variadic float argument read as double). Pointer
+// arguments are converted to pointers of any type. Arg panics if no arguments
+// remain, or if the argument is not convertible to the type of typ.
+//
+//    x := va.Arg(ap, *new(int64)).(int64)
+func Arg(ap, typ interface{}) interface{} {
+	return convert(next(ap), reflect.TypeOf(typ))
+}
+
+// next removes and returns the next argument of the va_list at address ap.
+func next(ap interface{}) interface{} {
+	mu.Lock()
+	defer mu.Unlock()
+	l := lookup(ap)
+	if len(l.args) == 0 {
+		panic("va: no variadic arguments remain")
+	}
+	arg := l.args[0]
+	l.args = l.args[1:]
+	return arg
+}
+
+// convert converts the given variadic argument to the specified type.
+func convert(arg interface{}, t reflect.Type) interface{} {
+	if arg == nil {
+		return reflect.Zero(t).Interface()
+	}
+	v := reflect.ValueOf(arg)
+	switch {
+	case v.Type() == t:
+		return arg
+	case v.Kind() == reflect.Ptr && t.Kind() == reflect.Ptr:
+		return reflect.NewAt(t.Elem(), unsafe.Pointer(v.Pointer())).Interface()
+	case v.Type().ConvertibleTo(t):
+		return v.Convert(t).Interface()
+	}
+	panic(fmt.Sprintf("va: unable to convert variadic argument of type %v to %v", v.Type(), t))
+}
+
+// lookup returns the variadic argument list of the va_list at address ap. The
+// caller must hold mu.
+func lookup(ap interface{}) *List {
+	l, ok := lists[key(ap)]
+	if !ok {
+		panic(fmt.Sprintf("va: va_list %v not started", ap))
+	}
+	return l
+}
+
+// key returns the key of the va_list at address ap. Pointers are identified by
+// address, as the same va_list may be accessed through pointers of different
+// types (e.g. *int8 and *[1]T).
+func key(ap interface{}) interface{} {
+	v := reflect.ValueOf(ap)
+	if v.Kind() == reflect.Ptr {
+		return v.Pointer()
+	}
+	return ap
+}
@@ -0,0 +1,69 @@
+package va
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+)
+
+func TestArg(t *testing.T) {
+	var list [1]struct{ a, b int64 }
+	ap := &list
+	x := int64(7)
+	Start((*int8)(unsafe.Pointer(ap)), []interface{}{int32(1), "foo", float32(2.5), &x})
+	if got := Arg(ap, *new(int32)).(int32); got != 1 {
+		t.Errorf("first argument mismatch; expected 1, got %v", got)
+	}
+	var cp [1]struct{ a, b int64 }
+	Copy(&cp, ap)
+	if got := Arg(ap, *new(string)).(string); got != "foo" {
+		t.Errorf("second argument mismatch; expected %q, got %q", "foo", got)
+	}
+	if got := Arg(&cp, *new(string)).(string); got != "foo" {
+		t.Errorf("second argument of copy mismatch; expected %q, got %q", "foo", got)
+	}
+	// Arguments are converted to the requested type.
+	if got := Arg(ap, *new(float64)).(float64); got != 2.5 {
+		t.Errorf("third argument mismatch; expected 2.5, got %v", got)
+	}
+	if got := Arg(ap, *new(*[2]int32)).(*[2]int32); unsafe.Pointer(got) != unsafe.Pointer(&x) {
+		t.Errorf("fourth argument mismatch; expected %p, got %p", &x, got)
+	}
+	End(ap)
+	End(&cp)
+	defer func() {
+		if e := recover(); e == nil {
+			t.Errorf("expected panic on access to ended va_list")
+		}
+	}()
+	Arg(ap, *new(int32))
+}
+
+func TestArgConcurrent(t *testing.T) {
+	// Arguments are read under the lock guarding the va_list; run with -race.
+	const n = 100
+	var list [1]int8
+	args := make([]interface{}, n)
+	for i := range args {
+		args[i] = int32(i)
+	}
+	Start(&list, args)
+	defer End(&list)
+	var wg sync.WaitGroup
+	seen := make([]int32, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			x := Arg(&list, *new(int32)).(int32)
+			atomic.AddInt32(&seen[x], 1)
+		}()
+	}
+	wg.Wait()
+	for i, c := range seen {
+		if c != 1 {
+			t.Errorf("argument %d read %d times; expected once", i, c)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Package srcmap implements source maps from decompiled Go source code to the
+// LLVM IR from which it was generated.
+//
+// A source map of the Go source file "foo.go" is stored as JSON in "foo.go.map",
+// and maps the line and column ranges of Go statements to the function, basic
+// block and instruction of their originating LLVM IR.
+//
+// While the Go source code is rewritten (e.g. by go-post), the origins of
+// statements are tracked by marker comments, which are retained together with
+// other comments of the statements. Markers are inserted into the Go source code
+// by Insert, and removed by Extract, which recovers the updated source map.
+//
+//    //srcmap:3
+//    _3 = _1 + _2
+package srcmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Ext is the file extension of source maps, appended to the path of the Go
+// source file.
+const Ext = ".map"
+
+// A Map is a source map from a Go source file to LLVM IR.
+type Map struct {
+	// Path of the LLVM IR assembly file (e.g. "foo.ll").
+	Source string `json:"source"`
+	// Mappings from Go statements to LLVM IR, in order of occurrence in the Go
+	// source file.
+	Mappings []Mapping `json:"mappings"`
+}
+
+// A Mapping maps the range of a Go statement to its originating LLVM IR.
+type Mapping struct {
+	// Start position of the Go statement.
+	Start Position `json:"start"`
+	// End position of the Go statement (exclusive).
+	End Position `json:"end"`
+	// Originating LLVM IR.
+	Origin
+}
+
+// A Position is a position in a Go source file.
+type Position struct {
+	// Line number, starting at 1.
+	Line int `json:"line"`
+	// Column number in bytes, starting at 1.
+	Column int `json:"column"`
+}
+
+// An Origin specifies the LLVM IR instruction or terminator from which a Go
+// statement originates.
+type Origin struct {
+	// Function name (e.g. "@main").
+	Func string `json:"func"`
+	// Basic block label (e.g. "%entry").
+	Block string `json:"block"`
+	// Index of the instruction in the basic block; the index of the terminator
+	// is the number of instructions of the basic block.
+	Index int `json:"index"`
+	// LLVM IR assembly of the instruction (e.g. "%3 = add i32 %1, %2").
+	Inst string `json:"inst"`
+}
+
+// Load loads the source map of the given Go source file. A nil source map is
+// returned if the Go source file has no source map.
+func Load(goPath string) (*Map, error) {
+	path := goPath + Ext
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	m := &Map{}
+	if err := json.Unmarshal(buf, m); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse source map %q", path)
+	}
+	return m, nil
+}
+
+// Store stores the source map of the given Go source file.
+func (m *Map) Store(goPath string) error {
+	buf, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	buf = append(buf, '\n')
+	if err := ioutil.WriteFile(goPath+Ext, buf, 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// markerPrefix is the prefix of the text of marker comments.
+const markerPrefix = "srcmap:"
+
+// Marker returns the text of the marker comment of the i:th origin, excluding
+// the comment delimiter.
+func Marker(i int) string {
+	return markerPrefix + strconv.Itoa(i)
+}
+
+// parseMarker returns the origin index of the given comment, if it is a marker
+// comment. The boolean return value indicates success.
+func parseMarker(c *ast.Comment) (int, bool) {
+	text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+	if !strings.HasPrefix(text, markerPrefix) {
+		return 0, false
+	}
+	i, err := strconv.Atoi(text[len(markerPrefix):])
+	if err != nil {
+		return 0, false
+	}
+	return i, true
+}
+
+// Insert inserts marker comments of the mappings of the source map into the
+// given Go source code, and returns the marked Go source code and the origins
+// of the markers. The marker of each mapping is inserted on the line preceding
+// its start position.
+func Insert(src []byte, m *Map) ([]byte, []Origin) {
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	// Map from line index to markers.
+	markers := make(map[int][]string)
+	var origins []Origin
+	for _, mapping := range m.Mappings {
+		line := mapping.Start.Line - 1
+		if line < 0 || line >= len(lines) {
+			continue
+		}
+		markers[line] = append(markers[line], Marker(len(origins)))
+		origins = append(origins, mapping.Origin)
+	}
+	out := &bytes.Buffer{}
+	for i, line := range lines {
+		// Indent the markers as the line they precede.
+		indent := line[:len(line)-len(bytes.TrimLeft(line, "\t"))]
+		for _, marker := range markers[i] {
+			out.Write(indent)
+			fmt.Fprintf(out, "//%s\n", marker)
+		}
+		out.Write(line)
+	}
+	return out.Bytes(), origins
+}
+
+// Extract removes the marker comments from the given Go source code, and
+// returns the unmarked Go source code and the mappings of the statements
+// preceded by marker comments to the specified origins. A statement preceded
+// by several markers (e.g. of statements removed while rewriting the Go source
+// code) is mapped to each of their origins.
+func Extract(src []byte, origins []Origin) ([]byte, []Mapping, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to parse Go source code")
+	}
+	tf := fset.File(f.Pos())
+	// Locate marker comments.
+	var markers []*ast.Comment
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if _, ok := parseMarker(c); ok {
+				markers = append(markers, c)
+			}
+		}
+	}
+	// Remove marker comments, and record removed lines.
+	out := &bytes.Buffer{}
+	var removedLines []int
+	prev := 0
+	for _, c := range markers {
+		start, end := tf.Offset(c.Pos()), tf.Offset(c.End())
+		line := tf.Line(c.Pos())
+		lineStart := tf.Offset(tf.LineStart(line))
+		lineEnd := end
+		if i := bytes.IndexByte(src[end:], '\n'); i != -1 {
+			lineEnd = end + i + 1
+		}
+		before := bytes.TrimRight(src[lineStart:start], " \t")
+		after := bytes.TrimSpace(src[end:lineEnd])
+		if len(before) == 0 && len(after) == 0 {
+			// Remove line of marker comment.
+			out.Write(src[prev:lineStart])
+			prev = lineEnd
+			removedLines = append(removedLines, line)
+			continue
+		}
+		// Remove marker comment at end of line.
+		out.Write(src[prev : lineStart+len(before)])
+		prev = end
+	}
+	out.Write(src[prev:])
+	// position returns the position in the unmarked Go source code of the given
+	// position of the marked Go source code.
+	position := func(pos token.Pos) Position {
+		p := tf.Position(pos)
+		n := sort.SearchInts(removedLines, p.Line)
+		return Position{Line: p.Line - n, Column: p.Column}
+	}
+	// Map statements to the origins of their markers.
+	type marked struct {
+		mapping Mapping
+		// Origin index of marker.
+		i int
+	}
+	var ms []marked
+	cmap := ast.NewCommentMap(fset, f, f.Comments)
+	for node, cgs := range cmap {
+		stmt, ok := node.(ast.Stmt)
+		if !ok {
+			continue
+		}
+		if _, ok := stmt.(*ast.BlockStmt); ok {
+			continue
+		}
+		for _, cg := range cgs {
+			for _, c := range cg.List {
+				i, ok := parseMarker(c)
+				if !ok || i < 0 || i >= len(origins) {
+					continue
+				}
+				mapping := Mapping{
+					Start:  position(stmt.Pos()),
+					End:    position(stmt.End()),
+					Origin: origins[i],
+				}
+				ms = append(ms, marked{mapping: mapping, i: i})
+			}
+		}
+	}
+	sort.Slice(ms, func(i, j int) bool {
+		a, b := ms[i].mapping, ms[j].mapping
+		switch {
+		case a.Start != b.Start:
+			return less(a.Start, b.Start)
+		case a.End != b.End:
+			return less(a.End, b.End)
+		}
+		return ms[i].i < ms[j].i
+	})
+	mappings := make([]Mapping, len(ms))
+	for i, m := range ms {
+		mappings[i] = m.mapping
+	}
+	return out.Bytes(), mappings, nil
+}
+
+// less reports whether the position p precedes q.
+func less(p, q Position) bool {
+	if p.Line != q.Line {
+		return p.Line < q.Line
+	}
+	return p.Column < q.Column
+}
@@ -0,0 +1,95 @@
+package srcmap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	const src = `package p
+
+func f(x int32) int32 {
+	var y int32
+	//srcmap:0
+	y = x + 1
+	// entry:
+	//srcmap:1
+	//srcmap:2
+	if y < 0 {
+		//srcmap:3
+		return 0
+	}
+	y = // srcmap:4
+		-y
+	return y
+}
+`
+	const want = `package p
+
+func f(x int32) int32 {
+	var y int32
+	y = x + 1
+	// entry:
+	if y < 0 {
+		return 0
+	}
+	y =
+		-y
+	return y
+}
+`
+	origins := []Origin{
+		{Func: "@f", Block: "%entry", Index: 0, Inst: "%y = add i32 %x, 1"},
+		{Func: "@f", Block: "%entry", Index: 1, Inst: "%c = icmp slt i32 %y, 0"},
+		{Func: "@f", Block: "%entry", Index: 2, Inst: "br i1 %c, label %neg, label %exit"},
+		{Func: "@f", Block: "%neg", Index: 0, Inst: "ret i32 0"},
+		{Func: "@f", Block: "%exit", Index: 0, Inst: "%z = sub i32 0, %y"},
+	}
+	out, mappings, err := Extract([]byte(src), origins)
+	if err != nil {
+		t.Fatalf("unable to extract source map; %v", err)
+	}
+	if string(out) != want {
+		t.Errorf("Go source code mismatch; expected\n%s\ngot\n%s", want, out)
+	}
+	golden := []Mapping{
+		{Start: Position{Line: 5, Column: 2}, End: Position{Line: 5, Column: 11}, Origin: origins[0]},
+		{Start: Position{Line: 7, Column: 2}, End: Position{Line: 9, Column: 3}, Origin: origins[1]},
+		{Start: Position{Line: 7, Column: 2}, End: Position{Line: 9, Column: 3}, Origin: origins[2]},
+		{Start: Position{Line: 8, Column: 3}, End: Position{Line: 8, Column: 11}, Origin: origins[3]},
+	}
+	if !reflect.DeepEqual(golden, mappings) {
+		t.Errorf("mappings mismatch; expected %+v, got %+v", golden, mappings)
+	}
+}
+
+func TestInsert(t *testing.T) {
+	const src = `package p
+
+func f(x int32) int32 {
+	if x < 0 {
+		return 0
+	}
+	return x
+}
+`
+	m := &Map{
+		Mappings: []Mapping{
+			{Start: Position{Line: 4, Column: 2}, End: Position{Line: 6, Column: 3}, Origin: Origin{Func: "@f", Block: "%entry", Index: 1}},
+			{Start: Position{Line: 5, Column: 3}, End: Position{Line: 5, Column: 11}, Origin: Origin{Func: "@f", Block: "%neg", Index: 0}},
+			{Start: Position{Line: 7, Column: 2}, End: Position{Line: 7, Column: 10}, Origin: Origin{Func: "@f", Block: "%exit", Index: 0}},
+		},
+	}
+	marked, origins := Insert([]byte(src), m)
+	// Markers are removed without changes to the mappings.
+	out, mappings, err := Extract(marked, origins)
+	if err != nil {
+		t.Fatalf("unable to extract source map; %v", err)
+	}
+	if string(out) != src {
+		t.Errorf("Go source code mismatch; expected\n%s\ngot\n%s", src, out)
+	}
+	if !reflect.DeepEqual(m.Mappings, mappings) {
+		t.Errorf("mappings mismatch; expected %+v, got %+v", m.Mappings, mappings)
+	}
+}